@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rancher/aks-operator/pkg/aks"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// matchContainerRegistryID matches the resource ID of a container registry
+var matchContainerRegistryID = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.ContainerRegistry/registries/[^/]+$`)
+
+// validateAttachACRs rejects malformed and duplicate container registry IDs
+func validateAttachACRs(spec *aksv1.AKSClusterConfigSpec) []error {
+	var errs []error
+	seen := map[string]bool{}
+	for _, id := range spec.AttachACRs {
+		if !matchContainerRegistryID.MatchString(id) {
+			errs = append(errs, fmt.Errorf("attachAcrs for cluster [%s] config has invalid entry [%s], must be a container registry resource ID",
+				spec.ClusterName, id))
+		}
+		if seen[strings.ToLower(id)] {
+			errs = append(errs, fmt.Errorf("attachAcrs for cluster [%s] config lists container registry [%s] more than once", spec.ClusterName, id))
+		}
+		seen[strings.ToLower(id)] = true
+	}
+	return errs
+}
+
+// syncAttachedACRs grants the kubelet identity the AcrPull role on the container registries of the spec and revokes
+// it on the registries removed from the spec. The registries attached by the operator are recorded on status, so each
+// registry is only attached once and only registries attached by the operator are detached.
+func (h *Handler) syncAttachedACRs(ctx context.Context, credentials *aks.Credentials, config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
+	attach := missingResourceIDs(config.Spec.AttachACRs, config.Status.AttachedACRs)
+	detach := missingResourceIDs(config.Status.AttachedACRs, config.Spec.AttachACRs)
+	if len(attach) == 0 && len(detach) == 0 {
+		return config, nil
+	}
+
+	principalID := config.Status.KubeletIdentityObjectID
+	if principalID == "" {
+		return config, fmt.Errorf("attachAcrs for cluster [%s] requires a cluster with a managed kubelet identity", config.Spec.ClusterName)
+	}
+	roleAssignmentsClient, err := aks.NewRoleAssignmentsClient(credentials)
+	if err != nil {
+		return config, err
+	}
+
+	for _, id := range detach {
+		logrus.Infof("Detaching container registry [%s] from cluster [%s]", id, config.Spec.ClusterName)
+		if err := aks.DetachACR(ctx, roleAssignmentsClient, id, principalID); err != nil {
+			return config, fmt.Errorf("error detaching container registry [%s] from cluster [%s]: %w", id, config.Spec.ClusterName, err)
+		}
+	}
+	for _, id := range attach {
+		logrus.Infof("Attaching container registry [%s] to cluster [%s]", id, config.Spec.ClusterName)
+		if err := aks.AttachACR(ctx, roleAssignmentsClient, id, principalID); err != nil {
+			return config, fmt.Errorf("error attaching container registry [%s] to cluster [%s]: %w", id, config.Spec.ClusterName, err)
+		}
+	}
+
+	config = config.DeepCopy()
+	config.Status.AttachedACRs = append([]string(nil), config.Spec.AttachACRs...)
+	return h.updateStatus(config)
+}
+
+// missingResourceIDs returns the resource IDs of ids which are not in otherIDs, ignoring case
+func missingResourceIDs(ids, otherIDs []string) []string {
+	others := map[string]bool{}
+	for _, id := range otherIDs {
+		others[strings.ToLower(id)] = true
+	}
+	var missing []string
+	for _, id := range ids {
+		if !others[strings.ToLower(id)] {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
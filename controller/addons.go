@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/rancher/aks-operator/pkg/aks"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// addonCheck compares an addon of the spec with the upstream cluster
+type addonCheck struct {
+	// name is the field of the addon in the spec
+	name string
+	// diff describes how the addon of spec differs from the upstream addon, it returns an empty string if the addon is
+	// not set in spec or does not differ
+	diff func(spec, upstreamSpec *aksv1.AKSClusterConfigSpec) string
+	// apply copies the settings of the addon from spec to the spec of a cluster update
+	apply func(update, spec *aksv1.AKSClusterConfigSpec)
+}
+
+// addonChecks are the addons compared with the upstream cluster, addons changed in the spec are sent with an update of
+// the cluster
+var addonChecks = []addonCheck{
+	{
+		name: "httpApplicationRouting",
+		diff: func(spec, upstreamSpec *aksv1.AKSClusterConfigSpec) string {
+			// a warning is recorded instead where the addon is not supported
+			if spec.HTTPApplicationRouting == nil || !aks.HasHTTPApplicationRoutingSupport(spec) {
+				return ""
+			}
+			enabled, upstreamEnabled := to.Bool(spec.HTTPApplicationRouting), to.Bool(upstreamSpec.HTTPApplicationRouting)
+			return toggleDiff(enabled != upstreamEnabled, enabled, upstreamEnabled)
+		},
+		apply: func(update, spec *aksv1.AKSClusterConfigSpec) {
+			update.HTTPApplicationRouting = spec.HTTPApplicationRouting
+		},
+	},
+	{
+		name: "monitoring",
+		diff: func(spec, upstreamSpec *aksv1.AKSClusterConfigSpec) string {
+			if spec.Monitoring == nil {
+				return ""
+			}
+			enabled, upstreamEnabled := to.Bool(spec.Monitoring), to.Bool(upstreamSpec.Monitoring)
+			if enabled && upstreamEnabled && monitoringWorkspaceChanged(spec, upstreamSpec) {
+				return fmt.Sprintf("moved to Log Analytics workspace [%s] in resource group [%s]",
+					to.String(spec.LogAnalyticsWorkspaceName), monitoringWorkspaceGroup(spec))
+			}
+			return toggleDiff(enabled != upstreamEnabled, enabled, upstreamEnabled)
+		},
+		apply: func(update, spec *aksv1.AKSClusterConfigSpec) {
+			update.Monitoring = spec.Monitoring
+			update.LogAnalyticsWorkspaceName = spec.LogAnalyticsWorkspaceName
+			update.LogAnalyticsWorkspaceGroup = spec.LogAnalyticsWorkspaceGroup
+		},
+	},
+	{
+		name: "keyVaultSecretsProvider",
+		diff: func(spec, upstreamSpec *aksv1.AKSClusterConfigSpec) string {
+			if spec.KeyVaultSecretsProvider == nil {
+				return ""
+			}
+			return toggleDiff(keyVaultSecretsProviderChanged(spec.KeyVaultSecretsProvider, upstreamSpec.KeyVaultSecretsProvider),
+				spec.KeyVaultSecretsProvider.Enabled, upstreamSpec.KeyVaultSecretsProvider.Enabled)
+		},
+		apply: func(update, spec *aksv1.AKSClusterConfigSpec) {
+			update.KeyVaultSecretsProvider = spec.KeyVaultSecretsProvider
+		},
+	},
+	{
+		name: "aciConnector",
+		diff: func(spec, upstreamSpec *aksv1.AKSClusterConfigSpec) string {
+			if spec.ACIConnector == nil {
+				return ""
+			}
+			return toggleDiff(aciConnectorChanged(spec.ACIConnector, upstreamSpec.ACIConnector),
+				spec.ACIConnector.Enabled, upstreamSpec.ACIConnector.Enabled)
+		},
+		apply: func(update, spec *aksv1.AKSClusterConfigSpec) {
+			update.ACIConnector = spec.ACIConnector
+		},
+	},
+	{
+		name: "ingressApplicationGateway",
+		diff: func(spec, upstreamSpec *aksv1.AKSClusterConfigSpec) string {
+			if spec.IngressApplicationGateway == nil {
+				return ""
+			}
+			return toggleDiff(ingressApplicationGatewayChanged(spec.IngressApplicationGateway, upstreamSpec.IngressApplicationGateway),
+				spec.IngressApplicationGateway.Enabled, upstreamSpec.IngressApplicationGateway.Enabled)
+		},
+		apply: func(update, spec *aksv1.AKSClusterConfigSpec) {
+			update.IngressApplicationGateway = spec.IngressApplicationGateway
+		},
+	},
+}
+
+// addonChanges returns the addons of spec which differ from the upstream cluster, described as e.g.
+// "httpApplicationRouting: enabled". The changed addons are copied to update.
+func addonChanges(spec, upstreamSpec, update *aksv1.AKSClusterConfigSpec) []string {
+	var changes []string
+	for _, check := range addonChecks {
+		if diff := check.diff(spec, upstreamSpec); diff != "" {
+			changes = append(changes, check.name+": "+diff)
+			check.apply(update, spec)
+		}
+	}
+	return changes
+}
+
+// toggleDiff describes the change of an addon which is enabled or disabled, or whose settings changed otherwise
+func toggleDiff(changed, enabled, upstreamEnabled bool) string {
+	switch {
+	case !changed:
+		return ""
+	case enabled && !upstreamEnabled:
+		return "enabled"
+	case !enabled && upstreamEnabled:
+		return "disabled"
+	default:
+		return "updated"
+	}
+}
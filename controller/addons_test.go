@@ -0,0 +1,228 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/to"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// testUpstreamCluster returns the ARM body of the active test cluster on Kubernetes 1.22.6 with the node pool "pool",
+// extended with properties
+func testUpstreamCluster(properties map[string]interface{}) map[string]interface{} {
+	clusterProperties := map[string]interface{}{
+		"provisioningState": "Succeeded",
+		"kubernetesVersion": "1.22.6",
+		"dnsPrefix":         "cluster-dns",
+		"agentPoolProfiles": []interface{}{
+			map[string]interface{}{
+				"name":                "pool",
+				"count":               1,
+				"maxPods":             110,
+				"vmSize":              "Standard_DS2_v2",
+				"osDiskSizeGB":        128,
+				"osDiskType":          "Managed",
+				"mode":                "System",
+				"osType":              "Linux",
+				"orchestratorVersion": "1.22.6",
+			},
+		},
+	}
+	for key, value := range properties {
+		clusterProperties[key] = value
+	}
+	return map[string]interface{}{"name": "cluster", "location": "eastus", "properties": clusterProperties}
+}
+
+// newUpstreamTestConfig returns a config of th which matches testUpstreamCluster
+func (th *testHandler) newUpstreamTestConfig() *aksv1.AKSClusterConfig {
+	config := th.newTestConfig()
+	np := validNodePool("pool")
+	np.OrchestratorVersion = to.StringPtr("1.22.6")
+	config.Spec.KubernetesVersion = to.StringPtr("1.22.6")
+	config.Spec.DNSPrefix = to.StringPtr("cluster-dns")
+	config.Spec.NodePools = []aksv1.AKSNodePool{np}
+	return config
+}
+
+// updateTestCluster reconciles config against the upstream cluster, and returns the updated config and the body of the
+// cluster update sent to Azure, nil if none was sent
+func (th *testHandler) updateTestCluster(t *testing.T, config *aksv1.AKSClusterConfig, upstream map[string]interface{}) (*aksv1.AKSClusterConfig, map[string]interface{}) {
+	t.Helper()
+	config.Status.Phase = aksConfigActivePhase
+	th.client.configs[config.Namespace+"/"+config.Name] = config.DeepCopy()
+	th.azure.on(http.MethodGet, testClusterPath, http.StatusOK, upstream)
+	th.azure.on(http.MethodPut, testClusterPath, http.StatusOK, upstream)
+	th.azure.on(http.MethodHead, armPath("/resourcegroups/rg"), http.StatusNoContent, nil)
+
+	upstreamSpec, err := BuildUpstreamClusterState(context.Background(), th.secretsCache, &config.Spec)
+	if err != nil {
+		t.Fatalf("unexpected error building the upstream state: %v", err)
+	}
+	updated, err := th.updateUpstreamClusterState(context.Background(), th.secretsCache, config, upstreamSpec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sent, _ := th.azure.sent(http.MethodPut, testClusterPath).(map[string]interface{})
+	return updated, sent
+}
+
+func TestAddonChanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     aksv1.AKSClusterConfigSpec
+		upstream aksv1.AKSClusterConfigSpec
+		want     []string
+		// wantUpdate is the update holding the changed addons
+		wantUpdate aksv1.AKSClusterConfigSpec
+	}{
+		{
+			name:       "HTTP application routing enabled",
+			spec:       aksv1.AKSClusterConfigSpec{HTTPApplicationRouting: to.BoolPtr(true)},
+			want:       []string{"httpApplicationRouting: enabled"},
+			wantUpdate: aksv1.AKSClusterConfigSpec{HTTPApplicationRouting: to.BoolPtr(true)},
+		},
+		{
+			name:       "HTTP application routing disabled",
+			spec:       aksv1.AKSClusterConfigSpec{HTTPApplicationRouting: to.BoolPtr(false)},
+			upstream:   aksv1.AKSClusterConfigSpec{HTTPApplicationRouting: to.BoolPtr(true)},
+			want:       []string{"httpApplicationRouting: disabled"},
+			wantUpdate: aksv1.AKSClusterConfigSpec{HTTPApplicationRouting: to.BoolPtr(false)},
+		},
+		{
+			name:     "HTTP application routing unchanged",
+			spec:     aksv1.AKSClusterConfigSpec{HTTPApplicationRouting: to.BoolPtr(true)},
+			upstream: aksv1.AKSClusterConfigSpec{HTTPApplicationRouting: to.BoolPtr(true)},
+		},
+		{
+			name: "HTTP application routing not supported",
+			spec: aksv1.AKSClusterConfigSpec{ResourceLocation: "chinaeast2", HTTPApplicationRouting: to.BoolPtr(true)},
+		},
+		{
+			name:     "addons not set in the spec",
+			upstream: aksv1.AKSClusterConfigSpec{HTTPApplicationRouting: to.BoolPtr(true), Monitoring: to.BoolPtr(true)},
+		},
+		{
+			name:       "monitoring enabled",
+			spec:       aksv1.AKSClusterConfigSpec{Monitoring: to.BoolPtr(true), LogAnalyticsWorkspaceName: to.StringPtr("workspace")},
+			want:       []string{"monitoring: enabled"},
+			wantUpdate: aksv1.AKSClusterConfigSpec{Monitoring: to.BoolPtr(true), LogAnalyticsWorkspaceName: to.StringPtr("workspace")},
+		},
+		{
+			name: "monitoring moved to another workspace",
+			spec: aksv1.AKSClusterConfigSpec{
+				ResourceGroup:              "rg",
+				Monitoring:                 to.BoolPtr(true),
+				LogAnalyticsWorkspaceGroup: to.StringPtr("logs"),
+				LogAnalyticsWorkspaceName:  to.StringPtr("other"),
+			},
+			upstream: aksv1.AKSClusterConfigSpec{
+				Monitoring:                 to.BoolPtr(true),
+				LogAnalyticsWorkspaceGroup: to.StringPtr("logs"),
+				LogAnalyticsWorkspaceName:  to.StringPtr("workspace"),
+			},
+			want: []string{"monitoring: moved to Log Analytics workspace [other] in resource group [logs]"},
+			wantUpdate: aksv1.AKSClusterConfigSpec{
+				Monitoring:                 to.BoolPtr(true),
+				LogAnalyticsWorkspaceGroup: to.StringPtr("logs"),
+				LogAnalyticsWorkspaceName:  to.StringPtr("other"),
+			},
+		},
+		{
+			name:       "several addons changed",
+			spec:       aksv1.AKSClusterConfigSpec{HTTPApplicationRouting: to.BoolPtr(true), Monitoring: to.BoolPtr(false)},
+			upstream:   aksv1.AKSClusterConfigSpec{Monitoring: to.BoolPtr(true)},
+			want:       []string{"httpApplicationRouting: enabled", "monitoring: disabled"},
+			wantUpdate: aksv1.AKSClusterConfigSpec{HTTPApplicationRouting: to.BoolPtr(true), Monitoring: to.BoolPtr(false)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update := &aksv1.AKSClusterConfigSpec{}
+			got := addonChanges(&tt.spec, &tt.upstream, update)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected addon changes %q, got %q", tt.want, got)
+			}
+			if !reflect.DeepEqual(*update, tt.wantUpdate) {
+				t.Errorf("expected update %+v, got %+v", tt.wantUpdate, *update)
+			}
+		})
+	}
+}
+
+func TestUpdateUpstreamClusterStateHTTPApplicationRouting(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  *bool
+		upstream bool
+		// wantEnabled is the addon state sent to Azure, nil if the cluster is not updated
+		wantEnabled *bool
+	}{
+		{
+			name:        "enabled",
+			enabled:     to.BoolPtr(true),
+			wantEnabled: to.BoolPtr(true),
+		},
+		{
+			name:        "disabled",
+			enabled:     to.BoolPtr(false),
+			upstream:    true,
+			wantEnabled: to.BoolPtr(false),
+		},
+		{
+			name:     "unchanged",
+			enabled:  to.BoolPtr(true),
+			upstream: true,
+		},
+		{
+			name:     "not set in the spec",
+			upstream: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+			config := th.newUpstreamTestConfig()
+			config.Spec.HTTPApplicationRouting = tt.enabled
+			upstream := testUpstreamCluster(map[string]interface{}{
+				"addonProfiles": map[string]interface{}{
+					"httpApplicationRouting": map[string]interface{}{"enabled": tt.upstream},
+				},
+			})
+
+			updated, sent := th.updateTestCluster(t, config, upstream)
+			if tt.wantEnabled == nil {
+				if sent != nil {
+					t.Errorf("expected no cluster update, got %v", sent)
+				}
+				return
+			}
+			if sent == nil {
+				t.Fatalf("expected the cluster to be updated, got requests %v", th.azure.recorded())
+			}
+			enabled := lookupJSON(sent, "properties", "addonProfiles", "httpApplicationRouting", "enabled")
+			if enabled != *tt.wantEnabled {
+				t.Errorf("expected httpApplicationRouting enabled %v to be sent, got %v", *tt.wantEnabled, enabled)
+			}
+			if want := toggleDiff(true, *tt.wantEnabled, tt.upstream); !reflect.DeepEqual(updated.Status.AddonChanges, []string{"httpApplicationRouting: " + want}) {
+				t.Errorf("expected the addon change to be written to the status, got %q", updated.Status.AddonChanges)
+			}
+		})
+	}
+}
+
+// lookupJSON returns the value at the path of keys in a decoded JSON object, nil if there is none
+func lookupJSON(value interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
@@ -4,23 +4,27 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"net"
 	"reflect"
 	"regexp"
+	"strings"
 	"time"
 
-	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2020-11-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/rancher/aks-operator/pkg/aks"
 	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
 	v10 "github.com/rancher/aks-operator/pkg/generated/controllers/aks.cattle.io/v1"
 	"github.com/rancher/aks-operator/pkg/utils"
 	wranglerv1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/pkg/generic"
+	"github.com/rancher/wrangler/pkg/merr"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	v15 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
 )
 
 const (
@@ -32,8 +36,29 @@ const (
 	aksConfigActivePhase     = "active"
 	aksConfigUpdatingPhase   = "updating"
 	aksConfigImportingPhase  = "importing"
-	poolNameMaxLength        = 6
-	wait                     = 30
+	aksConfigDeletingPhase   = "deleting"
+	// Azure limits the names of Linux node pools to 12 characters and those of Windows node pools to 6
+	linuxPoolNameMaxLength   = 12
+	windowsPoolNameMaxLength = 6
+	// lastSyncTimeInterval is the minimum interval between updates of status.lastSyncTime. Every status update
+	// triggers another reconcile, so the sync time cannot be written on each pass.
+	lastSyncTimeInterval = 5 * time.Minute
+	// clusterNameIndex indexes AKSClusterConfigs by spec.clusterName
+	clusterNameIndex = "aks.cattle.io/cluster-name"
+)
+
+// Event reasons of the cluster lifecycle, node pool upgrades, recreations and remediations have their own reasons
+const (
+	eventReasonClusterCreate  = "ClusterCreate"
+	eventReasonClusterCreated = "ClusterCreated"
+	eventReasonClusterDelete  = "ClusterDelete"
+	eventReasonClusterDeleted = "ClusterDeleted"
+	eventReasonNodePoolAdd    = "NodePoolAdd"
+	eventReasonNodePoolRemove = "NodePoolRemove"
+	eventReasonTagsUpdate     = "TagsUpdate"
+	// resources created by the operator for the cluster, deleted on request once the cluster is removed
+	eventReasonResourceGroupDeleted = "ResourceGroupDeleted"
+	eventReasonWorkspaceDeleted     = "WorkspaceDeleted"
 )
 
 // Cluster Status
@@ -70,35 +95,108 @@ const (
 
 	// NodePoolUpgrading The Upgrading state indicates that cluster was upgraded
 	NodePoolUpgrading = "Upgrading"
+
+	// NodePoolSucceeded The Succeeded state indicates that the node pool is ready to be updated
+	NodePoolSucceeded = "Succeeded"
+
+	// NodePoolFailed The Failed state indicates that the last operation on the node pool failed
+	NodePoolFailed = "Failed"
+
+	// NodePoolCanceled The Canceled state indicates that the last operation on the node pool was canceled
+	NodePoolCanceled = "Canceled"
 )
 
-var matchWorkspaceGroup = regexp.MustCompile("/resourcegroups/(.+?)/")
-var matchWorkspaceName = regexp.MustCompile("/workspaces/(.+?)")
+// ARM resource IDs are returned with varying casing, e.g. "resourceGroups" or "resourcegroups"
+var matchWorkspaceGroup = regexp.MustCompile("(?i)/resourcegroups/([^/]+)")
+var matchWorkspaceName = regexp.MustCompile("(?i)/workspaces/([^/]+)")
+
+// node pool names must start with a lowercase letter and only contain lowercase letters and numbers
+var matchPoolName = regexp.MustCompile("^[a-z][a-z0-9]*$")
+
+// matchUserAssignedIdentityID matches the resource ID of a user-assigned managed identity
+var matchUserAssignedIdentityID = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.ManagedIdentity/userAssignedIdentities/[^/]+$`)
+
+// matchPrivateDNSZoneID matches the resource ID of a private DNS zone
+var matchPrivateDNSZoneID = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.Network/privateDnsZones/[^/]+$`)
+
+// matchApplicationGatewayID matches the resource ID of an application gateway
+var matchApplicationGatewayID = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.Network/applicationGateways/[^/]+$`)
 
 type Handler struct {
+	// ctx is the context of the controller, it is cancelled when the operator shuts down
+	ctx             context.Context
 	aksCC           v10.AKSClusterConfigClient
+	aksCache        v10.AKSClusterConfigCache
+	aksCacheSynced  func() bool
 	aksEnqueueAfter func(namespace, name string, duration time.Duration)
 	aksEnqueue      func(namespace, name string)
 	secrets         wranglerv1.SecretClient
 	secretsCache    wranglerv1.SecretCache
+	secretsEnqueue  func(namespace, name string)
+	// secretsEnqueueAfter requeues a secret, to release it once the configs being deleted no longer reference it
+	secretsEnqueueAfter func(namespace, name string, duration time.Duration)
+	configMaps          wranglerv1.ConfigMapClient
+	egressIP            *egressIPResolver
+	recorder            record.EventRecorder
+	pollIntervals       pollIntervals
+	driftSyncPeriod     time.Duration
+	createTimeout       time.Duration
+	requeues            *Requeues
 }
 
 func Register(
 	ctx context.Context,
 	secrets wranglerv1.SecretController,
-	aks v10.AKSClusterConfigController) {
+	configMaps wranglerv1.ConfigMapController,
+	aks v10.AKSClusterConfigController,
+	recorder record.EventRecorder,
+	options Options) {
 
 	controller := &Handler{
-		aksCC:           aks,
-		aksEnqueue:      aks.Enqueue,
-		aksEnqueueAfter: aks.EnqueueAfter,
-		secretsCache:    secrets.Cache(),
-		secrets:         secrets,
-	}
+		ctx:                 ctx,
+		aksCC:               aks,
+		aksCache:            aks.Cache(),
+		aksCacheSynced:      aks.Informer().HasSynced,
+		aksEnqueue:          aks.Enqueue,
+		secretsCache:        secrets.Cache(),
+		secrets:             secrets,
+		secretsEnqueue:      secrets.Enqueue,
+		secretsEnqueueAfter: secrets.EnqueueAfter,
+		configMaps:          configMaps,
+		egressIP:            &egressIPResolver{},
+		recorder:            recorder,
+		pollIntervals:       newPollIntervals(options.WaitInterval),
+		driftSyncPeriod:     options.DriftSyncPeriod,
+		createTimeout:       options.CreateTimeout,
+		requeues:            options.Requeues,
+	}
+	if controller.createTimeout <= 0 {
+		controller.createTimeout = defaultCreateTimeout
+	}
+	controller.aksEnqueueAfter = func(namespace, name string, duration time.Duration) {
+		controller.requeues.schedule(namespace, name, duration, 0)
+		aks.EnqueueAfter(namespace, name, duration)
+	}
+
+	aks.Cache().AddIndexer(clusterNameIndex, func(obj *aksv1.AKSClusterConfig) ([]string, error) {
+		return []string{obj.Spec.ClusterName}, nil
+	})
+	aks.Cache().AddIndexer(credentialSecretIndex, func(obj *aksv1.AKSClusterConfig) ([]string, error) {
+		if obj.Spec.AzureCredentialSecret == "" {
+			return nil, nil
+		}
+		return []string{credentialSecretKey(qualifiedCredentialSecret(obj))}, nil
+	})
 
 	// Register handlers
-	aks.OnChange(ctx, controllerName, controller.recordError(controller.OnAksConfigChanged))
+	aks.OnChange(ctx, controllerName, controller.recordError(recordReconcileMetrics(controller.OnAksConfigChanged)))
 	aks.OnRemove(ctx, controllerRemoveName, controller.OnAksConfigRemoved)
+	secrets.OnChange(ctx, secretsControllerName, controller.OnSecretChanged)
+	secrets.OnChange(ctx, secretsRotationControllerName, controller.OnCredentialSecretChanged)
+
+	if options.WebhookAddress != "" {
+		controller.serveWebhook(ctx, options)
+	}
 }
 
 func (h *Handler) OnAksConfigChanged(key string, config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
@@ -106,6 +204,15 @@ func (h *Handler) OnAksConfigChanged(key string, config *aksv1.AKSClusterConfig)
 		return nil, nil
 	}
 
+	config, err := h.protectCredentialSecret(config)
+	if err != nil {
+		return config, err
+	}
+
+	if exportRequested(config) {
+		return h.exportSpec(config)
+	}
+
 	switch config.Status.Phase {
 	case aksConfigImportingPhase:
 		return h.importCluster(config)
@@ -115,28 +222,40 @@ func (h *Handler) OnAksConfigChanged(key string, config *aksv1.AKSClusterConfig)
 		return h.waitForCluster(config)
 	case aksConfigActivePhase, aksConfigUpdatingPhase:
 		return h.checkAndUpdate(config)
+	case aksConfigMissingPhase:
+		return h.clusterMissing(config)
 	default:
 		return config, fmt.Errorf("invalid phase: %v", config.Status.Phase)
 	}
 }
 
 func (h *Handler) OnAksConfigRemoved(key string, config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
+	config, err := h.removeCluster(config)
+	if err == nil {
+		h.releaseCredentialSecret(config)
+		h.requeues.remove(config.Namespace, config.Name)
+	}
+	return config, err
+}
+
+func (h *Handler) removeCluster(config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
 	if config.Spec.Imported {
 		logrus.Infof("Cluster [%s] is imported, will not delete AKS cluster", config.Spec.ClusterName)
 		return config, nil
 	}
-	if config.Status.Phase == aksConfigNotCreatedPhase {
-		// The most likely context here is that the cluster already existed in AKS, so we shouldn't delete it
+	if config.Status.Phase == aksConfigNotCreatedPhase && !config.Status.ResourceGroupCreated {
+		// The most likely context here is that the cluster already existed in AKS, so we shouldn't delete it. A cluster
+		// in a resource group created by the operator cannot have existed before.
 		logrus.Warnf("Cluster [%s] never advanced to creating status, will not delete AKS cluster", config.Name)
 		return config, nil
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(h.ctx)
 	defer cancel()
 
 	logrus.Infof("Removing cluster [%s]", config.Spec.ClusterName)
 
-	credentials, err := aks.GetSecrets(h.secretsCache, &config.Spec)
+	credentials, err := h.getCredentials(config)
 	if err != nil {
 		return config, err
 	}
@@ -146,70 +265,152 @@ func (h *Handler) OnAksConfigRemoved(key string, config *aksv1.AKSClusterConfig)
 		return config, err
 	}
 
-	if aks.ExistsCluster(ctx, resourceClusterClient, &config.Spec) {
-		if err = aks.RemoveCluster(ctx, resourceClusterClient, &config.Spec); err != nil {
-			return config, fmt.Errorf("error removing cluster [%s] message %v", config.Spec.ClusterName, err)
-		}
+	result, err := resourceClusterClient.Get(ctx, config.Spec.ResourceGroup, config.Spec.ClusterName)
+	if err != nil && !aks.IsNotFound(err) {
+		return config, fmt.Errorf("error checking if cluster [%s] exists: %w", config.Spec.ClusterName, err)
+	}
+	if err == nil {
+		return h.waitForClusterRemoval(ctx, resourceClusterClient, config, &result)
 	}
 
 	logrus.Infof("Cluster [%s] was removed successfully", config.Spec.ClusterName)
-	logrus.Infof("Resource group [%s] for cluster [%s] still exists, please remove it if needed", config.Spec.ResourceGroup, config.Spec.ClusterName)
+	h.recorder.Eventf(config, v1.EventTypeNormal, eventReasonClusterDeleted, "Cluster [%s] was deleted", config.Spec.ClusterName)
 
-	return config, nil
+	return h.removeCreatedResources(ctx, credentials, config)
 }
 
-// recordError writes the error return by onChange to the failureMessage field on status. If there is no error, then
-// empty string will be written to status
+// recordError writes the error return by onChange to the failureMessage field on status, along with its failure reason
+// and ARM error code, and sets the Ready condition to False with the error as its message. The message is truncated to
+// maxFailureMessageLength, the full error is logged instead. If there is no error, then empty strings will be written
+// to status. A warning event is recorded whenever the error changes, errors repeated by requeues are not recorded
+// again. Throttled requests set throttledUntil from the Retry-After header returned by Azure, the config is not
+// reconciled until then.
 func (h *Handler) recordError(onChange func(key string, config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error)) func(key string, config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
 	return func(key string, config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
+		if config != nil && config.DeletionTimestamp == nil {
+			if wait := time.Until(config.Status.ThrottledUntil.Time); wait > 0 {
+				// no requests are sent while Azure throttles them, every status update would otherwise send more
+				h.aksEnqueueAfter(config.Namespace, config.Name, wait)
+				return config, nil
+			}
+		}
+
 		var err error
-		var message string
+		var message, reason, code string
+		var throttledUntil v15.Time
 		config, err = onChange(key, config)
 		if config == nil {
 			// AKS config is likely deleting
 			return config, err
 		}
+		if errors.IsConflict(err) {
+			// the config changed while it was reconciled, it is reconciled again without recording a failure
+			logrus.Debugf("Config [%s] changed during reconcile, retrying: %v", config.Name, err)
+			return config, err
+		}
 		if err != nil {
-			message = err.Error()
+			message = truncateFailureMessage(aks.ErrorMessage(err))
+			reason = failureReason(err)
+			code = aks.ErrorCode(err)
+			if aks.IsThrottled(err) {
+				throttledUntil = v15.NewTime(time.Now().Add(throttledRetry(aks.RetryAfter(err))))
+			}
 		}
 
-		if config.Status.FailureMessage == message {
-			return config, err
+		updated := config.DeepCopy()
+		var conditionChanged bool
+		if err != nil {
+			conditionChanged = setCondition(&updated.Status, config.Generation, conditionReady, v1.ConditionFalse, reason, message)
 		}
 
-		config = config.DeepCopy()
-		if message != "" && config.Status.Phase == aksConfigActivePhase {
-			// can assume an update is failing
-			config.Status.Phase = aksConfigUpdatingPhase
+		failureChanged := config.Status.FailureMessage != message ||
+			config.Status.FailureReason != reason ||
+			config.Status.FailureCode != code
+		if !failureChanged && !conditionChanged && config.Status.ThrottledUntil.IsZero() && throttledUntil.IsZero() {
+			return config, h.retryTransientError(config, err)
 		}
-		config.Status.FailureMessage = message
 
-		var recordErr error
-		config, recordErr = h.aksCC.UpdateStatus(config)
+		if message != "" && message != err.Error() {
+			logrus.Errorf("Cluster [%s] failed with reason [%s]: %v", config.Name, reason, err)
+		}
+		if message != "" && failureChanged {
+			h.recorder.Event(config, v1.EventTypeWarning, reason, message)
+		}
+
+		if message != "" && updated.Status.Phase == aksConfigActivePhase {
+			// can assume an update is failing
+			updated.Status.Phase = aksConfigUpdatingPhase
+		}
+		if message != "" {
+			updated.Status.LastFailureTime = v15.Now()
+		}
+		updated.Status.FailureMessage = message
+		updated.Status.FailureReason = reason
+		updated.Status.FailureCode = code
+		updated.Status.ThrottledUntil = throttledUntil
+
+		result, recordErr := h.updateStatus(updated)
+		if errors.IsConflict(recordErr) {
+			logrus.Debugf("Config [%s] changed while recording its failure message, retrying: %v", config.Name, recordErr)
+			return config, err
+		}
 		if recordErr != nil {
 			logrus.Errorf("Error recording akscc [%s] failure message: %s", config.Name, recordErr.Error())
+			return config, err
 		}
-		return config, err
+		return result, h.retryTransientError(result, err)
 	}
 }
 
+// retryTransientError schedules the retry of config after a throttled or transient Azure failure and returns nil, so
+// that the workqueue does not retry it sooner. Throttled requests are retried once Azure accepts requests again,
+// other transient failures with an exponential backoff. Any other error is returned to be retried by the workqueue.
+func (h *Handler) retryTransientError(config *aksv1.AKSClusterConfig, err error) error {
+	var wait, backoff time.Duration
+	switch {
+	case err == nil:
+		return nil
+	case aks.IsThrottled(err):
+		wait = time.Until(config.Status.ThrottledUntil.Time)
+	case failureReason(err) == failureReasonUnknown && aks.IsAzureError(err):
+		wait = errorBackoff(config)
+		backoff = wait
+	default:
+		return err
+	}
+
+	logrus.Warnf("Retrying cluster [%s] in %v: %v", config.Name, wait.Round(time.Second), err)
+	h.aksEnqueueAfter(config.Namespace, config.Name, wait)
+	h.requeues.schedule(config.Namespace, config.Name, wait, backoff)
+	return nil
+}
+
 func (h *Handler) createCluster(config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
 	if err := h.validateConfig(config); err != nil {
-		return config, err
+		return config, invalidSpecError{err}
 	}
 
 	if config.Spec.Imported {
+		config, err := h.recordImportWarnings(config)
+		if err != nil {
+			return config, err
+		}
 		config = config.DeepCopy()
 		config.Status.Phase = aksConfigImportingPhase
-		return h.aksCC.UpdateStatus(config)
+		return h.updateStatus(config)
+	}
+
+	config, err := h.recordSpecWarnings(config)
+	if err != nil {
+		return config, err
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(h.ctx)
 	defer cancel()
 
 	logrus.Infof("Creating cluster [%s]", config.Spec.ClusterName)
 
-	credentials, err := aks.GetSecrets(h.secretsCache, &config.Spec)
+	credentials, err := h.getCredentials(config)
 	if err != nil {
 		return config, err
 	}
@@ -221,34 +422,98 @@ func (h *Handler) createCluster(config *aksv1.AKSClusterConfig) (*aksv1.AKSClust
 
 	logrus.Infof("Checking if resource group [%s] exists", config.Spec.ResourceGroup)
 
-	if !aks.ExistsResourceGroup(ctx, resourceGroupsClient, config.Spec.ResourceGroup) {
+	exists, err := aks.ExistsResourceGroup(ctx, resourceGroupsClient, config.Spec.ResourceGroup)
+	if err != nil {
+		return config, fmt.Errorf("error checking if resource group [%s] exists: %w", config.Spec.ResourceGroup, err)
+	}
+	if !exists {
 		logrus.Infof("Creating resource group [%s] for cluster [%s]", config.Spec.ResourceGroup, config.Spec.ClusterName)
 		err = aks.CreateResourceGroup(ctx, resourceGroupsClient, &config.Spec)
 		if err != nil {
-			return config, fmt.Errorf("error creating resource group [%s] with message %v", config.Spec.ResourceGroup, err)
+			return config, fmt.Errorf("error creating resource group [%s] with message %w", config.Spec.ResourceGroup, err)
 		}
 		logrus.Infof("Resource group [%s] created successfully", config.Spec.ResourceGroup)
+
+		// recorded before the cluster is created, the resource group exists on the next attempt if creating fails
+		config = config.DeepCopy()
+		config.Status.ResourceGroupCreated = true
+		return h.updateStatus(config)
+	}
+
+	resourceClusterClient, err := aks.NewClusterClient(credentials)
+	if err != nil {
+		return config, err
+	}
+
+	// the cluster exists if the operator stopped before the phase was recorded after a previous create
+	existing, err := resourceClusterClient.Get(ctx, config.Spec.ResourceGroup, config.Spec.ClusterName)
+	if err != nil && !aks.IsNotFound(err) {
+		return config, fmt.Errorf("error checking if cluster [%s] exists: %w", config.Spec.ClusterName, err)
+	}
+	if err == nil {
+		if !clusterOwnedBy(&existing, config) {
+			return config, invalidSpecError{fmt.Errorf("cluster [%s] already exists in resource group [%s] and was not created by this config, import it instead",
+				config.Spec.ClusterName, config.Spec.ResourceGroup)}
+		}
+		if existing.ManagedClusterProperties == nil || to.String(existing.ProvisioningState) != ClusterStatusFailed {
+			return h.resumeCreate(config, &existing)
+		}
 	}
 
 	logrus.Infof("Creating AKS cluster [%s]", config.Spec.ClusterName)
 
-	resourceClusterClient, err := aks.NewClusterClient(credentials)
+	spec, err := h.withEgressIP(ctx, withNodePoolDefaults(&config.Spec))
+	if err != nil {
+		return config, err
+	}
+	spec.Tags = withOwnerTag(spec.Tags, config)
+
+	proxyTrustedCA, err := httpProxyTrustedCA(h.secretsCache, spec)
 	if err != nil {
 		return config, err
 	}
 
-	err = aks.CreateOrUpdateCluster(ctx, credentials, resourceClusterClient, &config.Spec)
+	workspace, err := aks.CreateOrUpdateCluster(ctx, credentials, resourceClusterClient, spec, proxyTrustedCA)
 	if err != nil {
-		return config, fmt.Errorf("error failed to create cluster: %v ", err)
+		return config, fmt.Errorf("error failed to create cluster: %w", err)
 	}
+	h.recorder.Eventf(config, v1.EventTypeNormal, eventReasonClusterCreate, "Creating cluster [%s] in resource group [%s]",
+		config.Spec.ClusterName, config.Spec.ResourceGroup)
 
 	config = config.DeepCopy()
+	if workspace != nil {
+		setLogAnalyticsWorkspaceStatus(&config.Status, workspace.ID, workspace.Created)
+	}
+	config.Status.AutoScalerProfileSettings = appliedAutoScalerProfileSettings(spec)
+	config.Status.AppliedTags = appliedTags(&config.Spec)
 	config.Status.Phase = aksConfigCreatingPhase
-	return h.aksCC.UpdateStatus(config)
+	config.Status.LastUpdateAppliedTime = v15.Now()
+	setCondition(&config.Status, config.Generation, conditionProvisioned, v1.ConditionFalse, conditionReasonCreating, "")
+	setCondition(&config.Status, config.Generation, conditionReady, v1.ConditionFalse, conditionReasonCreating, "")
+	return h.updateStatus(config)
+}
+
+// resumeCreate moves config to the creating phase without creating its cluster again, the cluster was already created
+// by config but the phase was not recorded
+func (h *Handler) resumeCreate(config *aksv1.AKSClusterConfig, cluster *containerservice.ManagedCluster) (*aksv1.AKSClusterConfig, error) {
+	var clusterState string
+	if cluster.ManagedClusterProperties != nil {
+		clusterState = to.String(cluster.ProvisioningState)
+	}
+	logrus.Infof("Cluster [%s] was already created by this config with provisioning state [%s], waiting for it", config.Spec.ClusterName, clusterState)
+
+	config = config.DeepCopy()
+	config.Status.Phase = aksConfigCreatingPhase
+	if config.Status.LastUpdateAppliedTime.IsZero() {
+		config.Status.LastUpdateAppliedTime = v15.Now()
+	}
+	setCondition(&config.Status, config.Generation, conditionProvisioned, v1.ConditionFalse, conditionReasonCreating, "")
+	setCondition(&config.Status, config.Generation, conditionReady, v1.ConditionFalse, conditionReasonCreating, "")
+	return h.updateStatus(config)
 }
 
 func (h *Handler) importCluster(config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(h.ctx)
 	defer cancel()
 
 	logrus.Infof("Importing config for cluster [%s]", config.Spec.ClusterName)
@@ -259,16 +524,40 @@ func (h *Handler) importCluster(config *aksv1.AKSClusterConfig) (*aksv1.AKSClust
 		}
 	}
 
+	credentials, err := h.getCredentials(config)
+	if err != nil {
+		return config, err
+	}
+
+	resourceClusterClient, err := aks.NewClusterClient(credentials)
+	if err != nil {
+		return config, err
+	}
+
+	result, err := resourceClusterClient.Get(ctx, config.Spec.ResourceGroup, config.Spec.ClusterName)
+	if err != nil {
+		return config, err
+	}
+
 	config = config.DeepCopy()
+	setUpstreamStatus(&config.Status, &result)
+	setMonitoringCondition(&config.Status, config)
+	setCondition(&config.Status, config.Generation, conditionProvisioned, v1.ConditionTrue, conditionReasonImported, "")
+	setReadyConditions(config)
 	config.Status.Phase = aksConfigActivePhase
-	return h.aksCC.UpdateStatus(config)
+	return h.updateStatus(config)
 }
 
 func (h *Handler) checkAndUpdate(config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(h.ctx)
 	defer cancel()
 
-	credentials, err := aks.GetSecrets(h.secretsCache, &config.Spec)
+	refresh := refreshRequested(config)
+	if refresh {
+		logrus.Infof("Refreshing upstream state of cluster [%s]", config.Name)
+	}
+
+	credentials, err := h.getCredentials(config)
 	if err != nil {
 		return config, err
 	}
@@ -279,10 +568,30 @@ func (h *Handler) checkAndUpdate(config *aksv1.AKSClusterConfig) (*aksv1.AKSClus
 	}
 
 	result, err := resourceClusterClient.Get(ctx, config.Spec.ResourceGroup, config.Spec.ClusterName)
+	if aks.IsNotFound(err) {
+		return h.clusterMissing(config)
+	}
 	if err != nil {
 		return config, err
 	}
 
+	config, err = h.recordUpstreamStatus(config, &result)
+	if err != nil {
+		return config, err
+	}
+
+	config, err = h.recordSpecWarnings(config)
+	if err != nil {
+		return config, err
+	}
+
+	if result.ManagedClusterProperties == nil || result.ProvisioningState == nil || result.AgentPoolProfiles == nil {
+		// Azure can return a partial cluster object during incidents, treat it as still provisioning
+		logrus.Infof("Waiting for cluster [%s] to report its provisioning state", config.Name)
+		h.aksEnqueueAfter(config.Namespace, config.Name, h.pollInterval(config, h.pollIntervals.clusterUpdate))
+		return config, nil
+	}
+
 	clusterState := *result.ManagedClusterProperties.ProvisioningState
 	if clusterState == ClusterStatusFailed {
 		return config, fmt.Errorf("update failed for cluster [%s], status: %s", config.Spec.ClusterName, clusterState)
@@ -290,132 +599,410 @@ func (h *Handler) checkAndUpdate(config *aksv1.AKSClusterConfig) (*aksv1.AKSClus
 	if clusterState == ClusterStatusInProgress || clusterState == ClusterStatusUpgrading {
 		// upstream cluster is already updating, must wait until sending next update
 		logrus.Infof("Waiting for cluster [%s] to finish updating", config.Name)
-		if config.Status.Phase != aksConfigUpdatingPhase {
-			config = config.DeepCopy()
-			config.Status.Phase = aksConfigUpdatingPhase
-			return h.aksCC.UpdateStatus(config)
+		updated := config.DeepCopy()
+		if setUpdatingConditions(updated, fmt.Sprintf("cluster is in state %s", clusterState)) ||
+			config.Status.Phase != aksConfigUpdatingPhase {
+			updated.Status.Phase = aksConfigUpdatingPhase
+			return h.updateStatus(updated)
 		}
-		h.aksEnqueueAfter(config.Namespace, config.Name, 30*time.Second)
+		h.aksEnqueueAfter(config.Namespace, config.Name, h.pollInterval(config, h.pollIntervals.clusterUpdate))
 		return config, nil
 	}
 
+	// only compare the spec against the upstream cluster when every node pool has succeeded, updates sent to pools
+	// in any other state fail with conflicts
+	succeededNodePools := map[string]bool{}
 	for _, np := range *result.AgentPoolProfiles {
-		if status := to.String(np.ProvisioningState); status == NodePoolCreating ||
-			status == NodePoolScaling || status == NodePoolDeleting || status == NodePoolUpgrading {
-			if config.Status.Phase != aksConfigUpdatingPhase {
-				config = config.DeepCopy()
-				config.Status.Phase = aksConfigUpdatingPhase
-				config, err = h.aksCC.UpdateStatus(config)
-				if err != nil {
-					return config, err
+		status := to.String(np.ProvisioningState)
+		switch status {
+		case NodePoolSucceeded:
+			succeededNodePools[to.String(np.Name)] = true
+			continue
+		case NodePoolFailed, NodePoolCanceled:
+			if specNodePool := remediableNodePool(config, to.String(np.Name)); status == NodePoolFailed && specNodePool != nil {
+				if dryRunRequested(config) {
+					return h.recordPlannedChanges(config, []string{fmt.Sprintf("remediate failed node pool [%s]", to.String(np.Name))})
 				}
+				return h.remediateNodePool(ctx, credentials, config, specNodePool, status)
 			}
-			switch status {
-			case NodePoolDeleting:
-				logrus.Infof("Waiting for cluster [%s] to delete node pool [%s]", config.Name, to.String(np.Name))
-			default:
-				logrus.Infof("Waiting for cluster [%s] to update node pool [%s]", config.Name, to.String(np.Name))
+			return config, fmt.Errorf("node pool [%s] for cluster [%s] is in state %s", to.String(np.Name), config.Spec.ClusterName, status)
+		}
+
+		updated := config.DeepCopy()
+		message := fmt.Sprintf("node pool [%s] is in state %s", to.String(np.Name), status)
+		conditionChanged := setCondition(&updated.Status, config.Generation, conditionNodePoolsReady, v1.ConditionFalse,
+			conditionReasonNodePoolsUpdating, message)
+		conditionChanged = setCondition(&updated.Status, config.Generation, conditionReady, v1.ConditionFalse,
+			conditionReasonNodePoolsUpdating, message) || conditionChanged
+		if conditionChanged || config.Status.Phase != aksConfigUpdatingPhase {
+			updated.Status.Phase = aksConfigUpdatingPhase
+			config, err = h.updateStatus(updated)
+			if err != nil {
+				return config, err
 			}
-			h.aksEnqueueAfter(config.Namespace, config.Name, 30*time.Second)
-			return config, nil
 		}
+		interval := h.pollIntervals.nodePoolUpgrade
+		switch status {
+		case NodePoolDeleting:
+			logrus.Infof("Waiting for cluster [%s] to delete node pool [%s]", config.Name, to.String(np.Name))
+		case NodePoolCreating, NodePoolScaling:
+			logrus.Infof("Waiting for cluster [%s] to update node pool [%s]", config.Name, to.String(np.Name))
+			interval = h.pollIntervals.nodePoolScale
+		case NodePoolUpgrading:
+			logrus.Infof("Waiting for cluster [%s] to update node pool [%s]", config.Name, to.String(np.Name))
+		default:
+			logrus.Infof("Waiting for node pool [%s] of cluster [%s] in state [%s] to finish", to.String(np.Name), config.Name, status)
+		}
+		h.aksEnqueueAfter(config.Namespace, config.Name, h.pollInterval(config, interval))
+		return config, nil
+	}
+
+	config, err = h.resetNodePoolRemediation(config, succeededNodePools)
+	if err != nil {
+		return config, err
+	}
+
+	// clusters sharing the service principal of the operator are sent its client secret once it is rotated
+	secretHash, err := h.sharedServicePrincipalSecretHash(config, credentials, &result)
+	if err != nil {
+		return config, err
+	}
+	if secretHash != config.Status.ServicePrincipalSecretHash {
+		if dryRunRequested(config) {
+			return h.recordPlannedChanges(config, []string{"reset the service principal client secret"})
+		}
+		return h.resetServicePrincipal(ctx, resourceClusterClient, credentials, config, secretHash)
 	}
 
 	logrus.Infof("Checking configuration for cluster [%s]", config.Spec.ClusterName)
-	upstreamSpec, err := BuildUpstreamClusterState(ctx, h.secretsCache, &config.Spec)
+	upstreamSpec, err := BuildUpstreamClusterState(ctx, h.secretsCache, credentialSpec(config))
 	if err != nil {
 		return config, err
 	}
-	return h.updateUpstreamClusterState(ctx, h.secretsCache, config, upstreamSpec)
+	config, err = h.updateUpstreamClusterState(ctx, h.secretsCache, config, upstreamSpec)
+	if err != nil || !refresh {
+		return config, err
+	}
+	return h.completeRefresh(config)
 }
 
+// validateConfig validates the config and returns an error listing every problem found, so that all of them can be
+// fixed at once
 func (h *Handler) validateConfig(config *aksv1.AKSClusterConfig) error {
+	errs := h.validateConfigSpec(config)
+
+	if config.Spec.AzureCredentialSecret != "" {
+		credentials, err := h.getCredentials(config)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("couldn't get secret [%s] with error: %v", config.Spec.AzureCredentialSecret, err))
+		} else if config.Spec.Imported && len(errs) == 0 {
+			errs = append(errs, validateImportTarget(h.ctx, config, credentials))
+		} else if len(errs) == 0 {
+			ctx, cancel := context.WithCancel(h.ctx)
+			defer cancel()
+			errs = append(errs, validateNodePoolVMSizes(ctx, credentials, withNodePoolDefaults(&config.Spec))...)
+		}
+	}
+
+	return merr.NewErrors(errs...)
+}
+
+// validateConfigSpec returns every problem found in the config which can be detected without calling Azure, it is
+// shared by validateConfig and the validating webhook
+func (h *Handler) validateConfigSpec(config *aksv1.AKSClusterConfig) []error {
+	var errs []error
+
 	// Check for existing AKSClusterConfigs with the same display name
-	aksConfigs, err := h.aksCC.List(config.Namespace, v15.ListOptions{})
+	aksConfigs, err := h.configsByClusterName(config.Spec.ClusterName)
 	if err != nil {
-		return fmt.Errorf("cannot list AKSClusterConfig for display name check")
+		errs = append(errs, fmt.Errorf("cannot list AKSClusterConfig for display name check"))
 	}
-	for _, c := range aksConfigs.Items {
-		if c.Spec.ClusterName == config.Spec.ClusterName && c.Name != config.Name {
-			return fmt.Errorf("cannot create cluster [%s] because an AKSClusterConfig exists with the same name", config.Spec.ClusterName)
+	for _, c := range aksConfigs {
+		if c.Namespace == config.Namespace && c.Name != config.Name {
+			errs = append(errs, fmt.Errorf("cannot create cluster [%s] because an AKSClusterConfig exists with the same name", config.Spec.ClusterName))
+			break
+		}
+	}
+
+	// node pools are validated with the defaults they inherit
+	merged := config.DeepCopy()
+	merged.Spec = *withNodePoolDefaults(&config.Spec)
+
+	errs = append(errs, validateSpec(merged)...)
+	errs = append(errs, validateUnmanagedFields(&config.Spec)...)
+	errs = append(errs, validateTags(config.Spec.Tags, "tags", config.Spec.ClusterName)...)
+	errs = append(errs, validateAuthorizedIPRanges(&config.Spec)...)
+	errs = append(errs, validateNodePools(&merged.Spec)...)
+	return errs
+}
+
+// validateSpec returns every problem found in the config spec which can be detected without calling Azure
+func validateSpec(config *aksv1.AKSClusterConfig) []error {
+	var errs []error
+	seen := map[string]bool{}
+	addError := func(format string, args ...interface{}) {
+		err := fmt.Errorf(format, args...)
+		if !seen[err.Error()] {
+			seen[err.Error()] = true
+			errs = append(errs, err)
 		}
 	}
 
 	cannotBeNilError := "field [%s] must be provided for cluster [%s] config"
 	if config.Spec.ResourceLocation == "" {
-		return fmt.Errorf(cannotBeNilError, "resourceLocation", config.ClusterName)
+		addError(cannotBeNilError, "resourceLocation", config.ClusterName)
 	}
 	if config.Spec.ResourceGroup == "" {
-		return fmt.Errorf(cannotBeNilError, "resourceGroup", config.ClusterName)
+		addError(cannotBeNilError, "resourceGroup", config.ClusterName)
 	}
 	if config.Spec.ClusterName == "" {
-		return fmt.Errorf(cannotBeNilError, "clusterName", config.ClusterName)
+		addError(cannotBeNilError, "clusterName", config.ClusterName)
 	}
 	if config.Spec.AzureCredentialSecret == "" {
-		return fmt.Errorf(cannotBeNilError, "azureCredentialSecret", config.ClusterName)
-	}
-
-	if _, err = aks.GetSecrets(h.secretsCache, &config.Spec); err != nil {
-		return fmt.Errorf("couldn't get secret [%s] with error: %v", config.Spec.AzureCredentialSecret, err)
+		addError(cannotBeNilError, "azureCredentialSecret", config.ClusterName)
 	}
 
 	if config.Spec.Imported {
-		return nil
+		return errs
 	}
 	if config.Spec.KubernetesVersion == nil {
-		return fmt.Errorf(cannotBeNilError, "kubernetesVersion", config.ClusterName)
+		addError(cannotBeNilError, "kubernetesVersion", config.ClusterName)
 	}
 
 	systemMode := false
+	poolNames := map[string]bool{}
 	for _, np := range config.Spec.NodePools {
 		if np.Name == nil {
-			return fmt.Errorf(cannotBeNilError, "NodePool.Name", config.ClusterName)
+			addError(cannotBeNilError, "NodePool.Name", config.ClusterName)
+		} else {
+			maxLength := linuxPoolNameMaxLength
+			if np.OsType == "Windows" {
+				maxLength = windowsPoolNameMaxLength
+			}
+			if !matchPoolName.MatchString(*np.Name) {
+				addError("node pool name [%s] for cluster [%s] config must start with a lowercase letter and only contain lowercase letters and numbers",
+					*np.Name, config.Spec.ClusterName)
+			} else if len(*np.Name) > maxLength {
+				addError("node pool name [%s] for cluster [%s] config must not be longer than %d characters", *np.Name, config.Spec.ClusterName, maxLength)
+			}
+			if poolNames[*np.Name] {
+				addError("cluster [%s] cannot have multiple nodepools with name %s", config.Spec.ClusterName, *np.Name)
+			}
+			poolNames[*np.Name] = true
 		}
 		if np.Count == nil {
-			return fmt.Errorf(cannotBeNilError, "NodePool.Count", config.ClusterName)
+			addError(cannotBeNilError, "NodePool.Count", config.ClusterName)
 		}
 		if np.MaxPods == nil {
-			return fmt.Errorf(cannotBeNilError, "NodePool.MaxPods", config.ClusterName)
+			addError(cannotBeNilError, "NodePool.MaxPods", config.ClusterName)
 		}
 		if np.VMSize == "" {
-			return fmt.Errorf(cannotBeNilError, "NodePool.VMSize", config.ClusterName)
+			addError(cannotBeNilError, "NodePool.VMSize", config.ClusterName)
 		}
 		if np.OsDiskSizeGB == nil {
-			return fmt.Errorf(cannotBeNilError, "NodePool.OsDiskSizeGB", config.ClusterName)
+			addError(cannotBeNilError, "NodePool.OsDiskSizeGB", config.ClusterName)
 		}
 		if np.OsDiskType == "" {
-			return fmt.Errorf(cannotBeNilError, "NodePool.OSDiskType", config.ClusterName)
+			addError(cannotBeNilError, "NodePool.OSDiskType", config.ClusterName)
 		}
 		if np.Mode == "" {
-			return fmt.Errorf(cannotBeNilError, "NodePool.Mode", config.ClusterName)
+			addError(cannotBeNilError, "NodePool.Mode", config.ClusterName)
 		}
 		if np.Mode == "System" {
 			systemMode = true
 		}
 		if np.OsType == "" {
-			return fmt.Errorf(cannotBeNilError, "NodePool.OsType", config.ClusterName)
+			addError(cannotBeNilError, "NodePool.OsType", config.ClusterName)
 		}
 		if np.OsType == "Windows" {
-			return fmt.Errorf("windows node pools are not currently supported")
+			addError("windows node pools are not currently supported")
 		}
 	}
 	if !systemMode || len(config.Spec.NodePools) < 1 {
-		return fmt.Errorf("at least one NodePool with mode System is required")
+		addError("at least one NodePool with mode System is required")
+	}
+
+	if p := config.Spec.KeyVaultSecretsProvider; p != nil && p.RotationPollInterval != nil {
+		if interval, err := time.ParseDuration(*p.RotationPollInterval); err != nil || interval <= 0 {
+			addError("keyVaultSecretsProvider for cluster [%s] config has invalid rotationPollInterval [%s], must be a duration like 2m",
+				config.Spec.ClusterName, *p.RotationPollInterval)
+		}
+	}
+
+	if to.Bool(config.Spec.ForceDeleteResourceGroup) && !to.Bool(config.Spec.DeleteResourceGroupOnRemove) {
+		addError("forceDeleteResourceGroup for cluster [%s] config requires deleteResourceGroupOnRemove", config.Spec.ClusterName)
+	}
+
+	if c := config.Spec.ACIConnector; c != nil && c.Enabled {
+		if to.String(c.SubnetName) == "" {
+			addError("aciConnector for cluster [%s] config requires subnetName", config.Spec.ClusterName)
+		}
+		if to.String(config.Spec.NetworkPlugin) != string(containerservice.NetworkPluginAzure) {
+			addError("aciConnector for cluster [%s] config requires networkPlugin azure", config.Spec.ClusterName)
+		}
+	}
+
+	if g := config.Spec.IngressApplicationGateway; g != nil && g.Enabled {
+		if (g.GatewayID == nil) == (g.SubnetCIDR == nil) {
+			addError("ingressApplicationGateway for cluster [%s] config requires exactly one of gatewayId or subnetCidr", config.Spec.ClusterName)
+		}
+		if g.GatewayID != nil && !matchApplicationGatewayID.MatchString(*g.GatewayID) {
+			addError("ingressApplicationGateway for cluster [%s] config has invalid gatewayId [%s], must be an application gateway resource ID",
+				config.Spec.ClusterName, *g.GatewayID)
+		}
+		if g.SubnetCIDR != nil {
+			if _, _, err := net.ParseCIDR(*g.SubnetCIDR); err != nil {
+				addError("ingressApplicationGateway for cluster [%s] config has invalid subnetCidr [%s]", config.Spec.ClusterName, *g.SubnetCIDR)
+			}
+		}
+		if g.GatewayName != nil && g.GatewayID != nil {
+			addError("ingressApplicationGateway for cluster [%s] config can only set gatewayName with subnetCidr", config.Spec.ClusterName)
+		}
+	}
+
+	if d := config.Spec.Defender; d != nil && d.Enabled && d.LogAnalyticsWorkspaceID != nil &&
+		(d.LogAnalyticsWorkspaceGroup != nil || d.LogAnalyticsWorkspaceName != nil) {
+		addError("defender for cluster [%s] config can either set logAnalyticsWorkspaceId or logAnalyticsWorkspaceGroup and logAnalyticsWorkspaceName",
+			config.Spec.ClusterName)
+	}
+
+	var profileErrs []error
+	profileErrs = append(profileErrs, validateOutboundType(withNodePoolDefaults(&config.Spec))...)
+	profileErrs = append(profileErrs, validateLoadBalancerProfile(&config.Spec)...)
+	profileErrs = append(profileErrs, validateHTTPProxyConfig(&config.Spec)...)
+	profileErrs = append(profileErrs, validateAutoScalerProfile(&config.Spec)...)
+	profileErrs = append(profileErrs, validateAttachACRs(&config.Spec)...)
+	for _, err := range profileErrs {
+		addError("%v", err)
+	}
+
+	if config.Spec.Tier != nil {
+		switch *config.Spec.Tier {
+		case aks.TierFree, aks.TierStandard:
+		case aks.TierPremium:
+			// Premium requires the LongTermSupport plan, which cannot be set with the API version of the operator
+			addError("tier Premium for cluster [%s] config requires the LongTermSupport plan, which is not supported, use Standard for the uptime SLA",
+				config.Spec.ClusterName)
+		default:
+			addError("cluster [%s] config has invalid tier [%s], must be Free or Standard", config.Spec.ClusterName, *config.Spec.Tier)
+		}
+	}
+
+	if config.Spec.UpgradeChannel != nil {
+		switch *config.Spec.UpgradeChannel {
+		case string(containerservice.UpgradeChannelNone), string(containerservice.UpgradeChannelPatch), string(containerservice.UpgradeChannelStable),
+			string(containerservice.UpgradeChannelRapid), string(containerservice.UpgradeChannelNodeImage):
+		default:
+			addError("cluster [%s] config has invalid upgradeChannel [%s], must be none, patch, stable, rapid or node-image",
+				config.Spec.ClusterName, *config.Spec.UpgradeChannel)
+		}
+	}
+
+	if identity := config.Spec.Identity; identity != nil {
+		switch identity.Type {
+		case string(containerservice.ResourceIdentityTypeSystemAssigned):
+			if identity.UserAssignedIdentityID != nil {
+				addError("identity for cluster [%s] config cannot set userAssignedIdentityId with type SystemAssigned", config.Spec.ClusterName)
+			}
+		case string(containerservice.ResourceIdentityTypeUserAssigned):
+			if !matchUserAssignedIdentityID.MatchString(to.String(identity.UserAssignedIdentityID)) {
+				addError("identity for cluster [%s] config has invalid userAssignedIdentityId [%s], must be a user-assigned identity resource ID",
+					config.Spec.ClusterName, to.String(identity.UserAssignedIdentityID))
+			}
+		default:
+			addError("identity for cluster [%s] config has invalid type [%s], must be SystemAssigned or UserAssigned", config.Spec.ClusterName, identity.Type)
+		}
+	}
+	if kubeletIdentity := config.Spec.KubeletIdentity; kubeletIdentity != nil {
+		if config.Spec.Identity == nil || config.Spec.Identity.Type != string(containerservice.ResourceIdentityTypeUserAssigned) {
+			addError("kubelet identity for cluster [%s] config requires a UserAssigned cluster identity", config.Spec.ClusterName)
+		}
+		if !matchUserAssignedIdentityID.MatchString(to.String(kubeletIdentity.ResourceID)) {
+			addError("kubelet identity for cluster [%s] config has invalid resourceId [%s], must be a user-assigned identity resource ID",
+				config.Spec.ClusterName, to.String(kubeletIdentity.ResourceID))
+		}
+		if to.String(kubeletIdentity.ClientID) == "" || to.String(kubeletIdentity.ObjectID) == "" {
+			addError("kubelet identity for cluster [%s] config requires clientId and objectId", config.Spec.ClusterName)
+		}
+	}
+
+	if !to.Bool(config.Spec.PrivateCluster) && (config.Spec.PrivateDNSZone != nil || config.Spec.EnablePrivateClusterPublicFQDN != nil) {
+		addError("privateDnsZone and enablePrivateClusterPublicFqdn for cluster [%s] config require privateCluster", config.Spec.ClusterName)
+	}
+	if zone := to.String(config.Spec.PrivateDNSZone); config.Spec.PrivateDNSZone != nil && !strings.EqualFold(zone, "system") && !strings.EqualFold(zone, "none") {
+		if !matchPrivateDNSZoneID.MatchString(zone) {
+			addError("cluster [%s] config has invalid privateDnsZone [%s], must be system, none or a private DNS zone resource ID", config.Spec.ClusterName, zone)
+		}
+		// Azure requires a user-assigned identity to manage the records of a private DNS zone of the user
+		if config.Spec.Identity == nil || config.Spec.Identity.Type != string(containerservice.ResourceIdentityTypeUserAssigned) {
+			addError("privateDnsZone [%s] for cluster [%s] config requires a UserAssigned cluster identity", zone, config.Spec.ClusterName)
+		}
+	}
+
+	if to.Bool(config.Spec.WorkloadIdentityEnabled) && !to.Bool(config.Spec.OIDCIssuerEnabled) {
+		addError("workload identity for cluster [%s] config requires the OIDC issuer to be enabled", config.Spec.ClusterName)
 	}
 
 	if config.Spec.NetworkPolicy != nil &&
 		*config.Spec.NetworkPolicy != string(containerservice.NetworkPolicyAzure) &&
 		*config.Spec.NetworkPolicy != string(containerservice.NetworkPolicyCalico) {
-		return fmt.Errorf("wrong network policy value for [%s] cluster config", config.ClusterName)
+		addError("wrong network policy value for [%s] cluster config", config.ClusterName)
+	}
+	return errs
+}
+
+// validateImportTarget checks that the cluster referenced by an imported config exists and can be read with the
+// configured credentials
+func validateImportTarget(ctx context.Context, config *aksv1.AKSClusterConfig, credentials *aks.Credentials) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	resourceClusterClient, err := aks.NewClusterClient(credentials)
+	if err != nil {
+		return fmt.Errorf("couldn't create client with credentials from secret [%s]: %v", config.Spec.AzureCredentialSecret, err)
+	}
+
+	if _, err = resourceClusterClient.Get(ctx, config.Spec.ResourceGroup, config.Spec.ClusterName); err != nil {
+		switch {
+		case aks.IsNotFound(err):
+			return fmt.Errorf("import target not found: cluster [%s] does not exist in resource group [%s]",
+				config.Spec.ClusterName, config.Spec.ResourceGroup)
+		case aks.IsUnauthorized(err):
+			return fmt.Errorf("credentials from secret [%s] cannot access cluster [%s] in resource group [%s]: %w",
+				config.Spec.AzureCredentialSecret, config.Spec.ClusterName, config.Spec.ResourceGroup, err)
+		default:
+			return fmt.Errorf("couldn't get cluster [%s] in resource group [%s] to import: %w",
+				config.Spec.ClusterName, config.Spec.ResourceGroup, err)
+		}
 	}
 	return nil
 }
 
+// configsByClusterName returns the AKSClusterConfigs in all namespaces with the given spec.clusterName. The cache
+// index is used once the cache is synced, otherwise the configs are listed from the API.
+func (h *Handler) configsByClusterName(clusterName string) ([]*aksv1.AKSClusterConfig, error) {
+	if h.aksCacheSynced() {
+		return h.aksCache.GetByIndex(clusterNameIndex, clusterName)
+	}
+
+	aksConfigs, err := h.aksCC.List("", v15.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var ret []*aksv1.AKSClusterConfig
+	for i := range aksConfigs.Items {
+		if aksConfigs.Items[i].Spec.ClusterName == clusterName {
+			ret = append(ret, &aksConfigs.Items[i])
+		}
+	}
+	return ret, nil
+}
+
 func (h *Handler) waitForCluster(config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(h.ctx)
 	defer cancel()
 
-	credentials, err := aks.GetSecrets(h.secretsCache, &config.Spec)
+	credentials, err := h.getCredentials(config)
 	if err != nil {
 		return config, err
 	}
@@ -430,10 +1017,25 @@ func (h *Handler) waitForCluster(config *aksv1.AKSClusterConfig) (*aksv1.AKSClus
 		return config, err
 	}
 
-	clusterState := *result.ManagedClusterProperties.ProvisioningState
+	config, err = h.recordUpstreamStatus(config, &result)
+	if err != nil {
+		return config, err
+	}
+
+	// a cluster without properties or provisioning state is treated as still creating
+	var clusterState string
+	if result.ManagedClusterProperties != nil {
+		clusterState = to.String(result.ProvisioningState)
+	}
 	if clusterState == ClusterStatusFailed {
 		return config, fmt.Errorf("creation for cluster [%s] status: %s", config.Spec.ClusterName, clusterState)
 	}
+	// the create is sent once, LastUpdateAppliedTime is when it was sent
+	if clusterState != ClusterStatusSucceeded && !config.Status.LastUpdateAppliedTime.IsZero() &&
+		time.Since(config.Status.LastUpdateAppliedTime.Time) > h.createTimeout {
+		return config, createTimeoutError{fmt.Errorf("cluster [%s] was not created within %v, its provisioning state is [%s]",
+			config.Spec.ClusterName, h.createTimeout, clusterState)}
+	}
 	if clusterState == ClusterStatusSucceeded {
 		if err = h.createCASecret(ctx, config); err != nil {
 			if !errors.IsAlreadyExists(err) {
@@ -441,34 +1043,274 @@ func (h *Handler) waitForCluster(config *aksv1.AKSClusterConfig) (*aksv1.AKSClus
 			}
 		}
 		logrus.Infof("Cluster [%s] created successfully", config.Spec.ClusterName)
+		h.recorder.Eventf(config, v1.EventTypeNormal, eventReasonClusterCreated, "Cluster [%s] was created", config.Spec.ClusterName)
 		config = config.DeepCopy()
+		setCondition(&config.Status, config.Generation, conditionProvisioned, v1.ConditionTrue, conditionReasonCreated, "")
+		setReadyConditions(config)
 		config.Status.Phase = aksConfigActivePhase
-		return h.aksCC.UpdateStatus(config)
+		return h.updateStatus(config)
 	}
 
 	logrus.Infof("Waiting for cluster [%s] to finish creating", config.Name)
-	h.aksEnqueueAfter(config.Namespace, config.Name, wait*time.Second)
+	h.aksEnqueueAfter(config.Namespace, config.Name, h.pollInterval(config, h.pollIntervals.clusterCreate))
 
 	return config, nil
 }
 
-// enqueueUpdate enqueues the config if it is already in the updating phase. Otherwise, the
-// phase is updated to "updating". This is important because the object needs to reenter the
-// onChange handler to start waiting on the update.
-func (h *Handler) enqueueUpdate(config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
-	if config.Status.Phase == aksConfigUpdatingPhase {
-		h.aksEnqueue(config.Namespace, config.Name)
+// recordUpstreamStatus writes the observed state of the upstream cluster to status. The status is only updated if
+// one of the observed values has changed.
+func (h *Handler) recordUpstreamStatus(config *aksv1.AKSClusterConfig, cluster *containerservice.ManagedCluster) (*aksv1.AKSClusterConfig, error) {
+	status := config.Status.DeepCopy()
+	setUpstreamStatus(status, cluster)
+	setMonitoringCondition(status, config)
+	status.UnmanagedFields = config.Spec.UnmanagedFields
+	if reflect.DeepEqual(status, &config.Status) {
 		return config, nil
 	}
+
+	config = config.DeepCopy()
+	config.Status = *status
+	return h.updateStatus(config)
+}
+
+// setUpstreamStatus sets the resource ID, API server FQDNs, node resource group, running Kubernetes version, node pool
+// states, total node count, provisioning state and capability flags (RBAC, private cluster, managed AAD and managed
+// identity), pricing tier, identities, Key Vault secrets provider identity, application gateway and OIDC issuer URL of
+// the upstream cluster on status
+func setUpstreamStatus(status *aksv1.AKSClusterConfigStatus, cluster *containerservice.ManagedCluster) {
+	if cluster.ManagedClusterProperties == nil {
+		return
+	}
+
+	var nodeCount int32
+	var nodePoolVersions map[string]string
+	var nodePools map[string]aksv1.AKSNodePoolStatus
+	if cluster.AgentPoolProfiles != nil {
+		nodePoolVersions = make(map[string]string, len(*cluster.AgentPoolProfiles))
+		nodePools = make(map[string]aksv1.AKSNodePoolStatus, len(*cluster.AgentPoolProfiles))
+		for _, np := range *cluster.AgentPoolProfiles {
+			nodeCount += to.Int32(np.Count)
+			nodePoolVersions[to.String(np.Name)] = to.String(np.OrchestratorVersion)
+			nodePools[to.String(np.Name)] = aksv1.AKSNodePoolStatus{
+				ProvisioningState:   to.String(np.ProvisioningState),
+				Count:               to.Int32(np.Count),
+				OrchestratorVersion: to.String(np.OrchestratorVersion),
+			}
+		}
+	}
+	status.ClusterID = to.String(cluster.ID)
+	status.Endpoint = to.String(cluster.Fqdn)
+	status.PrivateFQDN = to.String(cluster.PrivateFQDN)
+	status.NodeResourceGroup = to.String(cluster.NodeResourceGroup)
+	status.NodePoolVersions = nodePoolVersions
+	status.NodePools = nodePools
+	status.KubernetesVersion = to.String(cluster.KubernetesVersion)
+	status.CurrentNodeCount = nodeCount
+	status.ProvisioningState = to.String(cluster.ProvisioningState)
+
+	status.RBACEnabled = to.Bool(cluster.EnableRBAC)
+	status.PrivateCluster = cluster.APIServerAccessProfile != nil && to.Bool(cluster.APIServerAccessProfile.EnablePrivateCluster)
+	status.ManagedAAD = cluster.AadProfile != nil && to.Bool(cluster.AadProfile.Managed)
+	status.ManagedIdentity = cluster.Identity != nil && cluster.Identity.Type != "" &&
+		cluster.Identity.Type != containerservice.ResourceIdentityTypeNone
+	status.Tier = aks.UpstreamTier(cluster)
+
+	status.IdentityPrincipalID = aks.IdentityPrincipalID(cluster)
+	status.IdentityClientID = aks.IdentityClientID(cluster)
+	status.KubeletIdentityObjectID = ""
+	status.KubeletIdentityClientID = ""
+	if kubeletIdentity := aks.UpstreamKubeletIdentity(cluster); kubeletIdentity != nil {
+		status.KubeletIdentityObjectID = to.String(kubeletIdentity.ObjectID)
+		status.KubeletIdentityClientID = to.String(kubeletIdentity.ClientID)
+	}
+
+	status.KeyVaultSecretsProviderClientID = aks.KeyVaultSecretsProviderClientID(cluster)
+	status.ApplicationGatewayID = aks.EffectiveApplicationGatewayID(cluster)
+
+	status.OIDCIssuerURL = ""
+	if cluster.OidcIssuerProfile != nil && to.Bool(cluster.OidcIssuerProfile.Enabled) {
+		status.OIDCIssuerURL = to.String(cluster.OidcIssuerProfile.IssuerURL)
+	}
+
+	var workspaceID string
+	if omsagent := cluster.AddonProfiles["omsagent"]; omsagent != nil && to.Bool(omsagent.Enabled) {
+		workspaceID = aks.LogAnalyticsWorkspaceID(omsagent)
+	}
+	setLogAnalyticsWorkspaceStatus(status, workspaceID, false)
+}
+
+// identityChanged returns true if the cluster identity of the spec differs from the upstream identity
+func identityChanged(identity, upstreamIdentity *aksv1.AKSClusterIdentity) bool {
+	if upstreamIdentity == nil {
+		return true
+	}
+	return identity.Type != upstreamIdentity.Type ||
+		!strings.EqualFold(to.String(identity.UserAssignedIdentityID), to.String(upstreamIdentity.UserAssignedIdentityID))
+}
+
+// keyVaultSecretsProviderChanged returns true if the Key Vault secrets provider addon differs from the upstream addon,
+// rotation settings are only compared if they are set
+func keyVaultSecretsProviderChanged(provider, upstreamProvider *aksv1.AKSKeyVaultSecretsProvider) bool {
+	if provider.Enabled != upstreamProvider.Enabled {
+		return true
+	}
+	if !provider.Enabled {
+		return false
+	}
+	if provider.RotationEnabled != nil && to.Bool(provider.RotationEnabled) != to.Bool(upstreamProvider.RotationEnabled) {
+		return true
+	}
+	if provider.RotationPollInterval != nil {
+		// the interval may be written differently upstream, e.g. "120s" instead of "2m"
+		interval, _ := time.ParseDuration(*provider.RotationPollInterval)
+		upstreamInterval, _ := time.ParseDuration(to.String(upstreamProvider.RotationPollInterval))
+		return interval != upstreamInterval
+	}
+	return false
+}
+
+// aciConnectorChanged returns true if the ACI connector addon differs from the upstream addon
+func aciConnectorChanged(connector, upstreamConnector *aksv1.AKSACIConnector) bool {
+	if connector.Enabled != upstreamConnector.Enabled {
+		return true
+	}
+	return connector.Enabled && to.String(connector.SubnetName) != to.String(upstreamConnector.SubnetName)
+}
+
+// ingressApplicationGatewayChanged returns true if the ingress application gateway addon differs from the upstream
+// addon
+func ingressApplicationGatewayChanged(gateway, upstreamGateway *aksv1.AKSIngressApplicationGateway) bool {
+	if gateway.Enabled != upstreamGateway.Enabled {
+		return true
+	}
+	if !gateway.Enabled {
+		return false
+	}
+	return !strings.EqualFold(to.String(gateway.GatewayID), to.String(upstreamGateway.GatewayID)) ||
+		to.String(gateway.SubnetCIDR) != to.String(upstreamGateway.SubnetCIDR) ||
+		(gateway.GatewayName != nil && *gateway.GatewayName != to.String(upstreamGateway.GatewayName))
+}
+
+// defenderChanged returns true if Microsoft Defender of the spec differs from the upstream profile. The workspace is
+// only compared if the spec names one, so clusters keep the workspace Defender already uses otherwise.
+func defenderChanged(spec, upstreamSpec *aksv1.AKSClusterConfigSpec) bool {
+	defender, upstreamDefender := spec.Defender, upstreamSpec.Defender
+	if defender.Enabled != upstreamDefender.Enabled {
+		return true
+	}
+	if !defender.Enabled {
+		return false
+	}
+	if workspaceID := to.String(defender.LogAnalyticsWorkspaceID); workspaceID != "" {
+		return !strings.EqualFold(strings.Trim(workspaceID, "/"), strings.Trim(to.String(upstreamDefender.LogAnalyticsWorkspaceID), "/"))
+	}
+	if to.String(defender.LogAnalyticsWorkspaceName) == "" {
+		return false
+	}
+	group := to.String(defender.LogAnalyticsWorkspaceGroup)
+	if group == "" {
+		group = spec.ResourceGroup
+	}
+	return !strings.EqualFold(to.String(defender.LogAnalyticsWorkspaceName), to.String(upstreamDefender.LogAnalyticsWorkspaceName)) ||
+		!strings.EqualFold(group, to.String(upstreamDefender.LogAnalyticsWorkspaceGroup))
+}
+
+// monitoringWorkspaceChanged returns true if the spec names a Log Analytics workspace other than the one the
+// monitoring addon is wired to. Without a workspace name in the spec the current workspace is kept.
+func monitoringWorkspaceChanged(spec, upstreamSpec *aksv1.AKSClusterConfigSpec) bool {
+	if to.String(spec.LogAnalyticsWorkspaceName) == "" {
+		return false
+	}
+	return !strings.EqualFold(to.String(spec.LogAnalyticsWorkspaceName), to.String(upstreamSpec.LogAnalyticsWorkspaceName)) ||
+		!strings.EqualFold(monitoringWorkspaceGroup(spec), to.String(upstreamSpec.LogAnalyticsWorkspaceGroup))
+}
+
+// monitoringWorkspaceGroup returns the resource group of the Log Analytics workspace of the spec, which defaults to
+// the resource group of the cluster
+func monitoringWorkspaceGroup(spec *aksv1.AKSClusterConfigSpec) string {
+	if group := to.String(spec.LogAnalyticsWorkspaceGroup); group != "" {
+		return group
+	}
+	return spec.ResourceGroup
+}
+
+// setLogAnalyticsWorkspaceStatus records the Log Analytics workspace the monitoring addon is wired to. A workspace
+// created by the operator stays recorded until the operator deletes it, also while monitoring is disabled or wired to
+// another workspace, so that it is still removed along with the cluster.
+func setLogAnalyticsWorkspaceStatus(status *aksv1.AKSClusterConfigStatus, workspaceID string, created bool) {
+	if created {
+		status.LogAnalyticsWorkspaceID = workspaceID
+		status.LogAnalyticsWorkspaceCreated = true
+		return
+	}
+	if status.LogAnalyticsWorkspaceCreated {
+		return
+	}
+	status.LogAnalyticsWorkspaceID = workspaceID
+}
+
+// waitForClusterRemoval sends the delete of the upstream cluster, unless it is already being deleted, and records the
+// deleting phase so that the deletion is followed after a restart. The config is polled until the cluster is gone,
+// generic.ErrSkip keeps the finalizer in place meanwhile without retrying the remove handler as an error.
+func (h *Handler) waitForClusterRemoval(ctx context.Context, clusterClient *containerservice.ManagedClustersClient,
+	config *aksv1.AKSClusterConfig, cluster *containerservice.ManagedCluster) (*aksv1.AKSClusterConfig, error) {
+	var clusterState string
+	if cluster.ManagedClusterProperties != nil {
+		clusterState = to.String(cluster.ProvisioningState)
+	}
+
+	// the delete is sent again if a previous one failed, a deletion started outside of the operator is only followed
+	if clusterState != ClusterStatusDeleting && (config.Status.Phase != aksConfigDeletingPhase || clusterState == ClusterStatusFailed) {
+		if err := aks.RemoveCluster(ctx, clusterClient, &config.Spec); err != nil {
+			return config, fmt.Errorf("error removing cluster [%s] message %w", config.Spec.ClusterName, err)
+		}
+		h.recorder.Eventf(config, v1.EventTypeNormal, eventReasonClusterDelete, "Deleting cluster [%s]", config.Spec.ClusterName)
+
+		var err error
+		config = config.DeepCopy()
+		config.Status.Phase = aksConfigDeletingPhase
+		config.Status.LastUpdateAppliedTime = v15.Now()
+		config, err = h.updateStatus(config)
+		if err != nil {
+			return config, err
+		}
+	} else if config.Status.Phase != aksConfigDeletingPhase {
+		logrus.Infof("Cluster [%s] is already being deleted", config.Spec.ClusterName)
+		var err error
+		config = config.DeepCopy()
+		config.Status.Phase = aksConfigDeletingPhase
+		config, err = h.updateStatus(config)
+		if err != nil {
+			return config, err
+		}
+	}
+
+	logrus.Infof("Waiting for cluster [%s] to be deleted", config.Name)
+	h.aksEnqueueAfter(config.Namespace, config.Name, h.pollInterval(config, h.pollIntervals.clusterDelete))
+	return config, generic.ErrSkip
+}
+
+// enqueueUpdate records that an update was sent to Azure, sets the phase to "updating" and marks the cluster as not
+// ready until the update has finished. This is important because the object needs to reenter the onChange handler to
+// start waiting on the update, which the status update guarantees.
+func (h *Handler) enqueueUpdate(config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
 	config = config.DeepCopy()
 	config.Status.Phase = aksConfigUpdatingPhase
-	return h.aksCC.UpdateStatus(config)
+	config.Status.LastUpdateAppliedTime = v15.Now()
+	setUpdatingConditions(config, "")
+	return h.updateStatus(config)
 }
 
 // createCASecret creates a secret containing ca and endpoint. These can be used to create a kubeconfig via
-// the go sdk
+// the go sdk. Azure is not called if the secret already exists.
 func (h *Handler) createCASecret(ctx context.Context, config *aksv1.AKSClusterConfig) error {
-	kubeConfig, err := GetClusterKubeConfig(ctx, h.secretsCache, &config.Spec)
+	if _, err := h.secretsCache.Get(config.Namespace, config.Name); err == nil {
+		return nil
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+
+	kubeConfig, err := GetClusterKubeConfig(ctx, h.secretsCache, credentialSpec(config))
 	if err != nil {
 		return err
 	}
@@ -506,16 +1348,7 @@ func GetClusterKubeConfig(ctx context.Context, secretsCache wranglerv1.SecretCac
 	if err != nil {
 		return nil, err
 	}
-	accessProfile, err := resourceClusterClient.GetAccessProfile(ctx, spec.ResourceGroup, spec.ClusterName, "clusterAdmin")
-	if err != nil {
-		return nil, err
-	}
-
-	config, err := clientcmd.RESTConfigFromKubeConfig(*accessProfile.KubeConfig)
-	if err != nil {
-		return nil, err
-	}
-	return config, nil
+	return aks.GetKubeConfig(ctx, credentials, resourceClusterClient, spec)
 }
 
 // BuildUpstreamClusterState creates AKSClusterConfigSpec from existing cluster configuration
@@ -535,31 +1368,103 @@ func BuildUpstreamClusterState(ctx context.Context, secretsCache wranglerv1.Secr
 		return nil, err
 	}
 
+	if clusterState.ManagedClusterProperties == nil {
+		return nil, fmt.Errorf("cannot detect cluster [%s] upstream state", spec.ClusterName)
+	}
+
 	// set Kubernetes version
 	if clusterState.KubernetesVersion == nil {
 		return nil, fmt.Errorf("cannot detect cluster [%s] upstream kubernetes version", spec.ClusterName)
 	}
 	upstreamSpec.KubernetesVersion = clusterState.KubernetesVersion
 
+	// set HTTP proxy
+	var upstreamTrustedCA string
+	upstreamSpec.HTTPProxyConfig, upstreamTrustedCA = aks.UpstreamHTTPProxyConfig(&clusterState)
+	if upstreamSpec.HTTPProxyConfig != nil {
+		upstreamSpec.HTTPProxyConfig.TrustedCASecret = upstreamTrustedCASecret(secretsCache, spec, upstreamTrustedCA)
+	}
+
+	// set autoscaler profile
+	upstreamSpec.AutoScalerProfile = aks.UpstreamAutoScalerProfile(&clusterState)
+
+	// set pricing tier
+	upstreamSpec.Tier = to.StringPtr(aks.UpstreamTier(&clusterState))
+
+	// set auto-upgrade channel
+	upstreamSpec.UpgradeChannel = to.StringPtr(string(containerservice.UpgradeChannelNone))
+	if clusterState.AutoUpgradeProfile != nil && clusterState.AutoUpgradeProfile.UpgradeChannel != "" {
+		upstreamSpec.UpgradeChannel = to.StringPtr(string(clusterState.AutoUpgradeProfile.UpgradeChannel))
+	}
+
+	// set Kubernetes RBAC
+	upstreamSpec.EnableRBAC = clusterState.EnableRBAC
+
+	// set local accounts
+	upstreamSpec.DisableLocalAccounts = to.BoolPtr(to.Bool(clusterState.DisableLocalAccounts))
+
+	// set cluster and kubelet identity
+	upstreamSpec.Identity = aks.UpstreamIdentity(&clusterState)
+	upstreamSpec.KubeletIdentity = aks.UpstreamKubeletIdentity(&clusterState)
+
+	// set OIDC issuer and workload identity
+	upstreamSpec.OIDCIssuerEnabled = to.BoolPtr(clusterState.OidcIssuerProfile != nil && to.Bool(clusterState.OidcIssuerProfile.Enabled))
+	upstreamSpec.WorkloadIdentityEnabled = to.BoolPtr(clusterState.SecurityProfile != nil && clusterState.SecurityProfile.WorkloadIdentity != nil &&
+		to.Bool(clusterState.SecurityProfile.WorkloadIdentity.Enabled))
+
 	// set tags
 	upstreamSpec.Tags = make(map[string]string)
 	if len(clusterState.Tags) != 0 {
 		upstreamSpec.Tags = to.StringMap(clusterState.Tags)
+		delete(upstreamSpec.Tags, ownerTag)
 	}
 
 	// set AgentPool profile
+	if clusterState.AgentPoolProfiles == nil {
+		return nil, fmt.Errorf("cannot detect cluster [%s] upstream node pools", spec.ClusterName)
+	}
 	for _, np := range *clusterState.AgentPoolProfiles {
 		var upstreamNP aksv1.AKSNodePool
 		upstreamNP.Name = np.Name
 		upstreamNP.Count = np.Count
 		upstreamNP.MaxPods = np.MaxPods
-		upstreamNP.VMSize = string(np.VMSize)
+		upstreamNP.VMSize = to.String(np.VMSize)
 		upstreamNP.OsDiskSizeGB = np.OsDiskSizeGB
 		upstreamNP.OsDiskType = string(np.OsDiskType)
 		upstreamNP.Mode = string(np.Mode)
 		upstreamNP.OsType = string(np.OsType)
+		upstreamNP.OsSKU = string(np.OsSKU)
 		upstreamNP.OrchestratorVersion = np.OrchestratorVersion
 		upstreamNP.AvailabilityZones = np.AvailabilityZones
+		upstreamNP.VnetSubnetID = np.VnetSubnetID
+		upstreamNP.PodSubnetID = np.PodSubnetID
+		upstreamNP.KubeletConfig = aks.UpstreamKubeletConfig(np.KubeletConfig)
+		upstreamNP.LinuxOSConfig = aks.UpstreamLinuxOSConfig(np.LinuxOSConfig)
+		upstreamNP.EnableFIPS = np.EnableFIPS
+		upstreamNP.EnableEncryptionAtHost = np.EnableEncryptionAtHost
+		upstreamNP.EnableUltraSSD = np.EnableUltraSSD
+		upstreamNP.ScaleDownMode = string(np.ScaleDownMode)
+		upstreamNP.GpuInstanceProfile = string(np.GpuInstanceProfile)
+		upstreamNP.WorkloadRuntime = string(np.WorkloadRuntime)
+		if np.CreationData != nil {
+			upstreamNP.SnapshotID = np.CreationData.SourceResourceID
+		}
+		upstreamNP.ProximityPlacementGroupID = np.ProximityPlacementGroupID
+		upstreamNP.HostGroupID = np.HostGroupID
+		upstreamNP.ScaleSetPriority = string(np.ScaleSetPriority)
+		upstreamNP.ScaleSetEvictionPolicy = string(np.ScaleSetEvictionPolicy)
+		upstreamNP.SpotMaxPrice = np.SpotMaxPrice
+		if np.NodeTaints != nil && len(*np.NodeTaints) > 0 {
+			upstreamNP.NodeTaints = *np.NodeTaints
+		}
+		if len(np.NodeLabels) > 0 {
+			upstreamNP.NodeLabels = to.StringMap(np.NodeLabels)
+		}
+		if np.UpgradeSettings != nil && to.String(np.UpgradeSettings.MaxSurge) != "" {
+			upstreamNP.UpgradeSettings = &aksv1.AKSUpgradeSettings{
+				MaxSurge: to.String(np.UpgradeSettings.MaxSurge),
+			}
+		}
 		if np.EnableAutoScaling != nil {
 			upstreamNP.EnableAutoScaling = np.EnableAutoScaling
 			upstreamNP.MaxCount = np.MaxCount
@@ -571,21 +1476,33 @@ func BuildUpstreamClusterState(ctx context.Context, secretsCache wranglerv1.Secr
 	// set network configuration
 	networkProfile := clusterState.NetworkProfile
 	if networkProfile != nil {
-		upstreamSpec.NetworkPlugin = to.StringPtr(string(networkProfile.NetworkPlugin))
-		upstreamSpec.NetworkDNSServiceIP = networkProfile.DNSServiceIP
-		upstreamSpec.NetworkDockerBridgeCIDR = networkProfile.DockerBridgeCidr
-		upstreamSpec.NetworkServiceCIDR = networkProfile.ServiceCidr
-		upstreamSpec.NetworkPolicy = to.StringPtr(string(networkProfile.NetworkPolicy))
-		upstreamSpec.NetworkPodCIDR = networkProfile.PodCidr
-		upstreamSpec.LoadBalancerSKU = to.StringPtr(string(networkProfile.LoadBalancerSku))
+		upstreamSpec.NetworkPlugin = stringPtrOrNil(string(networkProfile.NetworkPlugin))
+		upstreamSpec.NetworkDNSServiceIP = stringPtrOrNil(to.String(networkProfile.DNSServiceIP))
+		upstreamSpec.NetworkDockerBridgeCIDR = stringPtrOrNil(to.String(networkProfile.DockerBridgeCidr))
+		upstreamSpec.NetworkServiceCIDR = stringPtrOrNil(to.String(networkProfile.ServiceCidr))
+		upstreamSpec.NetworkPolicy = stringPtrOrNil(string(networkProfile.NetworkPolicy))
+		upstreamSpec.NetworkPodCIDR = stringPtrOrNil(to.String(networkProfile.PodCidr))
+		upstreamSpec.LoadBalancerSKU = stringPtrOrNil(string(networkProfile.LoadBalancerSku))
+		upstreamSpec.OutboundType = stringPtrOrNil(string(networkProfile.OutboundType))
+		upstreamSpec.LoadBalancerProfile = upstreamLoadBalancerProfile(networkProfile.LoadBalancerProfile)
+		if natGateway := networkProfile.NatGatewayProfile; natGateway != nil {
+			upstreamSpec.NATGatewayProfile = &aksv1.AKSNATGatewayProfile{
+				IdleTimeoutInMinutes: natGateway.IdleTimeoutInMinutes,
+			}
+			if natGateway.ManagedOutboundIPProfile != nil {
+				upstreamSpec.NATGatewayProfile.ManagedOutboundIPCount = natGateway.ManagedOutboundIPProfile.Count
+			}
+		}
 	}
 
 	// set linux account profile
 	linuxProfile := clusterState.LinuxProfile
 	if linuxProfile != nil {
 		upstreamSpec.LinuxAdminUsername = linuxProfile.AdminUsername
-		sshKeys := *linuxProfile.SSH.PublicKeys
-		upstreamSpec.LinuxSSHPublicKey = sshKeys[0].KeyData
+		if linuxProfile.SSH != nil && linuxProfile.SSH.PublicKeys != nil && len(*linuxProfile.SSH.PublicKeys) > 0 {
+			sshKeys := *linuxProfile.SSH.PublicKeys
+			upstreamSpec.LinuxSSHPublicKey = sshKeys[0].KeyData
+		}
 	}
 
 	// set addons profile
@@ -594,16 +1511,41 @@ func BuildUpstreamClusterState(ctx context.Context, secretsCache wranglerv1.Secr
 		upstreamSpec.HTTPApplicationRouting = addonProfile["httpApplicationRouting"].Enabled
 	}
 
+	// set addon Key Vault secrets provider profile
+	upstreamSpec.KeyVaultSecretsProvider = aks.UpstreamKeyVaultSecretsProvider(&clusterState)
+
+	// set addon ACI connector profile
+	upstreamSpec.ACIConnector = aks.UpstreamACIConnector(&clusterState)
+
+	// set addon ingress application gateway profile
+	upstreamSpec.IngressApplicationGateway = aks.UpstreamIngressApplicationGateway(&clusterState)
+
+	// set Microsoft Defender profile
+	upstreamSpec.Defender = aks.UpstreamDefender(&clusterState)
+	if workspaceID := to.String(upstreamSpec.Defender.LogAnalyticsWorkspaceID); workspaceID != "" {
+		if match := matchWorkspaceGroup.FindStringSubmatch(workspaceID); len(match) > 1 {
+			upstreamSpec.Defender.LogAnalyticsWorkspaceGroup = to.StringPtr(match[1])
+		}
+		if match := matchWorkspaceName.FindStringSubmatch(workspaceID); len(match) > 1 {
+			upstreamSpec.Defender.LogAnalyticsWorkspaceName = to.StringPtr(match[1])
+		}
+	}
+
 	// set addon monitoring profile
 	if addonProfile["omsagent"] != nil {
 		upstreamSpec.Monitoring = addonProfile["omsagent"].Enabled
-		logAnalyticsWorkspaceResourceID := addonProfile["omsagent"].Config["logAnalyticsWorkspaceResourceID"]
 
-		logAnalyticsWorkspaceGroup := matchWorkspaceGroup.FindStringSubmatch(to.String(logAnalyticsWorkspaceResourceID))[1]
-		upstreamSpec.LogAnalyticsWorkspaceGroup = to.StringPtr(logAnalyticsWorkspaceGroup)
+		// the workspace cannot be derived for clusters using MSI based monitoring, which have no workspace in the
+		// addon config
+		logAnalyticsWorkspaceResourceID := aks.LogAnalyticsWorkspaceID(addonProfile["omsagent"])
+
+		if match := matchWorkspaceGroup.FindStringSubmatch(logAnalyticsWorkspaceResourceID); len(match) > 1 {
+			upstreamSpec.LogAnalyticsWorkspaceGroup = to.StringPtr(match[1])
+		}
 
-		logAnalyticsWorkspaceName := matchWorkspaceName.FindStringSubmatch(to.String(logAnalyticsWorkspaceResourceID))[1]
-		upstreamSpec.LogAnalyticsWorkspaceName = to.StringPtr(logAnalyticsWorkspaceName)
+		if match := matchWorkspaceName.FindStringSubmatch(logAnalyticsWorkspaceResourceID); len(match) > 1 {
+			upstreamSpec.LogAnalyticsWorkspaceName = to.StringPtr(match[1])
+		}
 	}
 
 	// set API server access profile
@@ -615,16 +1557,27 @@ func BuildUpstreamClusterState(ctx context.Context, secretsCache wranglerv1.Secr
 		if clusterState.APIServerAccessProfile.AuthorizedIPRanges != nil {
 			upstreamSpec.AuthorizedIPRanges = clusterState.APIServerAccessProfile.AuthorizedIPRanges
 		}
+		upstreamSpec.PrivateDNSZone = clusterState.APIServerAccessProfile.PrivateDNSZone
+		upstreamSpec.EnablePrivateClusterPublicFQDN = clusterState.APIServerAccessProfile.EnablePrivateClusterPublicFQDN
 	}
 
 	return upstreamSpec, err
 }
 
+// stringPtrOrNil returns nil for empty strings, so that values which are unset upstream compare equal to unset
+// fields in the config spec
+func stringPtrOrNil(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return to.StringPtr(s)
+}
+
 // updateUpstreamClusterState compares the upstream spec with the config spec, then updates the upstream AKS cluster to
 // match the config spec. Function returns after a update is finished.
 func (h *Handler) updateUpstreamClusterState(ctx context.Context, secretsCache wranglerv1.SecretCache,
 	config *aksv1.AKSClusterConfig, upstreamSpec *aksv1.AKSClusterConfigSpec) (*aksv1.AKSClusterConfig, error) {
-	credentials, err := aks.GetSecrets(secretsCache, &config.Spec)
+	credentials, err := h.getCredentials(config)
 	if err != nil {
 		return config, err
 	}
@@ -634,139 +1587,178 @@ func (h *Handler) updateUpstreamClusterState(ctx context.Context, secretsCache w
 		return config, err
 	}
 
-	// check tags for update
-	if config.Spec.Tags != nil {
-		if !reflect.DeepEqual(config.Spec.Tags, upstreamSpec.Tags) {
-			logrus.Infof("Updating tags for cluster [%s]", config.Spec.ClusterName)
-			tags := containerservice.TagsObject{
-				Tags: *to.StringMapPtr(config.Spec.Tags),
-			}
-			_, err = resourceClusterClient.UpdateTags(ctx, config.Spec.ResourceGroup, config.Spec.ClusterName, tags)
-			if err != nil {
-				return config, err
-			}
-			return h.enqueueUpdate(config)
-		}
+	// node pools are compared with the defaults they inherit
+	spec := withNodePoolDefaults(&config.Spec)
+	if errs := append(validateUnmanagedFields(spec), validateNodePools(spec)...); len(errs) > 0 {
+		return config, invalidSpecError{merr.NewErrors(errs...)}
+	}
+	// Azure does not allow enabling or disabling Kubernetes RBAC on an existing cluster
+	if spec.EnableRBAC != nil && upstreamSpec.EnableRBAC != nil && *spec.EnableRBAC != *upstreamSpec.EnableRBAC {
+		return config, invalidSpecError{fmt.Errorf("field [enableRbac] cannot be changed for cluster [%s] after it is created", spec.ClusterName)}
+	}
+	// Azure does not allow changing the outbound type of an existing cluster
+	if spec.OutboundType != nil && !strings.EqualFold(*spec.OutboundType, outboundType(upstreamSpec)) {
+		return config, invalidSpecError{fmt.Errorf("field [outboundType] cannot be changed from [%s] to [%s] for cluster [%s] after it is created",
+			outboundType(upstreamSpec), *spec.OutboundType, spec.ClusterName)}
+	}
+	// Azure does not allow changing the kubelet identity of an existing cluster
+	if spec.KubeletIdentity != nil && (upstreamSpec.KubeletIdentity == nil ||
+		!strings.EqualFold(to.String(spec.KubeletIdentity.ResourceID), to.String(upstreamSpec.KubeletIdentity.ResourceID))) {
+		return config, invalidSpecError{fmt.Errorf("field [kubeletIdentity] cannot be changed for cluster [%s] after it is created", spec.ClusterName)}
+	}
+	// Azure does not allow changing the private DNS zone or the public FQDN of an existing private cluster
+	if spec.PrivateDNSZone != nil && !strings.EqualFold(*spec.PrivateDNSZone, to.String(upstreamSpec.PrivateDNSZone)) {
+		return config, invalidSpecError{fmt.Errorf("field [privateDnsZone] cannot be changed from [%s] to [%s] for cluster [%s] after it is created",
+			to.String(upstreamSpec.PrivateDNSZone), *spec.PrivateDNSZone, spec.ClusterName)}
+	}
+	if spec.EnablePrivateClusterPublicFQDN != nil && *spec.EnablePrivateClusterPublicFQDN != to.Bool(upstreamSpec.EnablePrivateClusterPublicFQDN) {
+		return config, invalidSpecError{fmt.Errorf("field [enablePrivateClusterPublicFqdn] cannot be changed for cluster [%s] after it is created", spec.ClusterName)}
+	}
+	// fields listed in unmanagedFields take their upstream values and are never updated
+	spec = applyUnmanagedFields(spec, upstreamSpec)
+	applyFollowClusterVersion(spec, upstreamSpec)
+	// the operator egress IP is part of the authorized IP ranges sent to Azure, so it does not show as drift
+	spec, err = h.withEgressIP(ctx, spec)
+	if err != nil {
+		return config, err
+	}
+	// Azure rejects invalid upgrades only after the cluster has entered updating, so versions are checked first
+	upstreamNodePools, _ := utils.BuildNodePoolMap(upstreamSpec.NodePools, spec.ClusterName)
+	if err := validateVersionSkew(ctx, resourceClusterClient, spec, upstreamSpec, upstreamNodePools); err != nil {
+		return config, err
 	}
 
-	if config.Spec.NodePools != nil {
-		agentPoolClient, err := aks.NewAgentPoolClient(credentials)
+	plan, err := planClusterUpdate(config, spec, upstreamSpec, upstreamNodePools)
+	if err != nil {
+		return config, err
+	}
+	if dryRunRequested(config) {
+		return h.recordPlannedChanges(config, plan.changes)
+	}
+	// the changes planned by a dry run are cleared before they are applied
+	if config.Status.PlannedChanges != nil {
+		config = config.DeepCopy()
+		config.Status.PlannedChanges = nil
+		return h.updateStatus(config)
+	}
+	if len(plan.changes) > 0 {
+		logrus.Infof("Changes pending for cluster [%s]: %s", spec.ClusterName, strings.Join(plan.changes, "; "))
+	}
+
+	if plan.updateTags {
+		tags := containerservice.TagsObject{
+			Tags: *to.StringMapPtr(withOwnerTag(desiredTags(spec, upstreamSpec, config.Status.AppliedTags), config)),
+		}
+		_, err = resourceClusterClient.UpdateTags(ctx, spec.ResourceGroup, spec.ClusterName, tags)
 		if err != nil {
 			return config, err
 		}
+		h.recorder.Event(config, v1.EventTypeNormal, eventReasonTagsUpdate, "Updating cluster tags")
+		config = config.DeepCopy()
+		config.Status.AppliedTags = appliedTags(spec)
+		return h.enqueueUpdate(config)
+	}
 
-		downstreamNodePools, err := utils.BuildNodePoolMap(config.Spec.NodePools, config.Spec.ClusterName)
+	if plan.recreateNodePool != nil || len(plan.nodePoolChanges) > 0 || len(plan.nodePoolUpgrades) > 0 || len(plan.removedNodePools) > 0 {
+		agentPoolClient, err := aks.NewAgentPoolClient(credentials)
 		if err != nil {
 			return config, err
 		}
 
-		// check for updated NodePools
-		upstreamNodePools, _ := utils.BuildNodePoolMap(upstreamSpec.NodePools, config.Spec.ClusterName)
-		updateNodePool := false
-		for npName, np := range downstreamNodePools {
-			upstreamNodePool, ok := upstreamNodePools[npName]
-			if ok {
-				// There is a matching node pool in the cluster already, so update it if needed
-				if to.Int32(np.Count) != to.Int32(upstreamNodePool.Count) {
-					logrus.Infof("Updating node count in node pool [%s] for cluster [%s]", to.String(np.Name), config.Spec.ClusterName)
-					updateNodePool = true
-				}
-				if np.EnableAutoScaling != nil && to.Bool(np.EnableAutoScaling) != to.Bool(upstreamNodePool.EnableAutoScaling) {
-					logrus.Infof("Updating autoscaling in node pool [%s] for cluster [%s]", to.String(np.Name), config.Spec.ClusterName)
-					updateNodePool = true
-				}
-				if np.OrchestratorVersion != nil && to.String(np.OrchestratorVersion) != to.String(upstreamNodePool.OrchestratorVersion) {
-					logrus.Infof("Updating orchestrator version in node pool [%s] for cluster [%s]", to.String(np.Name), config.Spec.ClusterName)
-					updateNodePool = true
-				}
-			} else {
-				logrus.Infof("Adding node pool [%s] for cluster [%s]", to.String(np.Name), config.Spec.ClusterName)
-				updateNodePool = true
-			}
-
-			if updateNodePool {
-				err = aks.CreateOrUpdateAgentPool(ctx, agentPoolClient, &config.Spec, np)
-				if err != nil {
-					return config, fmt.Errorf("failed to update cluster: %v", err)
-				}
-				return h.enqueueUpdate(config)
-			}
-		}
-
-		// check for removed NodePools
-		for npName := range upstreamNodePools {
-			if _, ok := downstreamNodePools[npName]; !ok {
-				logrus.Infof("Removing node pool [%s] from cluster [%s]", npName, config.Spec.ClusterName)
-				err = aks.RemoveAgentPool(ctx, agentPoolClient, &config.Spec, upstreamNodePools[npName])
-				if err != nil {
-					return config, fmt.Errorf("failed to remove node pool: %v", err)
-				}
-				return h.enqueueUpdate(config)
-			}
-		}
-	}
-
-	updateAksCluster := false
-	// check Kubernetes version for update
-	if config.Spec.KubernetesVersion != nil {
-		if to.String(config.Spec.KubernetesVersion) != to.String(upstreamSpec.KubernetesVersion) {
-			logrus.Infof("Updating kubernetes version for cluster [%s]", config.Spec.ClusterName)
-			updateAksCluster = true
+		switch {
+		case plan.recreateNodePool != nil:
+			return h.recreateNodePool(ctx, agentPoolClient, config, spec, plan.recreateNodePool, upstreamNodePools)
+		case len(plan.nodePoolChanges) > 0:
+			return h.updateNodePools(ctx, agentPoolClient, config, spec, plan.nodePoolChanges, upstreamNodePools)
+		case len(plan.nodePoolUpgrades) > 0:
+			// orchestrator version upgrades are rolled out once the control plane has been upgraded
+			return h.upgradeNodePools(ctx, agentPoolClient, config, spec, upstreamNodePools, plan.nodePoolUpgrades)
+		default:
+			return h.removeNodePools(ctx, agentPoolClient, config, spec, plan.removedNodePools)
 		}
 	}
 
-	// check authorized IP ranges to access AKS
-	if config.Spec.AuthorizedIPRanges != nil {
-		if !reflect.DeepEqual(config.Spec.AuthorizedIPRanges, upstreamSpec.AuthorizedIPRanges) {
-			logrus.Infof("Updating authorized IP ranges for cluster [%s]", config.Spec.ClusterName)
-			updateAksCluster = true
+	if plan.clusterUpdate != nil {
+		resourceGroupsClient, err := aks.NewResourceGroupClient(credentials)
+		if err != nil {
+			return config, err
 		}
-	}
 
-	// check addon HTTP Application Routing
-	if config.Spec.HTTPApplicationRouting != nil {
-		if to.Bool(config.Spec.HTTPApplicationRouting) != to.Bool(upstreamSpec.HTTPApplicationRouting) {
-			logrus.Infof("Updating HTTP application routing for cluster [%s]", config.Spec.ClusterName)
+		exists, err := aks.ExistsResourceGroup(ctx, resourceGroupsClient, spec.ResourceGroup)
+		if err != nil {
+			return config, fmt.Errorf("error checking if resource group [%s] exists: %w", spec.ResourceGroup, err)
 		}
-	}
-
-	// check addon monitoring
-	if config.Spec.Monitoring != nil {
-		if to.Bool(config.Spec.Monitoring) != to.Bool(upstreamSpec.Monitoring) {
-			logrus.Infof("Updating monitoring addon for cluster [%s]", config.Spec.ClusterName)
-			updateAksCluster = true
+		if !exists {
+			logrus.Infof("Resource group [%s] does not exist, creating", spec.ResourceGroup)
+			if err = aks.CreateResourceGroup(ctx, resourceGroupsClient, spec); err != nil {
+				return config, fmt.Errorf("error during updating resource group %w", err)
+			}
+			logrus.Infof("Resource group [%s] updated successfully", spec.ResourceGroup)
 		}
-	}
 
-	if updateAksCluster {
-		resourceGroupsClient, err := aks.NewResourceGroupClient(credentials)
+		proxyTrustedCA, err := httpProxyTrustedCA(h.secretsCache, spec)
 		if err != nil {
 			return config, err
 		}
 
-		if !aks.ExistsResourceGroup(ctx, resourceGroupsClient, config.Spec.ResourceGroup) {
-			logrus.Infof("Resource group [%s] does not exist, creating", config.Spec.ResourceGroup)
-			if err = aks.CreateResourceGroup(ctx, resourceGroupsClient, &config.Spec); err != nil {
-				return config, fmt.Errorf("error during updating resource group %v", err)
-			}
-			logrus.Infof("Resource group [%s] updated successfully", config.Spec.ResourceGroup)
-		}
-
-		err = aks.CreateOrUpdateCluster(ctx, credentials, resourceClusterClient, &config.Spec)
+		workspace, err := aks.UpdateCluster(ctx, credentials, resourceClusterClient, plan.clusterUpdate, proxyTrustedCA)
 		if err != nil {
-			return config, fmt.Errorf("failed to update cluster: %v", err)
+			return config, fmt.Errorf("failed to update cluster: %w", err)
+		}
+		config = config.DeepCopy()
+		if workspace != nil {
+			setLogAnalyticsWorkspaceStatus(&config.Status, workspace.ID, workspace.Created)
+		}
+		config.Status.AutoScalerProfileSettings = appliedAutoScalerProfileSettings(spec)
+		config.Status.AddonChanges = plan.addons
+		if plan.upgradeControlPlane {
+			config.Status.UpgradeStage = upgradeStageControlPlane
+			config.Status.UpgradingNodePools = nil
 		}
 		return h.enqueueUpdate(config)
 	}
 
+	// registries are attached once the cluster no longer needs updates, the kubelet identity exists by then
+	config, err = h.syncAttachedACRs(ctx, credentials, config)
+	if err != nil {
+		return config, err
+	}
+
 	// no new updates, set to active
 	if config.Status.Phase != aksConfigActivePhase {
 		logrus.Infof("Cluster [%s] finished updating", config.Name)
 		config = config.DeepCopy()
 		config.Status.Phase = aksConfigActivePhase
-		return h.aksCC.UpdateStatus(config)
+		config.Status.LastSyncTime = v15.Now()
+		config.Status.AddonChanges = nil
+		config.Status.UpdatingNodePools = nil
+		if config.Status.NodePoolUpgradeProgress != "" {
+			h.recorder.Event(config, v1.EventTypeNormal, eventReasonNodePoolUpgrade, "Node pool upgrade finished")
+			config.Status.NodePoolUpgradeProgress = ""
+		}
+		config.Status.UpgradeStage = ""
+		config.Status.UpgradingNodePools = nil
+		setReadyConditions(config)
+		return h.updateStatus(config)
+	}
+
+	// conditions are refreshed for new generations of the config which needed no update
+	if updated := config.DeepCopy(); setReadyConditions(updated) {
+		return h.updateStatus(updated)
 	}
 
-	logrus.Infof("Configuration for cluster [%s] was verified", config.Spec.ClusterName)
+	logrus.Infof("Configuration for cluster [%s] was verified", spec.ClusterName)
+	if period := h.clusterDriftSyncPeriod(config); period > 0 {
+		h.aksEnqueueAfter(config.Namespace, config.Name, period)
+	}
+	// the tags are in sync, configs created before the applied tags were recorded record them here
+	applied := appliedTags(spec)
+	appliedChanged := len(applied) != len(config.Status.AppliedTags) || (len(applied) > 0 && !reflect.DeepEqual(applied, config.Status.AppliedTags))
+	if appliedChanged || time.Since(config.Status.LastSyncTime.Time) > lastSyncTimeInterval {
+		config = config.DeepCopy()
+		config.Status.AppliedTags = applied
+		config.Status.LastSyncTime = v15.Now()
+		return h.updateStatus(config)
+	}
 	return config, err
 }
@@ -0,0 +1,292 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/to"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// validNodePool returns a node pool which passes validateSpec
+func validNodePool(name string) aksv1.AKSNodePool {
+	return aksv1.AKSNodePool{
+		Name:         to.StringPtr(name),
+		Count:        to.Int32Ptr(1),
+		MaxPods:      to.Int32Ptr(110),
+		VMSize:       "Standard_DS2_v2",
+		OsDiskSizeGB: to.Int32Ptr(128),
+		OsDiskType:   "Managed",
+		Mode:         "System",
+		OsType:       "Linux",
+	}
+}
+
+func TestValidateSpecReportsAllErrors(t *testing.T) {
+	userPool := validNodePool("user")
+	userPool.Mode = "User"
+	userPool.Count = nil
+	otherUserPool := validNodePool("other")
+	otherUserPool.Mode = "User"
+	otherUserPool.Count = nil
+
+	config := &aksv1.AKSClusterConfig{Spec: aksv1.AKSClusterConfigSpec{
+		ClusterName:           "cluster",
+		AzureCredentialSecret: testSecretName,
+		NodePools:             []aksv1.AKSNodePool{userPool, otherUserPool},
+	}}
+	errs := validateSpec(config)
+
+	want := []string{
+		"field [resourceLocation] must be provided",
+		"field [resourceGroup] must be provided",
+		"field [kubernetesVersion] must be provided",
+		"field [NodePool.Count] must be provided",
+		"at least one NodePool with mode System is required",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %d: %v", len(want), len(errs), errs)
+	}
+	for i, err := range errs {
+		if !strings.Contains(err.Error(), want[i]) {
+			t.Errorf("expected error %d to contain %q, got %q", i, want[i], err)
+		}
+	}
+}
+
+func TestValidateSpecImported(t *testing.T) {
+	config := &aksv1.AKSClusterConfig{Spec: aksv1.AKSClusterConfigSpec{
+		Imported:              true,
+		ClusterName:           "cluster",
+		ResourceGroup:         "rg",
+		ResourceLocation:      "eastus",
+		AzureCredentialSecret: testSecretName,
+	}}
+	if errs := validateSpec(config); len(errs) != 0 {
+		t.Errorf("expected an imported config to only need its cluster reference, got %v", errs)
+	}
+}
+
+func TestValidateConfigSpecReportsAllErrors(t *testing.T) {
+	th := newTestHandler(t)
+	existing := th.newTestConfig()
+	existing.Name = "c-existing"
+	th.client.configs[existing.Namespace+"/"+existing.Name] = existing
+
+	config := th.newTestConfig()
+	config.Spec.KubernetesVersion = to.StringPtr("1.23.5")
+	pool := validNodePool("pool")
+	pool.UpgradeSettings = &aksv1.AKSUpgradeSettings{MaxSurge: "0"}
+	config.Spec.NodePools = []aksv1.AKSNodePool{pool}
+	config.Spec.AuthorizedIPRanges = &[]string{"not-an-ip"}
+
+	errs := th.validateConfigSpec(config)
+	want := []string{
+		"an AKSClusterConfig exists with the same name",
+		"not-an-ip",
+		"invalid maxSurge [0]",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %d: %v", len(want), len(errs), errs)
+	}
+	for i, err := range errs {
+		if !strings.Contains(err.Error(), want[i]) {
+			t.Errorf("expected error %d to contain %q, got %q", i, want[i], err)
+		}
+	}
+}
+
+func TestValidateSpecNodePoolNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		pools   []string
+		osType  string
+		wantErr string
+	}{
+		{
+			name:  "Linux name at the length limit",
+			pools: []string{"workerspool1"},
+		},
+		{
+			name:  "similarly prefixed names",
+			pools: []string{"workerspool1", "workerspool2"},
+		},
+		{
+			name:    "Linux name over the length limit",
+			pools:   []string{"workerspool12"},
+			wantErr: "node pool name [workerspool12] for cluster [cluster] config must not be longer than 12 characters",
+		},
+		{
+			name:    "Windows name over the length limit",
+			pools:   []string{"winpool"},
+			osType:  "Windows",
+			wantErr: "node pool name [winpool] for cluster [cluster] config must not be longer than 6 characters",
+		},
+		{
+			name:    "uppercase letters",
+			pools:   []string{"Pool"},
+			wantErr: "node pool name [Pool] for cluster [cluster] config must start with a lowercase letter",
+		},
+		{
+			name:    "leading digit",
+			pools:   []string{"1pool"},
+			wantErr: "node pool name [1pool] for cluster [cluster] config must start with a lowercase letter",
+		},
+		{
+			name:    "duplicate names",
+			pools:   []string{"pool", "pool"},
+			wantErr: "cluster [cluster] cannot have multiple nodepools with name pool",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &aksv1.AKSClusterConfig{Spec: aksv1.AKSClusterConfigSpec{
+				ClusterName:           "cluster",
+				ResourceGroup:         "rg",
+				ResourceLocation:      "eastus",
+				KubernetesVersion:     to.StringPtr("1.23.5"),
+				AzureCredentialSecret: testSecretName,
+			}}
+			for _, name := range tt.pools {
+				np := validNodePool(name)
+				if tt.osType != "" {
+					np.OsType = tt.osType
+				}
+				config.Spec.NodePools = append(config.Spec.NodePools, np)
+			}
+
+			errs := validateSpec(config)
+			if tt.wantErr == "" {
+				if len(errs) != 0 {
+					t.Errorf("expected no errors, got %v", errs)
+				}
+				return
+			}
+			if len(errs) == 0 || !strings.Contains(errs[0].Error(), tt.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, errs)
+			}
+		})
+	}
+}
+
+func TestValidateSpecFeatureSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(spec *aksv1.AKSClusterConfigSpec)
+		wantErr string
+	}{
+		{
+			name:    "workload identity without OIDC issuer",
+			change:  func(spec *aksv1.AKSClusterConfigSpec) { spec.WorkloadIdentityEnabled = to.BoolPtr(true) },
+			wantErr: "workload identity for cluster [cluster] config requires the OIDC issuer to be enabled",
+		},
+		{
+			name: "user-assigned identity ID with system-assigned identity",
+			change: func(spec *aksv1.AKSClusterConfigSpec) {
+				spec.Identity = &aksv1.AKSClusterIdentity{Type: "SystemAssigned", UserAssignedIdentityID: to.StringPtr("identity")}
+			},
+			wantErr: "identity for cluster [cluster] config cannot set userAssignedIdentityId with type SystemAssigned",
+		},
+		{
+			name:    "unknown identity type",
+			change:  func(spec *aksv1.AKSClusterConfigSpec) { spec.Identity = &aksv1.AKSClusterIdentity{Type: "None"} },
+			wantErr: "identity for cluster [cluster] config has invalid type [None], must be SystemAssigned or UserAssigned",
+		},
+		{
+			name: "kubelet identity without client and object IDs",
+			change: func(spec *aksv1.AKSClusterConfigSpec) {
+				identityID := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/identity"
+				spec.Identity = &aksv1.AKSClusterIdentity{Type: "UserAssigned", UserAssignedIdentityID: to.StringPtr(identityID)}
+				spec.KubeletIdentity = &aksv1.AKSKubeletIdentity{ResourceID: to.StringPtr(identityID)}
+			},
+			wantErr: "kubelet identity for cluster [cluster] config requires clientId and objectId",
+		},
+		{
+			name:    "unknown upgrade channel",
+			change:  func(spec *aksv1.AKSClusterConfigSpec) { spec.UpgradeChannel = to.StringPtr("weekly") },
+			wantErr: "cluster [cluster] config has invalid upgradeChannel [weekly], must be none, patch, stable, rapid or node-image",
+		},
+		{
+			name: "invalid Key Vault rotation poll interval",
+			change: func(spec *aksv1.AKSClusterConfigSpec) {
+				spec.KeyVaultSecretsProvider = &aksv1.AKSKeyVaultSecretsProvider{Enabled: true, RotationPollInterval: to.StringPtr("soon")}
+			},
+			wantErr: "keyVaultSecretsProvider for cluster [cluster] config has invalid rotationPollInterval [soon], must be a duration like 2m",
+		},
+		{
+			name: "application gateway without gateway ID or subnet",
+			change: func(spec *aksv1.AKSClusterConfigSpec) {
+				spec.IngressApplicationGateway = &aksv1.AKSIngressApplicationGateway{Enabled: true}
+			},
+			wantErr: "ingressApplicationGateway for cluster [cluster] config requires exactly one of gatewayId or subnetCidr",
+		},
+		{
+			name: "application gateway with invalid subnet",
+			change: func(spec *aksv1.AKSClusterConfigSpec) {
+				spec.IngressApplicationGateway = &aksv1.AKSIngressApplicationGateway{Enabled: true, SubnetCIDR: to.StringPtr("10.0.0.0")}
+			},
+			wantErr: "ingressApplicationGateway for cluster [cluster] config has invalid subnetCidr [10.0.0.0]",
+		},
+		{
+			name: "Defender with workspace ID and name",
+			change: func(spec *aksv1.AKSClusterConfigSpec) {
+				spec.Defender = &aksv1.AKSDefender{
+					Enabled:                   true,
+					LogAnalyticsWorkspaceID:   to.StringPtr("workspace-id"),
+					LogAnalyticsWorkspaceName: to.StringPtr("workspace"),
+				}
+			},
+			wantErr: "defender for cluster [cluster] config can either set logAnalyticsWorkspaceId or logAnalyticsWorkspaceGroup and logAnalyticsWorkspaceName",
+		},
+		{
+			name:    "private DNS zone of a public cluster",
+			change:  func(spec *aksv1.AKSClusterConfigSpec) { spec.PrivateDNSZone = to.StringPtr("system") },
+			wantErr: "privateDnsZone and enablePrivateClusterPublicFqdn for cluster [cluster] config require privateCluster",
+		},
+		{
+			name: "private DNS zone of the user without user-assigned identity",
+			change: func(spec *aksv1.AKSClusterConfigSpec) {
+				spec.PrivateCluster = to.BoolPtr(true)
+				spec.PrivateDNSZone = to.StringPtr("/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/privateDnsZones/privatelink.eastus.azmk8s.io")
+			},
+			wantErr: "privateDnsZone [/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/privateDnsZones/privatelink.eastus.azmk8s.io] for cluster [cluster] config requires a UserAssigned cluster identity",
+		},
+		{
+			name:    "unknown tier",
+			change:  func(spec *aksv1.AKSClusterConfigSpec) { spec.Tier = to.StringPtr("Gold") },
+			wantErr: "cluster [cluster] config has invalid tier [Gold], must be Free or Standard",
+		},
+		{
+			name: "ACI connector with kubenet",
+			change: func(spec *aksv1.AKSClusterConfigSpec) {
+				spec.NetworkPlugin = to.StringPtr("kubenet")
+				spec.ACIConnector = &aksv1.AKSACIConnector{Enabled: true, SubnetName: to.StringPtr("aci")}
+			},
+			wantErr: "aciConnector for cluster [cluster] config requires networkPlugin azure",
+		},
+		{
+			name:    "forced resource group deletion without deletion",
+			change:  func(spec *aksv1.AKSClusterConfigSpec) { spec.ForceDeleteResourceGroup = to.BoolPtr(true) },
+			wantErr: "forceDeleteResourceGroup for cluster [cluster] config requires deleteResourceGroupOnRemove",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &aksv1.AKSClusterConfig{Spec: aksv1.AKSClusterConfigSpec{
+				ClusterName:           "cluster",
+				ResourceGroup:         "rg",
+				ResourceLocation:      "eastus",
+				AzureCredentialSecret: testSecretName,
+				KubernetesVersion:     to.StringPtr("1.23.5"),
+				NodePools:             []aksv1.AKSNodePool{validNodePool("pool")},
+			}}
+			tt.change(&config.Spec)
+
+			errs := validateSpec(config)
+			if len(errs) != 1 || errs[0].Error() != tt.wantErr {
+				t.Errorf("expected the error %q, got %v", tt.wantErr, errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// maxAuthorizedIPRanges is the maximum number of authorized IP ranges of an AKS API server
+const maxAuthorizedIPRanges = 200
+
+// validateAuthorizedIPRanges returns an error for every authorized IP range which is not an IPv4 address or CIDR, and
+// if there are more ranges than Azure allows or the cluster is private
+func validateAuthorizedIPRanges(spec *aksv1.AKSClusterConfigSpec) []error {
+	if spec.AuthorizedIPRanges == nil {
+		return nil
+	}
+	ipRanges := *spec.AuthorizedIPRanges
+
+	var errs []error
+	if len(ipRanges) > maxAuthorizedIPRanges {
+		errs = append(errs, fmt.Errorf("field [authorizedIpRanges] for cluster [%s] config has %d ranges, at most %d are allowed",
+			spec.ClusterName, len(ipRanges), maxAuthorizedIPRanges))
+	}
+	if len(ipRanges) > 0 && to.Bool(spec.PrivateCluster) {
+		errs = append(errs, fmt.Errorf("field [authorizedIpRanges] for cluster [%s] config cannot be set for private clusters", spec.ClusterName))
+	}
+	for _, ipRange := range ipRanges {
+		if normalizeIPRange(ipRange) == "" {
+			errs = append(errs, fmt.Errorf("authorized IP range [%s] for cluster [%s] config is not a valid IPv4 address or CIDR",
+				ipRange, spec.ClusterName))
+		}
+	}
+	return errs
+}
+
+// authorizedIPRangesChanged returns true if the authorized IP ranges of spec differ from the upstream ones. An empty
+// list clears the upstream ranges, nil leaves them unmanaged. Ranges are compared regardless of their order, an
+// address is the same range as its /32 CIDR.
+func authorizedIPRangesChanged(spec, upstreamSpec *aksv1.AKSClusterConfigSpec) bool {
+	if spec.AuthorizedIPRanges == nil {
+		return false
+	}
+	desired := normalizeIPRanges(*spec.AuthorizedIPRanges)
+	var upstream []string
+	if upstreamSpec.AuthorizedIPRanges != nil {
+		upstream = normalizeIPRanges(*upstreamSpec.AuthorizedIPRanges)
+	}
+	return strings.Join(desired, ",") != strings.Join(upstream, ",")
+}
+
+// normalizeIPRanges returns the sorted CIDRs of ipRanges, invalid ranges are kept as they are
+func normalizeIPRanges(ipRanges []string) []string {
+	normalized := make([]string, 0, len(ipRanges))
+	for _, ipRange := range ipRanges {
+		if cidr := normalizeIPRange(ipRange); cidr != "" {
+			normalized = append(normalized, cidr)
+		} else {
+			normalized = append(normalized, ipRange)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// normalizeIPRange returns the IPv4 CIDR of ipRange, an address is returned as its /32 CIDR. Returns an empty string
+// if ipRange is not an IPv4 address or CIDR.
+func normalizeIPRange(ipRange string) string {
+	if ip := net.ParseIP(ipRange); ip != nil {
+		if ip.To4() == nil {
+			return ""
+		}
+		return ip.To4().String() + "/32"
+	}
+	ip, ipNet, err := net.ParseCIDR(ipRange)
+	if err != nil || ip.To4() == nil {
+		return ""
+	}
+	ones, _ := ipNet.Mask.Size()
+	return fmt.Sprintf("%s/%d", ip.To4().String(), ones)
+}
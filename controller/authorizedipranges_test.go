@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/to"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+func TestNormalizeIPRange(t *testing.T) {
+	tests := []struct {
+		ipRange string
+		want    string
+	}{
+		{"10.0.0.1", "10.0.0.1/32"},
+		{"10.0.0.0/8", "10.0.0.0/8"},
+		{"10.1.2.3/8", "10.1.2.3/8"},
+		{"::ffff:10.0.0.1", "10.0.0.1/32"},
+		{"2001:db8::1", ""},
+		{"2001:db8::/32", ""},
+		{"10.0.0.0/33", ""},
+		{"10.0.0", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeIPRange(tt.ipRange); got != tt.want {
+			t.Errorf("normalizeIPRange(%q) = %q, want %q", tt.ipRange, got, tt.want)
+		}
+	}
+}
+
+func TestAuthorizedIPRangesChanged(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     *[]string
+		upstream *[]string
+		want     bool
+	}{
+		{"unmanaged", nil, &[]string{"10.0.0.0/8"}, false},
+		{"equal", &[]string{"10.0.0.0/8"}, &[]string{"10.0.0.0/8"}, false},
+		{"different order", &[]string{"10.0.0.0/8", "192.168.0.1/32"}, &[]string{"192.168.0.1/32", "10.0.0.0/8"}, false},
+		{"address and its /32 CIDR", &[]string{"192.168.0.1"}, &[]string{"192.168.0.1/32"}, false},
+		{"cleared", &[]string{}, &[]string{"10.0.0.0/8"}, true},
+		{"cleared without upstream ranges", &[]string{}, nil, false},
+		{"added", &[]string{"10.0.0.0/8"}, nil, true},
+		{"changed", &[]string{"10.0.0.0/16"}, &[]string{"10.0.0.0/8"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &aksv1.AKSClusterConfigSpec{AuthorizedIPRanges: tt.spec}
+			upstreamSpec := &aksv1.AKSClusterConfigSpec{AuthorizedIPRanges: tt.upstream}
+			if got := authorizedIPRangesChanged(spec, upstreamSpec); got != tt.want {
+				t.Errorf("authorizedIPRangesChanged() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateAuthorizedIPRanges(t *testing.T) {
+	tooMany := make([]string, maxAuthorizedIPRanges+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+	}
+	tests := []struct {
+		name           string
+		ipRanges       *[]string
+		privateCluster bool
+		wantErrs       []string
+	}{
+		{name: "unmanaged"},
+		{name: "cleared", ipRanges: &[]string{}},
+		{name: "cleared on a private cluster", ipRanges: &[]string{}, privateCluster: true},
+		{name: "valid", ipRanges: &[]string{"10.0.0.1", "192.168.0.0/16"}},
+		{
+			name:     "invalid ranges",
+			ipRanges: &[]string{"10.0.0.1", "2001:db8::/32", "not-an-ip"},
+			wantErrs: []string{"[2001:db8::/32]", "[not-an-ip]"},
+		},
+		{
+			name:           "private cluster",
+			ipRanges:       &[]string{"10.0.0.1"},
+			privateCluster: true,
+			wantErrs:       []string{"cannot be set for private clusters"},
+		},
+		{
+			name:     "too many ranges",
+			ipRanges: &tooMany,
+			wantErrs: []string{fmt.Sprintf("has %d ranges, at most %d are allowed", maxAuthorizedIPRanges+1, maxAuthorizedIPRanges)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := validateAuthorizedIPRanges(&aksv1.AKSClusterConfigSpec{
+				ClusterName:        "cluster",
+				AuthorizedIPRanges: tt.ipRanges,
+				PrivateCluster:     to.BoolPtr(tt.privateCluster),
+			})
+			if len(errs) != len(tt.wantErrs) {
+				t.Fatalf("expected %d errors, got %v", len(tt.wantErrs), errs)
+			}
+			for i, err := range errs {
+				if !strings.Contains(err.Error(), tt.wantErrs[i]) {
+					t.Errorf("expected error %d to contain %q, got %q", i, tt.wantErrs[i], err)
+				}
+			}
+		})
+	}
+}
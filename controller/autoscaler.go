@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	"github.com/rancher/aks-operator/pkg/aks"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// validateAutoScalerProfile rejects unknown autoscaler settings and expanders
+func validateAutoScalerProfile(spec *aksv1.AKSClusterConfigSpec) []error {
+	supported := map[string]bool{}
+	for _, setting := range aks.AutoScalerProfileSettings {
+		supported[setting] = true
+	}
+
+	var errs []error
+	for _, setting := range sortedKeys(spec.AutoScalerProfile) {
+		if !supported[setting] {
+			errs = append(errs, fmt.Errorf("unknown setting [%s] in autoScalerProfile for cluster [%s] config, supported settings are %s",
+				setting, spec.ClusterName, strings.Join(aks.AutoScalerProfileSettings, ", ")))
+		}
+	}
+
+	if expander, ok := spec.AutoScalerProfile["expander"]; ok {
+		switch expander {
+		case string(containerservice.ExpanderLeastWaste), string(containerservice.ExpanderMostPods), string(containerservice.ExpanderPriority),
+			string(containerservice.ExpanderRandom):
+		default:
+			errs = append(errs, fmt.Errorf("autoScalerProfile for cluster [%s] config has invalid expander [%s], must be least-waste, most-pods, priority or random",
+				spec.ClusterName, expander))
+		}
+	}
+	return errs
+}
+
+// autoScalerProfileChanged returns true if a setting of the spec differs from the upstream profile, or if a setting
+// applied before has been removed from the spec and must be reset to its Azure default
+func autoScalerProfileChanged(spec, upstreamSpec *aksv1.AKSClusterConfigSpec, applied []string) bool {
+	for setting, value := range spec.AutoScalerProfile {
+		if upstreamSpec.AutoScalerProfile[setting] != value {
+			return true
+		}
+	}
+	for _, setting := range applied {
+		if _, ok := spec.AutoScalerProfile[setting]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// appliedAutoScalerProfileSettings returns the autoscaler settings sent to Azure for the spec
+func appliedAutoScalerProfileSettings(spec *aksv1.AKSClusterConfigSpec) []string {
+	if isUnmanaged(spec, unmanagedAutoScalerProfile) {
+		return nil
+	}
+	return sortedKeys(spec.AutoScalerProfile)
+}
+
+func sortedKeys(m map[string]string) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/rancher/aks-operator/pkg/aks"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	"github.com/rancher/wrangler/pkg/generic"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// removeCreatedResources deletes the Log Analytics workspace and the resource group the operator created for the
+// removed cluster, if the spec asks for it. Resources which are kept are reported with a warning event.
+func (h *Handler) removeCreatedResources(ctx context.Context, credentials *aks.Credentials, config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
+	config, err := h.removeCreatedWorkspace(ctx, credentials, config)
+	if err != nil {
+		return config, err
+	}
+	return h.removeCreatedResourceGroup(ctx, credentials, config)
+}
+
+// removeCreatedWorkspace deletes the Log Analytics workspace of the cluster if deleteWorkspaceOnRemove is set and the
+// operator created it. The workspace is kept while other clusters are wired to it. The started deletion is recorded in
+// status and the config is polled until the workspace is gone, then the workspace is removed from status so that a
+// retried removal does not delete it again.
+func (h *Handler) removeCreatedWorkspace(ctx context.Context, credentials *aks.Credentials, config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
+	workspaceID := config.Status.LogAnalyticsWorkspaceID
+	if !to.Bool(config.Spec.DeleteWorkspaceOnRemove) || !config.Status.LogAnalyticsWorkspaceCreated || workspaceID == "" {
+		return config, nil
+	}
+
+	workspacesClient, err := aks.NewOperationInsightsWorkspaceClient(credentials)
+	if err != nil {
+		return config, err
+	}
+
+	if !config.Status.LogAnalyticsWorkspaceDeleting {
+		users, err := h.otherConfigs(config, func(other *aksv1.AKSClusterConfig) bool {
+			return strings.EqualFold(other.Status.LogAnalyticsWorkspaceID, workspaceID)
+		})
+		if err != nil {
+			return config, err
+		}
+		if len(users) > 0 {
+			logrus.Warnf("Log Analytics workspace [%s] of cluster [%s] is used by [%s], will not delete it", workspaceID, config.Name, strings.Join(users, ", "))
+			h.recorder.Eventf(config, v1.EventTypeWarning, warningReasonWorkspaceRetained,
+				"Log Analytics workspace [%s] is kept, it is used by [%s]", workspaceID, strings.Join(users, ", "))
+			return config, nil
+		}
+
+		logrus.Infof("Removing Log Analytics workspace [%s] of cluster [%s]", workspaceID, config.Name)
+		if err := aks.RemoveLogAnalyticsWorkspace(ctx, workspacesClient, workspaceID); err != nil {
+			return config, fmt.Errorf("error removing Log Analytics workspace [%s]: %w", workspaceID, err)
+		}
+		config = config.DeepCopy()
+		config.Status.LogAnalyticsWorkspaceDeleting = true
+		if config, err = h.updateStatus(config); err != nil {
+			return config, err
+		}
+	}
+
+	exists, err := aks.ExistsLogAnalyticsWorkspace(ctx, workspacesClient, workspaceID)
+	if err != nil {
+		return config, fmt.Errorf("error checking Log Analytics workspace [%s]: %w", workspaceID, err)
+	}
+	if exists {
+		return h.waitForCreatedResourceRemoval(config, "Log Analytics workspace", workspaceID)
+	}
+	h.recorder.Eventf(config, v1.EventTypeNormal, eventReasonWorkspaceDeleted, "Log Analytics workspace [%s] was deleted", workspaceID)
+
+	config = config.DeepCopy()
+	config.Status.LogAnalyticsWorkspaceID = ""
+	config.Status.LogAnalyticsWorkspaceCreated = false
+	config.Status.LogAnalyticsWorkspaceDeleting = false
+	return h.updateStatus(config)
+}
+
+// removeCreatedResourceGroup deletes the resource group of the cluster if deleteResourceGroupOnRemove is set and the
+// operator created it. The resource group is kept while other configs use it, and while it still contains resources
+// unless forceDeleteResourceGroup is set. The started deletion is recorded in status and the config is polled until the
+// resource group is gone.
+func (h *Handler) removeCreatedResourceGroup(ctx context.Context, credentials *aks.Credentials, config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
+	resourceGroup := config.Spec.ResourceGroup
+	if !to.Bool(config.Spec.DeleteResourceGroupOnRemove) || !config.Status.ResourceGroupCreated {
+		logrus.Infof("Resource group [%s] for cluster [%s] still exists, please remove it if needed", resourceGroup, config.Spec.ClusterName)
+		h.recorder.Eventf(config, v1.EventTypeWarning, warningReasonResourceGroupRetained,
+			"Resource group [%s] still exists, please remove it if needed", resourceGroup)
+		return config, nil
+	}
+
+	resourceGroupsClient, err := aks.NewResourceGroupClient(credentials)
+	if err != nil {
+		return config, err
+	}
+
+	if !config.Status.ResourceGroupDeleting {
+		users, err := h.otherConfigs(config, func(other *aksv1.AKSClusterConfig) bool {
+			return strings.EqualFold(other.Spec.ResourceGroup, resourceGroup)
+		})
+		if err != nil {
+			return config, err
+		}
+		if len(users) > 0 {
+			logrus.Warnf("Resource group [%s] of cluster [%s] is used by [%s], will not delete it", resourceGroup, config.Name, strings.Join(users, ", "))
+			h.recorder.Eventf(config, v1.EventTypeWarning, warningReasonResourceGroupRetained,
+				"Resource group [%s] is kept, it is used by [%s]", resourceGroup, strings.Join(users, ", "))
+			return config, nil
+		}
+
+		if !to.Bool(config.Spec.ForceDeleteResourceGroup) {
+			resourcesClient, err := aks.NewResourcesClient(credentials)
+			if err != nil {
+				return config, err
+			}
+			resources, err := aks.ResourceGroupResources(ctx, resourcesClient, resourceGroup)
+			if aks.IsNotFound(err) {
+				return config, nil
+			}
+			if err != nil {
+				return config, fmt.Errorf("error listing resources of resource group [%s]: %w", resourceGroup, err)
+			}
+			if len(resources) > 0 {
+				logrus.Warnf("Resource group [%s] of cluster [%s] contains %d other resources, will not delete it", resourceGroup, config.Name, len(resources))
+				h.recorder.Eventf(config, v1.EventTypeWarning, warningReasonResourceGroupRetained,
+					"Resource group [%s] is kept, it contains %d other resources, set forceDeleteResourceGroup to delete them", resourceGroup, len(resources))
+				return config, nil
+			}
+		}
+
+		logrus.Infof("Removing resource group [%s] of cluster [%s]", resourceGroup, config.Name)
+		if err := aks.RemoveResourceGroup(ctx, resourceGroupsClient, resourceGroup); err != nil {
+			return config, fmt.Errorf("error removing resource group [%s]: %w", resourceGroup, err)
+		}
+		config = config.DeepCopy()
+		config.Status.ResourceGroupDeleting = true
+		if config, err = h.updateStatus(config); err != nil {
+			return config, err
+		}
+	}
+
+	exists, err := aks.ExistsResourceGroup(ctx, resourceGroupsClient, resourceGroup)
+	if err != nil {
+		return config, fmt.Errorf("error checking resource group [%s]: %w", resourceGroup, err)
+	}
+	if exists {
+		return h.waitForCreatedResourceRemoval(config, "resource group", resourceGroup)
+	}
+	h.recorder.Eventf(config, v1.EventTypeNormal, eventReasonResourceGroupDeleted, "Resource group [%s] was deleted", resourceGroup)
+	return config, nil
+}
+
+// waitForCreatedResourceRemoval polls the config while a resource created for the cluster is being deleted,
+// generic.ErrSkip keeps the finalizer in place meanwhile without retrying the remove handler as an error
+func (h *Handler) waitForCreatedResourceRemoval(config *aksv1.AKSClusterConfig, kind, name string) (*aksv1.AKSClusterConfig, error) {
+	logrus.Infof("Waiting for %s [%s] of cluster [%s] to be deleted", kind, name, config.Spec.ClusterName)
+	h.aksEnqueueAfter(config.Namespace, config.Name, h.pollInterval(config, h.pollIntervals.clusterDelete))
+	return config, generic.ErrSkip
+}
+
+// otherConfigs returns the namespaced names of the configs other than config which match
+func (h *Handler) otherConfigs(config *aksv1.AKSClusterConfig, match func(*aksv1.AKSClusterConfig) bool) ([]string, error) {
+	configs, err := h.aksCache.List("", labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, other := range configs {
+		if other.Namespace == config.Namespace && other.Name == config.Name {
+			continue
+		}
+		if match(other) {
+			names = append(names, other.Namespace+"/"+other.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
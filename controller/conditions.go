@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"github.com/Azure/go-autorest/autorest/to"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Condition types reported in status alongside the phase
+const (
+	conditionProvisioned     = "Provisioned"
+	conditionUpdated         = "Updated"
+	conditionNodePoolsReady  = "NodePoolsReady"
+	conditionMonitoringReady = "MonitoringReady"
+	conditionReady           = "Ready"
+)
+
+// Condition reasons
+const (
+	conditionReasonCreating           = "Creating"
+	conditionReasonCreated            = "Created"
+	conditionReasonImported           = "Imported"
+	conditionReasonUpdating           = "Updating"
+	conditionReasonUpToDate           = "UpToDate"
+	conditionReasonNodePoolsUpdating  = "NodePoolsUpdating"
+	conditionReasonNodePoolsSucceeded = "NodePoolsSucceeded"
+	conditionReasonMonitoringDisabled = "MonitoringDisabled"
+	conditionReasonWorkspacePending   = "WorkspacePending"
+	conditionReasonWorkspaceConnected = "WorkspaceConnected"
+)
+
+// setCondition sets the condition of conditionType in status for the given generation of the config. The last
+// transition time is only moved when the status of the condition changes. Returns true if the condition changed.
+func setCondition(status *aksv1.AKSClusterConfigStatus, generation int64, conditionType string, conditionStatus v1.ConditionStatus, reason, message string) bool {
+	for i := range status.Conditions {
+		condition := &status.Conditions[i]
+		if condition.Type != conditionType {
+			continue
+		}
+		if condition.Status == conditionStatus && condition.Reason == reason && condition.Message == message &&
+			condition.ObservedGeneration == generation {
+			return false
+		}
+		if condition.Status != conditionStatus {
+			condition.LastTransitionTime = metav1.Now()
+		}
+		condition.Status = conditionStatus
+		condition.ObservedGeneration = generation
+		condition.Reason = reason
+		condition.Message = message
+		return true
+	}
+
+	status.Conditions = append(status.Conditions, aksv1.AKSClusterConfigCondition{
+		Type:               conditionType,
+		Status:             conditionStatus,
+		ObservedGeneration: generation,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	})
+	return true
+}
+
+// setReadyConditions marks the cluster as up to date and ready once Azure reports it and all of its node pools as
+// succeeded
+func setReadyConditions(config *aksv1.AKSClusterConfig) bool {
+	changed := setCondition(&config.Status, config.Generation, conditionUpdated, v1.ConditionTrue, conditionReasonUpToDate, "")
+	changed = setCondition(&config.Status, config.Generation, conditionNodePoolsReady, v1.ConditionTrue, conditionReasonNodePoolsSucceeded, "") || changed
+	return setCondition(&config.Status, config.Generation, conditionReady, v1.ConditionTrue, conditionReasonUpToDate, "") || changed
+}
+
+// setUpdatingConditions marks the cluster as not ready while Azure applies an update
+func setUpdatingConditions(config *aksv1.AKSClusterConfig, message string) bool {
+	changed := setCondition(&config.Status, config.Generation, conditionUpdated, v1.ConditionFalse, conditionReasonUpdating, message)
+	return setCondition(&config.Status, config.Generation, conditionReady, v1.ConditionFalse, conditionReasonUpdating, message) || changed
+}
+
+// setMonitoringCondition reports whether the monitoring addon requested by the spec is wired to a Log Analytics
+// workspace
+func setMonitoringCondition(status *aksv1.AKSClusterConfigStatus, config *aksv1.AKSClusterConfig) bool {
+	switch {
+	case !to.Bool(config.Spec.Monitoring):
+		return setCondition(status, config.Generation, conditionMonitoringReady, v1.ConditionFalse, conditionReasonMonitoringDisabled, "")
+	case status.LogAnalyticsWorkspaceID == "":
+		return setCondition(status, config.Generation, conditionMonitoringReady, v1.ConditionFalse, conditionReasonWorkspacePending,
+			"the monitoring addon is not connected to a Log Analytics workspace yet")
+	default:
+		return setCondition(status, config.Generation, conditionMonitoringReady, v1.ConditionTrue, conditionReasonWorkspaceConnected, "")
+	}
+}
@@ -0,0 +1,187 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	v15 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// testClusterPath is the ARM path of the test cluster
+var testClusterPath = armPath("/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/cluster")
+
+// newCreateTestConfig returns a valid config of th which is not created yet, in a resource group which exists
+func (th *testHandler) newCreateTestConfig() *aksv1.AKSClusterConfig {
+	config := th.newTestConfig()
+	config.UID = "config-uid"
+	config.Spec.KubernetesVersion = to.StringPtr("1.23.5")
+	config.Spec.NodePools = []aksv1.AKSNodePool{validNodePool("pool")}
+	th.client.configs[config.Namespace+"/"+config.Name] = config.DeepCopy()
+	th.azure.on(http.MethodHead, armPath("/resourcegroups/rg"), http.StatusNoContent, nil)
+	return config
+}
+
+// existingCluster is the body of a cluster in provisioningState, tagged with the config UID owner
+func existingCluster(owner, provisioningState string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":       "cluster",
+		"tags":       map[string]string{ownerTag: owner},
+		"properties": map[string]interface{}{"provisioningState": provisioningState},
+	}
+}
+
+func TestCreateClusterResumesCreation(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing map[string]interface{}
+		// wantCreate is true if the cluster is sent to Azure
+		wantCreate bool
+		wantErr    string
+	}{
+		{
+			name:       "cluster not found",
+			wantCreate: true,
+		},
+		{
+			name:     "cluster creating",
+			existing: existingCluster("config-uid", "Creating"),
+		},
+		{
+			name:     "cluster created",
+			existing: existingCluster("config-uid", "Succeeded"),
+		},
+		{
+			name:       "cluster failed",
+			existing:   existingCluster("config-uid", "Failed"),
+			wantCreate: true,
+		},
+		{
+			name:     "cluster of another config",
+			existing: existingCluster("other-uid", "Succeeded"),
+			wantErr:  "cluster [cluster] already exists in resource group [rg] and was not created by this config, import it instead",
+		},
+		{
+			name:     "cluster not created by the operator",
+			existing: map[string]interface{}{"name": "cluster", "properties": map[string]interface{}{"provisioningState": "Succeeded"}},
+			wantErr:  "cluster [cluster] already exists in resource group [rg] and was not created by this config, import it instead",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+			config := th.newCreateTestConfig()
+			if tt.existing != nil {
+				th.azure.on(http.MethodGet, testClusterPath, http.StatusOK, tt.existing)
+			} else {
+				th.azure.on(http.MethodGet, testClusterPath, http.StatusNotFound, notFound("ResourceNotFound"))
+			}
+			th.azure.on(http.MethodPut, testClusterPath, http.StatusOK, existingCluster("config-uid", "Creating"))
+
+			updated, err := th.createCluster(config)
+			created := th.azure.sent(http.MethodPut, testClusterPath) != nil
+			if created != tt.wantCreate {
+				t.Errorf("expected the cluster to be created: %v, got requests %v", tt.wantCreate, th.azure.recorded())
+			}
+			if tt.wantErr != "" {
+				var invalidSpec invalidSpecError
+				if !errors.As(err, &invalidSpec) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected an invalid spec error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if updated.Status.Phase != aksConfigCreatingPhase {
+				t.Errorf("expected phase %q, got %q", aksConfigCreatingPhase, updated.Status.Phase)
+			}
+			if stored := th.client.configs[config.Namespace+"/"+config.Name]; stored.Status.Phase != aksConfigCreatingPhase {
+				t.Errorf("expected the creating phase to be stored, got %q", stored.Status.Phase)
+			}
+			if tt.wantCreate {
+				body, _ := th.azure.sent(http.MethodPut, testClusterPath).(map[string]interface{})
+				tags, _ := body["tags"].(map[string]interface{})
+				if tags[ownerTag] != "config-uid" {
+					t.Errorf("expected the created cluster to be tagged with the config UID, got tags %v", tags)
+				}
+			}
+		})
+	}
+}
+
+func TestWaitForClusterCreateDeadline(t *testing.T) {
+	tests := []struct {
+		name       string
+		sentBefore time.Duration
+		wantReason string
+	}{
+		{
+			name:       "within the deadline",
+			sentBefore: 30 * time.Minute,
+		},
+		{
+			name:       "deadline exceeded",
+			sentBefore: 2 * time.Hour,
+			wantReason: failureReasonCreateTimeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+			config := th.newTestConfig()
+			config.Status.Phase = aksConfigCreatingPhase
+			config.Status.LastUpdateAppliedTime = v15.NewTime(time.Now().Add(-tt.sentBefore))
+			th.client.configs[config.Namespace+"/"+config.Name] = config.DeepCopy()
+			th.azure.on(http.MethodGet, testClusterPath, http.StatusOK, existingCluster("config-uid", "Creating"))
+
+			updated, err := th.recordError(th.OnAksConfigChanged)("", config)
+			if tt.wantReason == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if updated.Status.FailureMessage != "" {
+					t.Errorf("expected no failure, got %q", updated.Status.FailureMessage)
+				}
+				if want := []string{config.Namespace + "/" + config.Name}; !reflect.DeepEqual(th.requeues, want) {
+					t.Errorf("expected the config to be requeued to wait for the cluster, got %v", th.requeues)
+				}
+				return
+			}
+			var timeoutErr createTimeoutError
+			if !errors.As(err, &timeoutErr) {
+				t.Fatalf("expected a create timeout error, got %v", err)
+			}
+			if updated.Status.FailureReason != tt.wantReason {
+				t.Errorf("expected failure reason %q, got %q", tt.wantReason, updated.Status.FailureReason)
+			}
+			if want := "cluster [cluster] was not created within 1h0m0s, its provisioning state is [Creating]"; updated.Status.FailureMessage != want {
+				t.Errorf("expected failure message %q, got %q", want, updated.Status.FailureMessage)
+			}
+		})
+	}
+}
+
+func TestWaitForClusterStopsWithController(t *testing.T) {
+	th := newTestHandler(t)
+	config := th.newTestConfig()
+	config.Status.Phase = aksConfigCreatingPhase
+	th.azure.on(http.MethodGet, testClusterPath, http.StatusOK, existingCluster("config-uid", "Creating"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	th.ctx = ctx
+	if _, err := th.waitForCluster(config); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the wait to stop once the controller is stopped, got %v", err)
+	}
+	if requests := th.azure.recorded(); len(requests) != 0 {
+		t.Errorf("expected no requests once the controller is stopped, got %v", requests)
+	}
+}
@@ -0,0 +1,245 @@
+package controller
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/rancher/aks-operator/pkg/aks"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	"github.com/rancher/aks-operator/pkg/utils"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// credentialSecretFinalizer keeps credential secrets from being deleted while AKSClusterConfigs reference them,
+	// clusters cannot be updated or removed without their credentials
+	credentialSecretFinalizer = "aks.cattle.io/credential-in-use"
+	// credentialSecretIndex indexes AKSClusterConfigs by the "namespace:name" of their credential secret
+	credentialSecretIndex = "aks.cattle.io/credential-secret"
+	// secretsControllerName is the name of the handler releasing credential secrets which are no longer referenced
+	secretsControllerName = "aks-credential-secret"
+	// secretsRotationControllerName is the name of the handler requeueing configs when their credential secret changes
+	secretsRotationControllerName = "aks-credential-secret-rotation"
+	// removeFinalizer is the finalizer wrangler adds to configs for the OnRemove handler, a deleting config keeps using
+	// its credentials until the finalizer is removed
+	removeFinalizer = "wrangler.cattle.io/" + controllerRemoveName
+	// credentialSecretReleaseInterval is how often a secret only referenced by deleting configs is checked again
+	credentialSecretReleaseInterval = 10 * time.Second
+
+	warningReasonCredentialSecretDeleting = "CredentialSecretDeleting"
+)
+
+// credentialSecretKey returns the key of a credential secret reference in credentialSecretIndex
+func credentialSecretKey(ref string) string {
+	ns, name := utils.ParseSecretName(ref)
+	return ns + ":" + name
+}
+
+// qualifiedCredentialSecret returns the "namespace:name" reference of the credential secret of the config, secrets
+// referenced without a namespace are in the namespace of the config
+func qualifiedCredentialSecret(config *aksv1.AKSClusterConfig) string {
+	ns, name := utils.ParseSecretName(config.Spec.AzureCredentialSecret)
+	if ns == "" {
+		ns = config.Namespace
+	}
+	return ns + ":" + name
+}
+
+// credentialSpec returns a copy of the spec of the config referencing its credential secret by namespace, for the
+// functions which resolve the secret from the spec alone
+func credentialSpec(config *aksv1.AKSClusterConfig) *aksv1.AKSClusterConfigSpec {
+	spec := config.Spec
+	if spec.AzureCredentialSecret != "" {
+		spec.AzureCredentialSecret = qualifiedCredentialSecret(config)
+	}
+	return &spec
+}
+
+// getCredentials returns the Azure credentials of the config. If the credential secret is missing, the error lists
+// the configs which still reference it.
+func (h *Handler) getCredentials(config *aksv1.AKSClusterConfig) (*aks.Credentials, error) {
+	credentials, err := aks.GetSecrets(h.secretsCache, credentialSpec(config))
+	if err == nil || config.Spec.AzureCredentialSecret == "" {
+		return credentials, err
+	}
+
+	ns, name := utils.ParseSecretName(qualifiedCredentialSecret(config))
+	if _, getErr := h.secretsCache.Get(ns, name); !errors.IsNotFound(getErr) {
+		return credentials, err
+	}
+	referencing, _, indexErr := h.configsByCredentialSecret(qualifiedCredentialSecret(config))
+	if indexErr != nil || len(referencing) == 0 {
+		return credentials, err
+	}
+	return credentials, fmt.Errorf("%w, it is still referenced by AKSClusterConfigs [%s]", err, strings.Join(referencing, ", "))
+}
+
+// configsByCredentialSecret returns the "namespace/name" of every config referencing the credential secret, and whether
+// all of them are being deleted. Deleting configs are counted until their cluster is removed, which needs the
+// credentials.
+func (h *Handler) configsByCredentialSecret(ref string) ([]string, bool, error) {
+	configs, err := h.aksCache.GetByIndex(credentialSecretIndex, credentialSecretKey(ref))
+	if err != nil {
+		return nil, false, err
+	}
+
+	var names []string
+	deleting := true
+	for _, c := range configs {
+		if c.DeletionTimestamp != nil && !hasFinalizer(c.Finalizers, removeFinalizer) {
+			continue
+		}
+		names = append(names, c.Namespace+"/"+c.Name)
+		deleting = deleting && c.DeletionTimestamp != nil
+	}
+	sort.Strings(names)
+	return names, deleting, nil
+}
+
+// protectCredentialSecret adds credentialSecretFinalizer to the credential secret of the config. A warning is recorded
+// on the config while the secret is being deleted.
+func (h *Handler) protectCredentialSecret(config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
+	if config.Spec.AzureCredentialSecret == "" {
+		return config, nil
+	}
+
+	ns, name := utils.ParseSecretName(qualifiedCredentialSecret(config))
+	secret, err := h.secretsCache.Get(ns, name)
+	if errors.IsNotFound(err) {
+		return config, nil
+	} else if err != nil {
+		return config, err
+	}
+
+	if secret.DeletionTimestamp != nil {
+		return h.setWarning(config, warningReasonCredentialSecretDeleting,
+			fmt.Sprintf("credential secret [%s] is being deleted, the cluster cannot be managed or removed without it", config.Spec.AzureCredentialSecret))
+	}
+
+	if !hasFinalizer(secret.Finalizers, credentialSecretFinalizer) {
+		logrus.Infof("Protecting credential secret [%s] of cluster [%s] from deletion", config.Spec.AzureCredentialSecret, config.Spec.ClusterName)
+		secret = secret.DeepCopy()
+		secret.Finalizers = append(secret.Finalizers, credentialSecretFinalizer)
+		if _, err := h.secrets.Update(secret); err != nil {
+			return config, err
+		}
+	}
+	return h.clearWarning(config, warningReasonCredentialSecretDeleting)
+}
+
+// OnSecretChanged removes credentialSecretFinalizer from secrets which are no longer referenced by any config. A secret
+// only referenced by configs being deleted is checked again until their clusters are removed.
+func (h *Handler) OnSecretChanged(key string, secret *v1.Secret) (*v1.Secret, error) {
+	if secret == nil || !hasFinalizer(secret.Finalizers, credentialSecretFinalizer) {
+		return secret, nil
+	}
+
+	referencing, deleting, err := h.configsByCredentialSecret(secret.Namespace + ":" + secret.Name)
+	if err != nil {
+		return secret, err
+	}
+	if len(referencing) > 0 {
+		if deleting {
+			h.secretsEnqueueAfter(secret.Namespace, secret.Name, credentialSecretReleaseInterval)
+		}
+		return secret, nil
+	}
+
+	logrus.Infof("Credential secret [%s/%s] is no longer referenced, removing finalizer", secret.Namespace, secret.Name)
+	secret = secret.DeepCopy()
+	var finalizers []string
+	for _, f := range secret.Finalizers {
+		if f != credentialSecretFinalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	secret.Finalizers = finalizers
+	return h.secrets.Update(secret)
+}
+
+// OnCredentialSecretChanged requeues the configs referencing a changed secret, so that rotated credentials are used
+// right away instead of once the clusters are polled again
+func (h *Handler) OnCredentialSecretChanged(key string, secret *v1.Secret) (*v1.Secret, error) {
+	if secret == nil {
+		return secret, nil
+	}
+
+	configs, err := h.aksCache.GetByIndex(credentialSecretIndex, secret.Namespace+":"+secret.Name)
+	if err != nil {
+		return secret, err
+	}
+	for _, c := range configs {
+		if c.DeletionTimestamp == nil {
+			h.aksEnqueue(c.Namespace, c.Name)
+		}
+	}
+	return secret, nil
+}
+
+// sharedServicePrincipalSecretHash returns an HMAC-SHA256 of the client secret of the credentials if the cluster runs
+// with the service principal of the operator, or an empty string otherwise. The HMAC is keyed by the UID of the
+// credential secret, so that the status of the config cannot be used to check guesses of the client secret.
+func (h *Handler) sharedServicePrincipalSecretHash(config *aksv1.AKSClusterConfig, credentials *aks.Credentials,
+	cluster *containerservice.ManagedCluster) (string, error) {
+	if credentials.AuthMode != aks.AuthModeClientSecret || cluster.ManagedClusterProperties == nil || cluster.ServicePrincipalProfile == nil ||
+		!strings.EqualFold(to.String(cluster.ServicePrincipalProfile.ClientID), credentials.ClientID) {
+		return "", nil
+	}
+
+	ns, name := utils.ParseSecretName(qualifiedCredentialSecret(config))
+	secret, err := h.secretsCache.Get(ns, name)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, []byte(secret.UID))
+	mac.Write([]byte(credentials.ClientSecret))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// resetServicePrincipal sends the rotated client secret of the operator to a cluster sharing its service principal.
+// The secret seen first is the one the cluster was created or imported with, it is only recorded.
+func (h *Handler) resetServicePrincipal(ctx context.Context, clusterClient *containerservice.ManagedClustersClient, credentials *aks.Credentials,
+	config *aksv1.AKSClusterConfig, secretHash string) (*aksv1.AKSClusterConfig, error) {
+	if secretHash == "" || config.Status.ServicePrincipalSecretHash == "" {
+		config = config.DeepCopy()
+		config.Status.ServicePrincipalSecretHash = secretHash
+		return h.updateStatus(config)
+	}
+
+	logrus.Infof("Client secret of the service principal of cluster [%s] was rotated, resetting its service principal profile", config.Spec.ClusterName)
+	if err := aks.ResetServicePrincipal(ctx, clusterClient, &config.Spec, credentials); err != nil {
+		return config, fmt.Errorf("failed to reset service principal profile of cluster [%s]: %w", config.Spec.ClusterName, err)
+	}
+	config = config.DeepCopy()
+	config.Status.ServicePrincipalSecretHash = secretHash
+	return h.enqueueUpdate(config)
+}
+
+// releaseCredentialSecret requeues the credential secret of a removed config so that its finalizer is removed once no
+// other config references it
+func (h *Handler) releaseCredentialSecret(config *aksv1.AKSClusterConfig) {
+	if config.Spec.AzureCredentialSecret == "" {
+		return
+	}
+	ns, name := utils.ParseSecretName(qualifiedCredentialSecret(config))
+	h.secretsEnqueue(ns, name)
+}
+
+func hasFinalizer(finalizers []string, finalizer string) bool {
+	for _, f := range finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
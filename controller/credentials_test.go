@@ -0,0 +1,292 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+	v15 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/rancher/aks-operator/pkg/aks"
+)
+
+func TestOnSecretChangedReleasesSecret(t *testing.T) {
+	now := v15.Now()
+	tests := []struct {
+		name              string
+		deletionTimestamp *v15.Time
+		finalizers        []string
+		wantReleased      bool
+		wantRequeued      bool
+	}{
+		{
+			name: "referenced by an active config",
+		},
+		{
+			name:              "referenced by a config whose cluster is being removed",
+			deletionTimestamp: &now,
+			finalizers:        []string{removeFinalizer},
+			wantRequeued:      true,
+		},
+		{
+			name:              "referenced by a config whose cluster was removed",
+			deletionTimestamp: &now,
+			wantReleased:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+			config := th.newTestConfig()
+			config.DeletionTimestamp = tt.deletionTimestamp
+			config.Finalizers = tt.finalizers
+			th.client.configs[config.Namespace+"/"+config.Name] = config
+
+			secret, _ := th.secretsCache.Get("cattle-global-data", "cc-test")
+			secret = secret.DeepCopy()
+			secret.Finalizers = []string{credentialSecretFinalizer}
+			secret, err := th.OnSecretChanged("cattle-global-data/cc-test", secret)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if released := !hasFinalizer(secret.Finalizers, credentialSecretFinalizer); released != tt.wantReleased {
+				t.Errorf("expected the secret to be released: %v, finalizers %v", tt.wantReleased, secret.Finalizers)
+			}
+			var wantRequeues []string
+			if tt.wantRequeued {
+				wantRequeues = []string{"cattle-global-data/cc-test"}
+			}
+			if !reflect.DeepEqual(th.secretRequeues, wantRequeues) {
+				t.Errorf("expected secret requeues %v, got %v", wantRequeues, th.secretRequeues)
+			}
+		})
+	}
+}
+
+func TestGetCredentialsListsDeletingConfigs(t *testing.T) {
+	th := newTestHandler(t)
+	config := th.newTestConfig()
+	now := v15.Now()
+	config.DeletionTimestamp = &now
+	config.Finalizers = []string{removeFinalizer}
+	th.client.configs[config.Namespace+"/"+config.Name] = config
+	delete(th.secretsCache.(*fakeSecretCache).secrets, "cattle-global-data/cc-test")
+
+	_, err := th.getCredentials(config)
+	if err == nil {
+		t.Fatal("expected an error for a missing credential secret")
+	}
+	if want := "still referenced by AKSClusterConfigs [cattle-global-data/c-test]"; !strings.Contains(err.Error(), want) {
+		t.Errorf("expected the error to contain %q, got %q", want, err)
+	}
+}
+
+func TestGetCredentialsSecretReference(t *testing.T) {
+	tests := []struct {
+		name      string
+		namespace string
+		ref       string
+		wantKey   string
+		wantErr   string
+	}{
+		{
+			name:      "qualified with a colon",
+			namespace: "fleet-default",
+			ref:       "cattle-global-data:cc-test",
+			wantKey:   "cattle-global-data:cc-test",
+		},
+		{
+			name:      "qualified with a slash",
+			namespace: "fleet-default",
+			ref:       "cattle-global-data/cc-test",
+			wantKey:   "cattle-global-data:cc-test",
+		},
+		{
+			name:      "unqualified",
+			namespace: "cattle-global-data",
+			ref:       "cc-test",
+			wantKey:   "cattle-global-data:cc-test",
+		},
+		{
+			name:      "unqualified in another namespace",
+			namespace: "fleet-default",
+			ref:       "cc-test",
+			wantKey:   "fleet-default:cc-test",
+			wantErr:   "couldn't find secret [cc-test] in namespace [fleet-default]",
+		},
+		{
+			name:      "qualified with a missing namespace",
+			namespace: "cattle-global-data",
+			ref:       "missing:cc-test",
+			wantKey:   "missing:cc-test",
+			wantErr:   "couldn't find secret [cc-test] in namespace [missing]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+			config := th.newTestConfig()
+			config.Namespace = tt.namespace
+			config.Spec.AzureCredentialSecret = tt.ref
+
+			if key := credentialSecretKey(qualifiedCredentialSecret(config)); key != tt.wantKey {
+				t.Errorf("expected the config to be indexed by %s, got %s", tt.wantKey, key)
+			}
+			credentials, err := th.getCredentials(config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if credentials.SubscriptionID != testSubscriptionID {
+				t.Errorf("expected the credentials of the secret, got subscription %s", credentials.SubscriptionID)
+			}
+			if config.Spec.AzureCredentialSecret != tt.ref {
+				t.Errorf("expected the spec of the config to be unchanged, got %s", config.Spec.AzureCredentialSecret)
+			}
+		})
+	}
+}
+
+func TestOnCredentialSecretChangedRequeuesReferencingConfigs(t *testing.T) {
+	th := newTestHandler(t)
+	now := v15.Now()
+	for name, ref := range map[string]string{
+		"c-first":     testSecretName,
+		"c-second":    "cattle-global-data/cc-test",
+		"c-unrelated": "cattle-global-data:cc-other",
+		"c-deleting":  testSecretName,
+	} {
+		config := th.newTestConfig()
+		config.Name = name
+		config.Spec.AzureCredentialSecret = ref
+		if name == "c-deleting" {
+			config.DeletionTimestamp = &now
+		}
+		th.client.configs[config.Namespace+"/"+config.Name] = config
+	}
+
+	secret, _ := th.secretsCache.Get("cattle-global-data", "cc-test")
+	if _, err := th.OnCredentialSecretChanged("cattle-global-data/cc-test", secret); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sort.Strings(th.requeues)
+	if want := []string{"cattle-global-data/c-first", "cattle-global-data/c-second"}; !reflect.DeepEqual(th.requeues, want) {
+		t.Errorf("expected requeues %v, got %v", want, th.requeues)
+	}
+}
+
+func TestSharedServicePrincipalSecretHash(t *testing.T) {
+	th := newTestHandler(t)
+	config := th.newTestConfig()
+	th.secretsCache.(*fakeSecretCache).secrets["cattle-global-data/cc-test"].UID = "secret-uid"
+	cluster := func(clientID string) *containerservice.ManagedCluster {
+		return &containerservice.ManagedCluster{ManagedClusterProperties: &containerservice.ManagedClusterProperties{
+			ServicePrincipalProfile: &containerservice.ManagedClusterServicePrincipalProfile{ClientID: to.StringPtr(clientID)},
+		}}
+	}
+	hash := func(credentials *aks.Credentials, cluster *containerservice.ManagedCluster) string {
+		t.Helper()
+		h, err := th.sharedServicePrincipalSecretHash(config, credentials, cluster)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return h
+	}
+	credentials := &aks.Credentials{AuthMode: aks.AuthModeClientSecret, ClientID: "client", ClientSecret: "secret"}
+	rotated := &aks.Credentials{AuthMode: aks.AuthModeClientSecret, ClientID: "client", ClientSecret: "rotated"}
+
+	secretHash := hash(credentials, cluster("CLIENT"))
+	if secretHash == "" {
+		t.Fatal("expected a hash of the client secret for a cluster sharing the service principal")
+	}
+	unkeyed := sha256.Sum256([]byte("secret"))
+	if secretHash == hex.EncodeToString(unkeyed[:]) {
+		t.Error("expected the hash to be keyed, got the SHA-256 of the client secret")
+	}
+	if again := hash(credentials, cluster("client")); again != secretHash {
+		t.Errorf("expected the same hash for the same client secret, got %q and %q", secretHash, again)
+	}
+	if other := hash(rotated, cluster("client")); other == secretHash {
+		t.Error("expected a different hash for a rotated client secret")
+	}
+	if other := hash(credentials, cluster("other")); other != "" {
+		t.Errorf("expected no hash for a cluster with another service principal, got %q", other)
+	}
+	if other := hash(credentials, &containerservice.ManagedCluster{}); other != "" {
+		t.Errorf("expected no hash for a cluster without service principal, got %q", other)
+	}
+	identity := &aks.Credentials{AuthMode: aks.AuthModeManagedIdentity, ClientID: "client"}
+	if other := hash(identity, cluster("client")); other != "" {
+		t.Errorf("expected no hash for credentials without client secret, got %q", other)
+	}
+
+	th.secretsCache.(*fakeSecretCache).secrets["cattle-global-data/cc-test"].UID = "recreated-uid"
+	if other := hash(credentials, cluster("client")); other == secretHash {
+		t.Error("expected the hash to depend on the credential secret")
+	}
+}
+
+func TestResetServicePrincipal(t *testing.T) {
+	resetPath := armPath("/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/cluster/resetServicePrincipalProfile")
+	tests := []struct {
+		name         string
+		recorded     string
+		wantRequests []string
+	}{
+		{
+			name: "first secret is recorded",
+		},
+		{
+			name:         "rotated secret is sent",
+			recorded:     "previous",
+			wantRequests: []string{"POST " + resetPath},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+			th.azure.on(http.MethodPost, resetPath, http.StatusOK, nil)
+			config := th.newTestConfig()
+			config.Status.ServicePrincipalSecretHash = tt.recorded
+			th.client.configs[config.Namespace+"/"+config.Name] = config.DeepCopy()
+			credentials, err := th.getCredentials(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			clusterClient, err := aks.NewClusterClient(credentials)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			updated, err := th.resetServicePrincipal(context.Background(), clusterClient, credentials, config, "current")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(th.azure.recorded(), tt.wantRequests) {
+				t.Errorf("expected requests %v, got %v", tt.wantRequests, th.azure.recorded())
+			}
+			if updated.Status.ServicePrincipalSecretHash != "current" {
+				t.Errorf("expected the current secret hash to be recorded, got %q", updated.Status.ServicePrincipalSecretHash)
+			}
+			if tt.wantRequests != nil {
+				body, _ := th.azure.sent(http.MethodPost, resetPath).(map[string]interface{})
+				if body["clientId"] != "client" || body["secret"] != "secret" {
+					t.Errorf("expected the client secret of the credentials to be sent, got %v", body)
+				}
+			}
+		})
+	}
+}
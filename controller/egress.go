@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// egressIPEnv is the environment variable holding the public egress IP of the operator, it takes precedence over
+	// discovering the IP
+	egressIPEnv = "AKS_OPERATOR_EGRESS_IP"
+	// egressIPURLEnv is the environment variable holding the URL of a service echoing the caller's public IP. The IP is
+	// only discovered if the URL is set, the operator never calls a third-party service on its own.
+	egressIPURLEnv  = "AKS_OPERATOR_EGRESS_IP_URL"
+	egressIPTimeout = 10 * time.Second
+	// egressIPCacheTTL is how long a resolved egress IP is used before it is resolved again, the egress IP of the
+	// operator changes when it moves to another node or NAT gateway
+	egressIPCacheTTL = 10 * time.Minute
+)
+
+// errEgressIPNotConfigured is returned if the egress IP is needed but neither egressIPEnv nor egressIPURLEnv is set
+var errEgressIPNotConfigured = fmt.Errorf("includeOperatorEgressIP requires the operator to be configured with %s or %s",
+	egressIPEnv, egressIPURLEnv)
+
+// egressIPResolver resolves the public egress IP of the operator and caches it for egressIPCacheTTL. Concurrent calls
+// share a single lookup, which runs without holding the lock. The lookup is bounded by egressIPTimeout rather than the
+// context of the caller which started it, so that a canceled caller does not fail the others waiting for it.
+type egressIPResolver struct {
+	mu      sync.Mutex
+	ip      string
+	expires time.Time
+	// lookup is the running lookup, nil if there is none
+	lookup *egressIPLookup
+}
+
+// egressIPLookup is a lookup of the egress IP, done is closed once ip or err is set
+type egressIPLookup struct {
+	done chan struct{}
+	ip   string
+	err  error
+}
+
+func (r *egressIPResolver) resolve(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	if r.ip != "" && time.Now().Before(r.expires) {
+		ip := r.ip
+		r.mu.Unlock()
+		return ip, nil
+	}
+	lookup := r.lookup
+	if lookup == nil {
+		lookup = &egressIPLookup{done: make(chan struct{})}
+		r.lookup = lookup
+		go r.run(lookup)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-lookup.done:
+		return lookup.ip, lookup.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// run does the lookup and caches the IP it resolved
+func (r *egressIPResolver) run(lookup *egressIPLookup) {
+	ctx, cancel := context.WithTimeout(context.Background(), egressIPTimeout)
+	defer cancel()
+	ip, err := resolveEgressIP(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil {
+		if ip != r.ip {
+			logrus.Infof("Using operator egress IP [%s]", ip)
+		}
+		r.ip = ip
+		r.expires = time.Now().Add(egressIPCacheTTL)
+	}
+	lookup.ip, lookup.err = ip, err
+	r.lookup = nil
+	close(lookup.done)
+}
+
+// resolveEgressIP returns the egress IP configured by egressIPEnv, or discovered from the URL of egressIPURLEnv
+func resolveEgressIP(ctx context.Context) (string, error) {
+	ip := strings.TrimSpace(os.Getenv(egressIPEnv))
+	if ip == "" {
+		url := strings.TrimSpace(os.Getenv(egressIPURLEnv))
+		if url == "" {
+			return "", errEgressIPNotConfigured
+		}
+		var err error
+		ip, err = lookupEgressIP(ctx, url)
+		if err != nil {
+			return "", fmt.Errorf("failed to discover operator egress IP from [%s], set %s to configure it: %w", url, egressIPEnv, err)
+		}
+	}
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil || parsed.To4() == nil {
+		return "", fmt.Errorf("operator egress IP [%s] is not a valid IPv4 address", ip)
+	}
+	return parsed.String(), nil
+}
+
+func lookupEgressIP(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// withEgressIP returns the spec with the operator egress IP appended to the authorized IP ranges if
+// includeOperatorEgressIP is set. The IP is only added to a non-empty list, an empty list leaves the API server
+// publicly accessible.
+func (h *Handler) withEgressIP(ctx context.Context, spec *aksv1.AKSClusterConfigSpec) (*aksv1.AKSClusterConfigSpec, error) {
+	if !to.Bool(spec.IncludeOperatorEgressIP) || spec.AuthorizedIPRanges == nil || len(*spec.AuthorizedIPRanges) == 0 ||
+		isUnmanaged(spec, unmanagedAuthorizedIPRanges) {
+		return spec, nil
+	}
+
+	ip, err := h.egressIP.resolve(ctx)
+	if errors.Is(err, errEgressIPNotConfigured) {
+		return spec, invalidSpecError{err}
+	}
+	if err != nil {
+		return spec, err
+	}
+	egressRange := ip + "/32"
+	for _, ipRange := range *spec.AuthorizedIPRanges {
+		if ipRange == egressRange || ipRange == ip {
+			return spec, nil
+		}
+	}
+
+	spec = spec.DeepCopy()
+	ipRanges := append(*spec.AuthorizedIPRanges, egressRange)
+	spec.AuthorizedIPRanges = &ipRanges
+	return spec, nil
+}
+
+// withoutEgressIP removes the operator egress IP added by withEgressIP from the authorized IP ranges of an upstream
+// spec
+func (h *Handler) withoutEgressIP(ctx context.Context, config *aksv1.AKSClusterConfig, upstreamSpec *aksv1.AKSClusterConfigSpec) {
+	if !to.Bool(config.Spec.IncludeOperatorEgressIP) || upstreamSpec.AuthorizedIPRanges == nil {
+		return
+	}
+
+	ip, err := h.egressIP.resolve(ctx)
+	if err != nil {
+		logrus.Warnf("Operator egress IP is kept in authorized IP ranges of cluster [%s]: %v", config.Spec.ClusterName, err)
+		return
+	}
+	egressRange := ip + "/32"
+
+	if config.Spec.AuthorizedIPRanges != nil {
+		for _, ipRange := range *config.Spec.AuthorizedIPRanges {
+			if ipRange == egressRange {
+				return
+			}
+		}
+	}
+	var ipRanges []string
+	for _, ipRange := range *upstreamSpec.AuthorizedIPRanges {
+		if ipRange != egressRange {
+			ipRanges = append(ipRanges, ipRange)
+		}
+	}
+	upstreamSpec.AuthorizedIPRanges = &ipRanges
+}
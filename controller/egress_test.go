@@ -0,0 +1,285 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest/to"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// setEgressIPEnv sets the egress IP environment variables of the operator for the duration of the test, an empty value
+// unsets the variable
+func setEgressIPEnv(t *testing.T, ip, url string) {
+	t.Helper()
+	for key, value := range map[string]string{egressIPEnv: ip, egressIPURLEnv: url} {
+		previous, ok := os.LookupEnv(key)
+		if value == "" {
+			os.Unsetenv(key)
+		} else {
+			os.Setenv(key, value)
+		}
+		key := key
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(key, previous)
+			} else {
+				os.Unsetenv(key)
+			}
+		})
+	}
+}
+
+// newEgressIPServer returns the URL of a service answering with ip, the number of requests is counted in requests
+func newEgressIPServer(t *testing.T, ip string, requests *int32) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(requests, 1)
+		fmt.Fprintln(rw, ip)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestEgressIPResolverFromEnv(t *testing.T) {
+	var requests int32
+	setEgressIPEnv(t, " 203.0.113.10 ", newEgressIPServer(t, "198.51.100.1", &requests))
+
+	ip, err := (&egressIPResolver{}).resolve(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ip != "203.0.113.10" {
+		t.Errorf("expected the configured IP 203.0.113.10, got %s", ip)
+	}
+	if requests != 0 {
+		t.Errorf("expected the configured IP to take precedence over the URL, got %d requests", requests)
+	}
+}
+
+func TestEgressIPResolverFromURL(t *testing.T) {
+	var requests int32
+	setEgressIPEnv(t, "", newEgressIPServer(t, "198.51.100.1", &requests))
+
+	resolver := &egressIPResolver{}
+	for i := 0; i < 2; i++ {
+		ip, err := resolver.resolve(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ip != "198.51.100.1" {
+			t.Errorf("expected the discovered IP 198.51.100.1, got %s", ip)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected the discovered IP to be cached, got %d requests", requests)
+	}
+
+	resolver.expires = time.Now().Add(-time.Second)
+	if _, err := resolver.resolve(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("expected an expired IP to be discovered again, got %d requests", requests)
+	}
+}
+
+func TestEgressIPResolverErrors(t *testing.T) {
+	var requests int32
+	tests := []struct {
+		name    string
+		ip      string
+		url     string
+		wantErr error
+	}{
+		{
+			name:    "not configured",
+			wantErr: errEgressIPNotConfigured,
+		},
+		{
+			name: "invalid IP",
+			ip:   "not-an-ip",
+		},
+		{
+			name: "IPv6",
+			ip:   "2001:db8::1",
+		},
+		{
+			name: "invalid discovered IP",
+			url:  newEgressIPServer(t, "<html></html>", &requests),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEgressIPEnv(t, tt.ip, tt.url)
+			resolver := &egressIPResolver{}
+			_, err := resolver.resolve(context.Background())
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+			if resolver.ip != "" {
+				t.Errorf("expected an error not to be cached, got %s", resolver.ip)
+			}
+		})
+	}
+}
+
+func TestEgressIPResolverSharesLookup(t *testing.T) {
+	var requests int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			close(started)
+		}
+		<-release
+		fmt.Fprint(rw, "198.51.100.1")
+	}))
+	t.Cleanup(server.Close)
+	setEgressIPEnv(t, "", server.URL)
+
+	resolver := &egressIPResolver{}
+	var wg sync.WaitGroup
+	ips := make([]string, 5)
+	errs := make([]error, len(ips))
+	for i := range ips {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ips[i], errs[i] = resolver.resolve(context.Background())
+		}(i)
+	}
+	<-started
+
+	// a caller whose context ends does not wait for the running lookup, nor for the lock
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := resolver.resolve(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a canceled caller to return while the lookup runs, got %v", err)
+	}
+
+	close(release)
+	wg.Wait()
+	for i := range ips {
+		if errs[i] != nil || ips[i] != "198.51.100.1" {
+			t.Errorf("expected caller %d to get 198.51.100.1, got %q, %v", i, ips[i], errs[i])
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected concurrent callers to share one lookup, got %d requests", requests)
+	}
+}
+
+func TestWithEgressIP(t *testing.T) {
+	setEgressIPEnv(t, "203.0.113.10", "")
+	h := &Handler{egressIP: &egressIPResolver{}}
+
+	tests := []struct {
+		name   string
+		ranges *[]string
+		want   []string
+	}{
+		{
+			name:   "adds the egress IP",
+			ranges: &[]string{"10.0.0.0/8"},
+			want:   []string{"10.0.0.0/8", "203.0.113.10/32"},
+		},
+		{
+			name:   "keeps an existing egress IP range",
+			ranges: &[]string{"203.0.113.10/32"},
+			want:   []string{"203.0.113.10/32"},
+		},
+		{
+			name:   "keeps an empty list",
+			ranges: &[]string{},
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &aksv1.AKSClusterConfigSpec{IncludeOperatorEgressIP: to.BoolPtr(true), AuthorizedIPRanges: tt.ranges}
+			got, err := h.withEgressIP(context.Background(), spec)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if fmt.Sprint(*got.AuthorizedIPRanges) != fmt.Sprint(tt.want) {
+				t.Errorf("expected authorized IP ranges %v, got %v", tt.want, *got.AuthorizedIPRanges)
+			}
+		})
+	}
+}
+
+func TestWithEgressIPNotConfigured(t *testing.T) {
+	setEgressIPEnv(t, "", "")
+	h := &Handler{egressIP: &egressIPResolver{}}
+
+	spec := &aksv1.AKSClusterConfigSpec{IncludeOperatorEgressIP: to.BoolPtr(true), AuthorizedIPRanges: &[]string{"10.0.0.0/8"}}
+	_, err := h.withEgressIP(context.Background(), spec)
+	var invalidSpec invalidSpecError
+	if !errors.As(err, &invalidSpec) || !errors.Is(err, errEgressIPNotConfigured) {
+		t.Errorf("expected an invalid spec error for an operator without egress IP configuration, got %v", err)
+	}
+}
+
+func TestEgressIPResolverCanceledFirstCaller(t *testing.T) {
+	var requests int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			close(started)
+		}
+		<-release
+		fmt.Fprint(rw, "198.51.100.1")
+	}))
+	t.Cleanup(server.Close)
+	setEgressIPEnv(t, "", server.URL)
+
+	// the first caller starts the lookup and is canceled while it runs
+	resolver := &egressIPResolver{}
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error)
+	go func() {
+		_, err := resolver.resolve(ctx)
+		firstErr <- err
+	}()
+	<-started
+
+	var wg sync.WaitGroup
+	ips := make([]string, 3)
+	errs := make([]error, len(ips))
+	for i := range ips {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ips[i], errs[i] = resolver.resolve(context.Background())
+		}(i)
+	}
+	cancel()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the first caller to be canceled, got %v", err)
+	}
+
+	close(release)
+	wg.Wait()
+	for i := range ips {
+		if errs[i] != nil || ips[i] != "198.51.100.1" {
+			t.Errorf("expected caller %d to get 198.51.100.1, got %q, %v", i, ips[i], errs[i])
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected the lookup to go on after the first caller is canceled, got %d requests", requests)
+	}
+}
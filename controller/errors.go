@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"errors"
+	"unicode/utf8"
+
+	"github.com/rancher/aks-operator/pkg/aks"
+)
+
+// Failure reasons written to status.failureReason
+const (
+	failureReasonQuotaExceeded = "QuotaExceeded"
+	failureReasonUnauthorized  = "Unauthorized"
+	failureReasonInvalidSpec   = "InvalidSpec"
+	failureReasonThrottled     = "Throttled"
+	failureReasonCreateTimeout = "CreateTimeout"
+	failureReasonUnknown       = "Unknown"
+)
+
+// maxFailureMessageLength is the maximum length of status.failureMessage. Azure errors can be several kilobytes long.
+const maxFailureMessageLength = 1024
+
+// invalidSpecError marks errors caused by the AKSClusterConfig spec itself rather than by Azure
+type invalidSpecError struct {
+	error
+}
+
+func (e invalidSpecError) Unwrap() error {
+	return e.error
+}
+
+// createTimeoutError marks clusters which were not created within the creation deadline
+type createTimeoutError struct {
+	error
+}
+
+func (e createTimeoutError) Unwrap() error {
+	return e.error
+}
+
+// failureReason classifies err into one of the failure reasons
+func failureReason(err error) string {
+	var specErr invalidSpecError
+	var timeoutErr createTimeoutError
+	switch {
+	case aks.IsThrottled(err):
+		return failureReasonThrottled
+	case aks.IsQuotaExceeded(err):
+		return failureReasonQuotaExceeded
+	case aks.IsUnauthorized(err):
+		return failureReasonUnauthorized
+	case errors.As(err, &specErr), aks.IsBadRequest(err):
+		return failureReasonInvalidSpec
+	case errors.As(err, &timeoutErr):
+		return failureReasonCreateTimeout
+	default:
+		return failureReasonUnknown
+	}
+}
+
+// truncateFailureMessage shortens message to maxFailureMessageLength, ending it with an ellipsis if it was truncated
+func truncateFailureMessage(message string) string {
+	if len(message) <= maxFailureMessageLength {
+		return message
+	}
+	// step back to the start of a rune, so that a multi-byte rune is not cut in half
+	n := maxFailureMessageLength - 3
+	for n > 0 && !utf8.RuneStart(message[n]) {
+		n--
+	}
+	return message[:n] + "..."
+}
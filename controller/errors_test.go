@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+)
+
+func TestTruncateFailureMessage(t *testing.T) {
+	limit := maxFailureMessageLength - 3
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{
+			name:    "short message",
+			message: "cluster failed",
+			want:    "cluster failed",
+		},
+		{
+			name:    "message of the maximum length",
+			message: strings.Repeat("a", maxFailureMessageLength),
+			want:    strings.Repeat("a", maxFailureMessageLength),
+		},
+		{
+			name:    "long message",
+			message: strings.Repeat("a", maxFailureMessageLength+1),
+			want:    strings.Repeat("a", limit) + "...",
+		},
+		{
+			name:    "two-byte rune across the limit",
+			message: strings.Repeat("a", limit-1) + "é" + strings.Repeat("b", 10),
+			want:    strings.Repeat("a", limit-1) + "...",
+		},
+		{
+			name:    "four-byte rune across the limit",
+			message: strings.Repeat("a", limit-2) + "😀" + strings.Repeat("b", 10),
+			want:    strings.Repeat("a", limit-2) + "...",
+		},
+		{
+			name:    "rune ending at the limit",
+			message: strings.Repeat("a", limit-3) + "€" + strings.Repeat("b", 10),
+			want:    strings.Repeat("a", limit-3) + "€...",
+		},
+		{
+			name:    "multi-byte runes only",
+			message: strings.Repeat("日", maxFailureMessageLength),
+			want:    strings.Repeat("日", limit/3) + "...",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateFailureMessage(tt.message)
+			if got != tt.want {
+				t.Errorf("truncateFailureMessage() = %q (%d bytes), want %q (%d bytes)", got, len(got), tt.want, len(tt.want))
+			}
+			if !utf8.ValidString(got) || len(got) > maxFailureMessageLength {
+				t.Errorf("expected valid UTF-8 of at most %d bytes, got %d bytes", maxFailureMessageLength, len(got))
+			}
+		})
+	}
+}
+
+func TestFailureReason(t *testing.T) {
+	azureError := func(statusCode int, code string) error {
+		return &azure.RequestError{
+			DetailedError: autorest.DetailedError{StatusCode: statusCode},
+			ServiceError:  &azure.ServiceError{Code: code},
+		}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"throttled", azureError(http.StatusTooManyRequests, "TooManyRequests"), failureReasonThrottled},
+		{"quota", azureError(http.StatusBadRequest, "QuotaExceeded"), failureReasonQuotaExceeded},
+		{"unauthorized", azureError(http.StatusForbidden, "AuthorizationFailed"), failureReasonUnauthorized},
+		{"bad request", azureError(http.StatusBadRequest, "InvalidParameter"), failureReasonInvalidSpec},
+		{"invalid spec", fmt.Errorf("wrapped: %w", invalidSpecError{errors.New("bad spec")}), failureReasonInvalidSpec},
+		{"create timeout", createTimeoutError{errors.New("timed out")}, failureReasonCreateTimeout},
+		{"other", errors.New("connection reset"), failureReasonUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := failureReason(tt.err); got != tt.want {
+				t.Errorf("failureReason() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v15 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+const (
+	// exportSpecAnnotation requests the upstream cluster to be exported as an AKSClusterConfig. The handler writes
+	// the result into a ConfigMap named after the config and removes the annotation.
+	exportSpecAnnotation = "aks.cattle.io/export-spec"
+	// exportSpecKey is the ConfigMap key holding the exported AKSClusterConfig
+	exportSpecKey = "aksclusterconfig.yaml"
+)
+
+// exportRequested returns true if the config is active and carries the export annotation
+func exportRequested(config *aksv1.AKSClusterConfig) bool {
+	return config.Status.Phase == aksConfigActivePhase && config.Annotations[exportSpecAnnotation] == "true"
+}
+
+// exportSpec builds the upstream cluster state, writes it as a complete AKSClusterConfig into a ConfigMap named after
+// the config and removes the export annotation
+func (h *Handler) exportSpec(config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
+	ctx, cancel := context.WithCancel(h.ctx)
+	defer cancel()
+
+	logrus.Infof("Exporting upstream state of cluster [%s]", config.Name)
+	upstreamSpec, err := BuildUpstreamClusterState(ctx, h.secretsCache, credentialSpec(config))
+	if err != nil {
+		return config, err
+	}
+
+	h.withoutEgressIP(ctx, config, upstreamSpec)
+
+	// the upstream state does not contain the fields identifying the cluster and its credentials
+	upstreamSpec.ClusterName = config.Spec.ClusterName
+	upstreamSpec.ResourceGroup = config.Spec.ResourceGroup
+	upstreamSpec.ResourceLocation = config.Spec.ResourceLocation
+	upstreamSpec.AzureCredentialSecret = config.Spec.AzureCredentialSecret
+	upstreamSpec.BaseURL = config.Spec.BaseURL
+	upstreamSpec.AuthBaseURL = config.Spec.AuthBaseURL
+	upstreamSpec.DNSPrefix = config.Spec.DNSPrefix
+	upstreamSpec.Imported = config.Spec.Imported
+	upstreamSpec.IncludeOperatorEgressIP = config.Spec.IncludeOperatorEgressIP
+
+	data, err := yaml.Marshal(&aksv1.AKSClusterConfig{
+		TypeMeta: v15.TypeMeta{
+			APIVersion: aksv1.SchemeGroupVersion.String(),
+			Kind:       aksClusterConfigKind,
+		},
+		ObjectMeta: v15.ObjectMeta{
+			Name:      config.Name,
+			Namespace: config.Namespace,
+		},
+		Spec: *upstreamSpec,
+	})
+	if err != nil {
+		return config, fmt.Errorf("failed to export spec of cluster [%s]: %w", config.Spec.ClusterName, err)
+	}
+
+	if err := h.writeExportConfigMap(config, string(data)); err != nil {
+		return config, err
+	}
+
+	logrus.Infof("Exported upstream state of cluster [%s] to ConfigMap [%s/%s]", config.Name, config.Namespace, config.Name)
+	config = config.DeepCopy()
+	delete(config.Annotations, exportSpecAnnotation)
+	return h.aksCC.Update(config)
+}
+
+func (h *Handler) writeExportConfigMap(config *aksv1.AKSClusterConfig, data string) error {
+	configMap, err := h.configMaps.Get(config.Namespace, config.Name, v15.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = h.configMaps.Create(
+			&v1.ConfigMap{
+				ObjectMeta: v15.ObjectMeta{
+					Name:      config.Name,
+					Namespace: config.Namespace,
+					OwnerReferences: []v15.OwnerReference{
+						{
+							APIVersion: aksv1.SchemeGroupVersion.String(),
+							Kind:       aksClusterConfigKind,
+							UID:        config.UID,
+							Name:       config.Name,
+						},
+					},
+				},
+				Data: map[string]string{
+					exportSpecKey: data,
+				},
+			})
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	configMap = configMap.DeepCopy()
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	configMap.Data[exportSpecKey] = data
+	_, err = h.configMaps.Update(configMap)
+	return err
+}
@@ -0,0 +1,320 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	wranglerv1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v15 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	v10 "github.com/rancher/aks-operator/pkg/generated/controllers/aks.cattle.io/v1"
+	"github.com/rancher/aks-operator/pkg/utils"
+)
+
+const (
+	testSubscriptionID = "00000000-0000-0000-0000-000000000000"
+	testTenantID       = "11111111-1111-1111-1111-111111111111"
+	testSecretName     = "cattle-global-data:cc-test"
+)
+
+// fakeAzureResponse is the response of fakeAzure to a request
+type fakeAzureResponse struct {
+	status int
+	body   interface{}
+}
+
+// fakeAzure is an ARM and Azure AD endpoint answering with the responses registered by method and path. Every ARM
+// request is recorded as "METHOD path", along with the last JSON body sent by method to path.
+type fakeAzure struct {
+	t         *testing.T
+	server    *httptest.Server
+	mu        sync.Mutex
+	responses map[string]fakeAzureResponse
+	requests  []string
+	bodies    map[string]interface{}
+}
+
+func newFakeAzure(t *testing.T) *fakeAzure {
+	f := &fakeAzure{
+		t:         t,
+		responses: map[string]fakeAzureResponse{},
+		bodies:    map[string]interface{}{},
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// on registers the response to requests with method to path
+func (f *fakeAzure) on(method, path string, status int, body interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[method+" "+strings.ToLower(path)] = fakeAzureResponse{status: status, body: body}
+}
+
+func (f *fakeAzure) recorded() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.requests...)
+}
+
+// sent returns the decoded JSON body of the last request with method to path, nil if there was none
+func (f *fakeAzure) sent(method, path string) interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.bodies[method+" "+strings.ToLower(path)]
+}
+
+func (f *fakeAzure) serveHTTP(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	if strings.HasSuffix(req.URL.Path, "/oauth2/token") {
+		expiresOn := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+		json.NewEncoder(rw).Encode(map[string]string{
+			"access_token": "token",
+			"token_type":   "Bearer",
+			"expires_in":   "3600",
+			"expires_on":   expiresOn,
+			"not_before":   strconv.FormatInt(time.Now().Unix(), 10),
+			"resource":     f.server.URL,
+		})
+		return
+	}
+
+	key := req.Method + " " + strings.ToLower(req.URL.Path)
+	var body interface{}
+	if req.Body != nil {
+		json.NewDecoder(req.Body).Decode(&body)
+	}
+	f.mu.Lock()
+	f.requests = append(f.requests, req.Method+" "+req.URL.Path)
+	if body != nil {
+		f.bodies[key] = body
+	}
+	response, ok := f.responses[key]
+	f.mu.Unlock()
+	if !ok {
+		f.t.Errorf("unexpected Azure request [%s]", key)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.WriteHeader(response.status)
+	if response.body != nil {
+		json.NewEncoder(rw).Encode(response.body)
+	}
+}
+
+// notFound is the body of an ARM 404 response
+func notFound(kind string) map[string]interface{} {
+	return map[string]interface{}{
+		"error": map[string]string{
+			"code":    kind + "NotFound",
+			"message": fmt.Sprintf("The %s was not found.", kind),
+		},
+	}
+}
+
+// fakeAKSClient stores the configs updated through it, the methods the handler does not use are not implemented
+type fakeAKSClient struct {
+	v10.AKSClusterConfigClient
+	mu      sync.Mutex
+	configs map[string]*aksv1.AKSClusterConfig
+	// conflicts is the number of status updates failing with a conflict before they are stored
+	conflicts int
+}
+
+func (c *fakeAKSClient) Get(namespace, name string, _ v15.GetOptions) (*aksv1.AKSClusterConfig, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	config, ok := c.configs[namespace+"/"+name]
+	if !ok {
+		return nil, errors.NewNotFound(schema.GroupResource{Group: "aks.cattle.io", Resource: "aksclusterconfigs"}, name)
+	}
+	return config.DeepCopy(), nil
+}
+
+func (c *fakeAKSClient) Update(config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.configs[config.Namespace+"/"+config.Name] = config.DeepCopy()
+	return config.DeepCopy(), nil
+}
+
+func (c *fakeAKSClient) UpdateStatus(config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
+	c.mu.Lock()
+	if c.conflicts > 0 {
+		c.conflicts--
+		c.mu.Unlock()
+		return nil, errors.NewConflict(schema.GroupResource{Group: "aks.cattle.io", Resource: "aksclusterconfigs"}, config.Name,
+			fmt.Errorf("the object has been modified; please apply your changes to the latest version and try again"))
+	}
+	c.mu.Unlock()
+	return c.Update(config)
+}
+
+// fakeAKSCache lists the configs of a fakeAKSClient
+type fakeAKSCache struct {
+	v10.AKSClusterConfigCache
+	client *fakeAKSClient
+}
+
+func (c *fakeAKSCache) Get(namespace, name string) (*aksv1.AKSClusterConfig, error) {
+	return c.client.Get(namespace, name, v15.GetOptions{})
+}
+
+func (c *fakeAKSCache) List(namespace string, _ labels.Selector) ([]*aksv1.AKSClusterConfig, error) {
+	c.client.mu.Lock()
+	defer c.client.mu.Unlock()
+	var configs []*aksv1.AKSClusterConfig
+	for _, config := range c.client.configs {
+		if namespace == "" || config.Namespace == namespace {
+			configs = append(configs, config.DeepCopy())
+		}
+	}
+	return configs, nil
+}
+
+func (c *fakeAKSCache) GetByIndex(indexName, key string) ([]*aksv1.AKSClusterConfig, error) {
+	configs, _ := c.List("", labels.Everything())
+	var matches []*aksv1.AKSClusterConfig
+	for _, config := range configs {
+		switch {
+		case indexName == clusterNameIndex && config.Spec.ClusterName == key:
+			matches = append(matches, config)
+		case indexName == credentialSecretIndex && config.Spec.AzureCredentialSecret != "" &&
+			credentialSecretKey(qualifiedCredentialSecret(config)) == key:
+			matches = append(matches, config)
+		}
+	}
+	return matches, nil
+}
+
+// fakeSecretCache holds the secrets of a test
+type fakeSecretCache struct {
+	wranglerv1.SecretCache
+	secrets map[string]*v1.Secret
+}
+
+func (c *fakeSecretCache) Get(namespace, name string) (*v1.Secret, error) {
+	secret, ok := c.secrets[namespace+"/"+name]
+	if !ok {
+		return nil, errors.NewNotFound(schema.GroupResource{Resource: "secrets"}, name)
+	}
+	return secret, nil
+}
+
+// fakeSecretClient stores the secrets updated through it in a fakeSecretCache
+type fakeSecretClient struct {
+	wranglerv1.SecretClient
+	cache *fakeSecretCache
+}
+
+func (c *fakeSecretClient) Update(secret *v1.Secret) (*v1.Secret, error) {
+	c.cache.secrets[secret.Namespace+"/"+secret.Name] = secret.DeepCopy()
+	return secret.DeepCopy(), nil
+}
+
+// testHandler is a Handler backed by fakes, along with the requeues it asked for
+type testHandler struct {
+	*Handler
+	client   *fakeAKSClient
+	azure    *fakeAzure
+	recorder *record.FakeRecorder
+	requeues []string
+	// requeueDelays are the delays of the requeues asked for with aksEnqueueAfter
+	requeueDelays []time.Duration
+	// secretRequeues are the secrets requeued with a delay
+	secretRequeues []string
+}
+
+// newTestHandler returns a handler whose configs are stored by a fakeAKSClient, with a credential secret named
+// testSecretName
+func newTestHandler(t *testing.T, configs ...*aksv1.AKSClusterConfig) *testHandler {
+	secretsCache := newFakeSecretCache()
+	th := &testHandler{
+		client:   &fakeAKSClient{configs: map[string]*aksv1.AKSClusterConfig{}},
+		azure:    newFakeAzure(t),
+		recorder: record.NewFakeRecorder(100),
+	}
+	for _, config := range configs {
+		th.client.configs[config.Namespace+"/"+config.Name] = config.DeepCopy()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	th.Handler = &Handler{
+		ctx:            ctx,
+		aksCC:          th.client,
+		aksCache:       &fakeAKSCache{client: th.client},
+		aksCacheSynced: func() bool { return true },
+		aksEnqueueAfter: func(namespace, name string, delay time.Duration) {
+			th.requeues = append(th.requeues, namespace+"/"+name)
+			th.requeueDelays = append(th.requeueDelays, delay)
+		},
+		aksEnqueue: func(namespace, name string) {
+			th.requeues = append(th.requeues, namespace+"/"+name)
+		},
+		secretsCache:   secretsCache,
+		secrets:        &fakeSecretClient{cache: secretsCache},
+		secretsEnqueue: func(namespace, name string) {},
+		secretsEnqueueAfter: func(namespace, name string, _ time.Duration) {
+			th.secretRequeues = append(th.secretRequeues, namespace+"/"+name)
+		},
+		egressIP:      &egressIPResolver{},
+		recorder:      th.recorder,
+		pollIntervals: newPollIntervals(0),
+		createTimeout: defaultCreateTimeout,
+	}
+	return th
+}
+
+// newFakeSecretCache returns a secret cache holding the credential secret testSecretName
+func newFakeSecretCache() *fakeSecretCache {
+	ns, name := utils.ParseSecretName(testSecretName)
+	return &fakeSecretCache{secrets: map[string]*v1.Secret{
+		ns + "/" + name: {
+			ObjectMeta: v15.ObjectMeta{Namespace: ns, Name: name},
+			Data: map[string][]byte{
+				"azurecredentialConfig-subscriptionId": []byte(testSubscriptionID),
+				"azurecredentialConfig-tenantId":       []byte(testTenantID),
+				"azurecredentialConfig-clientId":       []byte("client"),
+				"azurecredentialConfig-clientSecret":   []byte("secret"),
+			},
+		},
+	}}
+}
+
+// newTestConfig returns a config of the cluster "cluster" in the resource group "rg", sending its requests to th
+func (th *testHandler) newTestConfig() *aksv1.AKSClusterConfig {
+	return &aksv1.AKSClusterConfig{
+		ObjectMeta: v15.ObjectMeta{Namespace: "cattle-global-data", Name: "c-test"},
+		Spec: aksv1.AKSClusterConfigSpec{
+			ClusterName:           "cluster",
+			ResourceGroup:         "rg",
+			ResourceLocation:      "eastus",
+			AzureCredentialSecret: testSecretName,
+			BaseURL:               to.StringPtr(th.azure.server.URL),
+			AuthBaseURL:           to.StringPtr(th.azure.server.URL),
+		},
+	}
+}
+
+// armPath returns the ARM path of a resource of the test subscription
+func armPath(format string, args ...interface{}) string {
+	return "/subscriptions/" + testSubscriptionID + fmt.Sprintf(format, args...)
+}
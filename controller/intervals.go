@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"time"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// syncIntervalAnnotation overrides the drift sync period of a single cluster, e.g. "15m"
+const syncIntervalAnnotation = "aks.cattle.io/sync-interval"
+
+// minSyncInterval is the shortest drift sync period which can be requested by the sync interval annotation
+const minSyncInterval = 30 * time.Second
+
+// Options are the operator wide settings of the handler, their zero values keep the default behavior
+type Options struct {
+	// WaitInterval replaces the base poll intervals used while clusters are created or updated
+	WaitInterval time.Duration
+	// DriftSyncPeriod is the interval at which active clusters are compared against Azure. When it is zero they are
+	// only compared on changes and on the resync of the informer.
+	DriftSyncPeriod time.Duration
+	// CreateTimeout is how long a cluster may take to be created before its creation is reported as failed,
+	// defaultCreateTimeout is used when it is zero
+	CreateTimeout time.Duration
+	// WebhookAddress is the bind address of the validating webhook, it is not served when empty. WebhookCertFile and
+	// WebhookKeyFile hold its serving certificate.
+	WebhookAddress  string
+	WebhookCertFile string
+	WebhookKeyFile  string
+	// Requeues records the requeues scheduled by the handler for the debug server, nothing is recorded when it is nil
+	Requeues *Requeues
+}
+
+// defaultCreateTimeout is the default deadline for creating a cluster, Azure usually creates clusters within 15 minutes
+const defaultCreateTimeout = time.Hour
+
+// pollIntervals are the base intervals at which the handler polls Azure while waiting for an operation to finish.
+// Short operations like scaling a node pool are polled often, long ones like creating a cluster are polled rarely.
+type pollIntervals struct {
+	// nodePoolScale is used while node pools are created or scaled
+	nodePoolScale time.Duration
+	// nodePoolUpgrade is used while node pools are upgraded, deleted or in any other transitional state
+	nodePoolUpgrade time.Duration
+	// clusterCreate is used while the cluster is created
+	clusterCreate time.Duration
+	// clusterUpdate is used while the control plane is updated or upgraded
+	clusterUpdate time.Duration
+	// clusterDelete is used while the cluster and the resources created for it are deleted
+	clusterDelete time.Duration
+	// max is the upper bound of the interval once it has been lengthened for long running operations
+	max time.Duration
+}
+
+var defaultPollIntervals = pollIntervals{
+	nodePoolScale:   10 * time.Second,
+	nodePoolUpgrade: 30 * time.Second,
+	clusterCreate:   45 * time.Second,
+	clusterUpdate:   60 * time.Second,
+	clusterDelete:   45 * time.Second,
+	max:             2 * time.Minute,
+}
+
+// newPollIntervals returns the default poll intervals, or interval for every operation if it is set. The max interval is
+// raised to interval if needed.
+func newPollIntervals(interval time.Duration) pollIntervals {
+	if interval <= 0 {
+		return defaultPollIntervals
+	}
+	intervals := pollIntervals{
+		nodePoolScale:   interval,
+		nodePoolUpgrade: interval,
+		clusterCreate:   interval,
+		clusterUpdate:   interval,
+		clusterDelete:   interval,
+		max:             defaultPollIntervals.max,
+	}
+	if interval > intervals.max {
+		intervals.max = interval
+	}
+	return intervals
+}
+
+// clusterDriftSyncPeriod returns the interval after which the active cluster is compared against Azure again, or zero
+// to wait for the next change or informer resync. The sync interval annotation overrides the operator wide period.
+func (h *Handler) clusterDriftSyncPeriod(config *aksv1.AKSClusterConfig) time.Duration {
+	value, ok := config.Annotations[syncIntervalAnnotation]
+	if !ok {
+		return h.driftSyncPeriod
+	}
+	period, err := time.ParseDuration(value)
+	if err != nil || period < minSyncInterval {
+		logrus.Warnf("Ignoring annotation [%s] of cluster [%s], [%s] is not a duration of at least %v",
+			syncIntervalAnnotation, config.Name, value, minSyncInterval)
+		return h.driftSyncPeriod
+	}
+	return period
+}
+
+// pollBackoffStep is how long an operation has to run before its poll interval is doubled
+const pollBackoffStep = 10 * time.Minute
+
+// pollInterval returns the interval after which to check the operation again. The base interval is doubled for every
+// pollBackoffStep the operation has been running, measured from the last update sent to Azure, up to the max interval.
+func (h *Handler) pollInterval(config *aksv1.AKSClusterConfig, base time.Duration) time.Duration {
+	interval := base
+	if !config.Status.LastUpdateAppliedTime.IsZero() {
+		for elapsed := time.Since(config.Status.LastUpdateAppliedTime.Time); elapsed > pollBackoffStep && interval < h.pollIntervals.max; elapsed -= pollBackoffStep {
+			interval *= 2
+		}
+	}
+	if interval > h.pollIntervals.max {
+		return h.pollIntervals.max
+	}
+	return interval
+}
+
+const (
+	// throttledRetryDefault is the wait after Azure throttled a request without a Retry-After header
+	throttledRetryDefault = time.Minute
+	// errorBackoffMin and errorBackoffMax bound the wait before retrying after a transient Azure failure
+	errorBackoffMin = 15 * time.Second
+	errorBackoffMax = 10 * time.Minute
+	// retryJitterFactor is the maximum fraction added to retry waits, so that the clusters of a throttled
+	// subscription do not all retry at once
+	retryJitterFactor = 0.2
+)
+
+// throttledRetry returns how long to wait after Azure throttled a request, honoring its Retry-After header
+func throttledRetry(retryAfter time.Duration) time.Duration {
+	if retryAfter <= 0 {
+		retryAfter = throttledRetryDefault
+	}
+	return wait.Jitter(retryAfter, retryJitterFactor)
+}
+
+// errorBackoff returns how long to wait before retrying after a transient Azure failure. The wait is as long as the
+// failure has persisted since it was first recorded, so it doubles with every retry, bounded by errorBackoffMin and
+// errorBackoffMax.
+func errorBackoff(config *aksv1.AKSClusterConfig) time.Duration {
+	backoff := errorBackoffMin
+	if !config.Status.LastFailureTime.IsZero() {
+		if elapsed := time.Since(config.Status.LastFailureTime.Time); elapsed > backoff {
+			backoff = elapsed
+		}
+	}
+	if backoff > errorBackoffMax {
+		backoff = errorBackoffMax
+	}
+	return wait.Jitter(backoff, retryJitterFactor)
+}
@@ -0,0 +1,211 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+	v1 "k8s.io/api/core/v1"
+	v15 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/rancher/aks-operator/pkg/aks"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// azureResponseError returns an error like the ones returned by the Azure clients for a response with statusCode, code
+// and the Retry-After header retryAfter if it is set
+func azureResponseError(statusCode int, code, retryAfter string) error {
+	resp := &http.Response{StatusCode: statusCode, Header: http.Header{}}
+	if retryAfter != "" {
+		resp.Header.Set("Retry-After", retryAfter)
+	}
+	return &azure.RequestError{
+		DetailedError: autorest.DetailedError{StatusCode: statusCode, Response: resp},
+		ServiceError:  &azure.ServiceError{Code: code, Message: "request failed"},
+	}
+}
+
+// withinJitter returns true if delay is between base and base plus the retry jitter, allowing for the time the test
+// took
+func withinJitter(delay, base time.Duration) bool {
+	return delay > base-time.Second && delay <= base+time.Duration(float64(base)*retryJitterFactor)
+}
+
+func TestRecordErrorRetriesAzureFailures(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		lastFailure time.Duration
+		wantReason  string
+		wantDelay   time.Duration
+		// wantThrottled is true if throttledUntil is expected to be set
+		wantThrottled bool
+	}{
+		{
+			name:          "throttled with Retry-After",
+			err:           azureResponseError(http.StatusTooManyRequests, "TooManyRequests", "120"),
+			wantReason:    failureReasonThrottled,
+			wantDelay:     2 * time.Minute,
+			wantThrottled: true,
+		},
+		{
+			name:          "throttled without Retry-After",
+			err:           azureResponseError(http.StatusTooManyRequests, "SubscriptionRequestsThrottled", ""),
+			wantReason:    failureReasonThrottled,
+			wantDelay:     throttledRetryDefault,
+			wantThrottled: true,
+		},
+		{
+			name:       "first transient failure",
+			err:        azureResponseError(http.StatusInternalServerError, "InternalServerError", ""),
+			wantReason: failureReasonUnknown,
+			wantDelay:  errorBackoffMin,
+		},
+		{
+			name:        "transient failure lasting for a while",
+			err:         azureResponseError(http.StatusInternalServerError, "InternalServerError", ""),
+			lastFailure: 2 * time.Minute,
+			wantReason:  failureReasonUnknown,
+			wantDelay:   2 * time.Minute,
+		},
+		{
+			name:        "transient failure lasting for hours",
+			err:         azureResponseError(http.StatusInternalServerError, "InternalServerError", ""),
+			lastFailure: 3 * time.Hour,
+			wantReason:  failureReasonUnknown,
+			wantDelay:   errorBackoffMax,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+			config := th.newTestConfig()
+			config.Status.Phase = aksConfigActivePhase
+			if tt.lastFailure > 0 {
+				// the failure was recorded before with the same message, the status is not written again
+				message := truncateFailureMessage(aks.ErrorMessage(tt.err))
+				config.Status.FailureMessage = message
+				config.Status.FailureReason = tt.wantReason
+				config.Status.FailureCode = aks.ErrorCode(tt.err)
+				config.Status.LastFailureTime = v15.NewTime(time.Now().Add(-tt.lastFailure))
+				setCondition(&config.Status, config.Generation, conditionReady, v1.ConditionFalse, tt.wantReason, message)
+			}
+			th.client.configs[config.Namespace+"/"+config.Name] = config.DeepCopy()
+
+			updated, err := th.recordError(func(string, *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
+				return config, tt.err
+			})("", config)
+			if err != nil {
+				t.Errorf("expected the failure to be retried by the handler rather than the workqueue, got %v", err)
+			}
+			if updated.Status.FailureReason != tt.wantReason {
+				t.Errorf("expected failure reason %q, got %q", tt.wantReason, updated.Status.FailureReason)
+			}
+			if throttled := !updated.Status.ThrottledUntil.IsZero(); throttled != tt.wantThrottled {
+				t.Errorf("expected throttledUntil to be set: %v, got %v", tt.wantThrottled, updated.Status.ThrottledUntil)
+			}
+			if len(th.requeueDelays) != 1 {
+				t.Fatalf("expected one requeue, got %v", th.requeueDelays)
+			}
+			if delay := th.requeueDelays[0]; !withinJitter(delay, tt.wantDelay) {
+				t.Errorf("expected a requeue after %v plus jitter, got %v", tt.wantDelay, delay)
+			}
+		})
+	}
+}
+
+func TestRecordErrorWhileThrottled(t *testing.T) {
+	th := newTestHandler(t)
+	config := th.newTestConfig()
+	config.Status.ThrottledUntil = v15.NewTime(time.Now().Add(time.Minute))
+
+	var reconciled bool
+	_, err := th.recordError(func(string, *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
+		reconciled = true
+		return config, nil
+	})("", config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reconciled {
+		t.Error("expected the config not to be reconciled while throttled")
+	}
+	if len(th.requeueDelays) != 1 || th.requeueDelays[0] > time.Minute || th.requeueDelays[0] < 59*time.Second {
+		t.Errorf("expected a requeue once the config is no longer throttled, got %v", th.requeueDelays)
+	}
+}
+
+func TestRecordErrorReturnsOtherFailures(t *testing.T) {
+	th := newTestHandler(t)
+	config := th.newTestConfig()
+	th.client.configs[config.Namespace+"/"+config.Name] = config.DeepCopy()
+
+	failure := invalidSpecError{errors.New("node pool [pool] is invalid")}
+	_, err := th.recordError(func(string, *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
+		return config, failure
+	})("", config)
+	if err != failure {
+		t.Errorf("expected the failure to be retried by the workqueue, got %v", err)
+	}
+	if len(th.requeues) != 0 {
+		t.Errorf("expected no requeue by the handler, got %v", th.requeues)
+	}
+}
+
+func TestRetryTransientErrorRecordsRequeue(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		// wantBackoff is true if the requeue is reported with an error backoff
+		wantBackoff bool
+	}{
+		{
+			name:        "transient failure",
+			err:         azureResponseError(http.StatusInternalServerError, "InternalServerError", ""),
+			wantBackoff: true,
+		},
+		{
+			name: "throttled",
+			err:  azureResponseError(http.StatusTooManyRequests, "TooManyRequests", "120"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+			requeues := NewRequeues()
+			th.Handler.requeues = requeues
+			config := th.newTestConfig()
+			config.Status.Phase = aksConfigActivePhase
+			th.client.configs[config.Namespace+"/"+config.Name] = config.DeepCopy()
+
+			if _, err := th.recordError(func(string, *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
+				return config, tt.err
+			})("", config); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(th.requeueDelays) != 1 {
+				t.Fatalf("expected one requeue, got %v", th.requeueDelays)
+			}
+			delay := th.requeueDelays[0]
+			next, backoff := requeues.NextRequeue(config.Namespace, config.Name)
+			if until := time.Until(next); until > delay || until < delay-time.Second {
+				t.Errorf("expected the next requeue in %v, got %v", delay, until)
+			}
+			var wantBackoff time.Duration
+			if tt.wantBackoff {
+				wantBackoff = delay
+			}
+			if backoff != wantBackoff {
+				t.Errorf("expected error backoff %v, got %v", wantBackoff, backoff)
+			}
+
+			requeues.remove(config.Namespace, config.Name)
+			if next, _ := requeues.NextRequeue(config.Namespace, config.Name); !next.IsZero() {
+				t.Errorf("expected no requeue once the config is removed, got %v", next)
+			}
+		})
+	}
+}
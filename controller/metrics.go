@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"time"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	"github.com/rancher/aks-operator/pkg/metrics"
+)
+
+// recordReconcileMetrics records the duration and result of each reconcile by onChange, labelled with the phase the
+// config was in when the reconcile started. Configs which are being deleted are not recorded.
+func recordReconcileMetrics(onChange func(key string, config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error)) func(key string, config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
+	return func(key string, config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
+		if config == nil || config.DeletionTimestamp != nil {
+			return onChange(key, config)
+		}
+
+		phase := config.Status.Phase
+		start := time.Now()
+		result, err := onChange(key, config)
+		if err != nil {
+			metrics.ObserveReconcile(phase, metrics.ResultError, time.Since(start))
+		} else {
+			metrics.ObserveReconcile(phase, metrics.ResultSuccess, time.Since(start))
+		}
+		return result, err
+	}
+}
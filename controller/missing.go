@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"github.com/Azure/go-autorest/autorest/to"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// aksConfigMissingPhase is the phase of configs whose cluster was deleted from Azure by something other than the
+	// operator, nothing is reconciled in this phase
+	aksConfigMissingPhase         = "missing"
+	eventReasonClusterMissing     = "ClusterMissing"
+	conditionReasonClusterMissing = "ClusterMissing"
+)
+
+// recreateMissingCluster returns true if the cluster of config is created again when it was deleted out of band.
+// Imported clusters are never recreated.
+func recreateMissingCluster(config *aksv1.AKSClusterConfig) bool {
+	return !config.Spec.Imported && to.Bool(config.Spec.RecreateOnDelete)
+}
+
+// clusterMissing handles a cluster which no longer exists in Azure although its config is active. The config goes back
+// to the creating path if recreateOnDelete is set, otherwise it is moved to the missing phase until it is removed or
+// recreateOnDelete is set.
+func (h *Handler) clusterMissing(config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
+	if recreateMissingCluster(config) {
+		logrus.Infof("Cluster [%s] was deleted outside of the operator, recreating it", config.Spec.ClusterName)
+		h.recorder.Eventf(config, v1.EventTypeWarning, eventReasonClusterMissing,
+			"Cluster [%s] was deleted outside of the operator, recreating it", config.Spec.ClusterName)
+
+		config = config.DeepCopy()
+		config.Status.Phase = aksConfigNotCreatedPhase
+		setCondition(&config.Status, config.Generation, conditionProvisioned, v1.ConditionFalse, conditionReasonClusterMissing,
+			"the cluster was deleted outside of the operator and is recreated")
+		setCondition(&config.Status, config.Generation, conditionReady, v1.ConditionFalse, conditionReasonClusterMissing,
+			"the cluster was deleted outside of the operator and is recreated")
+		return h.updateStatus(config)
+	}
+
+	if config.Status.Phase == aksConfigMissingPhase {
+		return config, nil
+	}
+
+	logrus.Warnf("Cluster [%s] was deleted outside of the operator", config.Spec.ClusterName)
+	hint := "remove its config or set recreateOnDelete"
+	if config.Spec.Imported {
+		hint = "remove its config"
+	}
+	h.recorder.Eventf(config, v1.EventTypeWarning, eventReasonClusterMissing,
+		"Cluster [%s] was deleted outside of the operator, %s", config.Spec.ClusterName, hint)
+
+	config = config.DeepCopy()
+	config.Status.Phase = aksConfigMissingPhase
+	setCondition(&config.Status, config.Generation, conditionProvisioned, v1.ConditionFalse, conditionReasonClusterMissing,
+		"the cluster was deleted outside of the operator")
+	setCondition(&config.Status, config.Generation, conditionReady, v1.ConditionFalse, conditionReasonClusterMissing,
+		"the cluster was deleted outside of the operator")
+	return h.updateStatus(config)
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	v1 "k8s.io/api/core/v1"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+func TestCheckAndUpdateClusterMissing(t *testing.T) {
+	tests := []struct {
+		name             string
+		imported         bool
+		recreateOnDelete *bool
+		wantPhase        string
+	}{
+		{
+			name:      "marked missing by default",
+			wantPhase: aksConfigMissingPhase,
+		},
+		{
+			name:             "recreated",
+			recreateOnDelete: to.BoolPtr(true),
+			wantPhase:        aksConfigNotCreatedPhase,
+		},
+		{
+			name:             "marked missing without recreateOnDelete",
+			recreateOnDelete: to.BoolPtr(false),
+			wantPhase:        aksConfigMissingPhase,
+		},
+		{
+			name:             "imported cluster is never recreated",
+			imported:         true,
+			recreateOnDelete: to.BoolPtr(true),
+			wantPhase:        aksConfigMissingPhase,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+			config := th.newTestConfig()
+			config.Status.Phase = aksConfigActivePhase
+			config.Spec.Imported = tt.imported
+			config.Spec.RecreateOnDelete = tt.recreateOnDelete
+			th.client.configs[config.Namespace+"/"+config.Name] = config.DeepCopy()
+			th.azure.on(http.MethodGet, testClusterPath, http.StatusNotFound, notFound("ResourceNotFound"))
+
+			updated, err := th.checkAndUpdate(config)
+			if err != nil {
+				t.Fatalf("expected the missing cluster not to be reported as an error, got %v", err)
+			}
+			if updated.Status.Phase != tt.wantPhase {
+				t.Errorf("expected phase %q, got %q", tt.wantPhase, updated.Status.Phase)
+			}
+			for _, conditionType := range []string{conditionProvisioned, conditionReady} {
+				condition := findCondition(updated.Status.Conditions, conditionType)
+				if condition == nil || condition.Status != v1.ConditionFalse || condition.Reason != conditionReasonClusterMissing {
+					t.Errorf("expected condition %s to be False with reason %s, got %+v", conditionType, conditionReasonClusterMissing, condition)
+				}
+			}
+			if len(th.recorder.Events) != 1 {
+				t.Errorf("expected one event, got %d", len(th.recorder.Events))
+			}
+		})
+	}
+}
+
+func TestClusterMissingStopsRetrying(t *testing.T) {
+	th := newTestHandler(t)
+	config := th.newTestConfig()
+	config.Status.Phase = aksConfigMissingPhase
+	th.client.configs[config.Namespace+"/"+config.Name] = config.DeepCopy()
+
+	updated, err := th.OnAksConfigChanged("", config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.Status.Phase != aksConfigMissingPhase {
+		t.Errorf("expected the config to stay in phase %q, got %q", aksConfigMissingPhase, updated.Status.Phase)
+	}
+	if requests := th.azure.recorded(); len(requests) != 0 {
+		t.Errorf("expected no requests to Azure, got %v", requests)
+	}
+	if len(th.requeues) != 0 || len(th.recorder.Events) != 0 {
+		t.Errorf("expected no requeues and no events, got %v and %d events", th.requeues, len(th.recorder.Events))
+	}
+
+	// setting recreateOnDelete moves the config back to the creating path
+	config.Spec.RecreateOnDelete = to.BoolPtr(true)
+	updated, err = th.OnAksConfigChanged("", config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.Status.Phase != aksConfigNotCreatedPhase {
+		t.Errorf("expected phase %q once recreateOnDelete is set, got %q", aksConfigNotCreatedPhase, updated.Status.Phase)
+	}
+}
+
+// findCondition returns the condition of conditionType, nil if there is none
+func findCondition(conditions []aksv1.AKSClusterConfigCondition, conditionType string) *aksv1.AKSClusterConfigCondition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,571 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/rancher/aks-operator/pkg/aks"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	"github.com/rancher/wrangler/pkg/merr"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	eventReasonNodePoolUpgrade = "NodePoolUpgrade"
+
+	// the Windows OS SKUs are accepted by Azure but missing from the OSSKU values of the containerservice API version
+	osSKUWindows2019 = "Windows2019"
+	osSKUWindows2022 = "Windows2022"
+)
+
+var (
+	// subnetIDPattern matches the resource ID of a virtual network subnet
+	subnetIDPattern = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.Network/virtualNetworks/[^/]+/subnets/[^/]+$`)
+	// snapshotIDPattern matches the resource ID of a node pool snapshot
+	snapshotIDPattern = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.ContainerService/snapshots/[^/]+$`)
+	// proximityPlacementGroupIDPattern matches the resource ID of a proximity placement group
+	proximityPlacementGroupIDPattern = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.Compute/proximityPlacementGroups/[^/]+$`)
+	// hostGroupIDPattern matches the resource ID of a dedicated host group
+	hostGroupIDPattern = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.Compute/hostGroups/[^/]+$`)
+)
+
+// validateNodePools rejects node pools with invalid or conflicting settings: a pinned orchestrator version while
+// following the cluster version, malformed subnet, snapshot, placement group and host group IDs, OS SKUs not matching
+// the OS type, unknown GPU instance profiles and workload runtimes, UltraSSD without availability zones, Linux OS
+// settings on Windows node pools, autoscaling ranges not containing the count, invalid max surge values, deallocating
+// spot node pools, spot settings on regular node pools and spot system node pools
+func validateNodePools(spec *aksv1.AKSClusterConfigSpec) []error {
+	var errs []error
+	for _, np := range spec.NodePools {
+		if to.Bool(np.FollowClusterVersion) && np.OrchestratorVersion != nil {
+			errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config cannot set both orchestratorVersion and followClusterVersion",
+				to.String(np.Name), spec.ClusterName))
+		}
+
+		if np.VnetSubnetID != nil && !subnetIDPattern.MatchString(*np.VnetSubnetID) {
+			errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config has invalid vnetSubnetID [%s], must be a subnet resource ID",
+				to.String(np.Name), spec.ClusterName, *np.VnetSubnetID))
+		}
+		if np.PodSubnetID != nil && !subnetIDPattern.MatchString(*np.PodSubnetID) {
+			errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config has invalid podSubnetID [%s], must be a subnet resource ID",
+				to.String(np.Name), spec.ClusterName, *np.PodSubnetID))
+		}
+
+		if np.SnapshotID != nil && !snapshotIDPattern.MatchString(*np.SnapshotID) {
+			errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config has invalid snapshotId [%s], must be a node pool snapshot resource ID",
+				to.String(np.Name), spec.ClusterName, *np.SnapshotID))
+		}
+
+		if np.ProximityPlacementGroupID != nil && !proximityPlacementGroupIDPattern.MatchString(*np.ProximityPlacementGroupID) {
+			errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config has invalid proximityPlacementGroupID [%s], must be a proximity placement group resource ID",
+				to.String(np.Name), spec.ClusterName, *np.ProximityPlacementGroupID))
+		}
+		if np.HostGroupID != nil && !hostGroupIDPattern.MatchString(*np.HostGroupID) {
+			errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config has invalid hostGroupID [%s], must be a dedicated host group resource ID",
+				to.String(np.Name), spec.ClusterName, *np.HostGroupID))
+		}
+
+		windows := strings.EqualFold(np.OsType, string(containerservice.OSTypeWindows))
+		switch np.OsSKU {
+		case "":
+		case string(containerservice.OSSKUUbuntu), string(containerservice.OSSKUCBLMariner):
+			if windows {
+				errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config cannot use osSku [%s] on a Windows node pool",
+					to.String(np.Name), spec.ClusterName, np.OsSKU))
+			}
+		case osSKUWindows2019, osSKUWindows2022:
+			if !windows {
+				errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config can only use osSku [%s] on a Windows node pool",
+					to.String(np.Name), spec.ClusterName, np.OsSKU))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config has invalid osSku [%s], must be Ubuntu, CBLMariner, Windows2019 or Windows2022",
+				to.String(np.Name), spec.ClusterName, np.OsSKU))
+		}
+
+		switch np.GpuInstanceProfile {
+		case "", string(containerservice.GPUInstanceProfileMIG1g), string(containerservice.GPUInstanceProfileMIG2g), string(containerservice.GPUInstanceProfileMIG3g),
+			string(containerservice.GPUInstanceProfileMIG4g), string(containerservice.GPUInstanceProfileMIG7g):
+		default:
+			errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config has invalid gpuInstanceProfile [%s], must be MIG1g, MIG2g, MIG3g, MIG4g or MIG7g",
+				to.String(np.Name), spec.ClusterName, np.GpuInstanceProfile))
+		}
+		switch np.WorkloadRuntime {
+		case "", string(containerservice.WorkloadRuntimeOCIContainer), string(containerservice.WorkloadRuntimeWasmWasi):
+		default:
+			errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config has invalid workloadRuntime [%s], must be OCIContainer or WasmWasi",
+				to.String(np.Name), spec.ClusterName, np.WorkloadRuntime))
+		}
+
+		if to.Bool(np.EnableUltraSSD) && (np.AvailabilityZones == nil || len(*np.AvailabilityZones) == 0) {
+			errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config cannot enable UltraSSD without availability zones",
+				to.String(np.Name), spec.ClusterName))
+		}
+		if np.LinuxOSConfig != nil && windows {
+			errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config cannot set linuxOSConfig on a Windows node pool",
+				to.String(np.Name), spec.ClusterName))
+		}
+		// the AKS API version of the operator cannot send net.ipv4.tcp_rmem and net.ipv4.tcp_wmem
+		if s := np.LinuxOSConfig; s != nil && s.Sysctls != nil {
+			if s.Sysctls.NetIpv4TCPRmem != nil {
+				errs = append(errs, fmt.Errorf("linuxOSConfig of node pool [%s] for cluster [%s] config sets netIpv4TcpRmem, which is not supported by the AKS API version of the operator",
+					to.String(np.Name), spec.ClusterName))
+			}
+			if s.Sysctls.NetIpv4TCPWmem != nil {
+				errs = append(errs, fmt.Errorf("linuxOSConfig of node pool [%s] for cluster [%s] config sets netIpv4TcpWmem, which is not supported by the AKS API version of the operator",
+					to.String(np.Name), spec.ClusterName))
+			}
+		}
+
+		if to.Bool(np.EnableAutoScaling) {
+			switch {
+			case np.MinCount == nil || np.MaxCount == nil:
+				errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config must set minCount and maxCount to enable autoscaling",
+					to.String(np.Name), spec.ClusterName))
+			case to.Int32(np.MinCount) > to.Int32(np.MaxCount):
+				errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config has minCount %d greater than maxCount %d",
+					to.String(np.Name), spec.ClusterName, to.Int32(np.MinCount), to.Int32(np.MaxCount)))
+			case np.Count != nil && (*np.Count < *np.MinCount || *np.Count > *np.MaxCount):
+				errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config has count %d outside of minCount %d and maxCount %d",
+					to.String(np.Name), spec.ClusterName, *np.Count, *np.MinCount, *np.MaxCount))
+			}
+		}
+
+		if np.UpgradeSettings != nil && np.UpgradeSettings.MaxSurge != "" && !validMaxSurge(np.UpgradeSettings.MaxSurge) {
+			errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config has invalid maxSurge [%s], must be a positive integer or a percentage like 33%%",
+				to.String(np.Name), spec.ClusterName, np.UpgradeSettings.MaxSurge))
+		}
+
+		switch np.ScaleDownMode {
+		case "", string(containerservice.ScaleDownModeDelete):
+		case string(containerservice.ScaleDownModeDeallocate):
+			if np.ScaleSetPriority == string(containerservice.ScaleSetPrioritySpot) {
+				errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config cannot use scaleDownMode Deallocate with scaleSetPriority Spot",
+					to.String(np.Name), spec.ClusterName))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config has invalid scaleDownMode [%s], must be Delete or Deallocate",
+				to.String(np.Name), spec.ClusterName, np.ScaleDownMode))
+		}
+
+		switch np.ScaleSetPriority {
+		case "", string(containerservice.ScaleSetPriorityRegular):
+			if np.SpotMaxPrice != nil || np.ScaleSetEvictionPolicy != "" {
+				errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config can only set spotMaxPrice and scaleSetEvictionPolicy with scaleSetPriority Spot",
+					to.String(np.Name), spec.ClusterName))
+			}
+		case string(containerservice.ScaleSetPrioritySpot):
+			if np.Mode != string(containerservice.AgentPoolModeUser) {
+				errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config must have mode User to use scaleSetPriority Spot",
+					to.String(np.Name), spec.ClusterName))
+			}
+			if np.ScaleSetEvictionPolicy != "" && np.ScaleSetEvictionPolicy != string(containerservice.ScaleSetEvictionPolicyDelete) &&
+				np.ScaleSetEvictionPolicy != string(containerservice.ScaleSetEvictionPolicyDeallocate) {
+				errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config has invalid scaleSetEvictionPolicy [%s], must be Delete or Deallocate",
+					to.String(np.Name), spec.ClusterName, np.ScaleSetEvictionPolicy))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config has invalid scaleSetPriority [%s], must be Regular or Spot",
+				to.String(np.Name), spec.ClusterName, np.ScaleSetPriority))
+		}
+	}
+	return errs
+}
+
+// scaleDownMode returns the scale-down mode of a node pool, Azure deletes the nodes of node pools without a mode
+func scaleDownMode(np *aksv1.AKSNodePool) string {
+	if np.ScaleDownMode == "" {
+		return string(containerservice.ScaleDownModeDelete)
+	}
+	return np.ScaleDownMode
+}
+
+// scaleSetPriority returns the priority of a node pool, node pools without a priority are regular
+func scaleSetPriority(np *aksv1.AKSNodePool) string {
+	if np.ScaleSetPriority == "" {
+		return string(containerservice.ScaleSetPriorityRegular)
+	}
+	return np.ScaleSetPriority
+}
+
+// applyUpstreamImmutableSettings takes the availability zones, subnets, snapshot, placement group, host group, OS SKU,
+// GPU instance profile, workload runtime, FIPS, disk encryption, UltraSSD and spot settings of an existing node pool
+// from upstream if the spec doesn't set them, the settings cannot be changed and must be sent unchanged when the node
+// pool is updated. The OS disk type is always taken from upstream, node pools created before it was sent to Azure may
+// differ from their spec. The kubelet and Linux OS settings are always taken from upstream as well, Azure fills in
+// settings the spec leaves unset.
+func applyUpstreamImmutableSettings(np, upstreamNodePool *aksv1.AKSNodePool) {
+	if upstreamNodePool.OsDiskType != "" {
+		np.OsDiskType = upstreamNodePool.OsDiskType
+	}
+	np.KubeletConfig = upstreamNodePool.KubeletConfig
+	np.LinuxOSConfig = upstreamNodePool.LinuxOSConfig
+	if np.AvailabilityZones == nil {
+		np.AvailabilityZones = upstreamNodePool.AvailabilityZones
+	}
+	if np.VnetSubnetID == nil {
+		np.VnetSubnetID = upstreamNodePool.VnetSubnetID
+	}
+	if np.PodSubnetID == nil {
+		np.PodSubnetID = upstreamNodePool.PodSubnetID
+	}
+	if np.OsSKU == "" {
+		np.OsSKU = upstreamNodePool.OsSKU
+	}
+	if np.SnapshotID == nil {
+		np.SnapshotID = upstreamNodePool.SnapshotID
+	}
+	if np.ProximityPlacementGroupID == nil {
+		np.ProximityPlacementGroupID = upstreamNodePool.ProximityPlacementGroupID
+	}
+	if np.HostGroupID == nil {
+		np.HostGroupID = upstreamNodePool.HostGroupID
+	}
+	if np.GpuInstanceProfile == "" {
+		np.GpuInstanceProfile = upstreamNodePool.GpuInstanceProfile
+	}
+	if np.WorkloadRuntime == "" {
+		np.WorkloadRuntime = upstreamNodePool.WorkloadRuntime
+	}
+	if np.EnableFIPS == nil {
+		np.EnableFIPS = upstreamNodePool.EnableFIPS
+	}
+	if np.EnableEncryptionAtHost == nil {
+		np.EnableEncryptionAtHost = upstreamNodePool.EnableEncryptionAtHost
+	}
+	if np.EnableUltraSSD == nil {
+		np.EnableUltraSSD = upstreamNodePool.EnableUltraSSD
+	}
+	if np.ScaleSetPriority != "" {
+		return
+	}
+	np.ScaleSetPriority = upstreamNodePool.ScaleSetPriority
+	np.ScaleSetEvictionPolicy = upstreamNodePool.ScaleSetEvictionPolicy
+	np.SpotMaxPrice = upstreamNodePool.SpotMaxPrice
+}
+
+// applyFollowClusterVersion sets the orchestrator version of node pools following the cluster version to the current
+// version of the upstream control plane. Node pools without an orchestrator version follow the cluster version if the
+// spec manages the Kubernetes version. The pools are therefore upgraded after the control plane has finished
+// upgrading, not together with it.
+func applyFollowClusterVersion(spec, upstreamSpec *aksv1.AKSClusterConfigSpec) {
+	if upstreamSpec.KubernetesVersion == nil || isUnmanaged(spec, unmanagedNodePools) ||
+		isUnmanaged(spec, unmanagedNodePoolsOrchestratorVersion) {
+		return
+	}
+	for i := range spec.NodePools {
+		np := &spec.NodePools[i]
+		if to.Bool(np.FollowClusterVersion) || (np.OrchestratorVersion == nil && spec.KubernetesVersion != nil) {
+			np.OrchestratorVersion = to.StringPtr(to.String(upstreamSpec.KubernetesVersion))
+		}
+	}
+}
+
+// withNodePoolDefaults returns a copy of spec in which the fields of spec.nodePoolDefaults are merged into every node
+// pool that doesn't set them. All validation, payloads and comparisons with the upstream cluster use this merged view,
+// so inherited values never show as drift and changing a default updates the pools inheriting it.
+func withNodePoolDefaults(spec *aksv1.AKSClusterConfigSpec) *aksv1.AKSClusterConfigSpec {
+	spec = spec.DeepCopy()
+	defaults := spec.NodePoolDefaults
+	if defaults == nil {
+		return spec
+	}
+
+	for i := range spec.NodePools {
+		np := &spec.NodePools[i]
+		if np.Count == nil {
+			np.Count = defaults.Count
+		}
+		if np.MaxPods == nil {
+			np.MaxPods = defaults.MaxPods
+		}
+		if np.VMSize == "" {
+			np.VMSize = defaults.VMSize
+		}
+		if np.OsDiskSizeGB == nil {
+			np.OsDiskSizeGB = defaults.OsDiskSizeGB
+		}
+		if np.OsDiskType == "" {
+			np.OsDiskType = defaults.OsDiskType
+		}
+		if np.Mode == "" {
+			np.Mode = defaults.Mode
+		}
+		if np.OsType == "" {
+			np.OsType = defaults.OsType
+		}
+		if np.AvailabilityZones == nil {
+			np.AvailabilityZones = defaults.AvailabilityZones
+		}
+		if np.EnableAutoScaling == nil {
+			np.EnableAutoScaling = defaults.EnableAutoScaling
+		}
+		if np.MinCount == nil {
+			np.MinCount = defaults.MinCount
+		}
+		if np.MaxCount == nil {
+			np.MaxCount = defaults.MaxCount
+		}
+		// a pinned version and following the cluster version are exclusive, only inherit them if the pool sets
+		// neither
+		if np.OrchestratorVersion == nil && np.FollowClusterVersion == nil {
+			np.OrchestratorVersion = defaults.OrchestratorVersion
+			np.FollowClusterVersion = defaults.FollowClusterVersion
+		}
+	}
+	// copy again so that the node pools don't share pointers with the defaults
+	return spec.DeepCopy()
+}
+
+const (
+	upgradeStageControlPlane    = "ControlPlane"
+	upgradeStageSystemNodePools = "SystemNodePools"
+	upgradeStageUserNodePools   = "UserNodePools"
+)
+
+// controlPlaneUpgradePending returns true if the Kubernetes version of the spec differs from the upstream control
+// plane, node pools are only upgraded once the control plane has been upgraded
+func controlPlaneUpgradePending(spec, upstreamSpec *aksv1.AKSClusterConfigSpec) bool {
+	return spec.KubernetesVersion != nil && to.String(spec.KubernetesVersion) != to.String(upstreamSpec.KubernetesVersion)
+}
+
+// nodePoolUpgrades returns the node pools of the spec whose orchestrator version differs from their upstream version,
+// in the order they are upgraded: System node pools before User node pools, each by upgrade priority
+func nodePoolUpgrades(spec *aksv1.AKSClusterConfigSpec, upstreamNodePools map[string]*aksv1.AKSNodePool) []*aksv1.AKSNodePool {
+	var upgrades []*aksv1.AKSNodePool
+	for i := range spec.NodePools {
+		np := &spec.NodePools[i]
+		upstreamNodePool, ok := upstreamNodePools[to.String(np.Name)]
+		if ok && np.OrchestratorVersion != nil && to.String(np.OrchestratorVersion) != to.String(upstreamNodePool.OrchestratorVersion) {
+			upgrades = append(upgrades, np)
+		}
+	}
+	sort.SliceStable(upgrades, func(i, j int) bool {
+		if system := isSystemNodePool(upgrades[i]); system != isSystemNodePool(upgrades[j]) {
+			return system
+		}
+		return to.Int32(upgrades[i].UpgradePriority) < to.Int32(upgrades[j].UpgradePriority)
+	})
+	return upgrades
+}
+
+func isSystemNodePool(np *aksv1.AKSNodePool) bool {
+	return np.Mode == string(containerservice.AgentPoolModeSystem)
+}
+
+// upgradeNodePools starts the upgrade of the next batch of node pools. The batch is only started once all node pools
+// have succeeded, so a failed upgrade stops the rollout. A batch never mixes System and User node pools, the User node
+// pools are only upgraded once every System node pool has been upgraded.
+func (h *Handler) upgradeNodePools(ctx context.Context, agentPoolClient *containerservice.AgentPoolsClient,
+	config *aksv1.AKSClusterConfig, spec *aksv1.AKSClusterConfigSpec, upstreamNodePools map[string]*aksv1.AKSNodePool,
+	upgrades []*aksv1.AKSNodePool) (*aksv1.AKSClusterConfig, error) {
+	batchSize := 1
+	if n := int(to.Int32(spec.MaxConcurrentNodePoolUpgrades)); n > 1 {
+		batchSize = n
+	}
+	stage := upgradeStageUserNodePools
+	if isSystemNodePool(upgrades[0]) {
+		stage = upgradeStageSystemNodePools
+	}
+	for i, upgrade := range upgrades {
+		if i == batchSize || isSystemNodePool(upgrade) != isSystemNodePool(upgrades[0]) {
+			upgrades = upgrades[:i]
+			break
+		}
+	}
+
+	progress := nodePoolUpgradeProgress(spec, upstreamNodePools)
+	var names []string
+	for _, upgrade := range upgrades {
+		np := *upgrade
+		applyUpstreamImmutableSettings(&np, upstreamNodePools[to.String(np.Name)])
+		logrus.Infof("Updating orchestrator version in node pool [%s] for cluster [%s] to [%s], %s",
+			to.String(np.Name), spec.ClusterName, to.String(np.OrchestratorVersion), progress)
+		if err := aks.CreateOrUpdateAgentPool(ctx, agentPoolClient, spec, &np); err != nil {
+			h.recorder.Eventf(config, v1.EventTypeWarning, eventReasonNodePoolUpgrade,
+				"Failed to upgrade node pool [%s] to [%s], rollout stopped with %s: %v",
+				to.String(np.Name), to.String(np.OrchestratorVersion), progress, err)
+			return config, fmt.Errorf("failed to update cluster: %w", err)
+		}
+		names = append(names, to.String(np.Name))
+	}
+
+	h.recorder.Eventf(config, v1.EventTypeNormal, eventReasonNodePoolUpgrade, "Upgrading node pools [%s], %s",
+		strings.Join(names, ", "), progress)
+	config = config.DeepCopy()
+	config.Status.NodePoolUpgradeProgress = progress
+	config.Status.UpgradeStage = stage
+	config.Status.UpgradingNodePools = names
+	return h.enqueueUpdate(config)
+}
+
+// nodePoolUpgradeProgress returns how many of the node pools with an orchestrator version in the spec run that version
+func nodePoolUpgradeProgress(spec *aksv1.AKSClusterConfigSpec, upstreamNodePools map[string]*aksv1.AKSNodePool) string {
+	var total, upgraded int
+	for _, np := range spec.NodePools {
+		upstreamNodePool, ok := upstreamNodePools[to.String(np.Name)]
+		if !ok || np.OrchestratorVersion == nil {
+			continue
+		}
+		total++
+		if to.String(np.OrchestratorVersion) == to.String(upstreamNodePool.OrchestratorVersion) {
+			upgraded++
+		}
+	}
+	return fmt.Sprintf("%d/%d node pools upgraded", upgraded, total)
+}
+
+// equalNodeTaints compares node taints regardless of their order, no taints and an empty list are equal
+func equalNodeTaints(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[string]int, len(a))
+	for _, taint := range a {
+		counts[taint]++
+	}
+	for _, taint := range b {
+		if counts[taint] == 0 {
+			return false
+		}
+		counts[taint]--
+	}
+	return true
+}
+
+// equalNodeLabels compares node labels, no labels and an empty map are equal
+func equalNodeLabels(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
+
+// validMaxSurge returns true if maxSurge is a positive integer or a percentage between 1% and 100%
+func validMaxSurge(maxSurge string) bool {
+	value, isPercentage := strings.TrimSuffix(maxSurge, "%"), strings.HasSuffix(maxSurge, "%")
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || strings.HasPrefix(value, "+") {
+		return false
+	}
+	return !isPercentage || n <= 100
+}
+
+// resourceIDChanged returns true if the spec sets a resource ID which differs from upstream, resource IDs are case
+// insensitive
+func resourceIDChanged(spec, upstream *string) bool {
+	return spec != nil && !strings.EqualFold(*spec, to.String(upstream))
+}
+
+// settingsChanged returns true if a setting set in spec differs from upstream. spec and upstream are pointers to
+// structs of the same type holding pointer, slice and struct pointer fields, fields which are not set in spec are
+// ignored since Azure fills in defaults for them.
+func settingsChanged(spec, upstream interface{}) bool {
+	specValue, upstreamValue := reflect.ValueOf(spec), reflect.ValueOf(upstream)
+	if specValue.IsNil() {
+		return false
+	}
+	if upstreamValue.IsNil() {
+		return true
+	}
+	specValue, upstreamValue = specValue.Elem(), upstreamValue.Elem()
+	for i := 0; i < specValue.NumField(); i++ {
+		field, upstreamField := specValue.Field(i), upstreamValue.Field(i)
+		if field.IsNil() {
+			continue
+		}
+		if field.Kind() == reflect.Ptr && field.Elem().Kind() == reflect.Struct {
+			if settingsChanged(field.Interface(), upstreamField.Interface()) {
+				return true
+			}
+			continue
+		}
+		if !reflect.DeepEqual(field.Interface(), upstreamField.Interface()) {
+			return true
+		}
+	}
+	return false
+}
+
+// updateNodePools sends the creation or update of every node pool in changes at once, Azure runs operations on
+// different node pools concurrently. A failing node pool does not stop the others, all failures are returned together
+// once the node pools which were sent are recorded.
+func (h *Handler) updateNodePools(ctx context.Context, agentPoolClient *containerservice.AgentPoolsClient, config *aksv1.AKSClusterConfig,
+	spec *aksv1.AKSClusterConfigSpec, changes []*aksv1.AKSNodePool, upstreamNodePools map[string]*aksv1.AKSNodePool) (*aksv1.AKSClusterConfig, error) {
+	var sent []string
+	var errs []error
+	for _, np := range changes {
+		name := to.String(np.Name)
+		_, exists := upstreamNodePools[name]
+		if err := aks.CreateOrUpdateAgentPool(ctx, agentPoolClient, spec, np); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update node pool [%s]: %w", name, err))
+			continue
+		}
+		if !exists {
+			h.recorder.Eventf(config, v1.EventTypeNormal, eventReasonNodePoolAdd, "Adding node pool [%s]", name)
+		}
+		sent = append(sent, name)
+	}
+	return h.nodePoolsSent(config, sent, errs)
+}
+
+// removeNodePools sends the removal of every node pool in removed at once. A failing node pool does not stop the
+// others, all failures are returned together once the node pools which were sent are recorded.
+func (h *Handler) removeNodePools(ctx context.Context, agentPoolClient *containerservice.AgentPoolsClient, config *aksv1.AKSClusterConfig,
+	spec *aksv1.AKSClusterConfigSpec, removed []*aksv1.AKSNodePool) (*aksv1.AKSClusterConfig, error) {
+	sort.Slice(removed, func(i, j int) bool {
+		return to.String(removed[i].Name) < to.String(removed[j].Name)
+	})
+
+	var sent []string
+	var errs []error
+	for _, np := range removed {
+		name := to.String(np.Name)
+		logrus.Infof("Removing node pool [%s] from cluster [%s]", name, spec.ClusterName)
+		if err := aks.RemoveAgentPool(ctx, agentPoolClient, spec, np); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove node pool [%s]: %w", name, err))
+			continue
+		}
+		h.recorder.Eventf(config, v1.EventTypeNormal, eventReasonNodePoolRemove, "Removing node pool [%s]", name)
+		sent = append(sent, name)
+	}
+
+	// the replacement of a recreated node pool is done once the replacing node pool is removed
+	for _, name := range sent {
+		for replaced, replacement := range config.Status.NodePoolReplacements {
+			if replacement == name {
+				config = config.DeepCopy()
+				delete(config.Status.NodePoolReplacements, replaced)
+			}
+		}
+	}
+	return h.nodePoolsSent(config, sent, errs)
+}
+
+// nodePoolsSent moves config to updating if any node pool operation was sent and returns the failed operations
+func (h *Handler) nodePoolsSent(config *aksv1.AKSClusterConfig, sent []string, errs []error) (*aksv1.AKSClusterConfig, error) {
+	if len(sent) == 0 {
+		return config, merr.NewErrors(errs...)
+	}
+
+	config = config.DeepCopy()
+	config.Status.UpdatingNodePools = sent
+	config, err := h.enqueueUpdate(config)
+	if err != nil {
+		return config, err
+	}
+	return config, merr.NewErrors(errs...)
+}
@@ -0,0 +1,589 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/to"
+
+	"github.com/rancher/aks-operator/pkg/aks"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+func TestResourceIDPatterns(t *testing.T) {
+	prefix := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg/providers/"
+	tests := []struct {
+		name    string
+		pattern *regexp.Regexp
+		id      string
+		want    bool
+	}{
+		{"subnet", subnetIDPattern, prefix + "Microsoft.Network/virtualNetworks/vnet/subnets/subnet", true},
+		{"subnet in lower case", subnetIDPattern, strings.ToLower(prefix + "Microsoft.Network/virtualNetworks/vnet/subnets/subnet"), true},
+		{"subnet without name", subnetIDPattern, prefix + "Microsoft.Network/virtualNetworks/vnet/subnets/", false},
+		{"virtual network instead of subnet", subnetIDPattern, prefix + "Microsoft.Network/virtualNetworks/vnet", false},
+		{"subnet name only", subnetIDPattern, "subnet", false},
+		{"subnet with trailing segment", subnetIDPattern, prefix + "Microsoft.Network/virtualNetworks/vnet/subnets/subnet/extra", false},
+		{"snapshot", snapshotIDPattern, prefix + "Microsoft.ContainerService/snapshots/snapshot", true},
+		{"snapshot of another provider", snapshotIDPattern, prefix + "Microsoft.Compute/snapshots/snapshot", false},
+		{"proximity placement group", proximityPlacementGroupIDPattern, prefix + "Microsoft.Compute/proximityPlacementGroups/ppg", true},
+		{"proximity placement group without subscription", proximityPlacementGroupIDPattern, "/resourceGroups/rg/providers/Microsoft.Compute/proximityPlacementGroups/ppg", false},
+		{"host group", hostGroupIDPattern, prefix + "Microsoft.Compute/hostGroups/hg", true},
+		{"host of a host group", hostGroupIDPattern, prefix + "Microsoft.Compute/hostGroups/hg/hosts/host", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pattern.MatchString(tt.id); got != tt.want {
+				t.Errorf("%s matching %q = %t, want %t", tt.pattern, tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkspaceMatches(t *testing.T) {
+	tests := []struct {
+		id        string
+		wantGroup string
+		wantName  string
+	}{
+		{"/subscriptions/s/resourceGroups/rg/providers/Microsoft.OperationalInsights/workspaces/ws", "rg", "ws"},
+		{"/subscriptions/s/resourcegroups/RG/providers/microsoft.operationalinsights/Workspaces/WS", "RG", "WS"},
+		{"/subscriptions/s/resourceGroups/rg/providers/Microsoft.OperationalInsights/workspaces/ws/", "rg", "ws"},
+	}
+	for _, tt := range tests {
+		group, name := matchWorkspaceGroup.FindStringSubmatch(tt.id), matchWorkspaceName.FindStringSubmatch(tt.id)
+		if len(group) < 2 || group[1] != tt.wantGroup || len(name) < 2 || name[1] != tt.wantName {
+			t.Errorf("workspace of %q = %v %v, want [%s] [%s]", tt.id, group, name, tt.wantGroup, tt.wantName)
+		}
+	}
+}
+
+func TestValidMaxSurge(t *testing.T) {
+	tests := []struct {
+		maxSurge string
+		want     bool
+	}{
+		{"1", true},
+		{"10", true},
+		{"1%", true},
+		{"33%", true},
+		{"100%", true},
+		{"0", false},
+		{"0%", false},
+		{"101%", false},
+		{"-1", false},
+		{"+1", false},
+		{"1.5", false},
+		{"%", false},
+		{"33%%", false},
+		{"abc", false},
+	}
+	for _, tt := range tests {
+		if got := validMaxSurge(tt.maxSurge); got != tt.want {
+			t.Errorf("validMaxSurge(%q) = %t, want %t", tt.maxSurge, got, tt.want)
+		}
+	}
+}
+
+func TestEqualNodeTaints(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want bool
+	}{
+		{"nil and empty", nil, []string{}, true},
+		{"same order", []string{"a=b:NoSchedule", "c=d:NoExecute"}, []string{"a=b:NoSchedule", "c=d:NoExecute"}, true},
+		{"different order", []string{"a=b:NoSchedule", "c=d:NoExecute"}, []string{"c=d:NoExecute", "a=b:NoSchedule"}, true},
+		{"different taint", []string{"a=b:NoSchedule"}, []string{"a=b:NoExecute"}, false},
+		{"missing taint", []string{"a=b:NoSchedule"}, nil, false},
+		{"duplicates differ", []string{"a=b:NoSchedule", "a=b:NoSchedule"}, []string{"a=b:NoSchedule", "c=d:NoExecute"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := equalNodeTaints(tt.a, tt.b); got != tt.want {
+				t.Errorf("equalNodeTaints(%v, %v) = %t, want %t", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEqualNodeLabels(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b map[string]string
+		want bool
+	}{
+		{"nil and empty", nil, map[string]string{}, true},
+		{"equal", map[string]string{"a": "1", "b": "2"}, map[string]string{"b": "2", "a": "1"}, true},
+		{"different value", map[string]string{"a": "1"}, map[string]string{"a": "2"}, false},
+		{"different key", map[string]string{"a": "1"}, map[string]string{"b": "1"}, false},
+		{"empty value and missing key", map[string]string{"a": ""}, map[string]string{"b": ""}, false},
+		{"missing label", map[string]string{"a": "1"}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := equalNodeLabels(tt.a, tt.b); got != tt.want {
+				t.Errorf("equalNodeLabels(%v, %v) = %t, want %t", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSettingsChanged(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     *aksv1.AKSLinuxOSConfig
+		upstream *aksv1.AKSLinuxOSConfig
+		want     bool
+	}{
+		{
+			name:     "no spec",
+			upstream: &aksv1.AKSLinuxOSConfig{SwapFileSizeMB: to.Int32Ptr(1024)},
+		},
+		{
+			name: "spec without upstream",
+			spec: &aksv1.AKSLinuxOSConfig{SwapFileSizeMB: to.Int32Ptr(1024)},
+			want: true,
+		},
+		{
+			name:     "unset fields are filled in by Azure",
+			spec:     &aksv1.AKSLinuxOSConfig{},
+			upstream: &aksv1.AKSLinuxOSConfig{SwapFileSizeMB: to.Int32Ptr(1024), TransparentHugePageEnabled: to.StringPtr("always")},
+		},
+		{
+			name:     "equal field",
+			spec:     &aksv1.AKSLinuxOSConfig{SwapFileSizeMB: to.Int32Ptr(1024)},
+			upstream: &aksv1.AKSLinuxOSConfig{SwapFileSizeMB: to.Int32Ptr(1024), TransparentHugePageEnabled: to.StringPtr("always")},
+		},
+		{
+			name:     "changed field",
+			spec:     &aksv1.AKSLinuxOSConfig{SwapFileSizeMB: to.Int32Ptr(2048)},
+			upstream: &aksv1.AKSLinuxOSConfig{SwapFileSizeMB: to.Int32Ptr(1024)},
+			want:     true,
+		},
+		{
+			name:     "equal nested field",
+			spec:     &aksv1.AKSLinuxOSConfig{Sysctls: &aksv1.AKSSysctlConfig{NetCoreSomaxconn: to.Int32Ptr(4096)}},
+			upstream: &aksv1.AKSLinuxOSConfig{Sysctls: &aksv1.AKSSysctlConfig{NetCoreSomaxconn: to.Int32Ptr(4096), NetCoreRmemMax: to.Int32Ptr(1)}},
+		},
+		{
+			name:     "changed nested field",
+			spec:     &aksv1.AKSLinuxOSConfig{Sysctls: &aksv1.AKSSysctlConfig{NetCoreSomaxconn: to.Int32Ptr(8192)}},
+			upstream: &aksv1.AKSLinuxOSConfig{Sysctls: &aksv1.AKSSysctlConfig{NetCoreSomaxconn: to.Int32Ptr(4096)}},
+			want:     true,
+		},
+		{
+			name:     "nested settings without upstream",
+			spec:     &aksv1.AKSLinuxOSConfig{Sysctls: &aksv1.AKSSysctlConfig{NetCoreSomaxconn: to.Int32Ptr(4096)}},
+			upstream: &aksv1.AKSLinuxOSConfig{},
+			want:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := settingsChanged(tt.spec, tt.upstream); got != tt.want {
+				t.Errorf("settingsChanged() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+
+	kubeletSpec := &aksv1.AKSKubeletConfig{AllowedUnsafeSysctls: []string{"net.core.*"}}
+	kubeletUpstream := &aksv1.AKSKubeletConfig{AllowedUnsafeSysctls: []string{"kernel.msg*"}}
+	if !settingsChanged(kubeletSpec, kubeletUpstream) {
+		t.Error("expected a changed slice field to be a change")
+	}
+}
+
+func TestWithNodePoolDefaults(t *testing.T) {
+	spec := &aksv1.AKSClusterConfigSpec{
+		NodePoolDefaults: &aksv1.AKSNodePool{
+			Name:                 to.StringPtr("ignored"),
+			Count:                to.Int32Ptr(3),
+			VMSize:               "Standard_D2s_v3",
+			Mode:                 "User",
+			AvailabilityZones:    &[]string{"1", "2"},
+			FollowClusterVersion: to.BoolPtr(true),
+		},
+		NodePools: []aksv1.AKSNodePool{
+			{Name: to.StringPtr("inherits")},
+			{Name: to.StringPtr("overrides"), Count: to.Int32Ptr(1), VMSize: "Standard_D4s_v3", Mode: "System"},
+			{Name: to.StringPtr("pinned"), OrchestratorVersion: to.StringPtr("1.23.5")},
+		},
+	}
+
+	merged := withNodePoolDefaults(spec)
+
+	inherits := merged.NodePools[0]
+	if to.String(inherits.Name) != "inherits" || to.Int32(inherits.Count) != 3 || inherits.VMSize != "Standard_D2s_v3" ||
+		inherits.Mode != "User" || !reflect.DeepEqual(inherits.AvailabilityZones, &[]string{"1", "2"}) ||
+		!to.Bool(inherits.FollowClusterVersion) {
+		t.Errorf("expected the node pool to inherit the defaults, got %+v", inherits)
+	}
+	overrides := merged.NodePools[1]
+	if to.Int32(overrides.Count) != 1 || overrides.VMSize != "Standard_D4s_v3" || overrides.Mode != "System" {
+		t.Errorf("expected the values of the node pool to take precedence, got %+v", overrides)
+	}
+	pinned := merged.NodePools[2]
+	if to.String(pinned.OrchestratorVersion) != "1.23.5" || pinned.FollowClusterVersion != nil {
+		t.Errorf("expected a pinned node pool not to follow the cluster version, got %+v", pinned)
+	}
+
+	if spec.NodePools[0].Count != nil {
+		t.Error("expected the spec not to be modified")
+	}
+	(*merged.NodePools[0].AvailabilityZones)[0] = "3"
+	if (*merged.NodePools[1].AvailabilityZones)[0] != "1" || (*spec.NodePoolDefaults.AvailabilityZones)[0] != "1" {
+		t.Error("expected the node pools not to share the defaults")
+	}
+
+	withoutDefaults := &aksv1.AKSClusterConfigSpec{NodePools: []aksv1.AKSNodePool{{Name: to.StringPtr("pool")}}}
+	if merged := withNodePoolDefaults(withoutDefaults); !reflect.DeepEqual(merged, withoutDefaults) || merged == withoutDefaults {
+		t.Errorf("expected a copy of a spec without defaults, got %+v", merged)
+	}
+}
+
+func TestValidateNodePools(t *testing.T) {
+	subnetID := "/subscriptions/s/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet"
+	tests := []struct {
+		name     string
+		nodePool aksv1.AKSNodePool
+		wantErr  string
+	}{
+		{
+			name:     "valid",
+			nodePool: aksv1.AKSNodePool{Mode: "System", OsType: "Linux", VnetSubnetID: to.StringPtr(subnetID), OsSKU: "Ubuntu"},
+		},
+		{
+			name:     "pinned version and following the cluster version",
+			nodePool: aksv1.AKSNodePool{OrchestratorVersion: to.StringPtr("1.23.5"), FollowClusterVersion: to.BoolPtr(true)},
+			wantErr:  "cannot set both orchestratorVersion and followClusterVersion",
+		},
+		{
+			name:     "subnet name instead of ID",
+			nodePool: aksv1.AKSNodePool{VnetSubnetID: to.StringPtr("subnet")},
+			wantErr:  "invalid vnetSubnetID [subnet]",
+		},
+		{
+			name:     "pod subnet of a virtual network",
+			nodePool: aksv1.AKSNodePool{PodSubnetID: to.StringPtr(strings.TrimSuffix(subnetID, "/subnets/subnet"))},
+			wantErr:  "invalid podSubnetID",
+		},
+		{
+			name:     "malformed snapshot",
+			nodePool: aksv1.AKSNodePool{SnapshotID: to.StringPtr("snapshot")},
+			wantErr:  "invalid snapshotId",
+		},
+		{
+			name:     "malformed host group",
+			nodePool: aksv1.AKSNodePool{HostGroupID: to.StringPtr("hg")},
+			wantErr:  "invalid hostGroupID",
+		},
+		{
+			name:     "Linux OS SKU on Windows",
+			nodePool: aksv1.AKSNodePool{OsType: "Windows", OsSKU: "Ubuntu"},
+			wantErr:  "cannot use osSku [Ubuntu] on a Windows node pool",
+		},
+		{
+			name:     "Windows OS SKU on Linux",
+			nodePool: aksv1.AKSNodePool{OsType: "Linux", OsSKU: "Windows2022"},
+			wantErr:  "can only use osSku [Windows2022] on a Windows node pool",
+		},
+		{
+			name:     "UltraSSD without zones",
+			nodePool: aksv1.AKSNodePool{EnableUltraSSD: to.BoolPtr(true)},
+			wantErr:  "cannot enable UltraSSD without availability zones",
+		},
+		{
+			name:     "autoscaling without range",
+			nodePool: aksv1.AKSNodePool{EnableAutoScaling: to.BoolPtr(true), MinCount: to.Int32Ptr(1)},
+			wantErr:  "must set minCount and maxCount",
+		},
+		{
+			name:     "count outside autoscaling range",
+			nodePool: aksv1.AKSNodePool{EnableAutoScaling: to.BoolPtr(true), MinCount: to.Int32Ptr(1), MaxCount: to.Int32Ptr(3), Count: to.Int32Ptr(5)},
+			wantErr:  "count 5 outside of minCount 1 and maxCount 3",
+		},
+		{
+			name:     "invalid max surge",
+			nodePool: aksv1.AKSNodePool{UpgradeSettings: &aksv1.AKSUpgradeSettings{MaxSurge: "150%"}},
+			wantErr:  "invalid maxSurge [150%]",
+		},
+		{
+			name:     "deallocating spot node pool",
+			nodePool: aksv1.AKSNodePool{Mode: "User", ScaleSetPriority: "Spot", ScaleDownMode: "Deallocate"},
+			wantErr:  "cannot use scaleDownMode Deallocate with scaleSetPriority Spot",
+		},
+		{
+			name:     "spot system node pool",
+			nodePool: aksv1.AKSNodePool{Mode: "System", ScaleSetPriority: "Spot"},
+			wantErr:  "must have mode User to use scaleSetPriority Spot",
+		},
+		{
+			name:     "spot settings on a regular node pool",
+			nodePool: aksv1.AKSNodePool{ScaleSetEvictionPolicy: "Delete"},
+			wantErr:  "can only set spotMaxPrice and scaleSetEvictionPolicy with scaleSetPriority Spot",
+		},
+		{
+			name:     "supported sysctls",
+			nodePool: aksv1.AKSNodePool{LinuxOSConfig: &aksv1.AKSLinuxOSConfig{Sysctls: &aksv1.AKSSysctlConfig{NetCoreRmemMax: to.Int32Ptr(212992)}}},
+		},
+		{
+			name:     "TCP receive buffer sysctl",
+			nodePool: aksv1.AKSNodePool{LinuxOSConfig: &aksv1.AKSLinuxOSConfig{Sysctls: &aksv1.AKSSysctlConfig{NetIpv4TCPRmem: to.Int32Ptr(4096)}}},
+			wantErr:  "sets netIpv4TcpRmem, which is not supported by the AKS API version of the operator",
+		},
+		{
+			name:     "TCP send buffer sysctl",
+			nodePool: aksv1.AKSNodePool{LinuxOSConfig: &aksv1.AKSLinuxOSConfig{Sysctls: &aksv1.AKSSysctlConfig{NetIpv4TCPWmem: to.Int32Ptr(4096)}}},
+			wantErr:  "sets netIpv4TcpWmem, which is not supported by the AKS API version of the operator",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			np := tt.nodePool
+			np.Name = to.StringPtr("pool")
+			errs := validateNodePools(&aksv1.AKSClusterConfigSpec{ClusterName: "cluster", NodePools: []aksv1.AKSNodePool{np}})
+			if tt.wantErr == "" {
+				if len(errs) != 0 {
+					t.Errorf("expected no errors, got %v", errs)
+				}
+				return
+			}
+			if len(errs) != 1 || !strings.Contains(errs[0].Error(), tt.wantErr) {
+				t.Errorf("expected one error containing %q, got %v", tt.wantErr, errs)
+			}
+		})
+	}
+}
+
+// upgradeTestNodePools returns a spec upgrading every node pool to 1.23.5 and the upstream node pools on 1.22.6
+func upgradeTestNodePools(nodePools ...aksv1.AKSNodePool) (*aksv1.AKSClusterConfigSpec, map[string]*aksv1.AKSNodePool) {
+	spec := &aksv1.AKSClusterConfigSpec{ClusterName: "cluster", ResourceGroup: "rg"}
+	upstreamNodePools := map[string]*aksv1.AKSNodePool{}
+	for _, np := range nodePools {
+		upstreamNodePool := np
+		upstreamNodePool.OrchestratorVersion = to.StringPtr("1.22.6")
+		upstreamNodePools[to.String(np.Name)] = &upstreamNodePool
+		np.OrchestratorVersion = to.StringPtr("1.23.5")
+		spec.NodePools = append(spec.NodePools, np)
+	}
+	return spec, upstreamNodePools
+}
+
+// upgradeNodePool returns a node pool with the mode and upgrade priority, a priority of 0 is left unset
+func upgradeNodePool(name, mode string, priority int32) aksv1.AKSNodePool {
+	np := validNodePool(name)
+	np.Mode = mode
+	if priority != 0 {
+		np.UpgradePriority = to.Int32Ptr(priority)
+	}
+	return np
+}
+
+func nodePoolNames(nodePools []*aksv1.AKSNodePool) []string {
+	var names []string
+	for _, np := range nodePools {
+		names = append(names, to.String(np.Name))
+	}
+	return names
+}
+
+func TestNodePoolUpgradesOrder(t *testing.T) {
+	spec, upstreamNodePools := upgradeTestNodePools(
+		upgradeNodePool("user1", "User", 2),
+		upgradeNodePool("user2", "User", 1),
+		upgradeNodePool("system1", "System", 0),
+		upgradeNodePool("user3", "User", 1),
+		upgradeNodePool("system2", "System", -1),
+	)
+	// a node pool already on the version of the spec and a node pool not created yet are not upgraded
+	current := upgradeNodePool("current", "User", 0)
+	current.OrchestratorVersion = to.StringPtr("1.23.5")
+	upstreamNodePools["current"] = &current
+	spec.NodePools = append(spec.NodePools, current, upgradeNodePool("new", "User", 0))
+	spec.NodePools[len(spec.NodePools)-1].OrchestratorVersion = to.StringPtr("1.23.5")
+
+	want := []string{"system2", "system1", "user2", "user3", "user1"}
+	if got := nodePoolNames(nodePoolUpgrades(spec, upstreamNodePools)); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected upgrade order %v, got %v", want, got)
+	}
+}
+
+func TestUpgradeNodePoolsBatches(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxConcurrent *int32
+		nodePools     []aksv1.AKSNodePool
+		wantUpgraded  []string
+		wantStage     string
+	}{
+		{
+			name:         "one node pool at a time by default",
+			nodePools:    []aksv1.AKSNodePool{upgradeNodePool("system1", "System", 0), upgradeNodePool("system2", "System", 0)},
+			wantUpgraded: []string{"system1"},
+			wantStage:    upgradeStageSystemNodePools,
+		},
+		{
+			name:          "batch of node pools",
+			maxConcurrent: to.Int32Ptr(2),
+			nodePools: []aksv1.AKSNodePool{upgradeNodePool("user1", "User", 0), upgradeNodePool("user2", "User", 0),
+				upgradeNodePool("user3", "User", 0)},
+			wantUpgraded: []string{"user1", "user2"},
+			wantStage:    upgradeStageUserNodePools,
+		},
+		{
+			name:          "System and User node pools are not mixed",
+			maxConcurrent: to.Int32Ptr(3),
+			nodePools:     []aksv1.AKSNodePool{upgradeNodePool("user1", "User", 0), upgradeNodePool("system1", "System", 0)},
+			wantUpgraded:  []string{"system1"},
+			wantStage:     upgradeStageSystemNodePools,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+			config := th.newTestConfig()
+			th.client.configs[config.Namespace+"/"+config.Name] = config.DeepCopy()
+			spec, upstreamNodePools := upgradeTestNodePools(tt.nodePools...)
+			spec.MaxConcurrentNodePoolUpgrades = tt.maxConcurrent
+			for _, np := range tt.nodePools {
+				th.azure.on(http.MethodPut, armPath("/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/cluster/agentPools/%s",
+					to.String(np.Name)), http.StatusOK, map[string]interface{}{"name": to.String(np.Name)})
+			}
+
+			credentials, err := th.getCredentials(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			agentPoolClient, err := aks.NewAgentPoolClient(credentials)
+			if err != nil {
+				t.Fatal(err)
+			}
+			updated, err := th.upgradeNodePools(context.Background(), agentPoolClient, config, spec, upstreamNodePools,
+				nodePoolUpgrades(spec, upstreamNodePools))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var sent []string
+			for _, request := range th.azure.recorded() {
+				sent = append(sent, request[strings.LastIndex(request, "/")+1:])
+			}
+			if !reflect.DeepEqual(sent, tt.wantUpgraded) || !reflect.DeepEqual(updated.Status.UpgradingNodePools, tt.wantUpgraded) {
+				t.Errorf("expected node pools %v to be upgraded, sent %v and recorded %v", tt.wantUpgraded, sent, updated.Status.UpgradingNodePools)
+			}
+			if updated.Status.UpgradeStage != tt.wantStage {
+				t.Errorf("expected upgrade stage %s, got %s", tt.wantStage, updated.Status.UpgradeStage)
+			}
+			if want := fmt.Sprintf("0/%d node pools upgraded", len(tt.nodePools)); updated.Status.NodePoolUpgradeProgress != want {
+				t.Errorf("expected progress %q, got %q", want, updated.Status.NodePoolUpgradeProgress)
+			}
+		})
+	}
+}
+
+func TestUpdateUpstreamClusterStateSendsAllNodePools(t *testing.T) {
+	userPool := func(name string) aksv1.AKSNodePool {
+		np := validNodePool(name)
+		np.Mode = "User"
+		np.OrchestratorVersion = to.StringPtr("1.22.6")
+		return np
+	}
+	tests := []struct {
+		name string
+		// added are the node pools added to the spec, removed the user node pools of the upstream cluster which are
+		// not in the spec
+		added   []string
+		removed []string
+		// failing are the node pools whose operation fails
+		failing    []string
+		wantMethod string
+		wantSent   []string
+		wantErr    string
+	}{
+		{
+			name:       "three node pools added",
+			added:      []string{"user1", "user2", "user3"},
+			wantMethod: http.MethodPut,
+			wantSent:   []string{"user1", "user2", "user3"},
+		},
+		{
+			name:       "one of three node pools failing",
+			added:      []string{"user1", "user2", "user3"},
+			failing:    []string{"user2"},
+			wantMethod: http.MethodPut,
+			wantSent:   []string{"user1", "user3"},
+			wantErr:    "failed to update node pool [user2]",
+		},
+		{
+			name:       "three node pools removed",
+			removed:    []string{"user3", "user1", "user2"},
+			wantMethod: http.MethodDelete,
+			wantSent:   []string{"user1", "user2", "user3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+			config := th.newUpstreamTestConfig()
+			config.Status.Phase = aksConfigActivePhase
+			for _, name := range tt.added {
+				config.Spec.NodePools = append(config.Spec.NodePools, userPool(name))
+			}
+			th.client.configs[config.Namespace+"/"+config.Name] = config.DeepCopy()
+
+			upstream := testUpstreamCluster(nil)
+			properties := upstream["properties"].(map[string]interface{})
+			for _, name := range tt.removed {
+				properties["agentPoolProfiles"] = append(properties["agentPoolProfiles"].([]interface{}), map[string]interface{}{
+					"name": name, "count": 1, "mode": "User", "vmSize": "Standard_DS2_v2", "orchestratorVersion": "1.22.6",
+				})
+			}
+			th.azure.on(http.MethodGet, testClusterPath, http.StatusOK, upstream)
+			for _, name := range append(tt.added, tt.removed...) {
+				th.azure.on(tt.wantMethod, agentPoolPath(name), http.StatusOK, map[string]interface{}{"name": name})
+			}
+			for _, name := range tt.failing {
+				th.azure.on(tt.wantMethod, agentPoolPath(name), http.StatusBadRequest, map[string]interface{}{
+					"error": map[string]string{"code": "InvalidParameter", "message": "The node pool is invalid."},
+				})
+			}
+
+			upstreamSpec, err := BuildUpstreamClusterState(context.Background(), th.secretsCache, &config.Spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			updated, err := th.updateUpstreamClusterState(context.Background(), th.secretsCache, config, upstreamSpec)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+
+			var sent []string
+			for _, request := range th.azure.recorded() {
+				if strings.HasPrefix(request, tt.wantMethod+" ") && strings.Contains(request, "/agentPools/") {
+					sent = append(sent, request[strings.LastIndex(request, "/")+1:])
+				}
+			}
+			if want := append(append([]string(nil), tt.added...), tt.removed...); len(sent) != len(want) {
+				t.Errorf("expected every node pool to be sent in one pass, got %v", sent)
+			}
+			if !reflect.DeepEqual(updated.Status.UpdatingNodePools, tt.wantSent) {
+				t.Errorf("expected updating node pools %v, got %v", tt.wantSent, updated.Status.UpdatingNodePools)
+			}
+			if updated.Status.Phase != aksConfigUpdatingPhase {
+				t.Errorf("expected phase %q, got %q", aksConfigUpdatingPhase, updated.Status.Phase)
+			}
+			if len(th.recorder.Events) != len(tt.wantSent) {
+				t.Errorf("expected an event for each sent node pool, got %d", len(th.recorder.Events))
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// matchPublicIPPrefixID matches the resource ID of a public IP prefix
+var matchPublicIPPrefixID = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.Network/publicIPPrefixes/[^/]+$`)
+
+// validateOutboundType rejects unknown outbound types, outbound types routing through a network of the user without a
+// custom subnet, and NAT gateway settings for clusters which do not use a managed NAT gateway
+func validateOutboundType(spec *aksv1.AKSClusterConfigSpec) []error {
+	var errs []error
+	outboundType := to.String(spec.OutboundType)
+	switch outboundType {
+	case "", string(containerservice.OutboundTypeLoadBalancer), string(containerservice.OutboundTypeManagedNATGateway):
+	case string(containerservice.OutboundTypeUserDefinedRouting), string(containerservice.OutboundTypeUserAssignedNATGateway):
+		if !hasCustomSubnet(spec) {
+			errs = append(errs, fmt.Errorf("outboundType [%s] for cluster [%s] config requires a custom subnet, set virtualNetwork and subnet or vnetSubnetID on every node pool",
+				outboundType, spec.ClusterName))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("cluster [%s] config has invalid outboundType [%s], must be loadBalancer, managedNATGateway, userAssignedNATGateway or userDefinedRouting",
+			spec.ClusterName, outboundType))
+	}
+
+	profile := spec.NATGatewayProfile
+	if profile == nil {
+		return errs
+	}
+	if outboundType != string(containerservice.OutboundTypeManagedNATGateway) {
+		errs = append(errs, fmt.Errorf("natGatewayProfile for cluster [%s] config requires outboundType managedNATGateway", spec.ClusterName))
+	}
+	if profile.ManagedOutboundIPCount != nil && (*profile.ManagedOutboundIPCount < 1 || *profile.ManagedOutboundIPCount > 16) {
+		errs = append(errs, fmt.Errorf("natGatewayProfile for cluster [%s] config has invalid managedOutboundIPCount [%d], must be between 1 and 16",
+			spec.ClusterName, *profile.ManagedOutboundIPCount))
+	}
+	if profile.IdleTimeoutInMinutes != nil && (*profile.IdleTimeoutInMinutes < 4 || *profile.IdleTimeoutInMinutes > 120) {
+		errs = append(errs, fmt.Errorf("natGatewayProfile for cluster [%s] config has invalid idleTimeoutInMinutes [%d], must be between 4 and 120",
+			spec.ClusterName, *profile.IdleTimeoutInMinutes))
+	}
+	return errs
+}
+
+// outboundType returns the outbound type of the spec, which defaults to loadBalancer
+func outboundType(spec *aksv1.AKSClusterConfigSpec) string {
+	if outboundType := to.String(spec.OutboundType); outboundType != "" {
+		return outboundType
+	}
+	return string(containerservice.OutboundTypeLoadBalancer)
+}
+
+// hasCustomSubnet returns true if the nodes of the cluster are placed in a subnet of the user
+func hasCustomSubnet(spec *aksv1.AKSClusterConfigSpec) bool {
+	if spec.VirtualNetwork != nil && spec.Subnet != nil {
+		return true
+	}
+	if len(spec.NodePools) == 0 {
+		return false
+	}
+	for _, np := range spec.NodePools {
+		if to.String(np.VnetSubnetID) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// natGatewayProfileChanged returns true if a NAT gateway setting of the spec differs from the upstream profile
+func natGatewayProfileChanged(profile, upstreamProfile *aksv1.AKSNATGatewayProfile) bool {
+	if upstreamProfile == nil {
+		upstreamProfile = &aksv1.AKSNATGatewayProfile{}
+	}
+	return (profile.ManagedOutboundIPCount != nil && to.Int32(profile.ManagedOutboundIPCount) != to.Int32(upstreamProfile.ManagedOutboundIPCount)) ||
+		(profile.IdleTimeoutInMinutes != nil && to.Int32(profile.IdleTimeoutInMinutes) != to.Int32(upstreamProfile.IdleTimeoutInMinutes))
+}
+
+// validateLoadBalancerProfile rejects load balancer profiles setting both managed outbound IPs and IP prefixes,
+// malformed IP prefix IDs and settings out of the ranges allowed by Azure
+func validateLoadBalancerProfile(spec *aksv1.AKSClusterConfigSpec) []error {
+	profile := spec.LoadBalancerProfile
+	if profile == nil {
+		return nil
+	}
+
+	var errs []error
+	if strings.EqualFold(to.String(spec.LoadBalancerSKU), string(containerservice.LoadBalancerSkuBasic)) ||
+		outboundType(spec) != string(containerservice.OutboundTypeLoadBalancer) {
+		errs = append(errs, fmt.Errorf("loadBalancerProfile for cluster [%s] config requires a standard load balancer and outboundType loadBalancer",
+			spec.ClusterName))
+	}
+	if profile.ManagedOutboundIPCount != nil && len(profile.OutboundIPPrefixes) > 0 {
+		errs = append(errs, fmt.Errorf("loadBalancerProfile for cluster [%s] config can either set managedOutboundIPCount or outboundIPPrefixes",
+			spec.ClusterName))
+	}
+	if profile.ManagedOutboundIPCount != nil && (*profile.ManagedOutboundIPCount < 1 || *profile.ManagedOutboundIPCount > 100) {
+		errs = append(errs, fmt.Errorf("loadBalancerProfile for cluster [%s] config has invalid managedOutboundIPCount [%d], must be between 1 and 100",
+			spec.ClusterName, *profile.ManagedOutboundIPCount))
+	}
+	for _, id := range profile.OutboundIPPrefixes {
+		if !matchPublicIPPrefixID.MatchString(id) {
+			errs = append(errs, fmt.Errorf("loadBalancerProfile for cluster [%s] config has invalid outboundIPPrefixes entry [%s], must be a public IP prefix resource ID",
+				spec.ClusterName, id))
+		}
+	}
+	if ports := profile.AllocatedOutboundPorts; ports != nil && (*ports < 0 || *ports > 64000 || *ports%8 != 0) {
+		errs = append(errs, fmt.Errorf("loadBalancerProfile for cluster [%s] config has invalid allocatedOutboundPorts [%d], must be a multiple of 8 between 0 and 64000",
+			spec.ClusterName, *ports))
+	}
+	if profile.IdleTimeoutInMinutes != nil && (*profile.IdleTimeoutInMinutes < 4 || *profile.IdleTimeoutInMinutes > 120) {
+		errs = append(errs, fmt.Errorf("loadBalancerProfile for cluster [%s] config has invalid idleTimeoutInMinutes [%d], must be between 4 and 120",
+			spec.ClusterName, *profile.IdleTimeoutInMinutes))
+	}
+	return errs
+}
+
+// upstreamLoadBalancerProfile converts the load balancer profile of a cluster to the spec type
+func upstreamLoadBalancerProfile(upstream *containerservice.ManagedClusterLoadBalancerProfile) *aksv1.AKSLoadBalancerProfile {
+	if upstream == nil {
+		return nil
+	}
+	profile := &aksv1.AKSLoadBalancerProfile{
+		AllocatedOutboundPorts: upstream.AllocatedOutboundPorts,
+		IdleTimeoutInMinutes:   upstream.IdleTimeoutInMinutes,
+	}
+	if upstream.ManagedOutboundIPs != nil {
+		profile.ManagedOutboundIPCount = upstream.ManagedOutboundIPs.Count
+	}
+	if upstream.OutboundIPPrefixes != nil && upstream.OutboundIPPrefixes.PublicIPPrefixes != nil {
+		for _, prefix := range *upstream.OutboundIPPrefixes.PublicIPPrefixes {
+			profile.OutboundIPPrefixes = append(profile.OutboundIPPrefixes, to.String(prefix.ID))
+		}
+	}
+	return profile
+}
+
+// loadBalancerProfileChanged returns true if a load balancer setting of the spec differs from the upstream profile
+func loadBalancerProfileChanged(profile, upstreamProfile *aksv1.AKSLoadBalancerProfile) bool {
+	if upstreamProfile == nil {
+		upstreamProfile = &aksv1.AKSLoadBalancerProfile{}
+	}
+	if profile.ManagedOutboundIPCount != nil && to.Int32(profile.ManagedOutboundIPCount) != to.Int32(upstreamProfile.ManagedOutboundIPCount) {
+		return true
+	}
+	if len(profile.OutboundIPPrefixes) > 0 && !sameResourceIDs(profile.OutboundIPPrefixes, upstreamProfile.OutboundIPPrefixes) {
+		return true
+	}
+	return (profile.AllocatedOutboundPorts != nil && to.Int32(profile.AllocatedOutboundPorts) != to.Int32(upstreamProfile.AllocatedOutboundPorts)) ||
+		(profile.IdleTimeoutInMinutes != nil && to.Int32(profile.IdleTimeoutInMinutes) != to.Int32(upstreamProfile.IdleTimeoutInMinutes))
+}
+
+// sameResourceIDs returns true if both lists contain the same resource IDs in any order, ignoring case
+func sameResourceIDs(ids, otherIDs []string) bool {
+	if len(ids) != len(otherIDs) {
+		return false
+	}
+	seen := map[string]int{}
+	for _, id := range ids {
+		seen[strings.ToLower(id)]++
+	}
+	for _, id := range otherIDs {
+		seen[strings.ToLower(id)]--
+	}
+	for _, n := range seen {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,398 @@
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/rancher/wrangler/pkg/merr"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	"github.com/rancher/aks-operator/pkg/utils"
+)
+
+const (
+	// dryRunAnnotation makes the handler record the changes it would send to Azure in the status instead of sending
+	// them. Removing the annotation applies the changes.
+	dryRunAnnotation = "aks.cattle.io/dry-run"
+
+	eventReasonPlannedChanges = "PlannedChanges"
+)
+
+// dryRunRequested returns true if the config carries the dry-run annotation
+func dryRunRequested(config *aksv1.AKSClusterConfig) bool {
+	return config.Annotations[dryRunAnnotation] == "true"
+}
+
+// clusterUpdatePlan holds the operations which bring the upstream cluster in line with the spec. They are sent one kind
+// at a time, in the order of the fields, each by a pass of updateUpstreamClusterState.
+type clusterUpdatePlan struct {
+	// updateTags is true if the tags of the spec are added to or removed from the tags of the cluster
+	updateTags bool
+	// recreateNodePool is a node pool whose VM size changed, it is replaced by a temporary node pool, deleted and
+	// created again
+	recreateNodePool *aksv1.AKSNodePool
+	// nodePoolChanges are the node pools which are created or updated
+	nodePoolChanges []*aksv1.AKSNodePool
+	// nodePoolUpgrades are the node pools whose orchestrator version is upgraded, once the control plane is upgraded
+	nodePoolUpgrades []*aksv1.AKSNodePool
+	// removedNodePools are the upstream node pools which are no longer in the spec
+	removedNodePools []*aksv1.AKSNodePool
+	// clusterUpdate holds the fields of the cluster which changed, it is nil if none did
+	clusterUpdate       *aksv1.AKSClusterConfigSpec
+	upgradeControlPlane bool
+	addons              []string
+	// changes describe each planned operation, e.g. "add node pool [userpool]"
+	changes []string
+}
+
+func (p *clusterUpdatePlan) add(format string, args ...interface{}) {
+	p.changes = append(p.changes, fmt.Sprintf(format, args...))
+}
+
+// planClusterUpdate compares the spec with the upstream cluster and returns the operations needed to apply the spec.
+// It does not call Azure, so the same plan backs both the updates and the dry-run output. The spec is expected to
+// carry the node pool defaults, unmanaged fields and egress IP already.
+func planClusterUpdate(config *aksv1.AKSClusterConfig, spec, upstreamSpec *aksv1.AKSClusterConfigSpec,
+	upstreamNodePools map[string]*aksv1.AKSNodePool) (*clusterUpdatePlan, error) {
+	plan := &clusterUpdatePlan{}
+
+	// check tags for update, tags removed from the spec are removed upstream while tags added out-of-band are kept
+	if tagsChanged(spec, upstreamSpec, config.Status.AppliedTags) {
+		if errs := validateTags(spec.Tags, "tags", spec.ClusterName); len(errs) > 0 {
+			return nil, invalidSpecError{merr.NewErrors(errs...)}
+		}
+		plan.updateTags = true
+		plan.add("update tags")
+	}
+
+	if spec.NodePools != nil {
+		if err := planNodePools(plan, spec, upstreamSpec, upstreamNodePools, config.Status.NodePoolReplacements); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := planClusterFields(plan, config, spec, upstreamSpec); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// planNodePools adds the node pools which are recreated, created or updated, upgraded and removed to the plan.
+// replacements are the node pools temporarily replacing node pools which are recreated, by the name of the node pool
+// they replace.
+func planNodePools(plan *clusterUpdatePlan, spec, upstreamSpec *aksv1.AKSClusterConfigSpec, upstreamNodePools map[string]*aksv1.AKSNodePool,
+	replacements map[string]string) error {
+	downstreamNodePools, err := utils.BuildNodePoolMap(spec.NodePools, spec.ClusterName)
+	if err != nil {
+		return err
+	}
+
+	// check for updated NodePools, in the order of the spec. Every node pool which needs a change is sent in the same
+	// pass.
+	for i := range spec.NodePools {
+		np := spec.NodePools[i]
+		name := to.String(np.Name)
+		updateNodePool := false
+		upstreamNodePool, ok := upstreamNodePools[name]
+		if ok {
+			// Azure cannot resize an existing node pool, it has to be recreated
+			if vmSizeChanged(&np, upstreamNodePool) {
+				if plan.recreateNodePool == nil {
+					plan.recreateNodePool = &np
+					if _, ok := upstreamNodePools[replacements[name]]; ok && replacements[name] != "" {
+						plan.add("remove node pool [%s] replaced by node pool [%s] to recreate it with VM size %s", name, replacements[name], np.VMSize)
+					} else {
+						plan.add("add node pool replacing node pool [%s] to change its VM size from %s to %s", name, upstreamNodePool.VMSize, np.VMSize)
+					}
+				}
+				continue
+			}
+			// Azure does not allow changing the availability zones of an existing node pool
+			if np.AvailabilityZones != nil && !equalZones(np.AvailabilityZones, upstreamNodePool.AvailabilityZones) {
+				return invalidSpecError{fmt.Errorf("availability zones cannot be changed on node pool [%s] for cluster [%s], delete and recreate the node pool",
+					name, spec.ClusterName)}
+			}
+			// Azure does not allow changing the subnets of an existing node pool
+			if resourceIDChanged(np.VnetSubnetID, upstreamNodePool.VnetSubnetID) {
+				return invalidSpecError{fmt.Errorf("vnetSubnetID cannot be changed on node pool [%s] for cluster [%s], delete and recreate the node pool",
+					name, spec.ClusterName)}
+			}
+			if resourceIDChanged(np.PodSubnetID, upstreamNodePool.PodSubnetID) {
+				return invalidSpecError{fmt.Errorf("podSubnetID cannot be changed on node pool [%s] for cluster [%s], delete and recreate the node pool",
+					name, spec.ClusterName)}
+			}
+			if np.OsSKU != "" && !strings.EqualFold(np.OsSKU, upstreamNodePool.OsSKU) {
+				return invalidSpecError{fmt.Errorf("osSku cannot be changed from %s to %s on node pool [%s] for cluster [%s], delete and recreate the node pool",
+					upstreamNodePool.OsSKU, np.OsSKU, name, spec.ClusterName)}
+			}
+			if resourceIDChanged(np.SnapshotID, upstreamNodePool.SnapshotID) {
+				return invalidSpecError{fmt.Errorf("snapshotId cannot be changed on node pool [%s] for cluster [%s], delete and recreate the node pool",
+					name, spec.ClusterName)}
+			}
+			if resourceIDChanged(np.ProximityPlacementGroupID, upstreamNodePool.ProximityPlacementGroupID) {
+				return invalidSpecError{fmt.Errorf("proximityPlacementGroupID cannot be changed on node pool [%s] for cluster [%s], delete and recreate the node pool",
+					name, spec.ClusterName)}
+			}
+			if resourceIDChanged(np.HostGroupID, upstreamNodePool.HostGroupID) {
+				return invalidSpecError{fmt.Errorf("hostGroupID cannot be changed on node pool [%s] for cluster [%s], delete and recreate the node pool",
+					name, spec.ClusterName)}
+			}
+			if np.GpuInstanceProfile != "" && np.GpuInstanceProfile != upstreamNodePool.GpuInstanceProfile {
+				return invalidSpecError{fmt.Errorf("gpuInstanceProfile cannot be changed on node pool [%s] for cluster [%s], delete and recreate the node pool",
+					name, spec.ClusterName)}
+			}
+			if np.EnableFIPS != nil && to.Bool(np.EnableFIPS) != to.Bool(upstreamNodePool.EnableFIPS) {
+				return invalidSpecError{fmt.Errorf("enableFips cannot be changed on node pool [%s] for cluster [%s], delete and recreate the node pool",
+					name, spec.ClusterName)}
+			}
+			if np.EnableEncryptionAtHost != nil && to.Bool(np.EnableEncryptionAtHost) != to.Bool(upstreamNodePool.EnableEncryptionAtHost) {
+				return invalidSpecError{fmt.Errorf("enableEncryptionAtHost cannot be changed on node pool [%s] for cluster [%s], delete and recreate the node pool",
+					name, spec.ClusterName)}
+			}
+			if np.EnableUltraSSD != nil && to.Bool(np.EnableUltraSSD) != to.Bool(upstreamNodePool.EnableUltraSSD) {
+				return invalidSpecError{fmt.Errorf("enableUltraSSD cannot be changed on node pool [%s] for cluster [%s], delete and recreate the node pool",
+					name, spec.ClusterName)}
+			}
+			// kubelet and Linux OS settings are only applied when the node pool is created
+			if np.KubeletConfig != nil && settingsChanged(np.KubeletConfig, upstreamNodePool.KubeletConfig) {
+				return invalidSpecError{fmt.Errorf("kubeletConfig cannot be changed on node pool [%s] for cluster [%s], delete and recreate the node pool",
+					name, spec.ClusterName)}
+			}
+			if np.LinuxOSConfig != nil && settingsChanged(np.LinuxOSConfig, upstreamNodePool.LinuxOSConfig) {
+				return invalidSpecError{fmt.Errorf("linuxOSConfig cannot be changed on node pool [%s] for cluster [%s], delete and recreate the node pool",
+					name, spec.ClusterName)}
+			}
+			// Azure does not allow changing the priority of an existing node pool
+			applyUpstreamImmutableSettings(&np, upstreamNodePool)
+			if scaleSetPriority(&np) != scaleSetPriority(upstreamNodePool) {
+				return invalidSpecError{fmt.Errorf("scaleSetPriority of node pool [%s] for cluster [%s] cannot be changed from %s to %s, the node pool must be recreated",
+					name, spec.ClusterName, scaleSetPriority(upstreamNodePool), scaleSetPriority(&np))}
+			}
+			// There is a matching node pool in the cluster already, so update it if needed
+			if to.Int32(np.Count) != to.Int32(upstreamNodePool.Count) {
+				plan.add("update node count of node pool [%s] from %d to %d", name, to.Int32(upstreamNodePool.Count), to.Int32(np.Count))
+				updateNodePool = true
+			}
+			if np.EnableAutoScaling != nil && to.Bool(np.EnableAutoScaling) != to.Bool(upstreamNodePool.EnableAutoScaling) {
+				plan.add("update autoscaling of node pool [%s] to %t", name, to.Bool(np.EnableAutoScaling))
+				updateNodePool = true
+			}
+			if to.Bool(np.EnableAutoScaling) && to.Bool(upstreamNodePool.EnableAutoScaling) &&
+				(to.Int32(np.MinCount) != to.Int32(upstreamNodePool.MinCount) || to.Int32(np.MaxCount) != to.Int32(upstreamNodePool.MaxCount)) {
+				plan.add("update autoscaling range of node pool [%s] from %d-%d to %d-%d", name,
+					to.Int32(upstreamNodePool.MinCount), to.Int32(upstreamNodePool.MaxCount), to.Int32(np.MinCount), to.Int32(np.MaxCount))
+				updateNodePool = true
+			}
+			if !equalNodeTaints(np.NodeTaints, upstreamNodePool.NodeTaints) {
+				plan.add("update node taints of node pool [%s]", name)
+				updateNodePool = true
+			}
+			if !equalNodeLabels(np.NodeLabels, upstreamNodePool.NodeLabels) {
+				plan.add("update node labels of node pool [%s]", name)
+				updateNodePool = true
+			}
+			if np.ScaleDownMode != "" && scaleDownMode(&np) != scaleDownMode(upstreamNodePool) {
+				plan.add("update scale-down mode of node pool [%s] to %s", name, scaleDownMode(&np))
+				updateNodePool = true
+			}
+			if np.UpgradeSettings != nil && np.UpgradeSettings.MaxSurge != "" &&
+				(upstreamNodePool.UpgradeSettings == nil || np.UpgradeSettings.MaxSurge != upstreamNodePool.UpgradeSettings.MaxSurge) {
+				plan.add("update max surge of node pool [%s] to %s", name, np.UpgradeSettings.MaxSurge)
+				updateNodePool = true
+			}
+			// orchestrator version upgrades are rolled out separately
+			np.OrchestratorVersion = upstreamNodePool.OrchestratorVersion
+		} else {
+			plan.add("add node pool [%s]", name)
+			updateNodePool = true
+		}
+
+		if updateNodePool {
+			plan.nodePoolChanges = append(plan.nodePoolChanges, &np)
+		}
+	}
+
+	// orchestrator version upgrades are rolled out once the control plane has been upgraded
+	upgrades := nodePoolUpgrades(spec, upstreamNodePools)
+	for _, np := range upgrades {
+		plan.add("upgrade node pool [%s] from %s to %s", to.String(np.Name),
+			to.String(upstreamNodePools[to.String(np.Name)].OrchestratorVersion), to.String(np.OrchestratorVersion))
+	}
+	if !controlPlaneUpgradePending(spec, upstreamSpec) {
+		plan.nodePoolUpgrades = upgrades
+	}
+
+	// check for removed NodePools, all of them are removed in the same pass. A node pool replacing a recreated one is
+	// kept until the recreated node pool has its new VM size.
+	var removed []string
+	for npName := range upstreamNodePools {
+		if _, ok := downstreamNodePools[npName]; !ok && !replacementInProgress(spec, replacements, npName, upstreamNodePools) {
+			removed = append(removed, npName)
+		}
+	}
+	sort.Strings(removed)
+	for _, npName := range removed {
+		plan.removedNodePools = append(plan.removedNodePools, upstreamNodePools[npName])
+		plan.add("remove node pool [%s]", npName)
+	}
+	return nil
+}
+
+// planClusterFields adds the fields of the cluster which differ from the upstream cluster to the plan. Only those
+// fields are sent, so that settings the spec does not model or which were changed out-of-band are kept.
+func planClusterFields(plan *clusterUpdatePlan, config *aksv1.AKSClusterConfig, spec, upstreamSpec *aksv1.AKSClusterConfigSpec) error {
+	updateAksCluster := false
+	update := clusterUpdateSpec(spec)
+	// check Kubernetes version for update
+	if controlPlaneUpgradePending(spec, upstreamSpec) {
+		plan.upgradeControlPlane = true
+		plan.add("upgrade control plane from %s to %s", to.String(upstreamSpec.KubernetesVersion), to.String(spec.KubernetesVersion))
+		update.KubernetesVersion = spec.KubernetesVersion
+		updateAksCluster = true
+	}
+
+	// check authorized IP ranges to access AKS, an empty list clears them
+	if authorizedIPRangesChanged(spec, upstreamSpec) {
+		plan.add("update authorized IP ranges")
+		update.AuthorizedIPRanges = spec.AuthorizedIPRanges
+		updateAksCluster = true
+	}
+
+	// check addons for update
+	plan.addons = addonChanges(spec, upstreamSpec, update)
+	for _, addon := range plan.addons {
+		plan.add("update addon %s", addon)
+		updateAksCluster = true
+	}
+
+	// check Microsoft Defender for update
+	if spec.Defender != nil && defenderChanged(spec, upstreamSpec) {
+		plan.add("update Microsoft Defender")
+		update.Defender = spec.Defender
+		updateAksCluster = true
+	}
+
+	// check NAT gateway for update
+	if spec.NATGatewayProfile != nil && natGatewayProfileChanged(spec.NATGatewayProfile, upstreamSpec.NATGatewayProfile) {
+		plan.add("update NAT gateway")
+		update.NATGatewayProfile = spec.NATGatewayProfile
+		updateAksCluster = true
+	}
+
+	// check load balancer profile for update
+	if spec.LoadBalancerProfile != nil && loadBalancerProfileChanged(spec.LoadBalancerProfile, upstreamSpec.LoadBalancerProfile) {
+		plan.add("update load balancer profile")
+		update.LoadBalancerProfile = spec.LoadBalancerProfile
+		updateAksCluster = true
+	}
+
+	// check HTTP proxy for update
+	if spec.HTTPProxyConfig != nil && httpProxyConfigChanged(spec.HTTPProxyConfig, upstreamSpec.HTTPProxyConfig) {
+		plan.add("update HTTP proxy")
+		update.HTTPProxyConfig = spec.HTTPProxyConfig
+		updateAksCluster = true
+	}
+
+	// check autoscaler profile for update
+	if autoScalerProfileChanged(spec, upstreamSpec, config.Status.AutoScalerProfileSettings) {
+		plan.add("update autoscaler profile")
+		// the profile is sent without the removed settings to reset them to their Azure defaults
+		update.AutoScalerProfile = spec.AutoScalerProfile
+		if update.AutoScalerProfile == nil {
+			update.AutoScalerProfile = map[string]string{}
+		}
+		updateAksCluster = true
+	}
+
+	// check pricing tier for update
+	if spec.Tier != nil && *spec.Tier != to.String(upstreamSpec.Tier) {
+		plan.add("update pricing tier from %s to %s", to.String(upstreamSpec.Tier), *spec.Tier)
+		update.Tier = spec.Tier
+		updateAksCluster = true
+	}
+
+	// check auto-upgrade channel for update
+	if spec.UpgradeChannel != nil && *spec.UpgradeChannel != to.String(upstreamSpec.UpgradeChannel) {
+		plan.add("update auto-upgrade channel from %s to %s", to.String(upstreamSpec.UpgradeChannel), *spec.UpgradeChannel)
+		update.UpgradeChannel = spec.UpgradeChannel
+		updateAksCluster = true
+	}
+
+	// check cluster identity for update
+	if spec.Identity != nil && identityChanged(spec.Identity, upstreamSpec.Identity) {
+		plan.add("update identity to %s", spec.Identity.Type)
+		update.Identity = spec.Identity
+		updateAksCluster = true
+	}
+
+	// check local accounts for update
+	if spec.DisableLocalAccounts != nil && to.Bool(spec.DisableLocalAccounts) != to.Bool(upstreamSpec.DisableLocalAccounts) {
+		if to.Bool(spec.DisableLocalAccounts) && !config.Status.ManagedAAD {
+			return invalidSpecError{fmt.Errorf("local accounts of cluster [%s] can only be disabled if it uses managed Azure AD", spec.ClusterName)}
+		}
+		plan.add("update local accounts, disabled: %t", to.Bool(spec.DisableLocalAccounts))
+		update.DisableLocalAccounts = spec.DisableLocalAccounts
+		updateAksCluster = true
+	}
+
+	// check OIDC issuer and workload identity for update
+	if spec.OIDCIssuerEnabled != nil && to.Bool(spec.OIDCIssuerEnabled) != to.Bool(upstreamSpec.OIDCIssuerEnabled) {
+		if !to.Bool(spec.OIDCIssuerEnabled) {
+			return invalidSpecError{fmt.Errorf("the OIDC issuer of cluster [%s] cannot be disabled once it is enabled", spec.ClusterName)}
+		}
+		plan.add("enable OIDC issuer")
+		update.OIDCIssuerEnabled = spec.OIDCIssuerEnabled
+		updateAksCluster = true
+	}
+	if spec.WorkloadIdentityEnabled != nil && to.Bool(spec.WorkloadIdentityEnabled) != to.Bool(upstreamSpec.WorkloadIdentityEnabled) {
+		if to.Bool(spec.WorkloadIdentityEnabled) && !to.Bool(spec.OIDCIssuerEnabled) && !to.Bool(upstreamSpec.OIDCIssuerEnabled) {
+			return invalidSpecError{fmt.Errorf("workload identity of cluster [%s] requires the OIDC issuer to be enabled", spec.ClusterName)}
+		}
+		plan.add("update workload identity, enabled: %t", to.Bool(spec.WorkloadIdentityEnabled))
+		update.WorkloadIdentityEnabled = spec.WorkloadIdentityEnabled
+		updateAksCluster = true
+	}
+
+	if updateAksCluster {
+		plan.clusterUpdate = update
+	}
+	return nil
+}
+
+// clusterUpdateSpec returns a spec holding only the fields of spec which identify the cluster. The fields which differ
+// from the upstream cluster are copied to it before it is sent with UpdateCluster.
+func clusterUpdateSpec(spec *aksv1.AKSClusterConfigSpec) *aksv1.AKSClusterConfigSpec {
+	return &aksv1.AKSClusterConfigSpec{
+		ClusterName:      spec.ClusterName,
+		ResourceGroup:    spec.ResourceGroup,
+		ResourceLocation: spec.ResourceLocation,
+	}
+}
+
+// recordPlannedChanges writes the changes a dry run found to the status, an event is recorded when they change
+func (h *Handler) recordPlannedChanges(config *aksv1.AKSClusterConfig, changes []string) (*aksv1.AKSClusterConfig, error) {
+	if len(changes) == 0 {
+		changes = nil
+	}
+	if period := h.clusterDriftSyncPeriod(config); period > 0 {
+		h.aksEnqueueAfter(config.Namespace, config.Name, period)
+	}
+	if reflect.DeepEqual(changes, config.Status.PlannedChanges) {
+		return config, nil
+	}
+
+	message := "No changes planned"
+	if len(changes) > 0 {
+		message = "Planned changes: " + strings.Join(changes, "; ")
+	}
+	logrus.Infof("Dry run of cluster [%s]: %s", config.Name, message)
+	h.recorder.Event(config, v1.EventTypeNormal, eventReasonPlannedChanges, message)
+	config = config.DeepCopy()
+	config.Status.PlannedChanges = changes
+	return h.updateStatus(config)
+}
@@ -0,0 +1,396 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/to"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// planTestNodePools returns the spec and the upstream node pools of a cluster with a single node pool, the spec node
+// pool is changed by specChange and the upstream node pool by upstreamChange
+func planTestNodePools(specChange, upstreamChange func(np *aksv1.AKSNodePool)) (*aksv1.AKSClusterConfigSpec, map[string]*aksv1.AKSNodePool) {
+	np := validNodePool("pool")
+	upstreamNodePool := np
+	specChange(&np)
+	upstreamChange(&upstreamNodePool)
+	spec := &aksv1.AKSClusterConfigSpec{ClusterName: "cluster", NodePools: []aksv1.AKSNodePool{np}}
+	return spec, map[string]*aksv1.AKSNodePool{"pool": &upstreamNodePool}
+}
+
+func TestPlanNodePoolsAutoscaling(t *testing.T) {
+	noChange := func(np *aksv1.AKSNodePool) {}
+	autoscaling := func(min, max int32) func(np *aksv1.AKSNodePool) {
+		return func(np *aksv1.AKSNodePool) {
+			np.EnableAutoScaling = to.BoolPtr(true)
+			np.MinCount = to.Int32Ptr(min)
+			np.MaxCount = to.Int32Ptr(max)
+		}
+	}
+	tests := []struct {
+		name     string
+		spec     func(np *aksv1.AKSNodePool)
+		upstream func(np *aksv1.AKSNodePool)
+		want     []string
+	}{
+		{
+			name:     "unchanged range",
+			spec:     autoscaling(1, 3),
+			upstream: autoscaling(1, 3),
+		},
+		{
+			name:     "changed max count",
+			spec:     autoscaling(1, 5),
+			upstream: autoscaling(1, 3),
+			want:     []string{"update autoscaling range of node pool [pool] from 1-3 to 1-5"},
+		},
+		{
+			name:     "changed min count",
+			spec:     autoscaling(2, 3),
+			upstream: autoscaling(1, 3),
+			want:     []string{"update autoscaling range of node pool [pool] from 1-3 to 2-3"},
+		},
+		{
+			name:     "enabled autoscaling",
+			spec:     autoscaling(1, 3),
+			upstream: noChange,
+			want:     []string{"update autoscaling of node pool [pool] to true"},
+		},
+		{
+			name:     "disabled autoscaling",
+			spec:     func(np *aksv1.AKSNodePool) { np.EnableAutoScaling = to.BoolPtr(false) },
+			upstream: autoscaling(1, 3),
+			want:     []string{"update autoscaling of node pool [pool] to false"},
+		},
+		{
+			name:     "autoscaling not set in spec",
+			spec:     noChange,
+			upstream: autoscaling(1, 3),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, upstreamNodePools := planTestNodePools(tt.spec, tt.upstream)
+			plan := &clusterUpdatePlan{}
+			if err := planNodePools(plan, spec, &aksv1.AKSClusterConfigSpec{}, upstreamNodePools, nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(plan.changes, tt.want) {
+				t.Errorf("expected changes %v, got %v", tt.want, plan.changes)
+			}
+			if updated := len(plan.nodePoolChanges) > 0; updated != (len(tt.want) > 0) {
+				t.Errorf("expected the node pool to be updated: %t, got %d node pool changes", len(tt.want) > 0, len(plan.nodePoolChanges))
+			}
+		})
+	}
+}
+
+func TestUpdateUpstreamClusterStateTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     map[string]string
+		applied  []string
+		upstream map[string]string
+		// want are the tags sent to Azure, nil if no update is sent
+		want map[string]string
+	}{
+		{
+			name:     "out-of-band tag is kept",
+			tags:     map[string]string{"team": "b"},
+			applied:  []string{"team"},
+			upstream: map[string]string{"team": "a", "costcenter": "42"},
+			want:     map[string]string{"team": "b", "costcenter": "42"},
+		},
+		{
+			name:     "tag removed from the spec is deleted",
+			tags:     map[string]string{"team": "a"},
+			applied:  []string{"env", "team"},
+			upstream: map[string]string{"team": "a", "env": "dev", "costcenter": "42"},
+			want:     map[string]string{"team": "a", "costcenter": "42"},
+		},
+		{
+			name:     "cleared tags are deleted",
+			applied:  []string{"team"},
+			upstream: map[string]string{"team": "a", "costcenter": "42"},
+			want:     map[string]string{"costcenter": "42"},
+		},
+		{
+			name:     "equal tags are not updated",
+			tags:     map[string]string{"team": "a"},
+			applied:  []string{"team"},
+			upstream: map[string]string{"team": "a", "costcenter": "42"},
+		},
+		{
+			name:     "tags never set in the spec are kept",
+			upstream: map[string]string{"team": "a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+			config := th.newTestConfig()
+			config.UID = "uid"
+			config.Spec.Tags = tt.tags
+			config.Status.Phase = aksConfigActivePhase
+			config.Status.AppliedTags = tt.applied
+			th.client.configs[config.Namespace+"/"+config.Name] = config.DeepCopy()
+			clusterPath := armPath("/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/cluster")
+			th.azure.on(http.MethodPatch, clusterPath, http.StatusOK, map[string]interface{}{"name": "cluster"})
+
+			upstreamSpec := &aksv1.AKSClusterConfigSpec{ClusterName: "cluster", ResourceGroup: "rg", Tags: tt.upstream}
+			if _, err := th.updateUpstreamClusterState(context.Background(), th.secretsCache, config, upstreamSpec); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			sent := th.azure.sent(http.MethodPatch, clusterPath)
+			if tt.want == nil {
+				if sent != nil {
+					t.Errorf("expected no tag update, got %v", sent)
+				}
+				return
+			}
+			want := map[string]interface{}{ownerTag: "uid"}
+			for name, value := range tt.want {
+				want[name] = value
+			}
+			if got, _ := sent.(map[string]interface{}); !reflect.DeepEqual(got["tags"], want) {
+				t.Errorf("expected tags %v to be sent, got %v", want, sent)
+			}
+		})
+	}
+}
+
+func TestUpdateUpstreamClusterStateKeepsUnmanagedSettings(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(spec *aksv1.AKSClusterConfigSpec)
+		// want are the values expected in the cluster update at the path of keys
+		want map[string]interface{}
+	}{
+		{
+			name: "Kubernetes version",
+			change: func(spec *aksv1.AKSClusterConfigSpec) {
+				spec.KubernetesVersion = to.StringPtr("1.23.5")
+			},
+			want: map[string]interface{}{"properties.kubernetesVersion": "1.23.5"},
+		},
+		{
+			name: "authorized IP ranges",
+			change: func(spec *aksv1.AKSClusterConfigSpec) {
+				spec.AuthorizedIPRanges = &[]string{"10.0.0.0/16"}
+			},
+			want: map[string]interface{}{"properties.kubernetesVersion": "1.22.6"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+			th.azure.on(http.MethodGet, upgradeProfilePath, http.StatusOK, testUpgradeProfile)
+			config := th.newUpstreamTestConfig()
+			// the addon is set in the spec without its rotation settings, which are kept upstream
+			config.Spec.KeyVaultSecretsProvider = &aksv1.AKSKeyVaultSecretsProvider{Enabled: true}
+			tt.change(&config.Spec)
+			upstream := testUpstreamCluster(map[string]interface{}{
+				"addonProfiles": map[string]interface{}{
+					"azurepolicy":            map[string]interface{}{"enabled": true},
+					"httpApplicationRouting": map[string]interface{}{"enabled": true},
+					"azureKeyvaultSecretsProvider": map[string]interface{}{
+						"enabled": true,
+						"config":  map[string]interface{}{"enableSecretRotation": "true"},
+					},
+				},
+				"autoUpgradeProfile": map[string]interface{}{"upgradeChannel": "stable"},
+			})
+
+			_, sent := th.updateTestCluster(t, config, upstream)
+			if sent == nil {
+				t.Fatalf("expected the cluster to be updated, got requests %v", th.azure.recorded())
+			}
+			want := map[string]interface{}{
+				"properties.addonProfiles.azurepolicy.enabled":                                      true,
+				"properties.addonProfiles.httpApplicationRouting.enabled":                           true,
+				"properties.addonProfiles.azureKeyvaultSecretsProvider.enabled":                     true,
+				"properties.addonProfiles.azureKeyvaultSecretsProvider.config.enableSecretRotation": "true",
+				"properties.autoUpgradeProfile.upgradeChannel":                                      "stable",
+			}
+			for path, value := range tt.want {
+				want[path] = value
+			}
+			for path, value := range want {
+				if got := lookupJSON(sent, strings.Split(path, ".")...); got != value {
+					t.Errorf("expected %s to be %v, got %v", path, value, got)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanClusterUpdate(t *testing.T) {
+	newPool := func(name, version string) aksv1.AKSNodePool {
+		np := validNodePool(name)
+		np.OrchestratorVersion = to.StringPtr(version)
+		return np
+	}
+	tests := []struct {
+		name   string
+		change func(spec *aksv1.AKSClusterConfigSpec)
+		// removed is a user node pool of the upstream cluster which is not in the spec, if it is set
+		removed string
+		want    []string
+		// wantStages are the kinds of operations planned, in the order they are sent
+		wantStages []string
+	}{
+		{
+			name:   "no changes",
+			change: func(*aksv1.AKSClusterConfigSpec) {},
+		},
+		{
+			name:       "tags",
+			change:     func(spec *aksv1.AKSClusterConfigSpec) { spec.Tags = map[string]string{"team": "a"} },
+			want:       []string{"update tags"},
+			wantStages: []string{"tags"},
+		},
+		{
+			name: "control plane and node pool upgrade",
+			change: func(spec *aksv1.AKSClusterConfigSpec) {
+				spec.KubernetesVersion = to.StringPtr("1.23.5")
+				spec.NodePools[0].OrchestratorVersion = to.StringPtr("1.23.5")
+			},
+			want: []string{
+				"upgrade node pool [pool] from 1.21.9 to 1.23.5",
+				"upgrade control plane from 1.22.6 to 1.23.5",
+			},
+			// the node pool is upgraded once the control plane is
+			wantStages: []string{"cluster"},
+		},
+		{
+			name: "node pool upgrade",
+			change: func(spec *aksv1.AKSClusterConfigSpec) {
+				spec.NodePools[0].OrchestratorVersion = to.StringPtr("1.22.6")
+			},
+			want:       []string{"upgrade node pool [pool] from 1.21.9 to 1.22.6"},
+			wantStages: []string{"nodePoolUpgrades"},
+		},
+		{
+			name:    "node pools added, scaled and removed",
+			removed: "old",
+			change: func(spec *aksv1.AKSClusterConfigSpec) {
+				spec.NodePools[0].Count = to.Int32Ptr(3)
+				spec.NodePools = append(spec.NodePools, newPool("gpupool", "1.21.9"))
+			},
+			want: []string{
+				"update node count of node pool [pool] from 1 to 3",
+				"add node pool [gpupool]",
+				"remove node pool [old]",
+			},
+			wantStages: []string{"nodePoolChanges", "removedNodePools"},
+		},
+		{
+			name: "VM size",
+			change: func(spec *aksv1.AKSClusterConfigSpec) {
+				spec.NodePools[0].VMSize = "Standard_D4s_v3"
+			},
+			want:       []string{"add node pool replacing node pool [pool] to change its VM size from Standard_DS2_v2 to Standard_D4s_v3"},
+			wantStages: []string{"recreateNodePool"},
+		},
+		{
+			name:       "authorized IP ranges",
+			change:     func(spec *aksv1.AKSClusterConfigSpec) { spec.AuthorizedIPRanges = &[]string{"10.0.0.0/16"} },
+			want:       []string{"update authorized IP ranges"},
+			wantStages: []string{"cluster"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &aksv1.AKSClusterConfig{}
+			upstreamSpec := &aksv1.AKSClusterConfigSpec{
+				ClusterName:       "cluster",
+				ResourceGroup:     "rg",
+				KubernetesVersion: to.StringPtr("1.22.6"),
+				NodePools:         []aksv1.AKSNodePool{newPool("pool", "1.21.9")},
+			}
+			upstreamNodePools := map[string]*aksv1.AKSNodePool{
+				"pool": &upstreamSpec.NodePools[0],
+			}
+			spec := upstreamSpec.DeepCopy()
+			if tt.removed != "" {
+				removed := newPool(tt.removed, "1.21.9")
+				removed.Mode = "User"
+				upstreamNodePools[tt.removed] = &removed
+			}
+			tt.change(spec)
+
+			plan, err := planClusterUpdate(config, spec, upstreamSpec, upstreamNodePools)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(plan.changes, tt.want) {
+				t.Errorf("expected changes %q, got %q", tt.want, plan.changes)
+			}
+			var stages []string
+			for stage, planned := range map[string]bool{
+				"tags":             plan.updateTags,
+				"recreateNodePool": plan.recreateNodePool != nil,
+				"nodePoolChanges":  len(plan.nodePoolChanges) > 0,
+				"nodePoolUpgrades": len(plan.nodePoolUpgrades) > 0,
+				"removedNodePools": len(plan.removedNodePools) > 0,
+				"cluster":          plan.clusterUpdate != nil,
+			} {
+				if planned {
+					stages = append(stages, stage)
+				}
+			}
+			sort.Strings(stages)
+			wantStages := append([]string(nil), tt.wantStages...)
+			sort.Strings(wantStages)
+			if !reflect.DeepEqual(stages, wantStages) {
+				t.Errorf("expected stages %v, got %v", wantStages, stages)
+			}
+		})
+	}
+}
+
+func TestUpdateUpstreamClusterStateDryRun(t *testing.T) {
+	th := newTestHandler(t)
+	th.azure.on(http.MethodGet, upgradeProfilePath, http.StatusOK, testUpgradeProfile)
+	config := th.newUpstreamTestConfig()
+	config.Annotations = map[string]string{dryRunAnnotation: "true"}
+	config.Spec.KubernetesVersion = to.StringPtr("1.23.5")
+	userPool := validNodePool("userpool")
+	userPool.Mode = "User"
+	config.Spec.NodePools = append(config.Spec.NodePools, userPool)
+	upstream := testUpstreamCluster(nil)
+
+	updated, _ := th.updateTestCluster(t, config, upstream)
+	want := []string{"add node pool [userpool]", "upgrade control plane from 1.22.6 to 1.23.5"}
+	if !reflect.DeepEqual(updated.Status.PlannedChanges, want) {
+		t.Errorf("expected planned changes %q, got %q", want, updated.Status.PlannedChanges)
+	}
+	if len(th.recorder.Events) != 1 {
+		t.Errorf("expected one event, got %d", len(th.recorder.Events))
+	}
+
+	// the same plan is not recorded again
+	updated, _ = th.updateTestCluster(t, updated, upstream)
+	if len(th.recorder.Events) != 1 {
+		t.Errorf("expected no event for an unchanged plan, got %d events", len(th.recorder.Events))
+	}
+	for _, request := range th.azure.recorded() {
+		if !strings.HasPrefix(request, http.MethodGet+" ") {
+			t.Errorf("expected only reads during a dry run, got %s", request)
+		}
+	}
+
+	// removing the annotation clears the plan before the changes are applied
+	delete(updated.Annotations, dryRunAnnotation)
+	updated, _ = th.updateTestCluster(t, updated, upstream)
+	if updated.Status.PlannedChanges != nil {
+		t.Errorf("expected the planned changes to be cleared, got %q", updated.Status.PlannedChanges)
+	}
+}
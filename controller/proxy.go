@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	"github.com/rancher/aks-operator/pkg/utils"
+	wranglerv1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+)
+
+// httpProxyTrustedCAKey is the key of the PEM encoded CA in the secret referenced by httpProxyConfig.trustedCaSecret
+const httpProxyTrustedCAKey = "ca.crt"
+
+// matchNoProxyHost matches host names and domain suffixes such as ".example.com" in the no proxy list
+var matchNoProxyHost = regexp.MustCompile(`^\.?([a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?\.)*[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?$`)
+
+// validateHTTPProxyConfig rejects proxy URLs which are not http or https URLs and no proxy entries which are neither
+// host names, IP addresses nor CIDRs
+func validateHTTPProxyConfig(spec *aksv1.AKSClusterConfigSpec) []error {
+	config := spec.HTTPProxyConfig
+	if config == nil {
+		return nil
+	}
+
+	var errs []error
+	proxies := []struct {
+		field string
+		url   *string
+	}{
+		{"httpProxy", config.HTTPProxy},
+		{"httpsProxy", config.HTTPSProxy},
+	}
+	for _, proxy := range proxies {
+		if proxy.url == nil {
+			continue
+		}
+		if u, err := url.Parse(*proxy.url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("httpProxyConfig for cluster [%s] config has invalid %s [%s], must be an http or https URL",
+				spec.ClusterName, proxy.field, *proxy.url))
+		}
+	}
+	for _, entry := range config.NoProxy {
+		if net.ParseIP(entry) != nil || matchNoProxyHost.MatchString(entry) {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(entry); err != nil {
+			errs = append(errs, fmt.Errorf("httpProxyConfig for cluster [%s] config has invalid noProxy entry [%s], must be a host name, IP address or CIDR",
+				spec.ClusterName, entry))
+		}
+	}
+	return errs
+}
+
+// httpProxyTrustedCA reads the PEM encoded CA from the secret referenced by the HTTP proxy settings of the spec
+func httpProxyTrustedCA(secretsCache wranglerv1.SecretCache, spec *aksv1.AKSClusterConfigSpec) (string, error) {
+	if spec.HTTPProxyConfig == nil || spec.HTTPProxyConfig.TrustedCASecret == "" {
+		return "", nil
+	}
+	ns, name := utils.ParseSecretName(spec.HTTPProxyConfig.TrustedCASecret)
+	secret, err := secretsCache.Get(ns, name)
+	if err != nil {
+		return "", fmt.Errorf("couldn't find proxy CA secret [%s] in namespace [%s]: %w", name, ns, err)
+	}
+	ca := secret.Data[httpProxyTrustedCAKey]
+	if len(ca) == 0 {
+		return "", fmt.Errorf("field [%s] must be provided in proxy CA secret [%s]", httpProxyTrustedCAKey, spec.HTTPProxyConfig.TrustedCASecret)
+	}
+	return string(ca), nil
+}
+
+// httpProxyConfigChanged returns true if the HTTP proxy settings of the spec differ from the upstream settings. The
+// upstream settings reference the CA secret of the spec only if the upstream CA matches its content.
+func httpProxyConfigChanged(config, upstreamConfig *aksv1.AKSHTTPProxyConfig) bool {
+	if upstreamConfig == nil {
+		upstreamConfig = &aksv1.AKSHTTPProxyConfig{}
+	}
+	return to.String(config.HTTPProxy) != to.String(upstreamConfig.HTTPProxy) ||
+		to.String(config.HTTPSProxy) != to.String(upstreamConfig.HTTPSProxy) ||
+		((len(config.NoProxy) > 0 || len(upstreamConfig.NoProxy) > 0) && !reflect.DeepEqual(config.NoProxy, upstreamConfig.NoProxy)) ||
+		config.TrustedCASecret != upstreamConfig.TrustedCASecret
+}
+
+// upstreamTrustedCASecret returns the CA secret of the spec if its content is the upstream CA
+func upstreamTrustedCASecret(secretsCache wranglerv1.SecretCache, spec *aksv1.AKSClusterConfigSpec, upstreamCA string) string {
+	if upstreamCA == "" {
+		return ""
+	}
+	ca, err := httpProxyTrustedCA(secretsCache, spec)
+	if err != nil || strings.TrimSpace(ca) != strings.TrimSpace(upstreamCA) {
+		return ""
+	}
+	return spec.HTTPProxyConfig.TrustedCASecret
+}
@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/rancher/aks-operator/pkg/aks"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// allowPoolRecreateAnnotation allows node pools whose VM size changed to be deleted and created again with the new
+	// VM size, Azure cannot resize node pools in place
+	allowPoolRecreateAnnotation = "aks.cattle.io/allow-pool-recreate"
+	eventReasonNodePoolRecreate = "NodePoolRecreate"
+
+	// maxLinuxNodePoolNameLength and maxWindowsNodePoolNameLength are the longest node pool names Azure accepts
+	maxLinuxNodePoolNameLength   = 12
+	maxWindowsNodePoolNameLength = 6
+)
+
+// vmSizeChanged returns true if the spec sets a VM size which differs from upstream
+func vmSizeChanged(np, upstreamNodePool *aksv1.AKSNodePool) bool {
+	return np.VMSize != "" && !strings.EqualFold(np.VMSize, upstreamNodePool.VMSize)
+}
+
+// replacementNodePoolName returns the name of the node pool which temporarily replaces np while it is recreated. The
+// name is derived from the name of np within the length limits of Azure and does not collide with another node pool.
+func replacementNodePoolName(spec *aksv1.AKSClusterConfigSpec, np *aksv1.AKSNodePool, upstreamNodePools map[string]*aksv1.AKSNodePool) (string, error) {
+	maxLength := maxLinuxNodePoolNameLength
+	if strings.EqualFold(np.OsType, string(containerservice.OSTypeWindows)) {
+		maxLength = maxWindowsNodePoolNameLength
+	}
+	taken := map[string]bool{}
+	for name := range upstreamNodePools {
+		taken[name] = true
+	}
+	for _, other := range spec.NodePools {
+		taken[to.String(other.Name)] = true
+	}
+
+	name := to.String(np.Name)
+	for i := 0; i < 10; i++ {
+		suffix := fmt.Sprintf("r%d", i)
+		base := name
+		if len(base)+len(suffix) > maxLength {
+			base = base[:maxLength-len(suffix)]
+		}
+		if !taken[base+suffix] {
+			return base + suffix, nil
+		}
+	}
+	return "", fmt.Errorf("no free name for a node pool replacing node pool [%s] for cluster [%s]", name, spec.ClusterName)
+}
+
+// replacementInProgress returns true if replacement is the node pool temporarily replacing name, and name has not been
+// created again with the VM size of the spec yet. The replacement is kept until then.
+func replacementInProgress(spec *aksv1.AKSClusterConfigSpec, replacements map[string]string, replacement string,
+	upstreamNodePools map[string]*aksv1.AKSNodePool) bool {
+	for name, r := range replacements {
+		if r != replacement {
+			continue
+		}
+		upstreamNodePool, ok := upstreamNodePools[name]
+		if !ok {
+			return true
+		}
+		for i := range spec.NodePools {
+			if to.String(spec.NodePools[i].Name) == name {
+				return vmSizeChanged(&spec.NodePools[i], upstreamNodePool)
+			}
+		}
+	}
+	return false
+}
+
+// recreateNodePool handles a VM size change of an existing node pool. Unless the config carries
+// allowPoolRecreateAnnotation the change is rejected. Otherwise a replacement node pool with the new VM size is created
+// under a temporary name first, the node pool is only deleted once the replacement has succeeded, so that its
+// workloads always have nodes to run on. The following reconciles create the node pool again from the spec and remove
+// the replacement once it has succeeded. A failed replacement leaves the node pool untouched.
+func (h *Handler) recreateNodePool(ctx context.Context, agentPoolClient *containerservice.AgentPoolsClient, config *aksv1.AKSClusterConfig,
+	spec *aksv1.AKSClusterConfigSpec, np *aksv1.AKSNodePool, upstreamNodePools map[string]*aksv1.AKSNodePool) (*aksv1.AKSClusterConfig, error) {
+	name := to.String(np.Name)
+	upstreamNodePool := upstreamNodePools[name]
+
+	if config.Annotations[allowPoolRecreateAnnotation] != "true" {
+		return config, invalidSpecError{fmt.Errorf("vmSize cannot be changed from %s to %s on node pool [%s] for cluster [%s], add a node pool with the new VM size and remove this one, or set annotation %s=true to recreate it",
+			upstreamNodePool.VMSize, np.VMSize, name, spec.ClusterName, allowPoolRecreateAnnotation)}
+	}
+
+	// the plan only runs once every node pool has succeeded, an existing replacement is ready to take over
+	replacement := config.Status.NodePoolReplacements[name]
+	if _, ok := upstreamNodePools[replacement]; replacement != "" && ok {
+		logrus.Infof("Removing node pool [%s] for cluster [%s], it was replaced by node pool [%s]", name, spec.ClusterName, replacement)
+		h.recorder.Eventf(config, v1.EventTypeNormal, eventReasonNodePoolRecreate,
+			"Deleting node pool [%s] to recreate it with VM size %s, node pool [%s] replaces it meanwhile", name, np.VMSize, replacement)
+		if err := aks.RemoveAgentPool(ctx, agentPoolClient, spec, upstreamNodePool); err != nil {
+			return config, fmt.Errorf("failed to remove node pool [%s] to recreate it: %w", name, err)
+		}
+		return h.nodePoolsSent(config, []string{name}, nil)
+	}
+
+	if replacement == "" {
+		var err error
+		replacement, err = replacementNodePoolName(spec, np, upstreamNodePools)
+		if err != nil {
+			return config, err
+		}
+	}
+	replacementNodePool := np.DeepCopy()
+	replacementNodePool.Name = to.StringPtr(replacement)
+	// the replacement runs the version of the node pool, upgrades are rolled out separately
+	replacementNodePool.OrchestratorVersion = upstreamNodePool.OrchestratorVersion
+
+	logrus.Infof("Creating node pool [%s] for cluster [%s] to replace node pool [%s] while its VM size is changed from %s to %s",
+		replacement, spec.ClusterName, name, upstreamNodePool.VMSize, np.VMSize)
+	h.recorder.Eventf(config, v1.EventTypeNormal, eventReasonNodePoolRecreate,
+		"Creating node pool [%s] with VM size %s to replace node pool [%s]", replacement, np.VMSize, name)
+	if err := aks.CreateOrUpdateAgentPool(ctx, agentPoolClient, spec, replacementNodePool); err != nil {
+		return config, fmt.Errorf("failed to create node pool [%s] to replace node pool [%s]: %w", replacement, name, err)
+	}
+
+	config = config.DeepCopy()
+	if config.Status.NodePoolReplacements == nil {
+		config.Status.NodePoolReplacements = map[string]string{}
+	}
+	config.Status.NodePoolReplacements[name] = replacement
+	return h.nodePoolsSent(config, []string{replacement}, nil)
+}
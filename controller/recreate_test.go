@@ -0,0 +1,243 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/rancher/aks-operator/pkg/aks"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// agentPoolPath returns the ARM path of a node pool of the test cluster
+func agentPoolPath(name string) string {
+	return armPath("/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/cluster/agentPools/%s", name)
+}
+
+// recreateTestNodePools returns the spec of a cluster whose node pool "pool" changed its VM size, and the upstream node
+// pools including the node pools named in extra
+func recreateTestNodePools(extra ...string) (*aksv1.AKSClusterConfigSpec, map[string]*aksv1.AKSNodePool) {
+	np := validNodePool("pool")
+	np.VMSize = "Standard_D4s_v3"
+	upstreamNodePool := validNodePool("pool")
+	upstreamNodePools := map[string]*aksv1.AKSNodePool{"pool": &upstreamNodePool}
+	for _, name := range extra {
+		other := validNodePool(name)
+		other.VMSize = np.VMSize
+		upstreamNodePools[name] = &other
+	}
+	spec := &aksv1.AKSClusterConfigSpec{ClusterName: "cluster", ResourceGroup: "rg", NodePools: []aksv1.AKSNodePool{np}}
+	return spec, upstreamNodePools
+}
+
+// recreateNodePool runs recreateNodePool for the node pool "pool" of spec against the fake Azure of th
+func (th *testHandler) recreateNodePool(t *testing.T, config *aksv1.AKSClusterConfig, spec *aksv1.AKSClusterConfigSpec,
+	upstreamNodePools map[string]*aksv1.AKSNodePool) (*aksv1.AKSClusterConfig, error) {
+	t.Helper()
+	th.client.configs[config.Namespace+"/"+config.Name] = config.DeepCopy()
+	credentials, err := th.getCredentials(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agentPoolClient, err := aks.NewAgentPoolClient(credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return th.Handler.recreateNodePool(context.Background(), agentPoolClient, config, spec, &spec.NodePools[0], upstreamNodePools)
+}
+
+func TestRecreateNodePoolRequiresAnnotation(t *testing.T) {
+	th := newTestHandler(t)
+	spec, upstreamNodePools := recreateTestNodePools()
+
+	_, err := th.recreateNodePool(t, th.newTestConfig(), spec, upstreamNodePools)
+	var invalidSpec invalidSpecError
+	if !errors.As(err, &invalidSpec) {
+		t.Fatalf("expected an invalid spec error without the %s annotation, got %v", allowPoolRecreateAnnotation, err)
+	}
+	if requests := th.azure.recorded(); len(requests) != 0 {
+		t.Errorf("expected no requests to Azure, got %v", requests)
+	}
+}
+
+func TestRecreateNodePoolCreatesReplacementFirst(t *testing.T) {
+	th := newTestHandler(t)
+	config := th.newTestConfig()
+	config.Annotations = map[string]string{allowPoolRecreateAnnotation: "true"}
+	spec, upstreamNodePools := recreateTestNodePools()
+	th.azure.on(http.MethodPut, agentPoolPath("poolr0"), http.StatusOK, map[string]interface{}{"name": "poolr0"})
+
+	updated, err := th.recreateNodePool(t, config, spec, upstreamNodePools)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"PUT " + agentPoolPath("poolr0")}; !reflect.DeepEqual(th.azure.recorded(), want) {
+		t.Errorf("expected only the replacement to be created, got %v", th.azure.recorded())
+	}
+	if want := map[string]string{"pool": "poolr0"}; !reflect.DeepEqual(updated.Status.NodePoolReplacements, want) {
+		t.Errorf("expected node pool replacements %v, got %v", want, updated.Status.NodePoolReplacements)
+	}
+	if updated.Status.Phase != aksConfigUpdatingPhase {
+		t.Errorf("expected phase %q, got %q", aksConfigUpdatingPhase, updated.Status.Phase)
+	}
+}
+
+func TestRecreateNodePoolRemovesReplacedNodePool(t *testing.T) {
+	th := newTestHandler(t)
+	config := th.newTestConfig()
+	config.Annotations = map[string]string{allowPoolRecreateAnnotation: "true"}
+	config.Status.NodePoolReplacements = map[string]string{"pool": "poolr0"}
+	spec, upstreamNodePools := recreateTestNodePools("poolr0")
+	th.azure.on(http.MethodDelete, agentPoolPath("pool"), http.StatusOK, nil)
+
+	updated, err := th.recreateNodePool(t, config, spec, upstreamNodePools)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"DELETE " + agentPoolPath("pool")}; !reflect.DeepEqual(th.azure.recorded(), want) {
+		t.Errorf("expected the replaced node pool to be removed, got %v", th.azure.recorded())
+	}
+	if want := map[string]string{"pool": "poolr0"}; !reflect.DeepEqual(updated.Status.NodePoolReplacements, want) {
+		t.Errorf("expected the replacement to be kept until the node pool is recreated, got %v", updated.Status.NodePoolReplacements)
+	}
+}
+
+func TestPlanNodePoolsKeepsReplacement(t *testing.T) {
+	replacements := map[string]string{"pool": "poolr0"}
+	tests := []struct {
+		name     string
+		upstream []string
+		// resized sets the VM size of the spec on the upstream node pool "pool"
+		resized bool
+		want    []string
+	}{
+		{
+			name:     "replacement is created",
+			upstream: []string{"pool"},
+			want:     []string{"add node pool replacing node pool [pool] to change its VM size from Standard_DS2_v2 to Standard_D4s_v3"},
+		},
+		{
+			name:     "replaced node pool is removed",
+			upstream: []string{"pool", "poolr0"},
+			want:     []string{"remove node pool [pool] replaced by node pool [poolr0] to recreate it with VM size Standard_D4s_v3"},
+		},
+		{
+			name:     "node pool is created again while the replacement is kept",
+			upstream: []string{"poolr0"},
+			want:     []string{"add node pool [pool]"},
+		},
+		{
+			name:     "replacement is removed once the node pool has its new VM size",
+			upstream: []string{"pool", "poolr0"},
+			resized:  true,
+			want:     []string{"remove node pool [poolr0]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, allNodePools := recreateTestNodePools("poolr0")
+			if tt.resized {
+				allNodePools["pool"].VMSize = spec.NodePools[0].VMSize
+			}
+			upstreamNodePools := map[string]*aksv1.AKSNodePool{}
+			for _, name := range tt.upstream {
+				upstreamNodePools[name] = allNodePools[name]
+			}
+
+			plan := &clusterUpdatePlan{}
+			if err := planNodePools(plan, spec, &aksv1.AKSClusterConfigSpec{}, upstreamNodePools, replacements); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(plan.changes, tt.want) {
+				t.Errorf("expected changes %v, got %v", tt.want, plan.changes)
+			}
+		})
+	}
+}
+
+func TestRemoveNodePoolsClearsReplacement(t *testing.T) {
+	th := newTestHandler(t)
+	config := th.newTestConfig()
+	config.Status.NodePoolReplacements = map[string]string{"pool": "poolr0"}
+	th.client.configs[config.Namespace+"/"+config.Name] = config.DeepCopy()
+	th.azure.on(http.MethodDelete, agentPoolPath("poolr0"), http.StatusOK, nil)
+
+	credentials, err := th.getCredentials(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agentPoolClient, err := aks.NewAgentPoolClient(credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replacement := validNodePool("poolr0")
+	updated, err := th.removeNodePools(context.Background(), agentPoolClient, config, &config.Spec, []*aksv1.AKSNodePool{&replacement})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(updated.Status.NodePoolReplacements) != 0 {
+		t.Errorf("expected the replacement to be cleared once it is removed, got %v", updated.Status.NodePoolReplacements)
+	}
+}
+
+func TestReplacementNodePoolName(t *testing.T) {
+	tests := []struct {
+		name     string
+		pool     string
+		osType   string
+		upstream []string
+		want     string
+	}{
+		{
+			name: "short name",
+			pool: "pool",
+			want: "poolr0",
+		},
+		{
+			name: "name at the Linux length limit",
+			pool: "userpool1234",
+			want: "userpool12r0",
+		},
+		{
+			name:   "Windows name",
+			pool:   "win1",
+			osType: "Windows",
+			want:   "win1r0",
+		},
+		{
+			name:   "name at the Windows length limit",
+			pool:   "winpol",
+			osType: "Windows",
+			want:   "winpr0",
+		},
+		{
+			name:     "name taken by another node pool",
+			pool:     "pool",
+			upstream: []string{"poolr0"},
+			want:     "poolr1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			np := validNodePool(tt.pool)
+			if tt.osType != "" {
+				np.OsType = tt.osType
+			}
+			upstreamNodePools := map[string]*aksv1.AKSNodePool{tt.pool: &np}
+			for _, name := range tt.upstream {
+				other := validNodePool(name)
+				upstreamNodePools[name] = &other
+			}
+			spec := &aksv1.AKSClusterConfigSpec{ClusterName: "cluster", NodePools: []aksv1.AKSNodePool{np}}
+			got, err := replacementNodePoolName(spec, &np, upstreamNodePools)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want || len(got) > maxLinuxNodePoolNameLength || (tt.osType == "Windows" && len(got) > maxWindowsNodePoolNameLength) {
+				t.Errorf("expected replacement name %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"github.com/sirupsen/logrus"
+	v15 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// refreshAnnotation requests an immediate sync with the upstream cluster. Adding the annotation triggers a reconcile,
+// the handler removes it once the sync has finished.
+const refreshAnnotation = "aks.cattle.io/refresh"
+
+// refreshRequested returns true if the config is active and carries the refresh annotation. Refreshes requested while
+// the cluster is being created or updated are left in place until the cluster is active again.
+func refreshRequested(config *aksv1.AKSClusterConfig) bool {
+	return config.Status.Phase == aksConfigActivePhase && config.Annotations[refreshAnnotation] == "true"
+}
+
+// completeRefresh records the sync time of a requested refresh, regardless of the lastSyncTimeInterval throttle, and
+// removes the refresh annotation
+func (h *Handler) completeRefresh(config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
+	var err error
+	if config.Status.Phase == aksConfigActivePhase {
+		config = config.DeepCopy()
+		config.Status.LastSyncTime = v15.Now()
+		config, err = h.updateStatus(config)
+		if err != nil {
+			return config, err
+		}
+	}
+
+	logrus.Infof("Refresh of cluster [%s] finished", config.Name)
+	config = config.DeepCopy()
+	delete(config.Annotations, refreshAnnotation)
+	return h.aksCC.Update(config)
+}
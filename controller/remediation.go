@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/rancher/aks-operator/pkg/aks"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	v15 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// maxNodePoolRemediationAttempts bounds how often a failed node pool is deleted to be recreated
+	maxNodePoolRemediationAttempts = 3
+	eventReasonNodePoolRemediation = "NodePoolRemediation"
+)
+
+// remediableNodePool returns the spec of the failed node pool if it can be remediated: remediation is enabled, the
+// node pool is in the spec and reconciled, and the remediation attempts are not exhausted
+func remediableNodePool(config *aksv1.AKSClusterConfig, name string) *aksv1.AKSNodePool {
+	if !to.Bool(config.Spec.AutoRemediateFailedPools) || isUnmanaged(&config.Spec, unmanagedNodePools) {
+		return nil
+	}
+	if config.Status.NodePoolRemediationAttempts[name] >= maxNodePoolRemediationAttempts {
+		return nil
+	}
+	for i := range config.Spec.NodePools {
+		if to.String(config.Spec.NodePools[i].Name) == name {
+			return &config.Spec.NodePools[i]
+		}
+	}
+	return nil
+}
+
+// remediateNodePool deletes a failed node pool, it is recreated from the spec by the following reconciles once the
+// deletion has finished
+func (h *Handler) remediateNodePool(ctx context.Context, credentials *aks.Credentials, config *aksv1.AKSClusterConfig,
+	np *aksv1.AKSNodePool, state string) (*aksv1.AKSClusterConfig, error) {
+	name := to.String(np.Name)
+	attempt := config.Status.NodePoolRemediationAttempts[name] + 1
+
+	logrus.Infof("Remediating node pool [%s] of cluster [%s] in state %s, attempt %d of %d",
+		name, config.Spec.ClusterName, state, attempt, maxNodePoolRemediationAttempts)
+	h.recorder.Eventf(config, v1.EventTypeWarning, eventReasonNodePoolRemediation,
+		"Node pool [%s] is in state %s, deleting it to recreate it (attempt %d of %d)", name, state, attempt, maxNodePoolRemediationAttempts)
+
+	agentPoolClient, err := aks.NewAgentPoolClient(credentials)
+	if err != nil {
+		return config, err
+	}
+	if err := aks.RemoveAgentPool(ctx, agentPoolClient, &config.Spec, np); err != nil {
+		h.recorder.Eventf(config, v1.EventTypeWarning, eventReasonNodePoolRemediation,
+			"Failed to delete node pool [%s]: %v", name, err)
+		return config, fmt.Errorf("failed to remove failed node pool [%s]: %w", name, err)
+	}
+
+	config = config.DeepCopy()
+	if config.Status.NodePoolRemediationAttempts == nil {
+		config.Status.NodePoolRemediationAttempts = map[string]int32{}
+	}
+	config.Status.NodePoolRemediationAttempts[name] = attempt
+	config.Status.Phase = aksConfigUpdatingPhase
+	config.Status.LastUpdateAppliedTime = v15.Now()
+	return h.updateStatus(config)
+}
+
+// resetNodePoolRemediation removes the remediation attempts of node pools which have been provisioned
+func (h *Handler) resetNodePoolRemediation(config *aksv1.AKSClusterConfig, succeeded map[string]bool) (*aksv1.AKSClusterConfig, error) {
+	changed := false
+	for name := range config.Status.NodePoolRemediationAttempts {
+		if succeeded[name] {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return config, nil
+	}
+
+	config = config.DeepCopy()
+	for name := range config.Status.NodePoolRemediationAttempts {
+		if succeeded[name] {
+			logrus.Infof("Node pool [%s] of cluster [%s] was remediated", name, config.Spec.ClusterName)
+			delete(config.Status.NodePoolRemediationAttempts, name)
+		}
+	}
+	return h.updateStatus(config)
+}
@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/rancher/wrangler/pkg/generic"
+	v15 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+func TestRemoveClusterKeepsFinalizerUntilClusterIsGone(t *testing.T) {
+	th := newTestHandler(t)
+	config := th.newTestConfig()
+	config.Status.Phase = aksConfigActivePhase
+	now := v15.Now()
+	config.DeletionTimestamp = &now
+	th.client.configs[config.Namespace+"/"+config.Name] = config.DeepCopy()
+
+	clusterPath := armPath("/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/cluster")
+	th.azure.on(http.MethodGet, clusterPath, http.StatusOK, map[string]interface{}{
+		"name":       "cluster",
+		"properties": map[string]interface{}{"provisioningState": "Succeeded"},
+	})
+	th.azure.on(http.MethodDelete, clusterPath, http.StatusNoContent, nil)
+
+	updated, err := th.OnAksConfigRemoved("", config)
+	if err != generic.ErrSkip {
+		t.Fatalf("expected generic.ErrSkip to keep the finalizer once the delete is sent, got %v", err)
+	}
+	if updated.Status.Phase != aksConfigDeletingPhase {
+		t.Errorf("expected phase %q, got %q", aksConfigDeletingPhase, updated.Status.Phase)
+	}
+	if want := []string{config.Namespace + "/" + config.Name}; !reflect.DeepEqual(th.requeues, want) {
+		t.Errorf("expected the config to be requeued to follow the deletion, got %v", th.requeues)
+	}
+	if want := []string{"GET " + clusterPath, "DELETE " + clusterPath}; !reflect.DeepEqual(th.azure.recorded(), want) {
+		t.Errorf("expected requests %v, got %v", want, th.azure.recorded())
+	}
+
+	// the delete is not sent again while Azure is deleting the cluster
+	th.azure.on(http.MethodGet, clusterPath, http.StatusOK, map[string]interface{}{
+		"name":       "cluster",
+		"properties": map[string]interface{}{"provisioningState": "Deleting"},
+	})
+	if _, err := th.OnAksConfigRemoved("", updated); err != generic.ErrSkip {
+		t.Fatalf("expected generic.ErrSkip while the cluster is deleting, got %v", err)
+	}
+	if requests := th.azure.recorded(); len(requests) != 3 || requests[2] != "GET "+clusterPath {
+		t.Errorf("expected only the cluster to be fetched, got %v", requests)
+	}
+}
+
+func TestRemoveClusterDeletesCreatedResourcesOnceClusterIsGone(t *testing.T) {
+	th := newTestHandler(t)
+	// the SDK sends some paths with "resourcegroups" in lower case
+	workspacePath := armPath("/resourcegroups/rg/providers/Microsoft.OperationalInsights/workspaces/workspace")
+	resourceGroupPath := armPath("/resourcegroups/rg")
+	config := th.newTestConfig()
+	config.Spec.DeleteWorkspaceOnRemove = to.BoolPtr(true)
+	config.Spec.DeleteResourceGroupOnRemove = to.BoolPtr(true)
+	config.Status.Phase = aksConfigDeletingPhase
+	config.Status.ResourceGroupCreated = true
+	config.Status.LogAnalyticsWorkspaceID = workspacePath
+	config.Status.LogAnalyticsWorkspaceCreated = true
+	now := v15.Now()
+	config.DeletionTimestamp = &now
+	th.client.configs[config.Namespace+"/"+config.Name] = config.DeepCopy()
+
+	clusterPath := armPath("/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/cluster")
+	th.azure.on(http.MethodGet, clusterPath, http.StatusNotFound, notFound("Resource"))
+	th.azure.on(http.MethodDelete, workspacePath, http.StatusOK, nil)
+	th.azure.on(http.MethodGet, workspacePath, http.StatusOK, map[string]interface{}{"name": "workspace"})
+
+	// the deletion of the workspace is started and followed without waiting for it
+	updated, err := th.OnAksConfigRemoved("", config)
+	if err != generic.ErrSkip {
+		t.Fatalf("expected generic.ErrSkip while the workspace is deleted, got %v", err)
+	}
+	if !updated.Status.LogAnalyticsWorkspaceDeleting {
+		t.Error("expected the started deletion of the workspace to be recorded")
+	}
+
+	// the delete is not sent again while the workspace is deleted, the resource group is deleted once it is gone
+	th.azure.on(http.MethodGet, workspacePath, http.StatusNotFound, notFound("Resource"))
+	th.azure.on(http.MethodGet, armPath("/resourceGroups/rg/resources"), http.StatusOK, map[string]interface{}{"value": []interface{}{}})
+	th.azure.on(http.MethodDelete, resourceGroupPath, http.StatusOK, nil)
+	th.azure.on(http.MethodHead, resourceGroupPath, http.StatusNoContent, nil)
+	updated, err = th.OnAksConfigRemoved("", updated)
+	if err != generic.ErrSkip {
+		t.Fatalf("expected generic.ErrSkip while the resource group is deleted, got %v", err)
+	}
+	if updated.Status.LogAnalyticsWorkspaceCreated || updated.Status.LogAnalyticsWorkspaceID != "" || updated.Status.LogAnalyticsWorkspaceDeleting {
+		t.Errorf("expected the deleted workspace to be removed from status, got %q created=%t deleting=%t", updated.Status.LogAnalyticsWorkspaceID,
+			updated.Status.LogAnalyticsWorkspaceCreated, updated.Status.LogAnalyticsWorkspaceDeleting)
+	}
+	if !updated.Status.ResourceGroupDeleting {
+		t.Error("expected the started deletion of the resource group to be recorded")
+	}
+
+	th.azure.on(http.MethodHead, resourceGroupPath, http.StatusNotFound, nil)
+	if _, err := th.OnAksConfigRemoved("", updated); err != nil {
+		t.Fatalf("expected the finalizer to be removed once the created resources are gone, got %v", err)
+	}
+
+	want := []string{
+		"GET " + clusterPath,
+		"DELETE " + workspacePath,
+		"GET " + workspacePath,
+		"GET " + clusterPath,
+		"GET " + workspacePath,
+		"GET " + armPath("/resourceGroups/rg/resources"),
+		"DELETE " + resourceGroupPath,
+		"HEAD " + resourceGroupPath,
+		"GET " + clusterPath,
+		"HEAD " + resourceGroupPath,
+	}
+	if !reflect.DeepEqual(th.azure.recorded(), want) {
+		t.Errorf("expected requests %v, got %v", want, th.azure.recorded())
+	}
+	if want := []string{config.Namespace + "/" + config.Name, config.Namespace + "/" + config.Name}; !reflect.DeepEqual(th.requeues, want) {
+		t.Errorf("expected the config to be requeued while the resources are deleted, got %v", th.requeues)
+	}
+}
+
+func TestSetLogAnalyticsWorkspaceStatusKeepsCreatedWorkspace(t *testing.T) {
+	created := "/subscriptions/s/resourceGroups/rg/providers/Microsoft.OperationalInsights/workspaces/created"
+	other := "/subscriptions/s/resourceGroups/rg/providers/Microsoft.OperationalInsights/workspaces/other"
+	tests := []struct {
+		name        string
+		status      aksv1.AKSClusterConfigStatus
+		workspaceID string
+		created     bool
+		wantID      string
+		wantCreated bool
+	}{
+		{
+			name:        "created workspace is recorded",
+			workspaceID: created,
+			created:     true,
+			wantID:      created,
+			wantCreated: true,
+		},
+		{
+			name:        "existing workspace is recorded",
+			workspaceID: other,
+			wantID:      other,
+		},
+		{
+			name:        "existing workspace replaces another existing workspace",
+			status:      aksv1.AKSClusterConfigStatus{LogAnalyticsWorkspaceID: created},
+			workspaceID: other,
+			wantID:      other,
+		},
+		{
+			name:        "created workspace is kept while monitoring is disabled",
+			status:      aksv1.AKSClusterConfigStatus{LogAnalyticsWorkspaceID: created, LogAnalyticsWorkspaceCreated: true},
+			wantID:      created,
+			wantCreated: true,
+		},
+		{
+			name:        "created workspace is kept while another workspace is wired",
+			status:      aksv1.AKSClusterConfigStatus{LogAnalyticsWorkspaceID: created, LogAnalyticsWorkspaceCreated: true},
+			workspaceID: other,
+			wantID:      created,
+			wantCreated: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := tt.status
+			setLogAnalyticsWorkspaceStatus(&status, tt.workspaceID, tt.created)
+			if status.LogAnalyticsWorkspaceID != tt.wantID || status.LogAnalyticsWorkspaceCreated != tt.wantCreated {
+				t.Errorf("expected %q created=%t, got %q created=%t", tt.wantID, tt.wantCreated,
+					status.LogAnalyticsWorkspaceID, status.LogAnalyticsWorkspaceCreated)
+			}
+		})
+	}
+}
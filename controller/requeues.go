@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"sync"
+	"time"
+)
+
+// Requeues keeps the next requeue scheduled by the handler for every config, so that the debug server can report when
+// each config is reconciled again. A nil Requeues records nothing.
+type Requeues struct {
+	mu        sync.Mutex
+	scheduled map[string]requeue
+}
+
+// requeue is a requeue of a config scheduled by the handler
+type requeue struct {
+	at time.Time
+	// backoff is the wait before retrying a transient Azure failure, it is zero for any other requeue
+	backoff time.Duration
+}
+
+// NewRequeues returns an empty Requeues, it is passed to Register in Options.Requeues
+func NewRequeues() *Requeues {
+	return &Requeues{scheduled: map[string]requeue{}}
+}
+
+// NextRequeue returns when the config is requeued next and the current error backoff, which is only set while a
+// transient Azure failure is retried. Both are zero if no requeue is pending.
+func (r *Requeues) NextRequeue(namespace, name string) (time.Time, time.Duration) {
+	if r == nil {
+		return time.Time{}, 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	scheduled, ok := r.scheduled[namespace+"/"+name]
+	if !ok || !scheduled.at.After(time.Now()) {
+		return time.Time{}, 0
+	}
+	return scheduled.at, scheduled.backoff
+}
+
+// schedule records a requeue of the config after wait, replacing the requeue recorded before
+func (r *Requeues) schedule(namespace, name string, wait, backoff time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.scheduled[namespace+"/"+name] = requeue{at: time.Now().Add(wait), backoff: backoff}
+}
+
+// remove forgets the requeues of a config which is deleted
+func (r *Requeues) remove(namespace, name string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.scheduled, namespace+"/"+name)
+}
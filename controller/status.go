@@ -0,0 +1,30 @@
+package controller
+
+import (
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v15 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// updateStatus writes the status of config. The status is owned by the handler, so on a conflict the latest config is
+// fetched and the status is written onto it again, keeping changes made to its spec and metadata meanwhile.
+func (h *Handler) updateStatus(config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
+	var result *aksv1.AKSClusterConfig
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var err error
+		result, err = h.aksCC.UpdateStatus(config)
+		if !errors.IsConflict(err) {
+			return err
+		}
+
+		latest, getErr := h.aksCC.Get(config.Namespace, config.Name, v15.GetOptions{})
+		if getErr != nil {
+			return getErr
+		}
+		latest.Status = config.Status
+		config = latest
+		return err
+	})
+	return result, err
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+func TestUpdateStatusRetriesConflicts(t *testing.T) {
+	tests := []struct {
+		name      string
+		conflicts int
+		wantErr   bool
+	}{
+		{
+			name: "no conflict",
+		},
+		{
+			name:      "conflict once",
+			conflicts: 1,
+		},
+		{
+			name:      "conflict on every retry",
+			conflicts: 10,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+			config := th.newTestConfig()
+			key := config.Namespace + "/" + config.Name
+			// the spec is changed after the handler read the config
+			changed := config.DeepCopy()
+			changed.Spec.Tags = map[string]string{"team": "platform"}
+			th.client.configs[key] = changed
+			th.client.conflicts = tt.conflicts
+
+			updated := config.DeepCopy()
+			updated.Status.Phase = aksConfigActivePhase
+			result, err := th.updateStatus(updated)
+			if tt.wantErr {
+				if !errors.IsConflict(err) {
+					t.Errorf("expected a conflict once the retries are exhausted, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Status.Phase != aksConfigActivePhase {
+				t.Errorf("expected phase %q, got %q", aksConfigActivePhase, result.Status.Phase)
+			}
+			stored := th.client.configs[key]
+			if stored.Status.Phase != aksConfigActivePhase {
+				t.Errorf("expected the status to be stored, got phase %q", stored.Status.Phase)
+			}
+			if tt.conflicts > 0 && stored.Spec.Tags["team"] != "platform" {
+				t.Errorf("expected the spec changed meanwhile to be kept, got tags %v", stored.Spec.Tags)
+			}
+		})
+	}
+}
+
+func TestRecordErrorConflict(t *testing.T) {
+	conflict := errors.NewConflict(schema.GroupResource{Group: "aks.cattle.io", Resource: "aksclusterconfigs"}, "c-test",
+		fmt.Errorf("the object has been modified; please apply your changes to the latest version and try again"))
+	tests := []struct {
+		name string
+		// err is returned by the reconcile
+		err       error
+		conflicts int
+		// wantFailure is true if the failure is recorded in the status
+		wantFailure bool
+	}{
+		{
+			name: "reconcile conflict",
+			err:  conflict,
+		},
+		{
+			name:        "failure recorded after a conflict",
+			err:         fmt.Errorf("cluster [cluster] failed"),
+			conflicts:   1,
+			wantFailure: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+			config := th.newTestConfig()
+			config.Spec.KubernetesVersion = to.StringPtr("1.23.5")
+			key := config.Namespace + "/" + config.Name
+			th.client.configs[key] = config.DeepCopy()
+			th.client.conflicts = tt.conflicts
+
+			_, err := th.recordError(func(string, *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
+				return config, tt.err
+			})("", config)
+			if err != tt.err {
+				t.Errorf("expected the reconcile error %v to be returned, got %v", tt.err, err)
+			}
+
+			stored := th.client.configs[key]
+			if recorded := stored.Status.FailureMessage != ""; recorded != tt.wantFailure {
+				t.Errorf("expected the failure to be recorded: %v, got failure message %q", tt.wantFailure, stored.Status.FailureMessage)
+			}
+			if events := len(th.recorder.Events); tt.wantFailure != (events > 0) {
+				t.Errorf("expected an event: %v, got %d events", tt.wantFailure, events)
+			}
+		})
+	}
+}
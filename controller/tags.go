@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// Azure resource tag limits
+const (
+	maxTags           = 50
+	maxTagNameLength  = 512
+	maxTagValueLength = 256
+	// invalidTagNameCharacters cannot be used in tag names
+	invalidTagNameCharacters = `<>%&\?/`
+)
+
+// ownerTag is set on the clusters created by the operator, its value is the UID of the config which created the
+// cluster. It is not part of the upstream spec.
+const ownerTag = "aks-operator-config-uid"
+
+// withOwnerTag returns a copy of tags with the owner tag of config added, unless config is imported
+func withOwnerTag(tags map[string]string, config *aksv1.AKSClusterConfig) map[string]string {
+	if config.Spec.Imported {
+		return tags
+	}
+	owned := make(map[string]string, len(tags)+1)
+	for name, value := range tags {
+		owned[name] = value
+	}
+	owned[ownerTag] = string(config.UID)
+	return owned
+}
+
+// desiredTags returns the upstream tags with the tags of the spec added or updated, and the applied tags which were
+// removed from the spec deleted. Tags added out-of-band, e.g. by Azure Policy or cost tooling, are kept. Tag names are
+// compared case-insensitively, like ARM does.
+func desiredTags(spec, upstreamSpec *aksv1.AKSClusterConfigSpec, applied []string) map[string]string {
+	tags := make(map[string]string, len(upstreamSpec.Tags)+len(spec.Tags))
+	for name, value := range upstreamSpec.Tags {
+		tags[name] = value
+	}
+	deleteTag := func(name string) {
+		for upstreamName := range tags {
+			if strings.EqualFold(upstreamName, name) {
+				delete(tags, upstreamName)
+			}
+		}
+	}
+	for _, name := range applied {
+		if _, ok := spec.Tags[name]; !ok {
+			deleteTag(name)
+		}
+	}
+	for name, value := range spec.Tags {
+		deleteTag(name)
+		tags[name] = value
+	}
+	return tags
+}
+
+// tagsChanged returns true if the desired tags differ from the upstream tags. Tags are only compared if the spec sets
+// them or tags were applied from it before, clusters whose tags were never set in the spec keep their upstream tags.
+func tagsChanged(spec, upstreamSpec *aksv1.AKSClusterConfigSpec, applied []string) bool {
+	if spec.Tags == nil && len(applied) == 0 {
+		return false
+	}
+	tags := desiredTags(spec, upstreamSpec, applied)
+	if len(tags) != len(upstreamSpec.Tags) {
+		return true
+	}
+	for name, value := range tags {
+		if upstreamValue, ok := upstreamSpec.Tags[name]; !ok || upstreamValue != value {
+			return true
+		}
+	}
+	return false
+}
+
+// appliedTags returns the names of the tags applied from the spec
+func appliedTags(spec *aksv1.AKSClusterConfigSpec) []string {
+	if isUnmanaged(spec, unmanagedTags) {
+		return nil
+	}
+	return sortedKeys(spec.Tags)
+}
+
+// clusterOwnedBy returns true if cluster was created by config
+func clusterOwnedBy(cluster *containerservice.ManagedCluster, config *aksv1.AKSClusterConfig) bool {
+	return to.String(cluster.Tags[ownerTag]) == string(config.UID)
+}
+
+// validateTags returns every tag which Azure would reject, field is the path of the tags in the spec (e.g. "tags") and
+// is included in the errors. Tag names are compared case-insensitively by ARM, so names differing only by case are
+// rejected as well.
+func validateTags(tags map[string]string, field, clusterName string) []error {
+	var errs []error
+	if len(tags) > maxTags {
+		errs = append(errs, fmt.Errorf("field [%s] for cluster [%s] config has %d tags, at most %d are allowed", field, clusterName, len(tags), maxTags))
+	}
+
+	// sort the names so that the errors are reported in a stable order
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	seen := map[string]string{}
+	for _, name := range names {
+		value := tags[name]
+		if name == "" {
+			errs = append(errs, fmt.Errorf("field [%s] for cluster [%s] config has a tag with an empty name", field, clusterName))
+		}
+		if length := utf8.RuneCountInString(name); length > maxTagNameLength {
+			errs = append(errs, fmt.Errorf("tag [%s] in field [%s] for cluster [%s] config has a name of %d characters, at most %d are allowed",
+				name, field, clusterName, length, maxTagNameLength))
+		}
+		if strings.EqualFold(name, ownerTag) {
+			errs = append(errs, fmt.Errorf("tag [%s] in field [%s] for cluster [%s] config is reserved for the operator",
+				name, field, clusterName))
+		}
+		if strings.ContainsAny(name, invalidTagNameCharacters) {
+			errs = append(errs, fmt.Errorf("tag [%s] in field [%s] for cluster [%s] config has a name containing one of the invalid characters [%s]",
+				name, field, clusterName, invalidTagNameCharacters))
+		}
+		if length := utf8.RuneCountInString(value); length > maxTagValueLength {
+			errs = append(errs, fmt.Errorf("tag [%s] in field [%s] for cluster [%s] config has a value of %d characters, at most %d are allowed",
+				name, field, clusterName, length, maxTagValueLength))
+		}
+		if other, ok := seen[strings.ToLower(name)]; ok {
+			errs = append(errs, fmt.Errorf("tags [%s] and [%s] in field [%s] for cluster [%s] config differ only by case, Azure treats tag names case-insensitively",
+				other, name, field, clusterName))
+			continue
+		}
+		seen[strings.ToLower(name)] = name
+	}
+	return errs
+}
@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	"github.com/rancher/aks-operator/pkg/utils"
+)
+
+// Field paths which can be listed in spec.unmanagedFields to exclude them from reconciliation
+const (
+	unmanagedTags                         = "tags"
+	unmanagedKubernetesVersion            = "kubernetesVersion"
+	unmanagedAuthorizedIPRanges           = "authorizedIPRanges"
+	unmanagedHTTPApplicationRouting       = "httpApplicationRouting"
+	unmanagedMonitoring                   = "monitoring"
+	unmanagedAutoScalerProfile            = "autoScalerProfile"
+	unmanagedNodePools                    = "nodePools"
+	unmanagedNodePoolsCount               = "nodePools.count"
+	unmanagedNodePoolsAutoScaling         = "nodePools.autoScaling"
+	unmanagedNodePoolsOrchestratorVersion = "nodePools.orchestratorVersion"
+)
+
+var unmanagedFieldPaths = map[string]bool{
+	unmanagedTags:                         true,
+	unmanagedKubernetesVersion:            true,
+	unmanagedAuthorizedIPRanges:           true,
+	unmanagedHTTPApplicationRouting:       true,
+	unmanagedMonitoring:                   true,
+	unmanagedAutoScalerProfile:            true,
+	unmanagedNodePools:                    true,
+	unmanagedNodePoolsCount:               true,
+	unmanagedNodePoolsAutoScaling:         true,
+	unmanagedNodePoolsOrchestratorVersion: true,
+}
+
+// validateUnmanagedFields rejects unknown field paths in spec.unmanagedFields
+func validateUnmanagedFields(spec *aksv1.AKSClusterConfigSpec) []error {
+	var errs []error
+	for _, path := range spec.UnmanagedFields {
+		if !unmanagedFieldPaths[path] {
+			errs = append(errs, fmt.Errorf("unknown field [%s] in unmanagedFields for cluster [%s] config", path, spec.ClusterName))
+		}
+	}
+	return errs
+}
+
+// isUnmanaged returns true if the field path is excluded from reconciliation. The Kubernetes version and node pool
+// orchestrator versions are also excluded while an auto-upgrade channel upgrades the cluster.
+func isUnmanaged(spec *aksv1.AKSClusterConfigSpec, path string) bool {
+	if (path == unmanagedKubernetesVersion || path == unmanagedNodePoolsOrchestratorVersion) && upgradeChannelManagesVersion(spec) {
+		return true
+	}
+	for _, p := range spec.UnmanagedFields {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// applyUnmanagedFields returns a copy of spec in which the unmanaged fields are replaced by their upstream values, so
+// that they never differ from the upstream cluster and are sent back unchanged in updates
+func applyUnmanagedFields(spec, upstreamSpec *aksv1.AKSClusterConfigSpec) *aksv1.AKSClusterConfigSpec {
+	spec = spec.DeepCopy()
+	if len(spec.UnmanagedFields) == 0 && !upgradeChannelManagesVersion(spec) {
+		return spec
+	}
+
+	if isUnmanaged(spec, unmanagedTags) {
+		spec.Tags = upstreamSpec.Tags
+	}
+	if isUnmanaged(spec, unmanagedKubernetesVersion) {
+		spec.KubernetesVersion = upstreamSpec.KubernetesVersion
+	}
+	if isUnmanaged(spec, unmanagedAuthorizedIPRanges) {
+		spec.AuthorizedIPRanges = upstreamSpec.AuthorizedIPRanges
+	}
+	if isUnmanaged(spec, unmanagedHTTPApplicationRouting) {
+		spec.HTTPApplicationRouting = upstreamSpec.HTTPApplicationRouting
+	}
+	if isUnmanaged(spec, unmanagedMonitoring) {
+		spec.Monitoring = upstreamSpec.Monitoring
+		spec.LogAnalyticsWorkspaceGroup = upstreamSpec.LogAnalyticsWorkspaceGroup
+		spec.LogAnalyticsWorkspaceName = upstreamSpec.LogAnalyticsWorkspaceName
+	}
+	if isUnmanaged(spec, unmanagedAutoScalerProfile) {
+		spec.AutoScalerProfile = upstreamSpec.AutoScalerProfile
+	}
+	if isUnmanaged(spec, unmanagedNodePools) {
+		spec.NodePools = upstreamSpec.NodePools
+		return spec
+	}
+
+	upstreamNodePools, _ := utils.BuildNodePoolMap(upstreamSpec.NodePools, spec.ClusterName)
+	for i := range spec.NodePools {
+		np := &spec.NodePools[i]
+		upstreamNodePool, ok := upstreamNodePools[to.String(np.Name)]
+		if !ok {
+			continue
+		}
+		if isUnmanaged(spec, unmanagedNodePoolsCount) {
+			np.Count = upstreamNodePool.Count
+		}
+		if isUnmanaged(spec, unmanagedNodePoolsAutoScaling) {
+			np.EnableAutoScaling = upstreamNodePool.EnableAutoScaling
+			np.MinCount = upstreamNodePool.MinCount
+			np.MaxCount = upstreamNodePool.MaxCount
+		}
+		if isUnmanaged(spec, unmanagedNodePoolsOrchestratorVersion) {
+			np.OrchestratorVersion = upstreamNodePool.OrchestratorVersion
+		}
+	}
+	return spec
+}
+
+// upgradeChannelManagesVersion returns true if the auto-upgrade channel of the spec upgrades the Kubernetes version,
+// the node-image channel only upgrades the node images
+func upgradeChannelManagesVersion(spec *aksv1.AKSClusterConfigSpec) bool {
+	switch to.String(spec.UpgradeChannel) {
+	case string(containerservice.UpgradeChannelPatch), string(containerservice.UpgradeChannelStable), string(containerservice.UpgradeChannelRapid):
+		return true
+	}
+	return false
+}
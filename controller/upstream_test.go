@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/to"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// buildUpstreamClusterState returns the upstream spec built from a cluster whose ARM properties are extended with
+// properties
+func buildUpstreamClusterState(t *testing.T, properties map[string]interface{}) *aksv1.AKSClusterConfigSpec {
+	t.Helper()
+	th := newTestHandler(t)
+	config := th.newTestConfig()
+
+	clusterProperties := map[string]interface{}{
+		"provisioningState": "Succeeded",
+		"kubernetesVersion": "1.23.5",
+		"agentPoolProfiles": []interface{}{
+			map[string]interface{}{"name": "pool", "count": 1, "mode": "System"},
+		},
+	}
+	for key, value := range properties {
+		clusterProperties[key] = value
+	}
+	th.azure.on(http.MethodGet, armPath("/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/cluster"),
+		http.StatusOK, map[string]interface{}{"name": "cluster", "properties": clusterProperties})
+
+	upstreamSpec, err := BuildUpstreamClusterState(context.Background(), th.secretsCache, &config.Spec)
+	if err != nil {
+		t.Fatalf("unexpected error building the upstream state: %v", err)
+	}
+	return upstreamSpec
+}
+
+func TestBuildUpstreamClusterStateLinuxProfile(t *testing.T) {
+	tests := []struct {
+		name         string
+		linuxProfile map[string]interface{}
+		wantKey      string
+	}{
+		{
+			name:         "without SSH settings",
+			linuxProfile: map[string]interface{}{"adminUsername": "azureuser"},
+		},
+		{
+			name:         "without SSH keys",
+			linuxProfile: map[string]interface{}{"adminUsername": "azureuser", "ssh": map[string]interface{}{}},
+		},
+		{
+			name: "with an empty list of SSH keys",
+			linuxProfile: map[string]interface{}{"adminUsername": "azureuser", "ssh": map[string]interface{}{
+				"publicKeys": []interface{}{},
+			}},
+		},
+		{
+			name: "with SSH keys",
+			linuxProfile: map[string]interface{}{"adminUsername": "azureuser", "ssh": map[string]interface{}{
+				"publicKeys": []interface{}{map[string]interface{}{"keyData": "ssh-rsa AAAA"}},
+			}},
+			wantKey: "ssh-rsa AAAA",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstreamSpec := buildUpstreamClusterState(t, map[string]interface{}{"linuxProfile": tt.linuxProfile})
+			if to.String(upstreamSpec.LinuxAdminUsername) != "azureuser" {
+				t.Errorf("expected admin username azureuser, got %q", to.String(upstreamSpec.LinuxAdminUsername))
+			}
+			if to.String(upstreamSpec.LinuxSSHPublicKey) != tt.wantKey {
+				t.Errorf("expected SSH public key %q, got %q", tt.wantKey, to.String(upstreamSpec.LinuxSSHPublicKey))
+			}
+		})
+	}
+}
+
+func TestBuildUpstreamClusterStateNetworkProfile(t *testing.T) {
+	upstreamSpec := buildUpstreamClusterState(t, map[string]interface{}{"networkProfile": map[string]interface{}{
+		"networkPlugin":    "kubenet",
+		"networkPolicy":    "",
+		"serviceCidr":      "10.0.0.0/16",
+		"dnsServiceIP":     "",
+		"dockerBridgeCidr": "",
+		"loadBalancerSku":  "standard",
+	}})
+
+	for name, value := range map[string]*string{
+		"networkPolicy":    upstreamSpec.NetworkPolicy,
+		"dnsServiceIp":     upstreamSpec.NetworkDNSServiceIP,
+		"dockerBridgeCidr": upstreamSpec.NetworkDockerBridgeCIDR,
+		"podCidr":          upstreamSpec.NetworkPodCIDR,
+		"outboundType":     upstreamSpec.OutboundType,
+	} {
+		if value != nil {
+			t.Errorf("expected empty upstream field [%s] to be unset, got %q", name, *value)
+		}
+	}
+	for name, field := range map[string]struct {
+		value *string
+		want  string
+	}{
+		"networkPlugin":   {upstreamSpec.NetworkPlugin, "kubenet"},
+		"serviceCidr":     {upstreamSpec.NetworkServiceCIDR, "10.0.0.0/16"},
+		"loadBalancerSku": {upstreamSpec.LoadBalancerSKU, "standard"},
+	} {
+		if to.String(field.value) != field.want {
+			t.Errorf("expected upstream field [%s] to be %q, got %q", name, field.want, to.String(field.value))
+		}
+	}
+}
+
+func TestStringPtrOrNil(t *testing.T) {
+	if got := stringPtrOrNil(""); got != nil {
+		t.Errorf("expected nil for an empty string, got %q", *got)
+	}
+	if got := stringPtrOrNil("azure"); to.String(got) != "azure" {
+		t.Errorf("expected azure, got %v", got)
+	}
+}
+
+func TestBuildUpstreamClusterStateMonitoringWorkspace(t *testing.T) {
+	for _, key := range []string{"logAnalyticsWorkspaceResourceID", "logAnalyticsWorkspaceResourceId"} {
+		t.Run(key, func(t *testing.T) {
+			upstreamSpec := buildUpstreamClusterState(t, map[string]interface{}{"addonProfiles": map[string]interface{}{
+				"omsagent": map[string]interface{}{"enabled": true, "config": map[string]interface{}{
+					key: "/subscriptions/s/resourcegroups/workspace-rg/providers/Microsoft.OperationalInsights/workspaces/workspace",
+				}},
+			}})
+			if !to.Bool(upstreamSpec.Monitoring) || to.String(upstreamSpec.LogAnalyticsWorkspaceGroup) != "workspace-rg" ||
+				to.String(upstreamSpec.LogAnalyticsWorkspaceName) != "workspace" {
+				t.Errorf("expected monitoring with workspace [workspace-rg/workspace], got %v [%s/%s]", to.Bool(upstreamSpec.Monitoring),
+					to.String(upstreamSpec.LogAnalyticsWorkspaceGroup), to.String(upstreamSpec.LogAnalyticsWorkspaceName))
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/rancher/aks-operator/pkg/aks"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	"github.com/rancher/wrangler/pkg/merr"
+)
+
+// maxNodePoolMinorVersionSkew is the number of minor versions node pools may be behind the control plane
+const maxNodePoolMinorVersionSkew = 2
+
+// kubernetesVersion is a parsed "major.minor.patch" version, the patch is -1 if the version only has a major and a
+// minor version
+type kubernetesVersion struct {
+	major, minor, patch int
+}
+
+func parseKubernetesVersion(version string) (kubernetesVersion, error) {
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return kubernetesVersion{}, fmt.Errorf("invalid Kubernetes version [%s]", version)
+	}
+	numbers := []int{0, 0, -1}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return kubernetesVersion{}, fmt.Errorf("invalid Kubernetes version [%s]", version)
+		}
+		numbers[i] = n
+	}
+	return kubernetesVersion{major: numbers[0], minor: numbers[1], patch: numbers[2]}, nil
+}
+
+// compare returns a negative number if v is older than other, 0 if they are equal and a positive number if v is newer.
+// Patch versions are only compared if both versions have one.
+func (v kubernetesVersion) compare(other kubernetesVersion) int {
+	if v.major != other.major {
+		return v.major - other.major
+	}
+	if v.minor != other.minor {
+		return v.minor - other.minor
+	}
+	if v.patch < 0 || other.patch < 0 {
+		return 0
+	}
+	return v.patch - other.patch
+}
+
+// validateVersionSkew checks the Kubernetes versions of the spec before any upgrade is sent to Azure: versions are
+// never downgraded, the control plane can only be upgraded to the versions offered by its upgrade profile, and node
+// pools can neither be newer than the control plane nor more than two minor versions behind it. Node pool upgrades
+// must be offered by the upgrade profile unless the node pool is upgraded to the version of the control plane. The
+// upgrade profile is only requested if a version changes.
+func validateVersionSkew(ctx context.Context, clusterClient *containerservice.ManagedClustersClient,
+	spec, upstreamSpec *aksv1.AKSClusterConfigSpec, upstreamNodePools map[string]*aksv1.AKSNodePool) error {
+	upgradeControlPlane := controlPlaneUpgradePending(spec, upstreamSpec)
+	var upgradedNodePools []*aksv1.AKSNodePool
+	for i := range spec.NodePools {
+		np := &spec.NodePools[i]
+		upstreamNodePool, ok := upstreamNodePools[to.String(np.Name)]
+		if np.OrchestratorVersion != nil && (!ok || to.String(np.OrchestratorVersion) != to.String(upstreamNodePool.OrchestratorVersion)) {
+			upgradedNodePools = append(upgradedNodePools, np)
+		}
+	}
+	if !upgradeControlPlane && len(upgradedNodePools) == 0 {
+		return nil
+	}
+
+	controlPlaneVersion := to.String(upstreamSpec.KubernetesVersion)
+	if spec.KubernetesVersion != nil {
+		controlPlaneVersion = *spec.KubernetesVersion
+	}
+	target, err := parseKubernetesVersion(controlPlaneVersion)
+	if err != nil {
+		return invalidSpecError{err}
+	}
+
+	var profile *aks.UpgradeProfile
+	getProfile := func() (*aks.UpgradeProfile, error) {
+		if profile == nil {
+			profile, err = aks.GetUpgradeProfile(ctx, clusterClient, spec)
+		}
+		return profile, err
+	}
+
+	var errs []error
+	if upgradeControlPlane {
+		current, err := parseKubernetesVersion(to.String(upstreamSpec.KubernetesVersion))
+		if err != nil {
+			return err
+		}
+		if target.compare(current) < 0 {
+			errs = append(errs, fmt.Errorf("kubernetesVersion of cluster [%s] cannot be downgraded from %s to %s",
+				spec.ClusterName, to.String(upstreamSpec.KubernetesVersion), controlPlaneVersion))
+		} else {
+			profile, err := getProfile()
+			if err != nil {
+				return fmt.Errorf("failed to get upgrade profile of cluster [%s]: %w", spec.ClusterName, err)
+			}
+			if !offeredVersion(controlPlaneVersion, profile.ControlPlaneUpgrades) {
+				errs = append(errs, fmt.Errorf("cluster [%s] cannot be upgraded from %s to %s, available upgrades are [%s]",
+					spec.ClusterName, to.String(upstreamSpec.KubernetesVersion), controlPlaneVersion, strings.Join(profile.ControlPlaneUpgrades, ", ")))
+			}
+		}
+	}
+
+	for _, np := range upgradedNodePools {
+		name := to.String(np.Name)
+		version, err := parseKubernetesVersion(to.String(np.OrchestratorVersion))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config has %v", name, spec.ClusterName, err))
+			continue
+		}
+		switch {
+		case version.compare(target) > 0:
+			errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config has orchestratorVersion %s newer than the control plane version %s",
+				name, spec.ClusterName, to.String(np.OrchestratorVersion), controlPlaneVersion))
+			continue
+		case version.major != target.major || target.minor-version.minor > maxNodePoolMinorVersionSkew:
+			errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config has orchestratorVersion %s, more than %d minor versions behind the control plane version %s",
+				name, spec.ClusterName, to.String(np.OrchestratorVersion), maxNodePoolMinorVersionSkew, controlPlaneVersion))
+			continue
+		}
+
+		upstreamNodePool, ok := upstreamNodePools[name]
+		if !ok {
+			continue
+		}
+		current, err := parseKubernetesVersion(to.String(upstreamNodePool.OrchestratorVersion))
+		if err != nil {
+			continue
+		}
+		if version.compare(current) < 0 {
+			errs = append(errs, fmt.Errorf("orchestratorVersion of node pool [%s] for cluster [%s] cannot be downgraded from %s to %s",
+				name, spec.ClusterName, to.String(upstreamNodePool.OrchestratorVersion), to.String(np.OrchestratorVersion)))
+			continue
+		}
+		// node pools are upgraded after the control plane, the upgrade profile only offers versions up to the current
+		// version of the control plane
+		if upgradeControlPlane || version.compare(target) == 0 {
+			continue
+		}
+		profile, err := getProfile()
+		if err != nil {
+			return fmt.Errorf("failed to get upgrade profile of cluster [%s]: %w", spec.ClusterName, err)
+		}
+		if !offeredVersion(to.String(np.OrchestratorVersion), profile.AgentPoolUpgrades[name]) {
+			errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] cannot be upgraded from %s to %s, available upgrades are [%s]",
+				name, spec.ClusterName, to.String(upstreamNodePool.OrchestratorVersion), to.String(np.OrchestratorVersion),
+				strings.Join(profile.AgentPoolUpgrades[name], ", ")))
+		}
+	}
+
+	if len(errs) > 0 {
+		return invalidSpecError{merr.NewErrors(errs...)}
+	}
+	return nil
+}
+
+// offeredVersion returns true if version is one of the offered versions, a version without a patch version matches
+// any patch version of its minor version
+func offeredVersion(version string, offered []string) bool {
+	parsed, err := parseKubernetesVersion(version)
+	if err != nil {
+		return false
+	}
+	for _, o := range offered {
+		if other, err := parseKubernetesVersion(o); err == nil && parsed.compare(other) == 0 {
+			return true
+		}
+	}
+	return false
+}
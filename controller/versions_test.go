@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/to"
+
+	"github.com/rancher/aks-operator/pkg/aks"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// upgradeProfilePath is the ARM path of the upgrade profile of the test cluster
+var upgradeProfilePath = armPath("/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/cluster/upgradeProfiles/default")
+
+// testUpgradeProfile offers the control plane on 1.22.6 an upgrade to 1.23.5, and the node pool "pool" on 1.21.9 an
+// upgrade to 1.22.6
+var testUpgradeProfile = map[string]interface{}{
+	"properties": map[string]interface{}{
+		"controlPlaneProfile": map[string]interface{}{
+			"kubernetesVersion": "1.22.6",
+			"osType":            "Linux",
+			"upgrades":          []map[string]string{{"kubernetesVersion": "1.23.5"}},
+		},
+		"agentPoolProfiles": []map[string]interface{}{{
+			"name":              "pool",
+			"kubernetesVersion": "1.21.9",
+			"osType":            "Linux",
+			"upgrades":          []map[string]string{{"kubernetesVersion": "1.22.6"}},
+		}},
+	},
+}
+
+func TestValidateVersionSkew(t *testing.T) {
+	tests := []struct {
+		name              string
+		kubernetesVersion string
+		poolVersion       string
+		// wantProfile is true if the upgrade profile is requested
+		wantProfile bool
+		wantErr     string
+	}{
+		{
+			name:              "no version change",
+			kubernetesVersion: "1.22.6",
+			poolVersion:       "1.21.9",
+		},
+		{
+			name:              "offered control plane upgrade",
+			kubernetesVersion: "1.23.5",
+			poolVersion:       "1.21.9",
+			wantProfile:       true,
+		},
+		{
+			name:              "control plane upgrade without patch version",
+			kubernetesVersion: "1.23",
+			poolVersion:       "1.21.9",
+			wantProfile:       true,
+		},
+		{
+			name:              "control plane upgrade not offered",
+			kubernetesVersion: "1.24.0",
+			poolVersion:       "1.22.6",
+			wantProfile:       true,
+			wantErr:           "cluster [cluster] cannot be upgraded from 1.22.6 to 1.24.0, available upgrades are [1.23.5]",
+		},
+		{
+			name:              "control plane downgrade",
+			kubernetesVersion: "1.21.9",
+			poolVersion:       "1.21.9",
+			wantErr:           "kubernetesVersion of cluster [cluster] cannot be downgraded from 1.22.6 to 1.21.9",
+		},
+		{
+			name:              "offered node pool upgrade",
+			kubernetesVersion: "1.22.6",
+			poolVersion:       "1.22.6",
+		},
+		{
+			name:              "node pool upgrade not offered",
+			kubernetesVersion: "1.22.6",
+			poolVersion:       "1.21.14",
+			wantProfile:       true,
+			wantErr:           "node pool [pool] for cluster [cluster] cannot be upgraded from 1.21.9 to 1.21.14, available upgrades are [1.22.6]",
+		},
+		{
+			name:              "node pool newer than the control plane",
+			kubernetesVersion: "1.22.6",
+			poolVersion:       "1.23.5",
+			wantErr:           "node pool [pool] for cluster [cluster] config has orchestratorVersion 1.23.5 newer than the control plane version 1.22.6",
+		},
+		{
+			name:              "node pool too far behind the control plane",
+			kubernetesVersion: "1.25.2",
+			poolVersion:       "1.22.6",
+			wantProfile:       true,
+			wantErr:           "node pool [pool] for cluster [cluster] config has orchestratorVersion 1.22.6, more than 2 minor versions behind the control plane version 1.25.2",
+		},
+		{
+			name:              "node pool downgrade",
+			kubernetesVersion: "1.22.6",
+			poolVersion:       "1.20.15",
+			wantErr:           "orchestratorVersion of node pool [pool] for cluster [cluster] cannot be downgraded from 1.21.9 to 1.20.15",
+		},
+		{
+			name:              "invalid node pool version",
+			kubernetesVersion: "1.22.6",
+			poolVersion:       "latest",
+			wantErr:           "node pool [pool] for cluster [cluster] config has invalid Kubernetes version [latest]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+			th.azure.on(http.MethodGet, upgradeProfilePath, http.StatusOK, testUpgradeProfile)
+			config := th.newTestConfig()
+			credentials, err := th.getCredentials(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			clusterClient, err := aks.NewClusterClient(credentials)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			np := validNodePool("pool")
+			np.OrchestratorVersion = to.StringPtr(tt.poolVersion)
+			spec := &aksv1.AKSClusterConfigSpec{
+				ClusterName:       "cluster",
+				ResourceGroup:     "rg",
+				KubernetesVersion: to.StringPtr(tt.kubernetesVersion),
+				NodePools:         []aksv1.AKSNodePool{np},
+			}
+			upstreamSpec := &aksv1.AKSClusterConfigSpec{KubernetesVersion: to.StringPtr("1.22.6")}
+			upstreamNodePool := validNodePool("pool")
+			upstreamNodePool.OrchestratorVersion = to.StringPtr("1.21.9")
+			upstreamNodePools := map[string]*aksv1.AKSNodePool{"pool": &upstreamNodePool}
+
+			err = validateVersionSkew(context.Background(), clusterClient, spec, upstreamSpec, upstreamNodePools)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			} else {
+				var invalidSpec invalidSpecError
+				if !errors.As(err, &invalidSpec) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected an invalid spec error containing %q, got %v", tt.wantErr, err)
+				}
+			}
+			if requested := len(th.azure.recorded()) > 0; requested != tt.wantProfile {
+				t.Errorf("expected the upgrade profile to be requested: %v, got requests %v", tt.wantProfile, th.azure.recorded())
+			}
+		})
+	}
+}
+
+func TestParseKubernetesVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    kubernetesVersion
+		wantErr bool
+	}{
+		{version: "1.23.5", want: kubernetesVersion{major: 1, minor: 23, patch: 5}},
+		{version: "v1.23.5", want: kubernetesVersion{major: 1, minor: 23, patch: 5}},
+		{version: "1.23", want: kubernetesVersion{major: 1, minor: 23, patch: -1}},
+		{version: "1", wantErr: true},
+		{version: "1.23.5.1", wantErr: true},
+		{version: "1.x.5", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, err := parseKubernetesVersion(tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected an error: %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/rancher/aks-operator/pkg/aks"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	osDiskTypeEphemeral = "Ephemeral"
+	// VM size capabilities reported by the resource SKUs API
+	capabilityEphemeralOSDiskSupported = "EphemeralOSDiskSupported"
+	capabilityCachedDiskBytes          = "CachedDiskBytes"
+	capabilityGPUs                     = "GPUs"
+)
+
+// validateNodePoolVMSizes checks the node pools against the VM sizes offered in the location of the cluster: the
+// availability zones must be offered for the VM size, ephemeral OS disks must be supported and fit into the cache of the
+// VM size, and GPU instance profiles require a VM size with GPUs. VM sizes which are not found are not validated, so that new sizes are never rejected.
+func validateNodePoolVMSizes(ctx context.Context, credentials *aks.Credentials, spec *aksv1.AKSClusterConfigSpec) []error {
+	errs := validateAvailabilityZoneNames(spec)
+	if len(errs) > 0 || !needsVMSizeValidation(spec) {
+		return errs
+	}
+
+	client, err := aks.NewResourceSkusClient(credentials)
+	if err != nil {
+		return append(errs, err)
+	}
+	vmSizes, err := aks.ListVMSizes(ctx, client, spec.ResourceLocation)
+	if err != nil {
+		logrus.Warnf("Cannot validate VM sizes of cluster [%s], failed to list VM sizes in [%s]: %v",
+			spec.ClusterName, spec.ResourceLocation, err)
+		return errs
+	}
+
+	for _, np := range spec.NodePools {
+		if !nodePoolNeedsVMSizeValidation(&np) {
+			continue
+		}
+		vmSize, ok := vmSizes[strings.ToLower(np.VMSize)]
+		if !ok {
+			logrus.Warnf("Cannot validate node pool [%s] for cluster [%s], VM size [%s] was not found in [%s]",
+				to.String(np.Name), spec.ClusterName, np.VMSize, spec.ResourceLocation)
+			continue
+		}
+		errs = append(errs, validateVMSizeZones(spec, &np, vmSize)...)
+		errs = append(errs, validateVMSizeEphemeralOSDisk(spec, &np, vmSize)...)
+		errs = append(errs, validateVMSizeGPU(spec, &np, vmSize)...)
+	}
+	return errs
+}
+
+func needsVMSizeValidation(spec *aksv1.AKSClusterConfigSpec) bool {
+	for _, np := range spec.NodePools {
+		if nodePoolNeedsVMSizeValidation(&np) {
+			return true
+		}
+	}
+	return false
+}
+
+func nodePoolNeedsVMSizeValidation(np *aksv1.AKSNodePool) bool {
+	return hasAvailabilityZones(np) || strings.EqualFold(np.OsDiskType, osDiskTypeEphemeral) || np.GpuInstanceProfile != ""
+}
+
+func hasAvailabilityZones(np *aksv1.AKSNodePool) bool {
+	return np.AvailabilityZones != nil && len(*np.AvailabilityZones) > 0
+}
+
+func validateVMSizeZones(spec *aksv1.AKSClusterConfigSpec, np *aksv1.AKSNodePool, vmSize aks.VMSize) []error {
+	if !hasAvailabilityZones(np) {
+		return nil
+	}
+
+	var errs []error
+	offered := map[string]bool{}
+	for _, zone := range vmSize.Zones {
+		offered[zone] = true
+	}
+	for _, zone := range *np.AvailabilityZones {
+		if !offered[zone] {
+			errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config has availability zone [%s], VM size [%s] is only available in zones [%s] of [%s]",
+				to.String(np.Name), spec.ClusterName, zone, np.VMSize, strings.Join(vmSize.Zones, ", "), spec.ResourceLocation))
+		}
+	}
+	return errs
+}
+
+func validateVMSizeEphemeralOSDisk(spec *aksv1.AKSClusterConfigSpec, np *aksv1.AKSNodePool, vmSize aks.VMSize) []error {
+	if !strings.EqualFold(np.OsDiskType, osDiskTypeEphemeral) {
+		return nil
+	}
+
+	if !strings.EqualFold(vmSize.Capabilities[capabilityEphemeralOSDiskSupported], "True") {
+		return []error{fmt.Errorf("node pool [%s] for cluster [%s] config uses an ephemeral OS disk, VM size [%s] does not support ephemeral OS disks",
+			to.String(np.Name), spec.ClusterName, np.VMSize)}
+	}
+
+	cachedDiskBytes, err := strconv.ParseInt(vmSize.Capabilities[capabilityCachedDiskBytes], 10, 64)
+	if err != nil || np.OsDiskSizeGB == nil {
+		return nil
+	}
+	cachedDiskGB := cachedDiskBytes / (1 << 30)
+	if int64(*np.OsDiskSizeGB) > cachedDiskGB {
+		return []error{fmt.Errorf("node pool [%s] for cluster [%s] config has an ephemeral OS disk of %d GB, the cache of VM size [%s] only fits %d GB",
+			to.String(np.Name), spec.ClusterName, *np.OsDiskSizeGB, np.VMSize, cachedDiskGB)}
+	}
+	return nil
+}
+
+func validateVMSizeGPU(spec *aksv1.AKSClusterConfigSpec, np *aksv1.AKSNodePool, vmSize aks.VMSize) []error {
+	if np.GpuInstanceProfile == "" {
+		return nil
+	}
+
+	gpus, err := strconv.Atoi(vmSize.Capabilities[capabilityGPUs])
+	if err != nil || gpus == 0 {
+		return []error{fmt.Errorf("node pool [%s] for cluster [%s] config has gpuInstanceProfile [%s], VM size [%s] has no GPUs",
+			to.String(np.Name), spec.ClusterName, np.GpuInstanceProfile, np.VMSize)}
+	}
+	return nil
+}
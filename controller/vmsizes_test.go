@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/to"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+func TestValidateNodePoolVMSizesEphemeralOSDisk(t *testing.T) {
+	// Standard_DS3_v2 has a cache of 172 GB
+	skus := []map[string]interface{}{
+		vmSizeSku("Standard_DS3_v2", nil, capabilityEphemeralOSDiskSupported, "True", capabilityCachedDiskBytes, "185220874240"),
+		vmSizeSku("Standard_B2s", nil, capabilityEphemeralOSDiskSupported, "False"),
+	}
+	tests := []struct {
+		name       string
+		vmSize     string
+		osDiskType string
+		diskSizeGB int32
+		wantErr    string
+	}{
+		{
+			name:       "supported VM size",
+			vmSize:     "Standard_DS3_v2",
+			osDiskType: "Ephemeral",
+			diskSizeGB: 128,
+		},
+		{
+			name:       "disk filling the cache",
+			vmSize:     "standard_ds3_v2",
+			osDiskType: "Ephemeral",
+			diskSizeGB: 172,
+		},
+		{
+			name:       "disk larger than the cache",
+			vmSize:     "Standard_DS3_v2",
+			osDiskType: "Ephemeral",
+			diskSizeGB: 256,
+			wantErr:    "node pool [pool] for cluster [cluster] config has an ephemeral OS disk of 256 GB, the cache of VM size [Standard_DS3_v2] only fits 172 GB",
+		},
+		{
+			name:       "unsupported VM size",
+			vmSize:     "Standard_B2s",
+			osDiskType: "Ephemeral",
+			diskSizeGB: 30,
+			wantErr:    "node pool [pool] for cluster [cluster] config uses an ephemeral OS disk, VM size [Standard_B2s] does not support ephemeral OS disks",
+		},
+		{
+			name:       "unknown VM size",
+			vmSize:     "Standard_New_v9",
+			osDiskType: "Ephemeral",
+			diskSizeGB: 128,
+		},
+		{
+			name:       "managed disk on an unsupported VM size",
+			vmSize:     "Standard_B2s",
+			osDiskType: "Managed",
+			diskSizeGB: 128,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+			np := validNodePool("pool")
+			np.VMSize = tt.vmSize
+			np.OsDiskType = tt.osDiskType
+			np.OsDiskSizeGB = to.Int32Ptr(tt.diskSizeGB)
+			errs := th.validateNodePoolVMSizes(t, np, skus...)
+			if tt.wantErr == "" {
+				if len(errs) != 0 {
+					t.Errorf("expected no errors, got %v", errs)
+				}
+				return
+			}
+			if len(errs) != 1 || !strings.Contains(errs[0].Error(), tt.wantErr) {
+				t.Errorf("expected one error containing %q, got %v", tt.wantErr, errs)
+			}
+		})
+	}
+}
+
+func TestNeedsVMSizeValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		nodePool aksv1.AKSNodePool
+		want     bool
+	}{
+		{
+			name:     "managed disk without zones",
+			nodePool: aksv1.AKSNodePool{OsDiskType: "Managed"},
+		},
+		{
+			name:     "empty zones",
+			nodePool: aksv1.AKSNodePool{AvailabilityZones: &[]string{}},
+		},
+		{
+			name:     "ephemeral disk",
+			nodePool: aksv1.AKSNodePool{OsDiskType: "ephemeral"},
+			want:     true,
+		},
+		{
+			name:     "zones",
+			nodePool: aksv1.AKSNodePool{AvailabilityZones: &[]string{"1"}},
+			want:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &aksv1.AKSClusterConfigSpec{NodePools: []aksv1.AKSNodePool{tt.nodePool}}
+			if got := needsVMSizeValidation(spec); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rancher/aks-operator/pkg/aks"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+// maxWarnings is the maximum number of warnings kept in status, the oldest warnings are dropped first
+const maxWarnings = 10
+
+// Warning reasons, each reason has at most one warning in status
+const (
+	warningReasonResourceGroupRetained             = "ResourceGroupRetained"
+	warningReasonWorkspaceRetained                 = "WorkspaceRetained"
+	warningReasonHTTPApplicationRoutingUnsupported = "HTTPApplicationRoutingUnsupported"
+	warningReasonImportIgnoredFields               = "ImportIgnoredFields"
+	warningReasonImportNodePools                   = "ImportNodePools"
+)
+
+// setWarning records a warning for reason in status without touching the failure message or the phase. A previous
+// warning with the same reason is replaced. The warning is mirrored as an event when it is first recorded, the
+// status is only updated if the warnings changed.
+func (h *Handler) setWarning(config *aksv1.AKSClusterConfig, reason, message string) (*aksv1.AKSClusterConfig, error) {
+	warning := fmt.Sprintf("%s: %s", reason, message)
+	for _, w := range config.Status.Warnings {
+		if w == warning {
+			return config, nil
+		}
+	}
+
+	h.recorder.Event(config, v1.EventTypeWarning, reason, message)
+
+	config = config.DeepCopy()
+	warnings := append(removeWarning(config.Status.Warnings, reason), warning)
+	if len(warnings) > maxWarnings {
+		warnings = warnings[len(warnings)-maxWarnings:]
+	}
+	config.Status.Warnings = warnings
+	return h.updateStatus(config)
+}
+
+// clearWarning removes the warning for reason from status once the underlying condition is resolved
+func (h *Handler) clearWarning(config *aksv1.AKSClusterConfig, reason string) (*aksv1.AKSClusterConfig, error) {
+	warnings := removeWarning(config.Status.Warnings, reason)
+	if len(warnings) == len(config.Status.Warnings) {
+		return config, nil
+	}
+
+	config = config.DeepCopy()
+	config.Status.Warnings = warnings
+	return h.updateStatus(config)
+}
+
+// recordSpecWarnings sets or clears the warnings for settings in the spec which cannot be applied
+func (h *Handler) recordSpecWarnings(config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
+	if config.Spec.HTTPApplicationRouting != nil && *config.Spec.HTTPApplicationRouting &&
+		!aks.HasHTTPApplicationRoutingSupport(&config.Spec) {
+		return h.setWarning(config, warningReasonHTTPApplicationRoutingUnsupported,
+			fmt.Sprintf("HTTP application routing is not supported in location [%s] and will not be enabled", config.Spec.ResourceLocation))
+	}
+	return h.clearWarning(config, warningReasonHTTPApplicationRoutingUnsupported)
+}
+
+// recordImportWarnings sets warnings for fields of an imported config which only apply when the operator creates the
+// cluster, and for node pools which will be reconciled against the imported cluster
+func (h *Handler) recordImportWarnings(config *aksv1.AKSClusterConfig) (*aksv1.AKSClusterConfig, error) {
+	spec := &config.Spec
+	var ignored []string
+	for field, set := range map[string]bool{
+		"dnsPrefix":                      spec.DNSPrefix != nil,
+		"linuxAdminUsername":             spec.LinuxAdminUsername != nil,
+		"sshPublicKey":                   spec.LinuxSSHPublicKey != nil,
+		"virtualNetwork":                 spec.VirtualNetwork != nil,
+		"virtualNetworkResourceGroup":    spec.VirtualNetworkResourceGroup != nil,
+		"subnet":                         spec.Subnet != nil,
+		"networkPlugin":                  spec.NetworkPlugin != nil,
+		"networkPolicy":                  spec.NetworkPolicy != nil,
+		"dnsServiceIp":                   spec.NetworkDNSServiceIP != nil,
+		"serviceCidr":                    spec.NetworkServiceCIDR != nil,
+		"dockerBridgeCidr":               spec.NetworkDockerBridgeCIDR != nil,
+		"podCidr":                        spec.NetworkPodCIDR != nil,
+		"loadBalancerSku":                spec.LoadBalancerSKU != nil,
+		"privateCluster":                 spec.PrivateCluster != nil,
+		"privateDnsZone":                 spec.PrivateDNSZone != nil,
+		"enablePrivateClusterPublicFqdn": spec.EnablePrivateClusterPublicFQDN != nil,
+		"deleteResourceGroupOnRemove":    spec.DeleteResourceGroupOnRemove != nil,
+		"forceDeleteResourceGroup":       spec.ForceDeleteResourceGroup != nil,
+		"deleteWorkspaceOnRemove":        spec.DeleteWorkspaceOnRemove != nil,
+		"recreateOnDelete":               spec.RecreateOnDelete != nil,
+	} {
+		if set {
+			ignored = append(ignored, field)
+		}
+	}
+
+	var err error
+	if len(ignored) > 0 {
+		sort.Strings(ignored)
+		config, err = h.setWarning(config, warningReasonImportIgnoredFields,
+			fmt.Sprintf("fields [%s] only apply to clusters created by the operator and are ignored on import", strings.Join(ignored, ", ")))
+		if err != nil {
+			return config, err
+		}
+	}
+
+	if len(spec.NodePools) > 0 {
+		return h.setWarning(config, warningReasonImportNodePools,
+			"nodePools are reconciled against the imported cluster, node pools which are not listed will be removed")
+	}
+	return config, nil
+}
+
+func removeWarning(warnings []string, reason string) []string {
+	var ret []string
+	for _, w := range warnings {
+		if !strings.HasPrefix(w, reason+": ") {
+			ret = append(ret, w)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/rancher/wrangler/pkg/merr"
+	"github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	v15 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// webhookPath is the path the validating webhook is served under
+const webhookPath = "/validate-aksclusterconfig"
+
+// immutableFields are the fields of the spec which cannot be changed once the cluster is created. Each returns an
+// empty string when the field is not set, fields which are not set yet may still be set.
+var immutableFields = []struct {
+	name  string
+	value func(spec *aksv1.AKSClusterConfigSpec) string
+}{
+	{"imported", func(spec *aksv1.AKSClusterConfigSpec) string { return strconv.FormatBool(spec.Imported) }},
+	{"resourceLocation", func(spec *aksv1.AKSClusterConfigSpec) string { return spec.ResourceLocation }},
+	{"resourceGroup", func(spec *aksv1.AKSClusterConfigSpec) string { return spec.ResourceGroup }},
+	{"clusterName", func(spec *aksv1.AKSClusterConfigSpec) string { return spec.ClusterName }},
+	{"dnsPrefix", func(spec *aksv1.AKSClusterConfigSpec) string { return to.String(spec.DNSPrefix) }},
+	{"linuxAdminUsername", func(spec *aksv1.AKSClusterConfigSpec) string { return to.String(spec.LinuxAdminUsername) }},
+	{"networkPlugin", func(spec *aksv1.AKSClusterConfigSpec) string { return to.String(spec.NetworkPlugin) }},
+	{"networkPolicy", func(spec *aksv1.AKSClusterConfigSpec) string { return to.String(spec.NetworkPolicy) }},
+	{"virtualNetworkResourceGroup", func(spec *aksv1.AKSClusterConfigSpec) string { return to.String(spec.VirtualNetworkResourceGroup) }},
+	{"virtualNetwork", func(spec *aksv1.AKSClusterConfigSpec) string { return to.String(spec.VirtualNetwork) }},
+	{"subnet", func(spec *aksv1.AKSClusterConfigSpec) string { return to.String(spec.Subnet) }},
+	{"dnsServiceIp", func(spec *aksv1.AKSClusterConfigSpec) string { return to.String(spec.NetworkDNSServiceIP) }},
+	{"serviceCidr", func(spec *aksv1.AKSClusterConfigSpec) string { return to.String(spec.NetworkServiceCIDR) }},
+	{"dockerBridgeCidr", func(spec *aksv1.AKSClusterConfigSpec) string { return to.String(spec.NetworkDockerBridgeCIDR) }},
+	{"podCidr", func(spec *aksv1.AKSClusterConfigSpec) string { return to.String(spec.NetworkPodCIDR) }},
+	{"loadBalancerSku", func(spec *aksv1.AKSClusterConfigSpec) string { return to.String(spec.LoadBalancerSKU) }},
+	{"outboundType", func(spec *aksv1.AKSClusterConfigSpec) string { return to.String(spec.OutboundType) }},
+	{"privateCluster", func(spec *aksv1.AKSClusterConfigSpec) string { return boolString(spec.PrivateCluster) }},
+	{"privateDnsZone", func(spec *aksv1.AKSClusterConfigSpec) string { return to.String(spec.PrivateDNSZone) }},
+	{"enablePrivateClusterPublicFqdn", func(spec *aksv1.AKSClusterConfigSpec) string { return boolString(spec.EnablePrivateClusterPublicFQDN) }},
+	{"enableRbac", func(spec *aksv1.AKSClusterConfigSpec) string { return boolString(spec.EnableRBAC) }},
+	{"kubeletIdentity", func(spec *aksv1.AKSClusterConfigSpec) string {
+		if spec.KubeletIdentity == nil {
+			return ""
+		}
+		return to.String(spec.KubeletIdentity.ResourceID)
+	}},
+}
+
+func boolString(b *bool) string {
+	if b == nil {
+		return ""
+	}
+	return strconv.FormatBool(*b)
+}
+
+// validateImmutableFields returns an error for each immutable field which was changed by an update of a config whose
+// cluster has been created
+func validateImmutableFields(oldConfig, config *aksv1.AKSClusterConfig) []error {
+	if oldConfig.Status.Phase == "" {
+		return nil
+	}
+
+	var errs []error
+	for _, field := range immutableFields {
+		oldValue, value := field.value(&oldConfig.Spec), field.value(&config.Spec)
+		if oldValue != "" && !strings.EqualFold(oldValue, value) {
+			errs = append(errs, fmt.Errorf("field [%s] cannot be changed from [%s] to [%s] for cluster [%s] after it is created",
+				field.name, oldValue, value, oldConfig.Spec.ClusterName))
+		}
+	}
+	return errs
+}
+
+// serveWebhook serves the validating webhook on the address of the options until ctx is done. The certificate is
+// read on each handshake, so that a rotated certificate is picked up without a restart.
+func (h *Handler) serveWebhook(ctx context.Context, options Options) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(webhookPath, h.handleAdmissionReview)
+	server := &http.Server{
+		Addr:    options.WebhookAddress,
+		Handler: mux,
+		TLSConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+				cert, err := tls.LoadX509KeyPair(options.WebhookCertFile, options.WebhookKeyFile)
+				if err != nil {
+					return nil, err
+				}
+				return &cert, nil
+			},
+		},
+	}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	go func() {
+		logrus.Infof("Starting validating webhook on [%s]", options.WebhookAddress)
+		if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+			logrus.Errorf("Validating webhook on [%s] stopped: %v", options.WebhookAddress, err)
+		}
+	}()
+}
+
+// handleAdmissionReview answers an AdmissionReview of an AKSClusterConfig, the config is rejected with every problem
+// found
+func (h *Handler) handleAdmissionReview(rw http.ResponseWriter, req *http.Request) {
+	review := admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(req.Body).Decode(&review); err != nil || review.Request == nil {
+		http.Error(rw, "invalid AdmissionReview", http.StatusBadRequest)
+		return
+	}
+
+	response := &admissionv1.AdmissionResponse{
+		UID:     review.Request.UID,
+		Allowed: true,
+	}
+	if err := h.admit(review.Request); err != nil {
+		response.Allowed = false
+		response.Result = &v15.Status{
+			Status:  v15.StatusFailure,
+			Code:    http.StatusUnprocessableEntity,
+			Reason:  v15.StatusReasonInvalid,
+			Message: err.Error(),
+		}
+	}
+	review.Request = nil
+	review.Response = response
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(review); err != nil {
+		logrus.Errorf("Error writing AdmissionReview response: %v", err)
+	}
+}
+
+// admit validates a created config, or the spec of an updated config along with the immutable fields. Configs being
+// deleted and updates which leave the spec unchanged, e.g. of annotations or finalizers, are always allowed.
+func (h *Handler) admit(req *admissionv1.AdmissionRequest) error {
+	config := &aksv1.AKSClusterConfig{}
+	if err := json.Unmarshal(req.Object.Raw, config); err != nil {
+		return fmt.Errorf("cannot decode AKSClusterConfig: %w", err)
+	}
+
+	switch req.Operation {
+	case admissionv1.Create:
+		return merr.NewErrors(h.validateConfigSpec(config)...)
+	case admissionv1.Update:
+		oldConfig := &aksv1.AKSClusterConfig{}
+		if err := json.Unmarshal(req.OldObject.Raw, oldConfig); err != nil {
+			return fmt.Errorf("cannot decode AKSClusterConfig: %w", err)
+		}
+		if config.DeletionTimestamp != nil || reflect.DeepEqual(oldConfig.Spec, config.Spec) {
+			return nil
+		}
+		errs := validateImmutableFields(oldConfig, config)
+		return merr.NewErrors(append(errs, h.validateConfigSpec(config)...)...)
+	}
+	return nil
+}
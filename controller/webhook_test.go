@@ -0,0 +1,248 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	admissionv1 "k8s.io/api/admission/v1"
+	v15 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// newWebhookTestConfig returns a valid config of th
+func (th *testHandler) newWebhookTestConfig() *aksv1.AKSClusterConfig {
+	config := th.newTestConfig()
+	config.Spec.KubernetesVersion = to.StringPtr("1.23.5")
+	config.Spec.DNSPrefix = to.StringPtr("cluster-dns")
+	config.Spec.NetworkPlugin = to.StringPtr("azure")
+	config.Spec.PrivateCluster = to.BoolPtr(true)
+	config.Spec.NodePools = []aksv1.AKSNodePool{validNodePool("pool")}
+	return config
+}
+
+func TestValidateImmutableFields(t *testing.T) {
+	tests := []struct {
+		name string
+		// notCreated is true if the cluster of the old config has not been created yet
+		notCreated bool
+		change     func(spec *aksv1.AKSClusterConfigSpec)
+		// wantErr is the field reported as changed, empty if the change is allowed
+		wantErr string
+	}{
+		{
+			name:    "resource group",
+			change:  func(spec *aksv1.AKSClusterConfigSpec) { spec.ResourceGroup = "other" },
+			wantErr: "field [resourceGroup] cannot be changed from [rg] to [other] for cluster [cluster] after it is created",
+		},
+		{
+			name:    "resource location",
+			change:  func(spec *aksv1.AKSClusterConfigSpec) { spec.ResourceLocation = "westeurope" },
+			wantErr: "field [resourceLocation] cannot be changed from [eastus] to [westeurope]",
+		},
+		{
+			name:    "cluster name",
+			change:  func(spec *aksv1.AKSClusterConfigSpec) { spec.ClusterName = "other" },
+			wantErr: "field [clusterName] cannot be changed from [cluster] to [other]",
+		},
+		{
+			name:    "network plugin",
+			change:  func(spec *aksv1.AKSClusterConfigSpec) { spec.NetworkPlugin = to.StringPtr("kubenet") },
+			wantErr: "field [networkPlugin] cannot be changed from [azure] to [kubenet]",
+		},
+		{
+			name:    "private cluster",
+			change:  func(spec *aksv1.AKSClusterConfigSpec) { spec.PrivateCluster = to.BoolPtr(false) },
+			wantErr: "field [privateCluster] cannot be changed from [true] to [false]",
+		},
+		{
+			name:    "private cluster unset",
+			change:  func(spec *aksv1.AKSClusterConfigSpec) { spec.PrivateCluster = nil },
+			wantErr: "field [privateCluster] cannot be changed from [true] to []",
+		},
+		{
+			name:    "imported",
+			change:  func(spec *aksv1.AKSClusterConfigSpec) { spec.Imported = true },
+			wantErr: "field [imported] cannot be changed from [false] to [true]",
+		},
+		{
+			name:   "case of the resource location",
+			change: func(spec *aksv1.AKSClusterConfigSpec) { spec.ResourceLocation = "EastUS" },
+		},
+		{
+			name:   "field set for the first time",
+			change: func(spec *aksv1.AKSClusterConfigSpec) { spec.NetworkServiceCIDR = to.StringPtr("10.0.0.0/16") },
+		},
+		{
+			name:   "mutable field",
+			change: func(spec *aksv1.AKSClusterConfigSpec) { spec.KubernetesVersion = to.StringPtr("1.24.0") },
+		},
+		{
+			name:       "cluster not created yet",
+			notCreated: true,
+			change:     func(spec *aksv1.AKSClusterConfigSpec) { spec.ResourceGroup = "other" },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+			oldConfig := th.newWebhookTestConfig()
+			if !tt.notCreated {
+				oldConfig.Status.Phase = aksConfigActivePhase
+			}
+			config := oldConfig.DeepCopy()
+			tt.change(&config.Spec)
+
+			errs := validateImmutableFields(oldConfig, config)
+			if tt.wantErr == "" {
+				if len(errs) != 0 {
+					t.Errorf("expected the change to be allowed, got %v", errs)
+				}
+				return
+			}
+			if len(errs) != 1 || !strings.Contains(errs[0].Error(), tt.wantErr) {
+				t.Errorf("expected one error containing %q, got %v", tt.wantErr, errs)
+			}
+		})
+	}
+}
+
+func TestHandleAdmissionReview(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation admissionv1.Operation
+		// stored is applied to the old config before the request is made, if it is set
+		stored func(config *aksv1.AKSClusterConfig)
+		// change is applied to the new config of the request, the old config of an update is unchanged
+		change func(config *aksv1.AKSClusterConfig)
+		// wantErr is part of the message of the rejection, empty if the config is allowed
+		wantErr string
+	}{
+		{
+			name:      "valid config created",
+			operation: admissionv1.Create,
+			change:    func(*aksv1.AKSClusterConfig) {},
+		},
+		{
+			name:      "invalid config created",
+			operation: admissionv1.Create,
+			change:    func(config *aksv1.AKSClusterConfig) { config.Spec.ResourceLocation = "" },
+			wantErr:   "field [resourceLocation] must be provided",
+		},
+		{
+			name:      "mutable field updated",
+			operation: admissionv1.Update,
+			change:    func(config *aksv1.AKSClusterConfig) { config.Spec.KubernetesVersion = to.StringPtr("1.24.0") },
+		},
+		{
+			name:      "immutable field updated",
+			operation: admissionv1.Update,
+			change:    func(config *aksv1.AKSClusterConfig) { config.Spec.ResourceGroup = "other" },
+			wantErr:   "field [resourceGroup] cannot be changed from [rg] to [other]",
+		},
+		{
+			name:      "invalid spec updated",
+			operation: admissionv1.Update,
+			change:    func(config *aksv1.AKSClusterConfig) { config.Spec.NodePools = nil },
+			wantErr:   "at least one NodePool with mode System is required",
+		},
+		{
+			name:      "annotations updated",
+			operation: admissionv1.Update,
+			// the stored spec is invalid, which does not block changes of the metadata
+			stored: func(config *aksv1.AKSClusterConfig) { config.Spec.ResourceLocation = "" },
+			change: func(config *aksv1.AKSClusterConfig) { config.Annotations = map[string]string{"team": "a"} },
+		},
+		{
+			name:      "config being deleted",
+			operation: admissionv1.Update,
+			change: func(config *aksv1.AKSClusterConfig) {
+				now := v15.Now()
+				config.DeletionTimestamp = &now
+				config.Spec.ResourceGroup = "other"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+			oldConfig := th.newWebhookTestConfig()
+			oldConfig.Status.Phase = aksConfigActivePhase
+			if tt.stored != nil {
+				tt.stored(oldConfig)
+			}
+			config := oldConfig.DeepCopy()
+			tt.change(config)
+
+			request := &admissionv1.AdmissionRequest{
+				UID:       types.UID("review"),
+				Operation: tt.operation,
+				Object:    rawConfig(t, config),
+			}
+			if tt.operation == admissionv1.Update {
+				request.OldObject = rawConfig(t, oldConfig)
+			}
+			response := sendAdmissionReview(t, th, admissionv1.AdmissionReview{Request: request})
+			if response.UID != "review" {
+				t.Errorf("expected the response to the review, got UID %q", response.UID)
+			}
+			if tt.wantErr == "" {
+				if !response.Allowed {
+					t.Errorf("expected the config to be allowed, got %+v", response.Result)
+				}
+				return
+			}
+			if response.Allowed || response.Result == nil || !strings.Contains(response.Result.Message, tt.wantErr) {
+				t.Errorf("expected the config to be rejected with %q, got %+v", tt.wantErr, response.Result)
+			}
+		})
+	}
+}
+
+func TestHandleAdmissionReviewInvalidRequest(t *testing.T) {
+	th := newTestHandler(t)
+	rw := httptest.NewRecorder()
+	th.handleAdmissionReview(rw, httptest.NewRequest(http.MethodPost, webhookPath, strings.NewReader("{}")))
+	if rw.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for a review without request, got %d", http.StatusBadRequest, rw.Code)
+	}
+}
+
+// rawConfig returns config encoded as the object of an AdmissionRequest
+func rawConfig(t *testing.T, config *aksv1.AKSClusterConfig) runtime.RawExtension {
+	t.Helper()
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return runtime.RawExtension{Raw: data}
+}
+
+// sendAdmissionReview sends review to the webhook of th and returns its response
+func sendAdmissionReview(t *testing.T, th *testHandler, review admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
+	t.Helper()
+	body, err := json.Marshal(review)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rw := httptest.NewRecorder()
+	th.handleAdmissionReview(rw, httptest.NewRequest(http.MethodPost, webhookPath, bytes.NewReader(body)))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rw.Code, rw.Body.String())
+	}
+	var result admissionv1.AdmissionReview
+	if err := json.Unmarshal(rw.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Response == nil {
+		t.Fatal("expected a response to the review")
+	}
+	return result.Response
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// availabilityZones are the zones a node pool can be deployed to in regions supporting availability zones
+var availabilityZones = map[string]bool{"1": true, "2": true, "3": true}
+
+// validateAvailabilityZoneNames rejects availability zones which do not exist
+func validateAvailabilityZoneNames(spec *aksv1.AKSClusterConfigSpec) []error {
+	var errs []error
+	for _, np := range spec.NodePools {
+		if np.AvailabilityZones == nil {
+			continue
+		}
+		for _, zone := range *np.AvailabilityZones {
+			if !availabilityZones[zone] {
+				errs = append(errs, fmt.Errorf("node pool [%s] for cluster [%s] config has invalid availability zone [%s], must be 1, 2 or 3",
+					to.String(np.Name), spec.ClusterName, zone))
+			}
+		}
+	}
+	return errs
+}
+
+// equalZones compares availability zones regardless of their order, no zones and an empty list are equal
+func equalZones(a, b *[]string) bool {
+	var zonesA, zonesB []string
+	if a != nil {
+		zonesA = append(zonesA, *a...)
+	}
+	if b != nil {
+		zonesB = append(zonesB, *b...)
+	}
+	if len(zonesA) != len(zonesB) {
+		return false
+	}
+	sort.Strings(zonesA)
+	sort.Strings(zonesB)
+	for i := range zonesA {
+		if zonesA[i] != zonesB[i] {
+			return false
+		}
+	}
+	return true
+}
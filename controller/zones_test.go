@@ -0,0 +1,209 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// resourceSkusPath is the ARM path of the resource SKUs of the test subscription
+var resourceSkusPath = armPath("/providers/Microsoft.Compute/skus")
+
+// vmSizeSku returns a resource SKU of a VM size offered in eastus in zones, with capabilities given as name and value
+// pairs
+func vmSizeSku(name string, zones []string, capabilities ...string) map[string]interface{} {
+	var skuCapabilities []map[string]string
+	for i := 0; i+1 < len(capabilities); i += 2 {
+		skuCapabilities = append(skuCapabilities, map[string]string{"name": capabilities[i], "value": capabilities[i+1]})
+	}
+	return map[string]interface{}{
+		"resourceType": "virtualMachines",
+		"name":         name,
+		"locationInfo": []map[string]interface{}{{"location": "eastus", "zones": zones}},
+		"capabilities": skuCapabilities,
+	}
+}
+
+// validateNodePoolVMSizes runs validateNodePoolVMSizes for a config of th with the node pool np, the resource SKUs
+// API answers with skus
+func (th *testHandler) validateNodePoolVMSizes(t *testing.T, np aksv1.AKSNodePool, skus ...map[string]interface{}) []error {
+	t.Helper()
+	th.azure.on(http.MethodGet, resourceSkusPath, http.StatusOK, map[string]interface{}{"value": skus})
+	config := th.newTestConfig()
+	config.Spec.NodePools = []aksv1.AKSNodePool{np}
+	credentials, err := th.getCredentials(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return validateNodePoolVMSizes(context.Background(), credentials, &config.Spec)
+}
+
+func TestValidateAvailabilityZoneNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		zones   *[]string
+		wantErr string
+	}{
+		{
+			name: "no zones",
+		},
+		{
+			name:  "valid zones",
+			zones: &[]string{"1", "2", "3"},
+		},
+		{
+			name:    "zone 4",
+			zones:   &[]string{"1", "4"},
+			wantErr: "node pool [pool] for cluster [cluster] config has invalid availability zone [4], must be 1, 2 or 3",
+		},
+		{
+			name:    "zone with region prefix",
+			zones:   &[]string{"eastus-1"},
+			wantErr: "invalid availability zone [eastus-1]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			np := validNodePool("pool")
+			np.AvailabilityZones = tt.zones
+			errs := validateAvailabilityZoneNames(&aksv1.AKSClusterConfigSpec{ClusterName: "cluster", NodePools: []aksv1.AKSNodePool{np}})
+			if tt.wantErr == "" {
+				if len(errs) != 0 {
+					t.Errorf("expected no errors, got %v", errs)
+				}
+				return
+			}
+			if len(errs) != 1 || !strings.Contains(errs[0].Error(), tt.wantErr) {
+				t.Errorf("expected one error containing %q, got %v", tt.wantErr, errs)
+			}
+		})
+	}
+}
+
+func TestPlanNodePoolsAvailabilityZones(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     *[]string
+		upstream *[]string
+		wantErr  bool
+	}{
+		{
+			name:     "unchanged zones",
+			spec:     &[]string{"1", "2"},
+			upstream: &[]string{"1", "2"},
+		},
+		{
+			name:     "reordered zones",
+			spec:     &[]string{"3", "1", "2"},
+			upstream: &[]string{"1", "2", "3"},
+		},
+		{
+			name:     "zones not set in spec",
+			upstream: &[]string{"1", "2"},
+		},
+		{
+			name:     "empty zones and no upstream zones",
+			spec:     &[]string{},
+			upstream: nil,
+		},
+		{
+			name:     "added zone",
+			spec:     &[]string{"1", "2", "3"},
+			upstream: &[]string{"1", "2"},
+			wantErr:  true,
+		},
+		{
+			name:     "removed zone",
+			spec:     &[]string{"1"},
+			upstream: &[]string{"1", "2"},
+			wantErr:  true,
+		},
+		{
+			name:    "zones added to a node pool without zones",
+			spec:    &[]string{"1"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, upstreamNodePools := planTestNodePools(
+				func(np *aksv1.AKSNodePool) { np.AvailabilityZones = tt.spec },
+				func(np *aksv1.AKSNodePool) { np.AvailabilityZones = tt.upstream },
+			)
+			plan := &clusterUpdatePlan{}
+			err := planNodePools(plan, spec, &aksv1.AKSClusterConfigSpec{}, upstreamNodePools, nil)
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				if len(plan.changes) != 0 {
+					t.Errorf("expected no changes, got %v", plan.changes)
+				}
+				return
+			}
+			var invalidSpec invalidSpecError
+			if !errors.As(err, &invalidSpec) || !strings.Contains(err.Error(), "availability zones cannot be changed on node pool [pool]") {
+				t.Errorf("expected an invalid spec error for changed availability zones, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateNodePoolVMSizesZones(t *testing.T) {
+	tests := []struct {
+		name    string
+		zones   []string
+		wantErr string
+	}{
+		{
+			name:  "zones offered for the VM size",
+			zones: []string{"1", "2"},
+		},
+		{
+			name:    "zone not offered for the VM size",
+			zones:   []string{"1", "3"},
+			wantErr: "node pool [pool] for cluster [cluster] config has availability zone [3], VM size [Standard_DS2_v2] is only available in zones [1, 2] of [eastus]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+			np := validNodePool("pool")
+			np.AvailabilityZones = &tt.zones
+			errs := th.validateNodePoolVMSizes(t, np, vmSizeSku("Standard_DS2_v2", []string{"1", "2"}))
+			if tt.wantErr == "" {
+				if len(errs) != 0 {
+					t.Errorf("expected no errors, got %v", errs)
+				}
+				return
+			}
+			if len(errs) != 1 || !strings.Contains(errs[0].Error(), tt.wantErr) {
+				t.Errorf("expected one error containing %q, got %v", tt.wantErr, errs)
+			}
+		})
+	}
+}
+
+func TestValidateNodePoolVMSizesSkipsInvalidZoneNames(t *testing.T) {
+	th := newTestHandler(t)
+	np := validNodePool("pool")
+	np.AvailabilityZones = &[]string{"4"}
+	config := th.newTestConfig()
+	config.Spec.NodePools = []aksv1.AKSNodePool{np}
+	credentials, err := th.getCredentials(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := validateNodePoolVMSizes(context.Background(), credentials, &config.Spec)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "invalid availability zone [4]") {
+		t.Errorf("expected an invalid availability zone error, got %v", errs)
+	}
+	if requests := th.azure.recorded(); len(requests) != 0 {
+		t.Errorf("expected the VM sizes not to be listed for invalid zone names, got %v", requests)
+	}
+}
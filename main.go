@@ -6,31 +6,158 @@ package main
 import (
 	"context"
 	"flag"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/rancher/aks-operator/controller"
+	aksapi "github.com/rancher/aks-operator/pkg/aks"
+	"github.com/rancher/aks-operator/pkg/debug"
 	aksv1 "github.com/rancher/aks-operator/pkg/generated/controllers/aks.cattle.io"
+	"github.com/rancher/aks-operator/pkg/leader"
+	"github.com/rancher/aks-operator/pkg/metrics"
 	core3 "github.com/rancher/wrangler/pkg/generated/controllers/core"
 	"github.com/rancher/wrangler/pkg/kubeconfig"
+	"github.com/rancher/wrangler/pkg/schemes"
 	"github.com/rancher/wrangler/pkg/signals"
 	"github.com/rancher/wrangler/pkg/start"
 	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// debugAddressEnv is the environment variable holding the bind address of the optional debug server
+	debugAddressEnv = "AKS_OPERATOR_DEBUG_ADDRESS"
+	// metricsAddressEnv is the environment variable holding the bind address of the optional metrics server
+	metricsAddressEnv = "AKS_OPERATOR_METRICS_ADDRESS"
+	// waitIntervalEnv and driftSyncPeriodEnv are the environment variables holding the defaults of the
+	// --wait-interval and --drift-sync-period flags
+	waitIntervalEnv    = "AKS_OPERATOR_WAIT_INTERVAL"
+	driftSyncPeriodEnv = "AKS_OPERATOR_DRIFT_SYNC_PERIOD"
+	// azureRequestTimeoutEnv and createTimeoutEnv are the environment variables holding the defaults of the
+	// --azure-request-timeout and --create-timeout flags
+	azureRequestTimeoutEnv = "AKS_OPERATOR_AZURE_REQUEST_TIMEOUT"
+	createTimeoutEnv       = "AKS_OPERATOR_CREATE_TIMEOUT"
+	// webhookAddressEnv, webhookCertFileEnv and webhookKeyFileEnv are the environment variables holding the defaults of
+	// the --webhook-address, --webhook-cert-file and --webhook-key-file flags
+	webhookAddressEnv  = "AKS_OPERATOR_WEBHOOK_ADDRESS"
+	webhookCertFileEnv = "AKS_OPERATOR_WEBHOOK_CERT_FILE"
+	webhookKeyFileEnv  = "AKS_OPERATOR_WEBHOOK_KEY_FILE"
+	// workersEnv, leaderElectEnv and leaderElectNamespaceEnv are the environment variables holding the defaults of the
+	// --workers, --leader-elect and --leader-elect-namespace flags
+	workersEnv              = "AKS_OPERATOR_WORKERS"
+	leaderElectEnv          = "AKS_OPERATOR_LEADER_ELECT"
+	leaderElectNamespaceEnv = "AKS_OPERATOR_LEADER_ELECT_NAMESPACE"
+	// leaderLeaseName is the name of the lease held by the replica of the operator which reconciles
+	leaderLeaseName = "aks-operator"
+	// defaultWorkers is the default number of configs reconciled at the same time
+	defaultWorkers = 2
+	// logLevelEnv is the environment variable holding the log level, "trace" logs every Azure request
+	logLevelEnv = "AKS_OPERATOR_LOG_LEVEL"
 )
 
 var (
-	masterURL      string
-	kubeconfigFile string
+	masterURL       string
+	kubeconfigFile  string
+	waitInterval    time.Duration
+	driftSyncPeriod time.Duration
+	requestTimeout  time.Duration
+	createTimeout   time.Duration
+	webhookAddress  string
+	webhookCertFile string
+	webhookKeyFile  string
+	workers         int
+	leaderElect     bool
+	leaderNamespace string
 )
 
 func init() {
 	flag.StringVar(&kubeconfigFile, "kubeconfig", "", "Path to a kubeconfig. Only required if out-of-cluster.")
 	flag.StringVar(&masterURL, "master", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
+	flag.DurationVar(&waitInterval, "wait-interval", durationEnv(waitIntervalEnv),
+		"Interval at which Azure is polled while clusters are created or updated. Zero keeps the default interval of each operation.")
+	flag.DurationVar(&driftSyncPeriod, "drift-sync-period", durationEnv(driftSyncPeriodEnv),
+		"Interval at which active clusters are compared against Azure. Zero only compares them on changes and informer resyncs.")
+	flag.DurationVar(&requestTimeout, "azure-request-timeout", durationEnvOr(azureRequestTimeoutEnv, aksapi.RequestTimeout),
+		"Timeout of each request sent to Azure. Zero disables it.")
+	flag.DurationVar(&createTimeout, "create-timeout", durationEnv(createTimeoutEnv),
+		"How long a cluster may take to be created before its creation is reported as failed. Zero uses the default of one hour.")
+	flag.StringVar(&webhookAddress, "webhook-address", os.Getenv(webhookAddressEnv),
+		"Bind address (e.g. :9443) of the validating webhook for AKSClusterConfigs. Empty disables the webhook.")
+	flag.StringVar(&webhookCertFile, "webhook-cert-file", os.Getenv(webhookCertFileEnv), "Path to the serving certificate of the validating webhook.")
+	flag.StringVar(&webhookKeyFile, "webhook-key-file", os.Getenv(webhookKeyFileEnv), "Path to the private key of the validating webhook.")
+	flag.IntVar(&workers, "workers", intEnvOr(workersEnv, defaultWorkers),
+		"Number of AKSClusterConfigs and secrets reconciled at the same time. A config is never reconciled by two workers at once.")
+	flag.BoolVar(&leaderElect, "leader-elect", os.Getenv(leaderElectEnv) == "true",
+		"Only reconcile while holding the leader lease, so that several replicas can run for high availability.")
+	flag.StringVar(&leaderNamespace, "leader-elect-namespace", stringEnvOr(leaderElectNamespaceEnv, "cattle-system"),
+		"Namespace of the leader lease.")
 	flag.Parse()
 }
 
+// stringEnvOr returns the value of the environment variable env, or def if it is not set
+func stringEnvOr(env, def string) string {
+	if value := os.Getenv(env); value != "" {
+		return value
+	}
+	return def
+}
+
+// intEnvOr returns the integer held by the environment variable env, or def if it is not set
+func intEnvOr(env string, def int) int {
+	value := os.Getenv(env)
+	if value == "" {
+		return def
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		logrus.Fatalf("Error parsing %s: %s", env, err.Error())
+	}
+	return i
+}
+
+// durationEnv returns the duration held by the environment variable env, or zero if it is not set
+func durationEnv(env string) time.Duration {
+	return durationEnvOr(env, 0)
+}
+
+// durationEnvOr returns the duration held by the environment variable env, or def if it is not set
+func durationEnvOr(env string, def time.Duration) time.Duration {
+	value := os.Getenv(env)
+	if value == "" {
+		return def
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		logrus.Fatalf("Error parsing %s: %s", env, err.Error())
+	}
+	return duration
+}
+
 func main() {
+	aksapi.RequestTimeout = requestTimeout
+	if workers < 1 {
+		logrus.Fatalf("--workers must be at least 1, got %d", workers)
+	}
+	if webhookAddress != "" && (webhookCertFile == "" || webhookKeyFile == "") {
+		logrus.Fatalf("The validating webhook requires --webhook-cert-file and --webhook-key-file")
+	}
+
 	// set up signals so we handle the first shutdown signal gracefully
 	ctx := signals.SetupSignalHandler(context.Background())
 
+	if level := os.Getenv(logLevelEnv); level != "" {
+		logLevel, err := logrus.ParseLevel(level)
+		if err != nil {
+			logrus.Fatalf("Error parsing log level: %s", err.Error())
+		}
+		logrus.SetLevel(logLevel)
+	}
+
 	// This will load the kubeconfig file in a style the same as kubectl
 	cfg, err := kubeconfig.GetNonInteractiveClientConfig(kubeconfigFile).ClientConfig()
 	if err != nil {
@@ -49,16 +176,74 @@ func main() {
 		logrus.Fatalf("Error building aks factory: %s", err.Error())
 	}
 
+	kubeClient, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		logrus.Fatalf("Error building kubernetes clientset: %s", err.Error())
+	}
+
+	// events are recorded against AKSClusterConfigs so they are visible with kubectl describe
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	recorder := eventBroadcaster.NewRecorder(schemes.All, corev1.EventSource{Component: "aks-controller"})
+
 	// The typical pattern is to build all your controller/clients then just pass to each handler
 	// the bare minimum of what they need.  This will eventually help with writing tests.  So
 	// don't pass in something like kubeClient, apps, or sample
+	requeues := controller.NewRequeues()
 	controller.Register(ctx,
 		core.Core().V1().Secret(),
-		aks.Aks().V1().AKSClusterConfig())
+		core.Core().V1().ConfigMap(),
+		aks.Aks().V1().AKSClusterConfig(),
+		recorder,
+		controller.Options{
+			WaitInterval:    waitInterval,
+			DriftSyncPeriod: driftSyncPeriod,
+			CreateTimeout:   createTimeout,
+			WebhookAddress:  webhookAddress,
+			WebhookCertFile: webhookCertFile,
+			WebhookKeyFile:  webhookKeyFile,
+			Requeues:        requeues,
+		})
+
+	// The debug server is off by default, it exposes pprof and the state of each AKSClusterConfig
+	debugAddress := os.Getenv(debugAddressEnv)
+	var debugMux *http.ServeMux
+	if debugAddress != "" {
+		debugMux = debug.NewServeMux(aks.Aks().V1().AKSClusterConfig().Cache(), requeues)
+	}
+
+	// The metrics server is off by default, it serves Prometheus metrics under /metrics. It shares the listener of
+	// the debug server if both have the same address.
+	if metricsAddress := os.Getenv(metricsAddressEnv); metricsAddress != "" {
+		metricsHandler := metrics.Handler(aks.Aks().V1().AKSClusterConfig().Cache())
+		if metricsAddress == debugAddress {
+			debugMux.Handle("/metrics", metricsHandler)
+		} else {
+			metricsMux := http.NewServeMux()
+			metricsMux.Handle("/metrics", metricsHandler)
+			debug.ListenAndServe(ctx, metricsAddress, metricsMux)
+		}
+	}
+
+	if debugMux != nil {
+		debug.ListenAndServe(ctx, debugAddress, debugMux)
+	}
 
-	// Start all the controllers
-	if err := start.All(ctx, 2, aks, core); err != nil {
-		logrus.Fatalf("Error starting: %s", err.Error())
+	// Start all the controllers, with leader election only the replica holding the lease starts them. The webhook,
+	// debug and metrics servers run on every replica.
+	startControllers := func(ctx context.Context) {
+		if err := start.All(ctx, workers, aks, core); err != nil {
+			logrus.Fatalf("Error starting: %s", err.Error())
+		}
+	}
+	if leaderElect {
+		go func() {
+			if err := leader.Run(ctx, leaderNamespace, leaderLeaseName, kubeClient, startControllers); err != nil {
+				logrus.Fatalf("Error starting leader election: %s", err.Error())
+			}
+		}()
+	} else {
+		startControllers(ctx)
 	}
 
 	<-ctx.Done()
@@ -0,0 +1,44 @@
+package aks
+
+import (
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+const (
+	aciConnectorAddon = "aciConnectorLinux"
+	// the config key of the ACI connector addon
+	aciConnectorSubnetNameKey = "SubnetName"
+)
+
+// aciConnectorAddonProfile converts the virtual node settings of the spec to an addon profile
+func aciConnectorAddonProfile(c *aksv1.AKSACIConnector) *containerservice.ManagedClusterAddonProfile {
+	if !c.Enabled {
+		return &containerservice.ManagedClusterAddonProfile{
+			Enabled: to.BoolPtr(false),
+		}
+	}
+	return &containerservice.ManagedClusterAddonProfile{
+		Enabled: to.BoolPtr(true),
+		Config: map[string]*string{
+			aciConnectorSubnetNameKey: c.SubnetName,
+		},
+	}
+}
+
+// UpstreamACIConnector converts the ACI connector addon of a cluster to the spec type
+func UpstreamACIConnector(cluster *containerservice.ManagedCluster) *aksv1.AKSACIConnector {
+	connector := &aksv1.AKSACIConnector{}
+	if cluster.ManagedClusterProperties == nil {
+		return connector
+	}
+	addon := cluster.AddonProfiles[aciConnectorAddon]
+	if addon == nil || !to.Bool(addon.Enabled) {
+		return connector
+	}
+
+	connector.Enabled = true
+	connector.SubnetName = addon.Config[aciConnectorSubnetNameKey]
+	return connector
+}
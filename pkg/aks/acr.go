@@ -0,0 +1,50 @@
+package aks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/authorization/mgmt/2020-10-01/authorization"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/google/uuid"
+)
+
+// acrPullRoleDefinitionID is the name of the built-in AcrPull role definition
+const acrPullRoleDefinitionID = "7f951dff-4ed6-4b2d-b2e1-ad9d0a5b9e2a"
+
+// acrPullRoleAssignmentName returns the name of the AcrPull role assignment of a principal on a container registry.
+// The name is derived from both, so attaching the same registry again targets the same role assignment.
+func acrPullRoleAssignmentName(registryID, principalID string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(strings.ToLower(registryID)+"/"+strings.ToLower(principalID))).String()
+}
+
+// AttachACR grants the AcrPull role on a container registry to a principal, a role assignment which already exists
+// is not an error
+func AttachACR(ctx context.Context, client *authorization.RoleAssignmentsClient, registryID, principalID string) error {
+	// the role definition is scoped to the subscription of the registry, the third segment of its resource ID
+	parts := strings.Split(registryID, "/")
+	if len(parts) < 3 {
+		return fmt.Errorf("invalid container registry ID [%s]", registryID)
+	}
+	_, err := client.Create(ctx, registryID, acrPullRoleAssignmentName(registryID, principalID), authorization.RoleAssignmentCreateParameters{
+		Properties: &authorization.RoleAssignmentProperties{
+			RoleDefinitionID: to.StringPtr(fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Authorization/roleDefinitions/%s", parts[2], acrPullRoleDefinitionID)),
+			PrincipalID:      to.StringPtr(principalID),
+		},
+	})
+	if err != nil && !IsRoleAssignmentExists(err) {
+		return err
+	}
+	return nil
+}
+
+// DetachACR revokes the AcrPull role on a container registry granted to a principal by AttachACR, a role assignment or
+// registry which no longer exists is not an error
+func DetachACR(ctx context.Context, client *authorization.RoleAssignmentsClient, registryID, principalID string) error {
+	_, err := client.Delete(ctx, registryID, acrPullRoleAssignmentName(registryID, principalID))
+	if err != nil && !IsNotFound(err) {
+		return err
+	}
+	return nil
+}
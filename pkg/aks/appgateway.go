@@ -0,0 +1,71 @@
+package aks
+
+import (
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+const (
+	ingressApplicationGatewayAddon = "ingressApplicationGateway"
+	// the config keys of the ingress application gateway addon
+	applicationGatewayIDKey          = "applicationGatewayId"
+	applicationGatewayNameKey        = "applicationGatewayName"
+	applicationGatewaySubnetCIDRKey  = "subnetCIDR"
+	effectiveApplicationGatewayIDKey = "effectiveApplicationGatewayId"
+)
+
+// ingressApplicationGatewayAddonProfile converts the ingress application gateway settings of the spec to an addon
+// profile
+func ingressApplicationGatewayAddonProfile(g *aksv1.AKSIngressApplicationGateway) *containerservice.ManagedClusterAddonProfile {
+	if !g.Enabled {
+		return &containerservice.ManagedClusterAddonProfile{
+			Enabled: to.BoolPtr(false),
+		}
+	}
+	config := map[string]*string{}
+	if g.GatewayID != nil {
+		config[applicationGatewayIDKey] = g.GatewayID
+	}
+	if g.GatewayName != nil {
+		config[applicationGatewayNameKey] = g.GatewayName
+	}
+	if g.SubnetCIDR != nil {
+		config[applicationGatewaySubnetCIDRKey] = g.SubnetCIDR
+	}
+	return &containerservice.ManagedClusterAddonProfile{
+		Enabled: to.BoolPtr(true),
+		Config:  config,
+	}
+}
+
+// UpstreamIngressApplicationGateway converts the ingress application gateway addon of a cluster to the spec type
+func UpstreamIngressApplicationGateway(cluster *containerservice.ManagedCluster) *aksv1.AKSIngressApplicationGateway {
+	gateway := &aksv1.AKSIngressApplicationGateway{}
+	if cluster.ManagedClusterProperties == nil {
+		return gateway
+	}
+	addon := cluster.AddonProfiles[ingressApplicationGatewayAddon]
+	if addon == nil || !to.Bool(addon.Enabled) {
+		return gateway
+	}
+
+	gateway.Enabled = true
+	gateway.GatewayID = addon.Config[applicationGatewayIDKey]
+	gateway.GatewayName = addon.Config[applicationGatewayNameKey]
+	gateway.SubnetCIDR = addon.Config[applicationGatewaySubnetCIDRKey]
+	return gateway
+}
+
+// EffectiveApplicationGatewayID returns the resource ID of the application gateway used by the ingress application
+// gateway addon, which is either the existing gateway or the one created by Azure
+func EffectiveApplicationGatewayID(cluster *containerservice.ManagedCluster) string {
+	if cluster.ManagedClusterProperties == nil {
+		return ""
+	}
+	addon := cluster.AddonProfiles[ingressApplicationGatewayAddon]
+	if addon == nil || !to.Bool(addon.Enabled) {
+		return ""
+	}
+	return to.String(addon.Config[effectiveApplicationGatewayIDKey])
+}
@@ -0,0 +1,128 @@
+package aks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/Azure/go-autorest/autorest/azure"
+)
+
+// the ways the operator authenticates to Azure, selected by the authMode field of the credential secret
+const (
+	// AuthModeClientSecret authenticates as a service principal with a client secret, it is the default
+	AuthModeClientSecret = "clientSecret"
+	// AuthModeManagedIdentity authenticates as the managed identity of the VM or node the operator runs on, the
+	// clientId of the credential selects a user-assigned identity
+	AuthModeManagedIdentity = "managedIdentity"
+	// AuthModeWorkloadIdentity authenticates as an application federated with the service account of the operator
+	AuthModeWorkloadIdentity = "workloadIdentity"
+)
+
+// the environment variables set by the AKS workload identity webhook
+const (
+	federatedTokenFileEnv = "AZURE_FEDERATED_TOKEN_FILE"
+	clientIDEnv           = "AZURE_CLIENT_ID"
+	tenantIDEnv           = "AZURE_TENANT_ID"
+)
+
+// servicePrincipalToken returns a token of the credentials for resource, built for their auth mode
+func servicePrincipalToken(cred *Credentials, resource string) (*adal.ServicePrincipalToken, error) {
+	authBaseURL := azure.PublicCloud.ActiveDirectoryEndpoint
+	if cred.AuthBaseURL != nil {
+		authBaseURL = *cred.AuthBaseURL
+	}
+
+	switch cred.AuthMode {
+	case AuthModeManagedIdentity:
+		msiEndpoint, err := adal.GetMSIEndpoint()
+		if err != nil {
+			return nil, err
+		}
+		if cred.ClientID != "" {
+			return adal.NewServicePrincipalTokenFromMSIWithUserAssignedID(msiEndpoint, resource, cred.ClientID)
+		}
+		return adal.NewServicePrincipalTokenFromMSI(msiEndpoint, resource)
+	case AuthModeWorkloadIdentity:
+		oauthConfig, err := adal.NewOAuthConfig(authBaseURL, cred.TenantID)
+		if err != nil {
+			return nil, err
+		}
+		return adal.NewServicePrincipalTokenWithSecret(*oauthConfig, cred.ClientID, resource, &federatedTokenSecret{path: cred.FederatedTokenFile})
+	case AuthModeClientSecret, "":
+		oauthConfig, err := adal.NewOAuthConfig(authBaseURL, cred.TenantID)
+		if err != nil {
+			return nil, err
+		}
+		return adal.NewServicePrincipalToken(*oauthConfig, cred.ClientID, cred.ClientSecret, resource)
+	default:
+		return nil, fmt.Errorf("unknown auth mode [%s]", cred.AuthMode)
+	}
+}
+
+// federatedTokenSecret authenticates a service principal with the service account token projected by the AKS
+// workload identity webhook. The token file is read on every refresh because the kubelet rotates it.
+type federatedTokenSecret struct {
+	path string
+}
+
+// SetAuthenticationValues implements adal.ServicePrincipalSecret by sending the service account token as client
+// assertion
+func (s *federatedTokenSecret) SetAuthenticationValues(_ *adal.ServicePrincipalToken, v *url.Values) error {
+	token, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read federated token file [%s]: %w", s.path, err)
+	}
+	v.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+	v.Set("client_assertion", strings.TrimSpace(string(token)))
+	return nil
+}
+
+// setAuthMode sets the auth mode of the credentials and checks that they have the fields the mode requires. Workload
+// identities take the fields the credential secret does not set from the environment of the operator.
+func setAuthMode(cred *Credentials, authMode string) error {
+	const cannotBeNilError = "field [azurecredentialConfig-%s] must be provided in cloud credential with authMode %s"
+	if authMode == "" {
+		authMode = AuthModeClientSecret
+	}
+	cred.AuthMode = authMode
+
+	switch authMode {
+	case AuthModeClientSecret:
+		if cred.TenantID == "" {
+			return fmt.Errorf(cannotBeNilError, "tenantId", authMode)
+		}
+		if cred.ClientID == "" {
+			return fmt.Errorf(cannotBeNilError, "clientId", authMode)
+		}
+		if cred.ClientSecret == "" {
+			return fmt.Errorf(cannotBeNilError, "clientSecret", authMode)
+		}
+	case AuthModeManagedIdentity:
+		// the clientId is optional, it selects a user-assigned identity
+	case AuthModeWorkloadIdentity:
+		if cred.TenantID == "" {
+			cred.TenantID = os.Getenv(tenantIDEnv)
+		}
+		if cred.ClientID == "" {
+			cred.ClientID = os.Getenv(clientIDEnv)
+		}
+		cred.FederatedTokenFile = os.Getenv(federatedTokenFileEnv)
+		if cred.TenantID == "" {
+			return fmt.Errorf(cannotBeNilError+" or set in %s", "tenantId", authMode, tenantIDEnv)
+		}
+		if cred.ClientID == "" {
+			return fmt.Errorf(cannotBeNilError+" or set in %s", "clientId", authMode, clientIDEnv)
+		}
+		if cred.FederatedTokenFile == "" {
+			return fmt.Errorf("authMode %s requires %s, the operator service account must use workload identity", authMode, federatedTokenFileEnv)
+		}
+	default:
+		return fmt.Errorf("field [azurecredentialConfig-authMode] in cloud credential has invalid value [%s], must be %s, %s or %s",
+			authMode, AuthModeClientSecret, AuthModeManagedIdentity, AuthModeWorkloadIdentity)
+	}
+	return nil
+}
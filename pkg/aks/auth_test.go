@@ -0,0 +1,270 @@
+package aks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+// setEnv sets the environment variables for the duration of the test, an empty value unsets the variable
+func setEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for key, value := range env {
+		previous, ok := os.LookupEnv(key)
+		if value == "" {
+			os.Unsetenv(key)
+		} else {
+			os.Setenv(key, value)
+		}
+		key := key
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(key, previous)
+			} else {
+				os.Unsetenv(key)
+			}
+		})
+	}
+}
+
+// tokenRequest is a token request received by a tokenServer
+type tokenRequest struct {
+	method string
+	path   string
+	header http.Header
+	// values are the query and form values of the request
+	values map[string]string
+}
+
+// tokenServer is an Azure AD and managed identity endpoint issuing tokens and recording the requests for them
+type tokenServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []tokenRequest
+}
+
+func newTokenServer(t *testing.T) *tokenServer {
+	s := &tokenServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		values := map[string]string{}
+		for key := range req.Form {
+			values[key] = req.Form.Get(key)
+		}
+		s.mu.Lock()
+		s.requests = append(s.requests, tokenRequest{method: req.Method, path: req.URL.Path, header: req.Header, values: values})
+		s.mu.Unlock()
+
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(map[string]string{
+			"access_token": "token",
+			"token_type":   "Bearer",
+			"expires_in":   "3600",
+			"expires_on":   strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10),
+			"resource":     "https://management.azure.com/",
+		})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *tokenServer) recorded() []tokenRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]tokenRequest(nil), s.requests...)
+}
+
+func TestSetAuthMode(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	tests := []struct {
+		name     string
+		authMode string
+		cred     Credentials
+		env      map[string]string
+		want     Credentials
+		wantErr  string
+	}{
+		{
+			name: "client secret by default",
+			cred: Credentials{TenantID: "tenant", ClientID: "client", ClientSecret: "secret"},
+			want: Credentials{TenantID: "tenant", ClientID: "client", ClientSecret: "secret", AuthMode: AuthModeClientSecret},
+		},
+		{
+			name:     "client secret without secret",
+			authMode: AuthModeClientSecret,
+			cred:     Credentials{TenantID: "tenant", ClientID: "client"},
+			wantErr:  "field [azurecredentialConfig-clientSecret] must be provided in cloud credential with authMode clientSecret",
+		},
+		{
+			name:    "client secret without tenant",
+			cred:    Credentials{ClientID: "client", ClientSecret: "secret"},
+			wantErr: "field [azurecredentialConfig-tenantId] must be provided",
+		},
+		{
+			name:     "system-assigned managed identity",
+			authMode: AuthModeManagedIdentity,
+			want:     Credentials{AuthMode: AuthModeManagedIdentity},
+		},
+		{
+			name:     "user-assigned managed identity",
+			authMode: AuthModeManagedIdentity,
+			cred:     Credentials{ClientID: "identity"},
+			want:     Credentials{ClientID: "identity", AuthMode: AuthModeManagedIdentity},
+		},
+		{
+			name:     "workload identity from the environment",
+			authMode: AuthModeWorkloadIdentity,
+			env:      map[string]string{tenantIDEnv: "env-tenant", clientIDEnv: "env-client", federatedTokenFileEnv: tokenFile},
+			want:     Credentials{TenantID: "env-tenant", ClientID: "env-client", AuthMode: AuthModeWorkloadIdentity, FederatedTokenFile: tokenFile},
+		},
+		{
+			name:     "workload identity of the credential",
+			authMode: AuthModeWorkloadIdentity,
+			cred:     Credentials{TenantID: "tenant", ClientID: "client"},
+			env:      map[string]string{tenantIDEnv: "env-tenant", clientIDEnv: "env-client", federatedTokenFileEnv: tokenFile},
+			want:     Credentials{TenantID: "tenant", ClientID: "client", AuthMode: AuthModeWorkloadIdentity, FederatedTokenFile: tokenFile},
+		},
+		{
+			name:     "workload identity without token file",
+			authMode: AuthModeWorkloadIdentity,
+			cred:     Credentials{TenantID: "tenant", ClientID: "client"},
+			wantErr:  "authMode workloadIdentity requires AZURE_FEDERATED_TOKEN_FILE",
+		},
+		{
+			name:     "workload identity without client",
+			authMode: AuthModeWorkloadIdentity,
+			cred:     Credentials{TenantID: "tenant"},
+			env:      map[string]string{federatedTokenFileEnv: tokenFile},
+			wantErr:  "field [azurecredentialConfig-clientId] must be provided in cloud credential with authMode workloadIdentity or set in AZURE_CLIENT_ID",
+		},
+		{
+			name:     "unknown auth mode",
+			authMode: "certificate",
+			wantErr:  "field [azurecredentialConfig-authMode] in cloud credential has invalid value [certificate]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{tenantIDEnv: "", clientIDEnv: "", federatedTokenFileEnv: ""}
+			for key, value := range tt.env {
+				env[key] = value
+			}
+			setEnv(t, env)
+
+			cred := tt.cred
+			err := setAuthMode(&cred, tt.authMode)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(cred, tt.want) {
+				t.Errorf("expected credentials %+v, got %+v", tt.want, cred)
+			}
+		})
+	}
+}
+
+func TestServicePrincipalTokenAuthModes(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("service-account-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		cred Credentials
+		// msi is true if the managed identity endpoint of the operator is the token server
+		msi        bool
+		wantMethod string
+		wantPath   string
+		wantValues map[string]string
+	}{
+		{
+			name:       "client secret",
+			cred:       Credentials{AuthMode: AuthModeClientSecret, TenantID: "tenant", ClientID: "client", ClientSecret: "secret"},
+			wantMethod: http.MethodPost,
+			wantPath:   "/tenant/oauth2/token",
+			wantValues: map[string]string{"grant_type": "client_credentials", "client_id": "client", "client_secret": "secret"},
+		},
+		{
+			name:       "workload identity",
+			cred:       Credentials{AuthMode: AuthModeWorkloadIdentity, TenantID: "tenant", ClientID: "client", FederatedTokenFile: tokenFile},
+			wantMethod: http.MethodPost,
+			wantPath:   "/tenant/oauth2/token",
+			wantValues: map[string]string{
+				"client_id":             "client",
+				"client_assertion":      "service-account-token",
+				"client_assertion_type": "urn:ietf:params:oauth:client-assertion-type:jwt-bearer",
+			},
+		},
+		{
+			name:       "user-assigned managed identity",
+			cred:       Credentials{AuthMode: AuthModeManagedIdentity, ClientID: "identity"},
+			msi:        true,
+			wantMethod: http.MethodGet,
+			wantPath:   "/msi/token",
+			wantValues: map[string]string{"clientid": "identity", "resource": "https://management.azure.com/"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTokenServer(t)
+			msiEndpoint, msiSecret := "", ""
+			if tt.msi {
+				msiEndpoint, msiSecret = server.URL+"/msi/token", "msi-secret"
+			}
+			setEnv(t, map[string]string{"MSI_ENDPOINT": msiEndpoint, "MSI_SECRET": msiSecret})
+
+			cred := tt.cred
+			cred.AuthBaseURL = to.StringPtr(server.URL)
+			token, err := servicePrincipalToken(&cred, "https://management.azure.com/")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := token.Refresh(); err != nil {
+				t.Fatalf("unexpected error refreshing the token: %v", err)
+			}
+
+			requests := server.recorded()
+			if len(requests) != 1 {
+				t.Fatalf("expected one token request, got %d", len(requests))
+			}
+			request := requests[0]
+			if request.method != tt.wantMethod || request.path != tt.wantPath {
+				t.Errorf("expected %s %s, got %s %s", tt.wantMethod, tt.wantPath, request.method, request.path)
+			}
+			for key, value := range tt.wantValues {
+				if request.values[key] != value {
+					t.Errorf("expected %s %q, got %q", key, value, request.values[key])
+				}
+			}
+			if _, ok := request.values["client_secret"]; ok && cred.AuthMode != AuthModeClientSecret {
+				t.Errorf("expected no client secret to be sent in auth mode %s", cred.AuthMode)
+			}
+			if tt.msi && request.header.Get("Secret") != msiSecret {
+				t.Errorf("expected the managed identity secret header, got %q", request.header.Get("Secret"))
+			}
+		})
+	}
+}
+
+func TestServicePrincipalTokenUnknownAuthMode(t *testing.T) {
+	if _, err := servicePrincipalToken(&Credentials{AuthMode: "certificate"}, "https://management.azure.com/"); err == nil {
+		t.Error("expected an error for an unknown auth mode")
+	}
+}
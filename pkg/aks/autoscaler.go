@@ -0,0 +1,59 @@
+package aks
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+)
+
+// AutoScalerProfileSettings are the settings of the cluster autoscaler profile supported by Azure
+var AutoScalerProfileSettings = []string{
+	"balance-similar-node-groups",
+	"expander",
+	"max-empty-bulk-delete",
+	"max-graceful-termination-sec",
+	"max-node-provision-time",
+	"max-total-unready-percentage",
+	"new-pod-scale-up-delay",
+	"ok-total-unready-count",
+	"scale-down-delay-after-add",
+	"scale-down-delay-after-delete",
+	"scale-down-delay-after-failure",
+	"scale-down-unneeded-time",
+	"scale-down-unready-time",
+	"scale-down-utilization-threshold",
+	"scan-interval",
+	"skip-nodes-with-local-storage",
+	"skip-nodes-with-system-pods",
+}
+
+// autoScalerProfile converts the autoscaler settings of the spec to the Azure type, whose JSON keys are the setting
+// names. Settings which are not set take their Azure defaults.
+func autoScalerProfile(settings map[string]string) (*containerservice.ManagedClusterPropertiesAutoScalerProfile, error) {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	profile := &containerservice.ManagedClusterPropertiesAutoScalerProfile{}
+	if err := json.Unmarshal(data, profile); err != nil {
+		return nil, fmt.Errorf("invalid autoscaler profile: %w", err)
+	}
+	return profile, nil
+}
+
+// UpstreamAutoScalerProfile converts the autoscaler profile of a cluster to the settings of the spec
+func UpstreamAutoScalerProfile(cluster *containerservice.ManagedCluster) map[string]string {
+	if cluster.ManagedClusterProperties == nil || cluster.AutoScalerProfile == nil {
+		return nil
+	}
+	data, err := json.Marshal(cluster.AutoScalerProfile)
+	if err != nil {
+		return nil
+	}
+	settings := map[string]string{}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil
+	}
+	return settings
+}
@@ -112,17 +112,27 @@ var regionToOmsRegionMap = map[string]string{
 	"chinanorth2": "chinaeast2",
 }
 
+// LogAnalyticsWorkspace is the Log Analytics workspace the monitoring addon of a cluster is wired to
+type LogAnalyticsWorkspace struct {
+	// ID is the full resource ID of the workspace
+	ID string
+	// Created is true if the workspace was created by the operator
+	Created bool
+}
+
+// CheckLogAnalyticsWorkspaceForMonitoring returns the Log Analytics workspace used for monitoring, creating it if
+// it does not exist yet
 func CheckLogAnalyticsWorkspaceForMonitoring(ctx context.Context, client *operationalinsights.WorkspacesClient,
-	location string, group string, wsg string, wsn string) (workspaceID string, err error) {
+	location string, group string, wsg string, wsn string) (*LogAnalyticsWorkspace, error) {
 
 	workspaceRegion, ok := regionToOmsRegionMap[location]
 	if !ok {
-		return "", fmt.Errorf("region %s not supported for Log Analytics workspace", location)
+		return nil, fmt.Errorf("region %s not supported for Log Analytics workspace", location)
 	}
 
 	workspaceRegionCode, ok := locationToOmsRegionCodeMap[workspaceRegion]
 	if !ok {
-		return "", fmt.Errorf("region %s not supported for Log Analytics workspace", workspaceRegion)
+		return nil, fmt.Errorf("region %s not supported for Log Analytics workspace", workspaceRegion)
 	}
 
 	workspaceResourceGroup := wsg
@@ -135,13 +145,10 @@ func CheckLogAnalyticsWorkspaceForMonitoring(ctx context.Context, client *operat
 		workspaceName = fmt.Sprintf("%s-%s", group, workspaceRegionCode)
 	}
 
-	// workspaceName string length can be only 63
-	if len(workspaceName) > workspaceLength {
-		workspaceName = generateUniqueLogWorkspace(workspaceName)
-	}
+	workspaceName = generateUniqueLogWorkspace(workspaceName)
 
 	if gotRet, gotErr := client.Get(ctx, workspaceResourceGroup, workspaceName); gotErr == nil {
-		return *gotRet.ID, nil
+		return &LogAnalyticsWorkspace{ID: *gotRet.ID}, nil
 	}
 
 	logrus.Infof("Create Azure Log Analytics Workspace %q on Resource Group %q", workspaceName, workspaceResourceGroup)
@@ -155,23 +162,29 @@ func CheckLogAnalyticsWorkspaceForMonitoring(ctx context.Context, client *operat
 		},
 	})
 	if asyncErr != nil {
-		return "", asyncErr
+		return nil, asyncErr
 	}
 
-	err = wait.Poll(5*time.Second, 30*time.Second, func() (bool, error) {
+	workspace := &LogAnalyticsWorkspace{Created: true}
+	err := wait.Poll(5*time.Second, 30*time.Second, func() (bool, error) {
 		ret, err := asyncRet.Result(*client)
 		if err != nil {
 			return false, err
 		}
 
-		workspaceID = *ret.ID
+		workspace.ID = *ret.ID
 		return true, nil
 	})
-	return
+	if err != nil {
+		return nil, err
+	}
+	return workspace, nil
 }
 
+// generateUniqueLogWorkspace returns workspaceName unchanged if it fits in the 63 characters allowed for a
+// workspace name. Longer names are truncated and suffixed with a hash of the full name to keep them unique.
 func generateUniqueLogWorkspace(workspaceName string) string {
-	if len(workspaceName) < workspaceNameLength {
+	if len(workspaceName) <= workspaceLength {
 		return workspaceName
 	}
 	s := workspaceName[0:workspaceNameLength]
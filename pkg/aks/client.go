@@ -3,11 +3,12 @@ package aks
 import (
 	"fmt"
 
-	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2020-11-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/authorization/mgmt/2020-10-01/authorization"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-01/compute"
 	"github.com/Azure/azure-sdk-for-go/services/operationalinsights/mgmt/2020-08-01/operationalinsights"
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
 	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-10-01/resources"
 	"github.com/Azure/go-autorest/autorest"
-	"github.com/Azure/go-autorest/autorest/adal"
 	"github.com/Azure/go-autorest/autorest/azure"
 	"github.com/Azure/go-autorest/autorest/to"
 	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
@@ -22,52 +23,101 @@ type Credentials struct {
 	TenantID       string
 	ClientID       string
 	ClientSecret   string
+	// AuthMode is the way the operator authenticates: clientSecret, managedIdentity or workloadIdentity
+	AuthMode string
+	// FederatedTokenFile is the service account token exchanged for Azure tokens in workloadIdentity mode
+	FederatedTokenFile string
+	// refresh reads the credentials again from their secret
+	refresh func() (*Credentials, error)
 }
 
 func NewResourceGroupClient(cred *Credentials) (*resources.GroupsClient, error) {
-	authorizer, err := NewClientAuthorizer(cred)
+	authorizer, err := newRefreshingAuthorizer(cred)
 	if err != nil {
 		return nil, err
 	}
 
 	client := resources.NewGroupsClientWithBaseURI(to.String(cred.BaseURL), cred.SubscriptionID)
 	client.Authorizer = authorizer
+	client.Sender = newSender(authorizer)
+
+	return &client, nil
+}
+
+func NewResourcesClient(cred *Credentials) (*resources.Client, error) {
+	authorizer, err := newRefreshingAuthorizer(cred)
+	if err != nil {
+		return nil, err
+	}
+
+	client := resources.NewClientWithBaseURI(to.String(cred.BaseURL), cred.SubscriptionID)
+	client.Authorizer = authorizer
+	client.Sender = newSender(authorizer)
 
 	return &client, nil
 }
 
 func NewClusterClient(cred *Credentials) (*containerservice.ManagedClustersClient, error) {
-	authorizer, err := NewClientAuthorizer(cred)
+	authorizer, err := newRefreshingAuthorizer(cred)
 	if err != nil {
 		return nil, err
 	}
 
 	client := containerservice.NewManagedClustersClientWithBaseURI(to.String(cred.BaseURL), cred.SubscriptionID)
 	client.Authorizer = authorizer
+	client.Sender = newSender(authorizer)
 
 	return &client, nil
 }
 
 func NewAgentPoolClient(cred *Credentials) (*containerservice.AgentPoolsClient, error) {
-	authorizer, err := NewClientAuthorizer(cred)
+	authorizer, err := newRefreshingAuthorizer(cred)
 	if err != nil {
 		return nil, err
 	}
 
 	agentProfile := containerservice.NewAgentPoolsClientWithBaseURI(to.String(cred.BaseURL), cred.SubscriptionID)
 	agentProfile.Authorizer = authorizer
+	agentProfile.Sender = newSender(authorizer)
 
 	return &agentProfile, nil
 }
 
 func NewOperationInsightsWorkspaceClient(cred *Credentials) (*operationalinsights.WorkspacesClient, error) {
-	authorizer, err := NewClientAuthorizer(cred)
+	authorizer, err := newRefreshingAuthorizer(cred)
 	if err != nil {
 		return nil, err
 	}
 
 	client := operationalinsights.NewWorkspacesClientWithBaseURI(to.String(cred.BaseURL), cred.SubscriptionID)
 	client.Authorizer = authorizer
+	client.Sender = newSender(authorizer)
+
+	return &client, nil
+}
+
+func NewResourceSkusClient(cred *Credentials) (*compute.ResourceSkusClient, error) {
+	authorizer, err := newRefreshingAuthorizer(cred)
+	if err != nil {
+		return nil, err
+	}
+
+	client := compute.NewResourceSkusClientWithBaseURI(to.String(cred.BaseURL), cred.SubscriptionID)
+	client.Authorizer = authorizer
+	client.Sender = newSender(authorizer)
+
+	return &client, nil
+}
+
+func NewRoleAssignmentsClient(cred *Credentials) (*authorization.RoleAssignmentsClient, error) {
+	authorizer, err := newRefreshingAuthorizer(cred)
+	if err != nil {
+		return nil, err
+	}
+
+	client := authorization.NewRoleAssignmentsClientWithBaseURI(to.String(cred.BaseURL), cred.SubscriptionID)
+	client.Authorizer = authorizer
+	client.Sender = newSender(authorizer)
 
 	return &client, nil
 }
@@ -81,14 +131,9 @@ func NewClientAuthorizer(cred *Credentials) (autorest.Authorizer, error) {
 		cred.BaseURL = to.StringPtr(azure.PublicCloud.ResourceManagerEndpoint)
 	}
 
-	oauthConfig, err := adal.NewOAuthConfig(to.String(cred.AuthBaseURL), cred.TenantID)
+	spToken, err := servicePrincipalToken(cred, to.String(cred.BaseURL))
 	if err != nil {
-		return nil, err
-	}
-
-	spToken, err := adal.NewServicePrincipalToken(*oauthConfig, cred.ClientID, cred.ClientSecret, to.String(cred.BaseURL))
-	if err != nil {
-		return nil, fmt.Errorf("couldn't authenticate to Azure cloud with error: %v", err)
+		return nil, fmt.Errorf("couldn't authenticate to Azure cloud with error: %w", err)
 	}
 
 	return autorest.NewBearerAuthorizer(spToken), nil
@@ -112,26 +157,36 @@ func GetSecrets(secretsCache wranglerv1.SecretCache, spec *aksv1.AKSClusterConfi
 	clientIDBytes := secret.Data["azurecredentialConfig-clientId"]
 	clientSecretBytes := secret.Data["azurecredentialConfig-clientSecret"]
 
-	cannotBeNilError := "field [azurecredentialConfig-%s] must be provided in cloud credential"
-	if tenantIDBytes == nil {
-		return nil, fmt.Errorf(cannotBeNilError, "tenantId")
-	}
 	if subscriptionIDBytes == nil {
-		return nil, fmt.Errorf(cannotBeNilError, "subscriptionId")
-	}
-	if clientIDBytes == nil {
-		return nil, fmt.Errorf(cannotBeNilError, "clientId")
-	}
-	if clientSecretBytes == nil {
-		return nil, fmt.Errorf(cannotBeNilError, "clientSecret")
+		return nil, fmt.Errorf("field [azurecredentialConfig-%s] must be provided in cloud credential", "subscriptionId")
 	}
 
 	cred.TenantID = string(tenantIDBytes)
 	cred.SubscriptionID = string(subscriptionIDBytes)
 	cred.ClientID = string(clientIDBytes)
 	cred.ClientSecret = string(clientSecretBytes)
+	// the tenantId, clientId and clientSecret required depend on the auth mode
+	if err := setAuthMode(&cred, string(secret.Data["azurecredentialConfig-authMode"])); err != nil {
+		return nil, err
+	}
 	cred.AuthBaseURL = spec.AuthBaseURL
 	cred.BaseURL = spec.BaseURL
+	// the endpoints of the spec take precedence over the endpoints of the Azure environment of the credential
+	if environmentBytes := secret.Data["azurecredentialConfig-environment"]; len(environmentBytes) > 0 {
+		env, err := azure.EnvironmentFromName(string(environmentBytes))
+		if err != nil {
+			return nil, fmt.Errorf("field [azurecredentialConfig-environment] in cloud credential is invalid: %w", err)
+		}
+		if cred.AuthBaseURL == nil {
+			cred.AuthBaseURL = to.StringPtr(env.ActiveDirectoryEndpoint)
+		}
+		if cred.BaseURL == nil {
+			cred.BaseURL = to.StringPtr(env.ResourceManagerEndpoint)
+		}
+	}
+	cred.refresh = func() (*Credentials, error) {
+		return GetSecrets(secretsCache, spec)
+	}
 
 	return &cred, nil
 }
@@ -0,0 +1,164 @@
+package aks
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	wranglerv1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// fakeSecretCache holds the secrets of a test
+type fakeSecretCache struct {
+	wranglerv1.SecretCache
+	secrets map[string]*v1.Secret
+}
+
+func (c *fakeSecretCache) Get(namespace, name string) (*v1.Secret, error) {
+	secret, ok := c.secrets[namespace+"/"+name]
+	if !ok {
+		return nil, errors.NewNotFound(schema.GroupResource{Resource: "secrets"}, name)
+	}
+	return secret, nil
+}
+
+// newCredentialSecretCache returns a secret cache holding the client secret credential cattle-global-data:cc-test
+// with the given Azure environment
+func newCredentialSecretCache(environment string) *fakeSecretCache {
+	data := map[string][]byte{
+		"azurecredentialConfig-subscriptionId": []byte("subscription"),
+		"azurecredentialConfig-tenantId":       []byte("tenant"),
+		"azurecredentialConfig-clientId":       []byte("client"),
+		"azurecredentialConfig-clientSecret":   []byte("secret"),
+	}
+	if environment != "" {
+		data["azurecredentialConfig-environment"] = []byte(environment)
+	}
+	return &fakeSecretCache{secrets: map[string]*v1.Secret{
+		"cattle-global-data/cc-test": {
+			ObjectMeta: metav1.ObjectMeta{Namespace: "cattle-global-data", Name: "cc-test"},
+			Data:       data,
+		},
+	}}
+}
+
+func TestGetSecretsEnvironment(t *testing.T) {
+	tests := []struct {
+		name        string
+		environment string
+		spec        aksv1.AKSClusterConfigSpec
+		wantBaseURL string
+		wantAuthURL string
+		wantErr     string
+	}{
+		{
+			name:        "public cloud by default",
+			wantBaseURL: "https://management.azure.com/",
+			wantAuthURL: "https://login.microsoftonline.com/",
+		},
+		{
+			name:        "US government cloud",
+			environment: "AzureUSGovernmentCloud",
+			wantBaseURL: "https://management.usgovcloudapi.net/",
+			wantAuthURL: "https://login.microsoftonline.us/",
+		},
+		{
+			name:        "China cloud",
+			environment: "AzureChinaCloud",
+			wantBaseURL: "https://management.chinacloudapi.cn/",
+			wantAuthURL: "https://login.chinacloudapi.cn/",
+		},
+		{
+			name:        "endpoints of the spec",
+			environment: "AzureChinaCloud",
+			spec:        aksv1.AKSClusterConfigSpec{BaseURL: to.StringPtr("https://arm.example.com/"), AuthBaseURL: to.StringPtr("https://aad.example.com/")},
+			wantBaseURL: "https://arm.example.com/",
+			wantAuthURL: "https://aad.example.com/",
+		},
+		{
+			name:        "unknown environment",
+			environment: "AzureMoonCloud",
+			wantErr:     "field [azurecredentialConfig-environment] in cloud credential is invalid",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := tt.spec
+			spec.AzureCredentialSecret = "cattle-global-data:cc-test"
+			cred, err := GetSecrets(newCredentialSecretCache(tt.environment), &spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			clusterClient, err := NewClusterClient(cred)
+			if err != nil {
+				t.Fatal(err)
+			}
+			agentPoolClient, err := NewAgentPoolClient(cred)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resourceGroupClient, err := NewResourceGroupClient(cred)
+			if err != nil {
+				t.Fatal(err)
+			}
+			workspacesClient, err := NewOperationInsightsWorkspaceClient(cred)
+			if err != nil {
+				t.Fatal(err)
+			}
+			skusClient, err := NewResourceSkusClient(cred)
+			if err != nil {
+				t.Fatal(err)
+			}
+			roleAssignmentsClient, err := NewRoleAssignmentsClient(cred)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for client, baseURI := range map[string]string{
+				"cluster":          clusterClient.BaseURI,
+				"agent pool":       agentPoolClient.BaseURI,
+				"resource group":   resourceGroupClient.BaseURI,
+				"workspace":        workspacesClient.BaseURI,
+				"resource SKU":     skusClient.BaseURI,
+				"role assignments": roleAssignmentsClient.BaseURI,
+			} {
+				if baseURI != tt.wantBaseURL {
+					t.Errorf("expected the %s client to use %s, got %s", client, tt.wantBaseURL, baseURI)
+				}
+			}
+
+			token, err := servicePrincipalToken(cred, to.String(cred.BaseURL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var marshaled struct {
+				OAuth struct {
+					TokenEndpoint url.URL `json:"tokenEndpoint"`
+				} `json:"oauth"`
+			}
+			data, err := json.Marshal(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(data, &marshaled); err != nil {
+				t.Fatal(err)
+			}
+			if want := tt.wantAuthURL + "tenant/oauth2/token"; !strings.HasPrefix(marshaled.OAuth.TokenEndpoint.String(), want) {
+				t.Errorf("expected tokens from %s, got %s", want, marshaled.OAuth.TokenEndpoint.String())
+			}
+		})
+	}
+}
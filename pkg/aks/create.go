@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2020-11-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
 	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-10-01/resources"
 	"github.com/Azure/go-autorest/autorest/to"
 	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
@@ -23,9 +23,10 @@ func CreateResourceGroup(ctx context.Context, groupsClient *resources.GroupsClie
 	return err
 }
 
-// CreateOrUpdateCluster creates a new managed Kubernetes cluster
+// CreateOrUpdateCluster creates a new managed Kubernetes cluster. If monitoring is enabled, the Log Analytics
+// workspace it is wired to is returned.
 func CreateOrUpdateCluster(ctx context.Context, cred *Credentials, clusterClient *containerservice.ManagedClustersClient,
-	spec *aksv1.AKSClusterConfigSpec) error {
+	spec *aksv1.AKSClusterConfigSpec, proxyTrustedCA string) (*LogAnalyticsWorkspace, error) {
 	dnsPrefix := spec.DNSPrefix
 	if dnsPrefix == nil {
 		dnsPrefix = to.StringPtr(spec.ClusterName)
@@ -41,20 +42,7 @@ func CreateOrUpdateCluster(ctx context.Context, cred *Credentials, clusterClient
 	var vmNetSubnetID *string
 	networkProfile := &containerservice.NetworkProfile{}
 	if hasCustomVirtualNetwork(spec) {
-		virtualNetworkResourceGroup := spec.ResourceGroup
-
-		//if virtual network resource group is set, use it, otherwise assume it is the same as the cluster
-		if spec.VirtualNetworkResourceGroup != nil {
-			virtualNetworkResourceGroup = *spec.VirtualNetworkResourceGroup
-		}
-
-		vmNetSubnetID = to.StringPtr(fmt.Sprintf(
-			"/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/virtualNetworks/%s/subnets/%s",
-			cred.SubscriptionID,
-			virtualNetworkResourceGroup,
-			*spec.VirtualNetwork,
-			*spec.Subnet,
-		))
+		vmNetSubnetID = clusterSubnetID(cred.SubscriptionID, spec)
 
 		networkProfile.DNSServiceIP = spec.NetworkDNSServiceIP
 		networkProfile.DockerBridgeCidr = spec.NetworkDockerBridgeCIDR
@@ -63,11 +51,11 @@ func CreateOrUpdateCluster(ctx context.Context, cred *Credentials, clusterClient
 		if spec.NetworkPlugin != nil {
 			networkProfile.NetworkPlugin = containerservice.NetworkPlugin(*spec.NetworkPlugin)
 		} else {
-			networkProfile.NetworkPlugin = containerservice.Kubenet
+			networkProfile.NetworkPlugin = containerservice.NetworkPluginKubenet
 		}
 
 		// if network plugin is 'Azure', set PodCIDR
-		if networkProfile.NetworkPlugin == containerservice.Azure {
+		if networkProfile.NetworkPlugin == containerservice.NetworkPluginAzure {
 			networkProfile.PodCidr = spec.NetworkPodCIDR
 		}
 
@@ -80,29 +68,23 @@ func CreateOrUpdateCluster(ctx context.Context, cred *Credentials, clusterClient
 			networkProfile.NetworkPolicy = containerservice.NetworkPolicy(*spec.NetworkPolicy)
 		}
 	}
+	if spec.OutboundType != nil {
+		networkProfile.OutboundType = containerservice.OutboundType(*spec.OutboundType)
+	}
+	if spec.NATGatewayProfile != nil {
+		networkProfile.NatGatewayProfile = natGatewayProfile(spec.NATGatewayProfile)
+	}
+	if spec.LoadBalancerProfile != nil {
+		networkProfile.LoadBalancerProfile = loadBalancerProfile(spec.LoadBalancerProfile, nil)
+	}
 
 	agentPoolProfiles := make([]containerservice.ManagedClusterAgentPoolProfile, 0, len(spec.NodePools))
 	for _, np := range spec.NodePools {
 		if np.OrchestratorVersion == nil {
 			np.OrchestratorVersion = spec.KubernetesVersion
 		}
-		agentProfile := containerservice.ManagedClusterAgentPoolProfile{
-			Name:                np.Name,
-			Count:               np.Count,
-			MaxPods:             np.MaxPods,
-			OsDiskSizeGB:        np.OsDiskSizeGB,
-			OsType:              containerservice.OSType(np.OsType),
-			VMSize:              containerservice.VMSizeTypes(np.VMSize),
-			Mode:                containerservice.AgentPoolMode(np.Mode),
-			OrchestratorVersion: np.OrchestratorVersion,
-			AvailabilityZones:   np.AvailabilityZones,
-		}
-		if np.EnableAutoScaling != nil && *np.EnableAutoScaling {
-			agentProfile.EnableAutoScaling = np.EnableAutoScaling
-			agentProfile.MaxCount = np.MaxCount
-			agentProfile.MinCount = np.MinCount
-		}
-		if hasCustomVirtualNetwork(spec) {
+		agentProfile := clusterAgentPoolProfile(np.Name, agentPoolProfileProperties(&np))
+		if hasCustomVirtualNetwork(spec) && np.VnetSubnetID == nil {
 			agentProfile.VnetSubnetID = vmNetSubnetID
 		}
 		agentPoolProfiles = append(agentPoolProfiles, agentProfile)
@@ -124,7 +106,7 @@ func CreateOrUpdateCluster(ctx context.Context, cred *Credentials, clusterClient
 
 	var addonProfiles map[string]*containerservice.ManagedClusterAddonProfile
 
-	if hasHTTPApplicationRoutingSupport(spec) {
+	if HasHTTPApplicationRoutingSupport(spec) {
 		addonProfiles = map[string]*containerservice.ManagedClusterAddonProfile{
 			"httpApplicationRouting": {
 				Enabled: spec.HTTPApplicationRouting,
@@ -132,32 +114,36 @@ func CreateOrUpdateCluster(ctx context.Context, cred *Credentials, clusterClient
 		}
 	}
 
+	var workspace *LogAnalyticsWorkspace
 	if to.Bool(spec.Monitoring) {
-		addonProfiles = map[string]*containerservice.ManagedClusterAddonProfile{
-			"omsagent": {
-				Enabled: spec.Monitoring,
-			},
-		}
-
-		operationInsightsWorkspaceClient, err := NewOperationInsightsWorkspaceClient(cred)
+		var omsagent *containerservice.ManagedClusterAddonProfile
+		var err error
+		omsagent, workspace, err = monitoringAddonProfile(ctx, cred, spec)
 		if err != nil {
-			return err
+			return nil, err
 		}
-
-		logAnalyticsWorkspaceResourceID, err := CheckLogAnalyticsWorkspaceForMonitoring(ctx, operationInsightsWorkspaceClient,
-			spec.ResourceLocation, spec.ResourceGroup, to.String(spec.LogAnalyticsWorkspaceGroup), to.String(spec.LogAnalyticsWorkspaceName))
-		if err != nil {
-			return err
+		addonProfiles = map[string]*containerservice.ManagedClusterAddonProfile{
+			"omsagent": omsagent,
 		}
+	}
 
-		if !strings.HasPrefix(logAnalyticsWorkspaceResourceID, "/") {
-			logAnalyticsWorkspaceResourceID = "/" + logAnalyticsWorkspaceResourceID
+	if spec.KeyVaultSecretsProvider != nil && spec.KeyVaultSecretsProvider.Enabled {
+		if addonProfiles == nil {
+			addonProfiles = map[string]*containerservice.ManagedClusterAddonProfile{}
 		}
-		logAnalyticsWorkspaceResourceID = strings.TrimSuffix(logAnalyticsWorkspaceResourceID, "/")
-
-		addonProfiles["omsagent"].Config = map[string]*string{
-			"logAnalyticsWorkspaceResourceID": to.StringPtr(logAnalyticsWorkspaceResourceID),
+		addonProfiles[keyVaultSecretsProviderAddon] = keyVaultSecretsProviderAddonProfile(spec.KeyVaultSecretsProvider)
+	}
+	if spec.ACIConnector != nil && spec.ACIConnector.Enabled {
+		if addonProfiles == nil {
+			addonProfiles = map[string]*containerservice.ManagedClusterAddonProfile{}
+		}
+		addonProfiles[aciConnectorAddon] = aciConnectorAddonProfile(spec.ACIConnector)
+	}
+	if spec.IngressApplicationGateway != nil && spec.IngressApplicationGateway.Enabled {
+		if addonProfiles == nil {
+			addonProfiles = map[string]*containerservice.ManagedClusterAddonProfile{}
 		}
+		addonProfiles[ingressApplicationGatewayAddon] = ingressApplicationGatewayAddonProfile(spec.IngressApplicationGateway)
 	}
 
 	managedCluster := containerservice.ManagedCluster{
@@ -167,6 +153,7 @@ func CreateOrUpdateCluster(ctx context.Context, cred *Credentials, clusterClient
 		ManagedClusterProperties: &containerservice.ManagedClusterProperties{
 			KubernetesVersion: spec.KubernetesVersion,
 			DNSPrefix:         dnsPrefix,
+			EnableRBAC:        to.BoolPtr(spec.EnableRBAC == nil || *spec.EnableRBAC),
 			AgentPoolProfiles: &agentPoolProfiles,
 			LinuxProfile:      linuxProfile,
 			NetworkProfile:    networkProfile,
@@ -178,6 +165,74 @@ func CreateOrUpdateCluster(ctx context.Context, cred *Credentials, clusterClient
 		},
 	}
 
+	if to.Bool(spec.DisableLocalAccounts) {
+		// local accounts can only be disabled on clusters using managed Azure AD, Azure RBAC authorizes the service
+		// principal without an admin group
+		managedCluster.DisableLocalAccounts = spec.DisableLocalAccounts
+		managedCluster.AadProfile = &containerservice.ManagedClusterAADProfile{
+			Managed:         to.BoolPtr(true),
+			EnableAzureRBAC: to.BoolPtr(true),
+			TenantID:        to.StringPtr(cred.TenantID),
+		}
+	}
+
+	identity := clusterIdentity(spec.Identity)
+	if identity == nil && cred.AuthMode != AuthModeClientSecret {
+		// without a client secret the service principal of the operator cannot be shared with the cluster
+		identity = &containerservice.ManagedClusterIdentity{Type: containerservice.ResourceIdentityTypeSystemAssigned}
+	}
+	if identity != nil {
+		// the cluster runs with its managed identity instead of the service principal of the operator
+		managedCluster.Identity = identity
+		managedCluster.ServicePrincipalProfile = nil
+		managedCluster.IdentityProfile = kubeletIdentityProfile(spec.KubeletIdentity)
+	}
+
+	if spec.AutoScalerProfile != nil {
+		profile, err := autoScalerProfile(spec.AutoScalerProfile)
+		if err != nil {
+			return nil, err
+		}
+		managedCluster.AutoScalerProfile = profile
+	}
+
+	if spec.HTTPProxyConfig != nil {
+		managedCluster.HTTPProxyConfig = httpProxyConfig(spec.HTTPProxyConfig, proxyTrustedCA)
+	}
+
+	if spec.Tier != nil {
+		managedCluster.Sku = clusterSKU(*spec.Tier)
+	}
+
+	if spec.UpgradeChannel != nil {
+		managedCluster.AutoUpgradeProfile = &containerservice.ManagedClusterAutoUpgradeProfile{
+			UpgradeChannel: containerservice.UpgradeChannel(*spec.UpgradeChannel),
+		}
+	}
+
+	if spec.OIDCIssuerEnabled != nil {
+		managedCluster.OidcIssuerProfile = &containerservice.ManagedClusterOIDCIssuerProfile{
+			Enabled: spec.OIDCIssuerEnabled,
+		}
+	}
+	if spec.WorkloadIdentityEnabled != nil {
+		managedCluster.SecurityProfile = &containerservice.ManagedClusterSecurityProfile{
+			WorkloadIdentity: &containerservice.ManagedClusterSecurityProfileWorkloadIdentity{
+				Enabled: spec.WorkloadIdentityEnabled,
+			},
+		}
+	}
+	if spec.Defender != nil && spec.Defender.Enabled {
+		defender, err := defenderProfile(ctx, cred, spec)
+		if err != nil {
+			return nil, err
+		}
+		if managedCluster.SecurityProfile == nil {
+			managedCluster.SecurityProfile = &containerservice.ManagedClusterSecurityProfile{}
+		}
+		managedCluster.SecurityProfile.AzureDefender = defender
+	}
+
 	if spec.AuthorizedIPRanges != nil {
 		managedCluster.APIServerAccessProfile = &containerservice.ManagedClusterAPIServerAccessProfile{
 			AuthorizedIPRanges: spec.AuthorizedIPRanges,
@@ -185,7 +240,9 @@ func CreateOrUpdateCluster(ctx context.Context, cred *Credentials, clusterClient
 	}
 	if to.Bool(spec.PrivateCluster) {
 		managedCluster.APIServerAccessProfile = &containerservice.ManagedClusterAPIServerAccessProfile{
-			EnablePrivateCluster: spec.PrivateCluster,
+			EnablePrivateCluster:           spec.PrivateCluster,
+			PrivateDNSZone:                 spec.PrivateDNSZone,
+			EnablePrivateClusterPublicFQDN: spec.EnablePrivateClusterPublicFQDN,
 		}
 	}
 
@@ -195,28 +252,246 @@ func CreateOrUpdateCluster(ctx context.Context, cred *Credentials, clusterClient
 		spec.ClusterName,
 		managedCluster,
 	)
+	if err != nil {
+		return nil, describeAgentPoolError(err)
+	}
 
-	return err
+	return workspace, nil
 }
 
 func CreateOrUpdateAgentPool(ctx context.Context, agentPoolClient *containerservice.AgentPoolsClient, spec *aksv1.AKSClusterConfigSpec, np *aksv1.AKSNodePool) error {
-	agentProfile := &containerservice.ManagedClusterAgentPoolProfileProperties{
-		Count:               np.Count,
-		MaxPods:             np.MaxPods,
-		OsDiskSizeGB:        np.OsDiskSizeGB,
-		OsType:              containerservice.OSType(np.OsType),
-		VMSize:              containerservice.VMSizeTypes(np.VMSize),
-		Mode:                containerservice.AgentPoolMode(np.Mode),
-		OrchestratorVersion: np.OrchestratorVersion,
+	properties := agentPoolProfileProperties(np)
+	if hasCustomVirtualNetwork(spec) && np.VnetSubnetID == nil {
+		properties.VnetSubnetID = clusterSubnetID(agentPoolClient.SubscriptionID, spec)
 	}
 
 	_, err := agentPoolClient.CreateOrUpdate(ctx, spec.ResourceGroup, spec.ClusterName, to.String(np.Name), containerservice.AgentPool{
-		ManagedClusterAgentPoolProfileProperties: agentProfile,
+		ManagedClusterAgentPoolProfileProperties: properties,
 	})
 
+	return describeAgentPoolError(err)
+}
+
+// describeAgentPoolError rewrites Azure errors caused by node pool features which are not enabled for the
+// subscription into errors explaining how to enable them
+func describeAgentPoolError(err error) error {
+	if IsEncryptionAtHostNotEnabled(err) {
+		return fmt.Errorf("host-based encryption is not enabled for the subscription, register it with "+
+			"\"az feature register --namespace Microsoft.Compute --name EncryptionAtHost\" and "+
+			"\"az provider register --namespace Microsoft.Compute\": %w", err)
+	}
 	return err
 }
 
+// agentPoolProfileProperties returns the properties of the node pool sent both when the cluster is created and when
+// the node pool is created or updated
+func agentPoolProfileProperties(np *aksv1.AKSNodePool) *containerservice.ManagedClusterAgentPoolProfileProperties {
+	properties := &containerservice.ManagedClusterAgentPoolProfileProperties{
+		Count:                     np.Count,
+		MaxPods:                   np.MaxPods,
+		OsDiskSizeGB:              np.OsDiskSizeGB,
+		OsDiskType:                containerservice.OSDiskType(np.OsDiskType),
+		OsType:                    containerservice.OSType(np.OsType),
+		OsSKU:                     containerservice.OSSKU(np.OsSKU),
+		VMSize:                    to.StringPtr(np.VMSize),
+		Mode:                      containerservice.AgentPoolMode(np.Mode),
+		OrchestratorVersion:       np.OrchestratorVersion,
+		AvailabilityZones:         np.AvailabilityZones,
+		VnetSubnetID:              np.VnetSubnetID,
+		PodSubnetID:               np.PodSubnetID,
+		KubeletConfig:             kubeletConfig(np.KubeletConfig),
+		LinuxOSConfig:             linuxOSConfig(np.LinuxOSConfig),
+		EnableFIPS:                np.EnableFIPS,
+		EnableEncryptionAtHost:    np.EnableEncryptionAtHost,
+		EnableUltraSSD:            np.EnableUltraSSD,
+		ProximityPlacementGroupID: np.ProximityPlacementGroupID,
+		HostGroupID:               np.HostGroupID,
+	}
+	if to.Bool(np.EnableAutoScaling) {
+		properties.EnableAutoScaling = np.EnableAutoScaling
+		properties.MinCount = np.MinCount
+		properties.MaxCount = np.MaxCount
+	} else if np.EnableAutoScaling != nil {
+		properties.EnableAutoScaling = to.BoolPtr(false)
+	}
+	if np.UpgradeSettings != nil && np.UpgradeSettings.MaxSurge != "" {
+		properties.UpgradeSettings = &containerservice.AgentPoolUpgradeSettings{
+			MaxSurge: to.StringPtr(np.UpgradeSettings.MaxSurge),
+		}
+	}
+	// taints and labels are always sent, so that removing them from the spec removes them from the node pool
+	nodeTaints := append([]string{}, np.NodeTaints...)
+	properties.NodeTaints = &nodeTaints
+	properties.NodeLabels = make(map[string]*string, len(np.NodeLabels))
+	for key, value := range np.NodeLabels {
+		properties.NodeLabels[key] = to.StringPtr(value)
+	}
+	if np.GpuInstanceProfile != "" {
+		properties.GpuInstanceProfile = containerservice.GPUInstanceProfile(np.GpuInstanceProfile)
+	}
+	if np.WorkloadRuntime != "" {
+		properties.WorkloadRuntime = containerservice.WorkloadRuntime(np.WorkloadRuntime)
+	}
+	if np.SnapshotID != nil {
+		properties.CreationData = &containerservice.CreationData{
+			SourceResourceID: np.SnapshotID,
+		}
+	}
+	if np.ScaleDownMode != "" {
+		properties.ScaleDownMode = containerservice.ScaleDownMode(np.ScaleDownMode)
+	}
+	if np.ScaleSetPriority != "" {
+		properties.ScaleSetPriority = containerservice.ScaleSetPriority(np.ScaleSetPriority)
+	}
+	if properties.ScaleSetPriority == containerservice.ScaleSetPrioritySpot {
+		properties.ScaleSetEvictionPolicy = containerservice.ScaleSetEvictionPolicy(np.ScaleSetEvictionPolicy)
+		properties.SpotMaxPrice = np.SpotMaxPrice
+	}
+	return properties
+}
+
+// clusterAgentPoolProfile converts agent pool properties to the agent pool profile of a managed cluster, the two types
+// have the same fields
+func clusterAgentPoolProfile(name *string, p *containerservice.ManagedClusterAgentPoolProfileProperties) containerservice.ManagedClusterAgentPoolProfile {
+	return containerservice.ManagedClusterAgentPoolProfile{
+		Name:                      name,
+		Count:                     p.Count,
+		VMSize:                    p.VMSize,
+		OsDiskSizeGB:              p.OsDiskSizeGB,
+		OsDiskType:                p.OsDiskType,
+		VnetSubnetID:              p.VnetSubnetID,
+		PodSubnetID:               p.PodSubnetID,
+		MaxPods:                   p.MaxPods,
+		OsType:                    p.OsType,
+		OsSKU:                     p.OsSKU,
+		MaxCount:                  p.MaxCount,
+		MinCount:                  p.MinCount,
+		EnableAutoScaling:         p.EnableAutoScaling,
+		Type:                      p.Type,
+		Mode:                      p.Mode,
+		OrchestratorVersion:       p.OrchestratorVersion,
+		NodeImageVersion:          p.NodeImageVersion,
+		UpgradeSettings:           p.UpgradeSettings,
+		AvailabilityZones:         p.AvailabilityZones,
+		EnableNodePublicIP:        p.EnableNodePublicIP,
+		ScaleSetPriority:          p.ScaleSetPriority,
+		ScaleSetEvictionPolicy:    p.ScaleSetEvictionPolicy,
+		SpotMaxPrice:              p.SpotMaxPrice,
+		Tags:                      p.Tags,
+		NodeLabels:                p.NodeLabels,
+		NodeTaints:                p.NodeTaints,
+		ProximityPlacementGroupID: p.ProximityPlacementGroupID,
+		KubeletConfig:             p.KubeletConfig,
+		LinuxOSConfig:             p.LinuxOSConfig,
+		EnableFIPS:                p.EnableFIPS,
+		EnableEncryptionAtHost:    p.EnableEncryptionAtHost,
+		EnableUltraSSD:            p.EnableUltraSSD,
+		ScaleDownMode:             p.ScaleDownMode,
+		GpuInstanceProfile:        p.GpuInstanceProfile,
+		WorkloadRuntime:           p.WorkloadRuntime,
+		CreationData:              p.CreationData,
+		HostGroupID:               p.HostGroupID,
+	}
+}
+
+// monitoringAddonProfile returns the enabled omsagent addon profile wired to the Log Analytics workspace of the spec,
+// creating the workspace if needed
+func monitoringAddonProfile(ctx context.Context, cred *Credentials, spec *aksv1.AKSClusterConfigSpec) (*containerservice.ManagedClusterAddonProfile, *LogAnalyticsWorkspace, error) {
+	operationInsightsWorkspaceClient, err := NewOperationInsightsWorkspaceClient(cred)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	workspace, err := CheckLogAnalyticsWorkspaceForMonitoring(ctx, operationInsightsWorkspaceClient,
+		spec.ResourceLocation, spec.ResourceGroup, to.String(spec.LogAnalyticsWorkspaceGroup), to.String(spec.LogAnalyticsWorkspaceName))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !strings.HasPrefix(workspace.ID, "/") {
+		workspace.ID = "/" + workspace.ID
+	}
+	workspace.ID = strings.TrimSuffix(workspace.ID, "/")
+
+	return &containerservice.ManagedClusterAddonProfile{
+		Enabled: to.BoolPtr(true),
+		Config: map[string]*string{
+			logAnalyticsWorkspaceIDKey: to.StringPtr(workspace.ID),
+		},
+	}, workspace, nil
+}
+
+// clusterSubnetID returns the resource ID of the subnet of the cluster's custom virtual network
+func clusterSubnetID(subscriptionID string, spec *aksv1.AKSClusterConfigSpec) *string {
+	virtualNetworkResourceGroup := spec.ResourceGroup
+
+	//if virtual network resource group is set, use it, otherwise assume it is the same as the cluster
+	if spec.VirtualNetworkResourceGroup != nil {
+		virtualNetworkResourceGroup = *spec.VirtualNetworkResourceGroup
+	}
+
+	return to.StringPtr(fmt.Sprintf(
+		"/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/virtualNetworks/%s/subnets/%s",
+		subscriptionID,
+		virtualNetworkResourceGroup,
+		*spec.VirtualNetwork,
+		*spec.Subnet,
+	))
+}
+
+// natGatewayProfile converts the NAT gateway settings of the spec to the Azure type
+func natGatewayProfile(p *aksv1.AKSNATGatewayProfile) *containerservice.ManagedClusterNATGatewayProfile {
+	profile := &containerservice.ManagedClusterNATGatewayProfile{
+		IdleTimeoutInMinutes: p.IdleTimeoutInMinutes,
+	}
+	if p.ManagedOutboundIPCount != nil {
+		profile.ManagedOutboundIPProfile = &containerservice.ManagedClusterManagedOutboundIPProfile{
+			Count: p.ManagedOutboundIPCount,
+		}
+	}
+	return profile
+}
+
+// loadBalancerProfile converts the load balancer settings of the spec to the Azure type. Settings missing from the spec
+// are taken from the upstream profile, if there is one.
+func loadBalancerProfile(p *aksv1.AKSLoadBalancerProfile, upstream *containerservice.ManagedClusterLoadBalancerProfile) *containerservice.ManagedClusterLoadBalancerProfile {
+	profile := &containerservice.ManagedClusterLoadBalancerProfile{}
+	if upstream != nil {
+		profile.ManagedOutboundIPs = upstream.ManagedOutboundIPs
+		profile.OutboundIPPrefixes = upstream.OutboundIPPrefixes
+		profile.OutboundIPs = upstream.OutboundIPs
+		profile.AllocatedOutboundPorts = upstream.AllocatedOutboundPorts
+		profile.IdleTimeoutInMinutes = upstream.IdleTimeoutInMinutes
+		profile.EnableMultipleStandardLoadBalancers = upstream.EnableMultipleStandardLoadBalancers
+	}
+
+	// managed outbound IPs, IP prefixes and IPs are mutually exclusive
+	if p.ManagedOutboundIPCount != nil {
+		profile.ManagedOutboundIPs = &containerservice.ManagedClusterLoadBalancerProfileManagedOutboundIPs{
+			Count: p.ManagedOutboundIPCount,
+		}
+		profile.OutboundIPPrefixes = nil
+		profile.OutboundIPs = nil
+	} else if len(p.OutboundIPPrefixes) > 0 {
+		prefixes := make([]containerservice.ResourceReference, 0, len(p.OutboundIPPrefixes))
+		for _, id := range p.OutboundIPPrefixes {
+			prefixes = append(prefixes, containerservice.ResourceReference{ID: to.StringPtr(id)})
+		}
+		profile.OutboundIPPrefixes = &containerservice.ManagedClusterLoadBalancerProfileOutboundIPPrefixes{
+			PublicIPPrefixes: &prefixes,
+		}
+		profile.ManagedOutboundIPs = nil
+		profile.OutboundIPs = nil
+	}
+	if p.AllocatedOutboundPorts != nil {
+		profile.AllocatedOutboundPorts = p.AllocatedOutboundPorts
+	}
+	if p.IdleTimeoutInMinutes != nil {
+		profile.IdleTimeoutInMinutes = p.IdleTimeoutInMinutes
+	}
+	return profile
+}
+
 func hasCustomVirtualNetwork(spec *aksv1.AKSClusterConfigSpec) bool {
 	return spec.VirtualNetwork != nil && spec.Subnet != nil
 }
@@ -225,7 +500,7 @@ func hasLinuxProfile(spec *aksv1.AKSClusterConfigSpec) bool {
 	return spec.LinuxAdminUsername != nil && spec.LinuxSSHPublicKey != nil
 }
 
-func hasHTTPApplicationRoutingSupport(spec *aksv1.AKSClusterConfigSpec) bool {
+func HasHTTPApplicationRoutingSupport(spec *aksv1.AKSClusterConfigSpec) bool {
 	// HttpApplicationRouting is not supported in azure china cloud
 	return !strings.HasPrefix(spec.ResourceLocation, "china")
 }
@@ -0,0 +1,58 @@
+package aks
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+func TestAgentPoolProfilePropertiesAutoscaling(t *testing.T) {
+	tests := []struct {
+		name        string
+		nodePool    aksv1.AKSNodePool
+		wantEnabled *bool
+		wantMin     *int32
+		wantMax     *int32
+	}{
+		{
+			name: "autoscaling not set",
+			nodePool: aksv1.AKSNodePool{
+				MinCount: to.Int32Ptr(1),
+				MaxCount: to.Int32Ptr(3),
+			},
+		},
+		{
+			name: "autoscaling enabled",
+			nodePool: aksv1.AKSNodePool{
+				EnableAutoScaling: to.BoolPtr(true),
+				MinCount:          to.Int32Ptr(1),
+				MaxCount:          to.Int32Ptr(3),
+			},
+			wantEnabled: to.BoolPtr(true),
+			wantMin:     to.Int32Ptr(1),
+			wantMax:     to.Int32Ptr(3),
+		},
+		{
+			name: "autoscaling disabled",
+			nodePool: aksv1.AKSNodePool{
+				EnableAutoScaling: to.BoolPtr(false),
+				MinCount:          to.Int32Ptr(1),
+				MaxCount:          to.Int32Ptr(3),
+			},
+			wantEnabled: to.BoolPtr(false),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			properties := agentPoolProfileProperties(&tt.nodePool)
+			if !reflect.DeepEqual(properties.EnableAutoScaling, tt.wantEnabled) {
+				t.Errorf("expected enableAutoScaling %v, got %v", tt.wantEnabled, properties.EnableAutoScaling)
+			}
+			if !reflect.DeepEqual(properties.MinCount, tt.wantMin) || !reflect.DeepEqual(properties.MaxCount, tt.wantMax) {
+				t.Errorf("expected minCount %v and maxCount %v, got %v and %v", tt.wantMin, tt.wantMax, properties.MinCount, properties.MaxCount)
+			}
+		})
+	}
+}
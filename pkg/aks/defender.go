@@ -0,0 +1,55 @@
+package aks
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// defenderProfile converts the Microsoft Defender settings of the spec to the Azure type. Without a workspace ID the
+// Log Analytics workspace is resolved like the monitoring workspace, and created if it does not exist yet.
+func defenderProfile(ctx context.Context, cred *Credentials, spec *aksv1.AKSClusterConfigSpec) (*containerservice.ManagedClusterSecurityProfileAzureDefender, error) {
+	defender := spec.Defender
+	if !defender.Enabled {
+		return &containerservice.ManagedClusterSecurityProfileAzureDefender{
+			Enabled: to.BoolPtr(false),
+		}, nil
+	}
+
+	workspaceID := to.String(defender.LogAnalyticsWorkspaceID)
+	if workspaceID == "" {
+		operationInsightsWorkspaceClient, err := NewOperationInsightsWorkspaceClient(cred)
+		if err != nil {
+			return nil, err
+		}
+		workspace, err := CheckLogAnalyticsWorkspaceForMonitoring(ctx, operationInsightsWorkspaceClient,
+			spec.ResourceLocation, spec.ResourceGroup, to.String(defender.LogAnalyticsWorkspaceGroup), to.String(defender.LogAnalyticsWorkspaceName))
+		if err != nil {
+			return nil, err
+		}
+		workspaceID = workspace.ID
+	}
+
+	if !strings.HasPrefix(workspaceID, "/") {
+		workspaceID = "/" + workspaceID
+	}
+	return &containerservice.ManagedClusterSecurityProfileAzureDefender{
+		Enabled:                         to.BoolPtr(true),
+		LogAnalyticsWorkspaceResourceID: to.StringPtr(strings.TrimSuffix(workspaceID, "/")),
+	}, nil
+}
+
+// UpstreamDefender converts the Microsoft Defender profile of a cluster to the spec type
+func UpstreamDefender(cluster *containerservice.ManagedCluster) *aksv1.AKSDefender {
+	defender := &aksv1.AKSDefender{}
+	if cluster.ManagedClusterProperties == nil || cluster.SecurityProfile == nil || cluster.SecurityProfile.AzureDefender == nil ||
+		!to.Bool(cluster.SecurityProfile.AzureDefender.Enabled) {
+		return defender
+	}
+	defender.Enabled = true
+	defender.LogAnalyticsWorkspaceID = cluster.SecurityProfile.AzureDefender.LogAnalyticsWorkspaceResourceID
+	return defender
+}
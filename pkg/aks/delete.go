@@ -3,34 +3,67 @@ package aks
 import (
 	"context"
 
-	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2020-11-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/operationalinsights/mgmt/2020-08-01/operationalinsights"
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-10-01/resources"
+	"github.com/Azure/go-autorest/autorest/azure"
 	"github.com/Azure/go-autorest/autorest/to"
 	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
-	"github.com/sirupsen/logrus"
 )
 
-// RemoveCluster Delete AKS managed Kubernetes cluster
+// RemoveCluster starts the deletion of the AKS managed Kubernetes cluster without waiting for it to finish, the
+// caller polls the cluster until it is gone
 func RemoveCluster(ctx context.Context, clusterClient *containerservice.ManagedClustersClient, spec *aksv1.AKSClusterConfigSpec) error {
-	future, err := clusterClient.Delete(ctx, spec.ResourceGroup, spec.ClusterName)
-	if err != nil {
-		return err
+	_, err := clusterClient.Delete(ctx, spec.ResourceGroup, spec.ClusterName, nil)
+	return err
+}
+
+// RemoveAgentPool Delete AKS Agent Pool
+func RemoveAgentPool(ctx context.Context, agentPoolClient *containerservice.AgentPoolsClient, spec *aksv1.AKSClusterConfigSpec, np *aksv1.AKSNodePool) error {
+	_, err := agentPoolClient.Delete(ctx, spec.ResourceGroup, spec.ClusterName, to.String(np.Name), nil)
+
+	return err
+}
+
+// RemoveResourceGroup starts the deletion of resourceGroup along with every resource in it without waiting for it to
+// finish, the caller polls the resource group until it is gone. A resource group which does not exist is ignored.
+func RemoveResourceGroup(ctx context.Context, groupsClient *resources.GroupsClient, resourceGroup string) error {
+	_, err := groupsClient.Delete(ctx, resourceGroup)
+	if IsNotFound(err) {
+		return nil
 	}
+	return err
+}
 
-	err = future.WaitForCompletionRef(ctx, clusterClient.Client)
+// RemoveLogAnalyticsWorkspace starts the deletion of the Log Analytics workspace with the resource ID workspaceID
+// without waiting for it to finish, the caller polls the workspace until it is gone. A workspace which does not exist
+// is ignored.
+func RemoveLogAnalyticsWorkspace(ctx context.Context, workspacesClient *operationalinsights.WorkspacesClient, workspaceID string) error {
+	resource, err := azure.ParseResourceID(workspaceID)
 	if err != nil {
-		logrus.Errorf("can't get the AKS cluster create or update future response: %v", err)
 		return err
 	}
 
-	logrus.Infof("Cluster %v removed successfully", spec.ClusterName)
-	logrus.Debugf("Cluster removal status %v", future.Status())
-
-	return nil
+	_, err = workspacesClient.Delete(ctx, resource.ResourceGroup, resource.ResourceName, nil)
+	if IsNotFound(err) {
+		return nil
+	}
+	return err
 }
 
-// RemoveAgentPool Delete AKS Agent Pool
-func RemoveAgentPool(ctx context.Context, agentPoolClient *containerservice.AgentPoolsClient, spec *aksv1.AKSClusterConfigSpec, np *aksv1.AKSNodePool) error {
-	_, err := agentPoolClient.Delete(ctx, spec.ResourceGroup, spec.ClusterName, to.String(np.Name))
+// ResourceGroupResources returns the resource IDs of the resources in resourceGroup
+func ResourceGroupResources(ctx context.Context, resourcesClient *resources.Client, resourceGroup string) ([]string, error) {
+	iter, err := resourcesClient.ListByResourceGroupComplete(ctx, resourceGroup, "", "", nil)
+	if err != nil {
+		return nil, err
+	}
 
-	return err
+	var ids []string
+	for ; iter.NotDone(); err = iter.NextWithContext(ctx) {
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, to.String(iter.Value().ID))
+	}
+	return ids, nil
 }
@@ -0,0 +1,257 @@
+package aks
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/Azure/go-autorest/autorest/azure"
+)
+
+// correlationIDHeader is set by ARM on every response, it identifies the request in Azure support cases
+const correlationIDHeader = "x-ms-correlation-request-id"
+
+var (
+	matchQuota         = regexp.MustCompile(`(?i)exceeding (?:the )?(?:approved )?(.+?) quota`)
+	matchQuotaLocation = regexp.MustCompile(`(?i)location:\s*([a-z0-9]+)`)
+)
+
+// ErrorCode returns the ARM error code (e.g. "QuotaExceeded") carried by err, or an empty string if err is not an
+// Azure service error
+func ErrorCode(err error) string {
+	if serviceErr := serviceError(err); serviceErr != nil {
+		return serviceErr.Code
+	}
+	return ""
+}
+
+// StatusCode returns the HTTP status code of the Azure response that caused err, or 0 if it is unknown
+func StatusCode(err error) int {
+	var detailedErr autorest.DetailedError
+	if errors.As(err, &detailedErr) {
+		if statusCode, ok := detailedErr.StatusCode.(int); ok {
+			return statusCode
+		}
+	}
+	var requestErr *azure.RequestError
+	if errors.As(err, &requestErr) {
+		if statusCode, ok := requestErr.StatusCode.(int); ok {
+			return statusCode
+		}
+	}
+	return 0
+}
+
+// IsNotFound returns true if err was caused by a resource, or the resource group containing it, not existing
+func IsNotFound(err error) bool {
+	if StatusCode(err) == http.StatusNotFound {
+		return true
+	}
+	switch ErrorCode(err) {
+	case "ResourceNotFound", "ResourceGroupNotFound", "NotFound":
+		return true
+	}
+	return false
+}
+
+// IsUnauthorized returns true if err was caused by rejected or missing Azure credentials
+func IsUnauthorized(err error) bool {
+	var tokenErr adal.TokenRefreshError
+	if errors.As(err, &tokenErr) {
+		return true
+	}
+	statusCode := StatusCode(err)
+	if statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden {
+		return true
+	}
+	switch ErrorCode(err) {
+	case "AuthenticationFailed", "AuthorizationFailed", "InvalidAuthenticationToken",
+		"InvalidAuthenticationTokenTenant", "Unauthorized", "LinkedAuthorizationFailed":
+		return true
+	}
+	return false
+}
+
+// IsThrottled returns true if err was caused by Azure throttling the request
+func IsThrottled(err error) bool {
+	if StatusCode(err) == http.StatusTooManyRequests {
+		return true
+	}
+	switch ErrorCode(err) {
+	case "TooManyRequests", "Throttled", "SubscriptionRequestsThrottled":
+		return true
+	}
+	return false
+}
+
+// RetryAfter returns the delay requested by the Retry-After header of the Azure response that caused err, or 0 if the
+// response did not request one
+func RetryAfter(err error) time.Duration {
+	resp := errorResponse(err)
+	if resp == nil {
+		return 0
+	}
+
+	value := resp.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+// IsAzureError returns true if err was returned by an Azure API, or by sending a request to it
+func IsAzureError(err error) bool {
+	var detailedErr autorest.DetailedError
+	return errors.As(err, &detailedErr) || serviceError(err) != nil
+}
+
+// IsQuotaExceeded returns true if err was caused by a subscription quota or limit
+func IsQuotaExceeded(err error) bool {
+	code := strings.ToLower(ErrorCode(err))
+	return strings.Contains(code, "quota") || strings.Contains(code, "limitexceeded")
+}
+
+// IsEncryptionAtHostNotEnabled returns true if err was caused by host-based encryption not being enabled for the
+// subscription
+func IsEncryptionAtHostNotEnabled(err error) bool {
+	return ErrorCode(err) == "SubscriptionNotEnabledEncryptionAtHost"
+}
+
+// IsRoleAssignmentExists returns true if err was caused by the role assignment being created already existing
+func IsRoleAssignmentExists(err error) bool {
+	return ErrorCode(err) == "RoleAssignmentExists"
+}
+
+// IsBadRequest returns true if Azure rejected the request because of its content
+func IsBadRequest(err error) bool {
+	return StatusCode(err) == http.StatusBadRequest
+}
+
+// CorrelationID returns the ARM correlation request ID of the Azure response that caused err, or an empty string if it
+// is unknown
+func CorrelationID(err error) string {
+	if resp := errorResponse(err); resp != nil {
+		return resp.Header.Get(correlationIDHeader)
+	}
+	return ""
+}
+
+// ErrorMessage returns a short description of err for status and events. Azure service errors are described by their
+// ARM error code, the client method that failed, the correlation ID and the ARM error message, quota errors also name
+// the exceeded quota and its region. Any context the error was wrapped in is kept. Other errors are returned as is.
+func ErrorMessage(err error) string {
+	serviceErr := serviceError(err)
+	if serviceErr == nil {
+		return err.Error()
+	}
+
+	var message strings.Builder
+	if wrapContext := errorContext(err); wrapContext != "" {
+		message.WriteString(wrapContext + ": ")
+	}
+	fmt.Fprintf(&message, "Azure error [%s]", serviceErr.Code)
+	if operation := errorOperation(err); operation != "" {
+		fmt.Fprintf(&message, " from %s", operation)
+	}
+	if correlationID := CorrelationID(err); correlationID != "" {
+		fmt.Fprintf(&message, " (correlation ID [%s])", correlationID)
+	}
+
+	text := serviceErrorText(serviceErr)
+	if IsQuotaExceeded(err) {
+		if quota := matchQuota.FindStringSubmatch(text); quota != nil {
+			fmt.Fprintf(&message, ", quota [%s]", quota[1])
+			if location := matchQuotaLocation.FindStringSubmatch(text); location != nil {
+				fmt.Fprintf(&message, " in region [%s]", location[1])
+			}
+		}
+	}
+	if text != "" {
+		message.WriteString(": " + text)
+	}
+	return message.String()
+}
+
+// serviceErrorText returns the message of serviceErr, or the messages of its details if it has none, on a single line
+func serviceErrorText(serviceErr *azure.ServiceError) string {
+	messages := []string{serviceErr.Message}
+	if serviceErr.Message == "" {
+		messages = nil
+		for _, detail := range serviceErr.Details {
+			if detailMessage, ok := detail["message"].(string); ok && detailMessage != "" {
+				messages = append(messages, detailMessage)
+			}
+		}
+	}
+	return strings.Join(strings.Fields(strings.Join(messages, "; ")), " ")
+}
+
+// errorContext returns the context the first Azure error in the chain of err was wrapped in, e.g. "error creating
+// node pool [pool1]"
+func errorContext(err error) string {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		switch e.(type) {
+		case autorest.DetailedError, *autorest.DetailedError, azure.RequestError, *azure.RequestError,
+			azure.ServiceError, *azure.ServiceError:
+			return strings.TrimSuffix(strings.TrimSuffix(err.Error(), e.Error()), ": ")
+		}
+	}
+	return ""
+}
+
+// errorOperation returns the client method that returned err, e.g. "containerservice.ManagedClustersClient#Get"
+func errorOperation(err error) string {
+	var detailedErr autorest.DetailedError
+	if errors.As(err, &detailedErr) && detailedErr.PackageType != "" {
+		return detailedErr.PackageType + "#" + detailedErr.Method
+	}
+	var requestErr *azure.RequestError
+	if errors.As(err, &requestErr) && requestErr.PackageType != "" {
+		return requestErr.PackageType + "#" + requestErr.Method
+	}
+	return ""
+}
+
+// errorResponse returns the Azure response that caused err, or nil if there is none
+func errorResponse(err error) *http.Response {
+	var detailedErr autorest.DetailedError
+	if errors.As(err, &detailedErr) && detailedErr.Response != nil {
+		return detailedErr.Response
+	}
+	var requestErr *azure.RequestError
+	if errors.As(err, &requestErr) && requestErr.Response != nil {
+		return requestErr.Response
+	}
+	return nil
+}
+
+func serviceError(err error) *azure.ServiceError {
+	var requestErr *azure.RequestError
+	if errors.As(err, &requestErr) && requestErr.ServiceError != nil {
+		return requestErr.ServiceError
+	}
+	var requestErrValue azure.RequestError
+	if errors.As(err, &requestErrValue) && requestErrValue.ServiceError != nil {
+		return requestErrValue.ServiceError
+	}
+	var serviceErr *azure.ServiceError
+	if errors.As(err, &serviceErr) {
+		return serviceErr
+	}
+	var serviceErrValue azure.ServiceError
+	if errors.As(err, &serviceErrValue) {
+		return &serviceErrValue
+	}
+	return nil
+}
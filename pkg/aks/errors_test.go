@@ -0,0 +1,132 @@
+package aks
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+)
+
+// newRequestError returns an error like the ones returned by the Azure clients for an ARM error response
+func newRequestError(statusCode int, serviceErr *azure.ServiceError) *azure.RequestError {
+	resp := &http.Response{StatusCode: statusCode, Header: http.Header{}}
+	resp.Header.Set(correlationIDHeader, "corr-id")
+	return &azure.RequestError{
+		DetailedError: autorest.DetailedError{
+			PackageType: "containerservice.AgentPoolsClient",
+			Method:      "CreateOrUpdate",
+			StatusCode:  statusCode,
+			Response:    resp,
+		},
+		ServiceError: serviceErr,
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "non-Azure error",
+			err:  errors.New("cannot read secret"),
+			want: "cannot read secret",
+		},
+		{
+			name: "service error",
+			err:  newRequestError(http.StatusBadRequest, &azure.ServiceError{Code: "InvalidParameter", Message: "The value of parameter\n  vmSize is invalid."}),
+			want: "Azure error [InvalidParameter] from containerservice.AgentPoolsClient#CreateOrUpdate (correlation ID [corr-id]): " +
+				"The value of parameter vmSize is invalid.",
+		},
+		{
+			name: "wrapped service error",
+			err: fmt.Errorf("error creating node pool [pool1]: %w",
+				newRequestError(http.StatusConflict, &azure.ServiceError{Code: "OperationNotAllowed", Message: "Operation is in progress."})),
+			want: "error creating node pool [pool1]: Azure error [OperationNotAllowed] from containerservice.AgentPoolsClient#CreateOrUpdate " +
+				"(correlation ID [corr-id]): Operation is in progress.",
+		},
+		{
+			name: "quota error",
+			err: newRequestError(http.StatusBadRequest, &azure.ServiceError{
+				Code: "QuotaExceeded",
+				Message: "Operation could not be completed as it results in exceeding approved Total Regional Cores quota. " +
+					"Additional details - Deployment Model: Resource Manager, Location: westeurope, Current Limit: 10",
+			}),
+			want: "Azure error [QuotaExceeded] from containerservice.AgentPoolsClient#CreateOrUpdate (correlation ID [corr-id]), " +
+				"quota [Total Regional Cores] in region [westeurope]: Operation could not be completed as it results in exceeding approved " +
+				"Total Regional Cores quota. Additional details - Deployment Model: Resource Manager, Location: westeurope, Current Limit: 10",
+		},
+		{
+			name: "service error with details only",
+			err: &azure.ServiceError{Code: "BadRequest", Details: []map[string]interface{}{
+				{"code": "A", "message": "first problem"},
+				{"code": "B", "message": "second problem"},
+			}},
+			want: "Azure error [BadRequest]: first problem; second problem",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorMessage(tt.err); got != tt.want {
+				t.Errorf("ErrorMessage() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorClassification(t *testing.T) {
+	notFound := newRequestError(http.StatusNotFound, &azure.ServiceError{Code: "ResourceNotFound"})
+	throttled := newRequestError(http.StatusTooManyRequests, &azure.ServiceError{Code: "SubscriptionRequestsThrottled"})
+	quota := fmt.Errorf("wrapped: %w", newRequestError(http.StatusBadRequest, &azure.ServiceError{Code: "OperationNotAllowed.QuotaExceeded"}))
+
+	if ErrorCode(notFound) != "ResourceNotFound" || !IsNotFound(notFound) || IsNotFound(throttled) {
+		t.Error("expected only the ResourceNotFound error to be not found")
+	}
+	if !IsThrottled(throttled) || IsThrottled(notFound) {
+		t.Error("expected only the throttled error to be throttled")
+	}
+	if !IsQuotaExceeded(quota) || IsQuotaExceeded(notFound) {
+		t.Error("expected only the wrapped quota error to be a quota error")
+	}
+	if CorrelationID(quota) != "corr-id" || CorrelationID(errors.New("other")) != "" {
+		t.Error("expected the correlation ID of Azure errors only")
+	}
+	if ErrorCode(errors.New("other")) != "" || StatusCode(errors.New("other")) != 0 {
+		t.Error("expected no code and status of non-Azure errors")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		// the delay is expected to be within wantMin and wantMax
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{name: "seconds", header: "120", wantMin: 2 * time.Minute, wantMax: 2 * time.Minute},
+		{name: "date", header: time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), wantMin: 59 * time.Minute, wantMax: time.Hour},
+		{name: "date in the past", header: time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)},
+		{name: "invalid", header: "soon"},
+		{name: "absent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newRequestError(http.StatusTooManyRequests, &azure.ServiceError{Code: "TooManyRequests"})
+			if tt.header != "" {
+				err.Response.Header.Set("Retry-After", tt.header)
+			}
+			if got := RetryAfter(fmt.Errorf("wrapped: %w", err)); got < tt.wantMin || got > tt.wantMax {
+				t.Errorf("RetryAfter() = %v, want between %v and %v", got, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+	if got := RetryAfter(errors.New("other")); got != 0 {
+		t.Errorf("expected no delay for non-Azure errors, got %v", got)
+	}
+}
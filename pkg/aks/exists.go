@@ -2,21 +2,58 @@ package aks
 
 import (
 	"context"
+	"net/http"
 
-	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2020-11-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/operationalinsights/mgmt/2020-08-01/operationalinsights"
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
 	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-10-01/resources"
+	"github.com/Azure/go-autorest/autorest/azure"
 	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
 )
 
-func ExistsResourceGroup(ctx context.Context, groupsClient *resources.GroupsClient, resourceGroup string) bool {
+// ExistsResourceGroup Check if resource group exists. Errors other than NotFound are returned so that the caller can
+// retry instead of assuming the resource group is missing.
+func ExistsResourceGroup(ctx context.Context, groupsClient *resources.GroupsClient, resourceGroup string) (bool, error) {
 	resp, err := groupsClient.CheckExistence(ctx, resourceGroup)
+	if err != nil {
+		if resp.StatusCode == http.StatusNotFound || IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
 
-	return err == nil && resp.StatusCode == 204
+	return resp.StatusCode == http.StatusNoContent, nil
 }
 
-// ExistsCluster Check if AKS managed Kubernetes cluster exist
-func ExistsCluster(ctx context.Context, clusterClient *containerservice.ManagedClustersClient, spec *aksv1.AKSClusterConfigSpec) bool {
+// ExistsCluster Check if AKS managed Kubernetes cluster exist. Errors other than NotFound are returned so that the
+// caller can retry instead of assuming the cluster is missing.
+func ExistsCluster(ctx context.Context, clusterClient *containerservice.ManagedClustersClient, spec *aksv1.AKSClusterConfigSpec) (bool, error) {
 	resp, err := clusterClient.Get(ctx, spec.ResourceGroup, spec.ClusterName)
+	if err != nil {
+		if resp.StatusCode == http.StatusNotFound || IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
 
-	return err == nil && resp.StatusCode == 200
+	return resp.StatusCode == http.StatusOK, nil
+}
+
+// ExistsLogAnalyticsWorkspace Check if the Log Analytics workspace with the resource ID workspaceID exists. Errors
+// other than NotFound are returned so that the caller can retry instead of assuming the workspace is missing.
+func ExistsLogAnalyticsWorkspace(ctx context.Context, workspacesClient *operationalinsights.WorkspacesClient, workspaceID string) (bool, error) {
+	resource, err := azure.ParseResourceID(workspaceID)
+	if err != nil {
+		return false, err
+	}
+
+	workspace, err := workspacesClient.Get(ctx, resource.ResourceGroup, resource.ResourceName)
+	if err != nil {
+		if workspace.StatusCode == http.StatusNotFound || IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return workspace.StatusCode == http.StatusOK, nil
 }
@@ -0,0 +1,101 @@
+package aks
+
+import (
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// kubeletIdentityKey is the key of the kubelet identity in the identity profile of a cluster
+const kubeletIdentityKey = "kubeletidentity"
+
+// clusterIdentity converts the cluster identity of the spec to the Azure type
+func clusterIdentity(i *aksv1.AKSClusterIdentity) *containerservice.ManagedClusterIdentity {
+	if i == nil || i.Type == "" {
+		return nil
+	}
+	identity := &containerservice.ManagedClusterIdentity{
+		Type: containerservice.ResourceIdentityType(i.Type),
+	}
+	if identity.Type == containerservice.ResourceIdentityTypeUserAssigned {
+		identity.UserAssignedIdentities = map[string]*containerservice.ManagedClusterIdentityUserAssignedIdentitiesValue{
+			to.String(i.UserAssignedIdentityID): {},
+		}
+	}
+	return identity
+}
+
+// kubeletIdentityProfile converts the kubelet identity of the spec to the identity profile of a cluster
+func kubeletIdentityProfile(i *aksv1.AKSKubeletIdentity) map[string]*containerservice.UserAssignedIdentity {
+	if i == nil {
+		return nil
+	}
+	return map[string]*containerservice.UserAssignedIdentity{
+		kubeletIdentityKey: {
+			ResourceID: i.ResourceID,
+			ClientID:   i.ClientID,
+			ObjectID:   i.ObjectID,
+		},
+	}
+}
+
+// UpstreamIdentity converts the identity of a cluster to the spec type, it returns nil for clusters using a service
+// principal
+func UpstreamIdentity(cluster *containerservice.ManagedCluster) *aksv1.AKSClusterIdentity {
+	if cluster.Identity == nil || cluster.Identity.Type == "" || cluster.Identity.Type == containerservice.ResourceIdentityTypeNone {
+		return nil
+	}
+	identity := &aksv1.AKSClusterIdentity{
+		Type: string(cluster.Identity.Type),
+	}
+	for id := range cluster.Identity.UserAssignedIdentities {
+		identity.UserAssignedIdentityID = to.StringPtr(id)
+	}
+	return identity
+}
+
+// UpstreamKubeletIdentity converts the kubelet identity of a cluster to the spec type
+func UpstreamKubeletIdentity(cluster *containerservice.ManagedCluster) *aksv1.AKSKubeletIdentity {
+	if cluster.ManagedClusterProperties == nil {
+		return nil
+	}
+	i := cluster.IdentityProfile[kubeletIdentityKey]
+	if i == nil {
+		return nil
+	}
+	return &aksv1.AKSKubeletIdentity{
+		ResourceID: i.ResourceID,
+		ClientID:   i.ClientID,
+		ObjectID:   i.ObjectID,
+	}
+}
+
+// IdentityPrincipalID returns the principal ID of the cluster identity, the principal of a user-assigned identity is
+// returned for UserAssigned identities
+func IdentityPrincipalID(cluster *containerservice.ManagedCluster) string {
+	if cluster.Identity == nil {
+		return ""
+	}
+	if cluster.Identity.Type == containerservice.ResourceIdentityTypeUserAssigned {
+		for _, value := range cluster.Identity.UserAssignedIdentities {
+			if value != nil {
+				return to.String(value.PrincipalID)
+			}
+		}
+	}
+	return to.String(cluster.Identity.PrincipalID)
+}
+
+// IdentityClientID returns the client ID of a user-assigned cluster identity, system-assigned identities have no client
+// ID
+func IdentityClientID(cluster *containerservice.ManagedCluster) string {
+	if cluster.Identity == nil || cluster.Identity.Type != containerservice.ResourceIdentityTypeUserAssigned {
+		return ""
+	}
+	for _, value := range cluster.Identity.UserAssignedIdentities {
+		if value != nil {
+			return to.String(value.ClientID)
+		}
+	}
+	return ""
+}
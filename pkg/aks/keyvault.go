@@ -0,0 +1,66 @@
+package aks
+
+import (
+	"strconv"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+const (
+	keyVaultSecretsProviderAddon = "azureKeyvaultSecretsProvider"
+	// the config keys of the Key Vault secrets provider addon
+	keyVaultSecretRotationKey    = "enableSecretRotation"
+	keyVaultRotationPollInterval = "rotationPollInterval"
+)
+
+// keyVaultSecretsProviderAddonProfile converts the Key Vault secrets provider settings of the spec to an addon profile
+func keyVaultSecretsProviderAddonProfile(p *aksv1.AKSKeyVaultSecretsProvider) *containerservice.ManagedClusterAddonProfile {
+	if !p.Enabled {
+		return &containerservice.ManagedClusterAddonProfile{
+			Enabled: to.BoolPtr(false),
+		}
+	}
+	config := map[string]*string{}
+	if p.RotationEnabled != nil {
+		config[keyVaultSecretRotationKey] = to.StringPtr(strconv.FormatBool(*p.RotationEnabled))
+	}
+	if p.RotationPollInterval != nil {
+		config[keyVaultRotationPollInterval] = p.RotationPollInterval
+	}
+	return &containerservice.ManagedClusterAddonProfile{
+		Enabled: to.BoolPtr(true),
+		Config:  config,
+	}
+}
+
+// UpstreamKeyVaultSecretsProvider converts the Key Vault secrets provider addon of a cluster to the spec type
+func UpstreamKeyVaultSecretsProvider(cluster *containerservice.ManagedCluster) *aksv1.AKSKeyVaultSecretsProvider {
+	provider := &aksv1.AKSKeyVaultSecretsProvider{}
+	if cluster.ManagedClusterProperties == nil {
+		return provider
+	}
+	addon := cluster.AddonProfiles[keyVaultSecretsProviderAddon]
+	if addon == nil || !to.Bool(addon.Enabled) {
+		return provider
+	}
+
+	provider.Enabled = true
+	rotationEnabled, _ := strconv.ParseBool(to.String(addon.Config[keyVaultSecretRotationKey]))
+	provider.RotationEnabled = to.BoolPtr(rotationEnabled)
+	provider.RotationPollInterval = addon.Config[keyVaultRotationPollInterval]
+	return provider
+}
+
+// KeyVaultSecretsProviderClientID returns the client ID of the identity of the Key Vault secrets provider addon
+func KeyVaultSecretsProviderClientID(cluster *containerservice.ManagedCluster) string {
+	if cluster.ManagedClusterProperties == nil {
+		return ""
+	}
+	addon := cluster.AddonProfiles[keyVaultSecretsProviderAddon]
+	if addon == nil || !to.Bool(addon.Enabled) || addon.Identity == nil {
+		return ""
+	}
+	return to.String(addon.Identity.ClientID)
+}
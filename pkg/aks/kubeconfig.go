@@ -0,0 +1,68 @@
+package aks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// aadServerAppID is the application ID of the AKS AAD server, tokens for the API server of clusters integrated with
+// Azure AD are requested for it
+const aadServerAppID = "6dae42f8-4368-4678-94ff-3960e28e3630"
+
+// GetKubeConfig returns the REST config of the cluster. The static clusterAdmin credentials are used unless local
+// accounts are disabled, the clusterUser kubeconfig is used then and authenticated with an Azure AD token of the
+// operator credentials instead of its exec plugin.
+func GetKubeConfig(ctx context.Context, cred *Credentials, clusterClient *containerservice.ManagedClustersClient,
+	spec *aksv1.AKSClusterConfigSpec) (*rest.Config, error) {
+	if !to.Bool(spec.DisableLocalAccounts) {
+		accessProfile, err := clusterClient.GetAccessProfile(ctx, spec.ResourceGroup, spec.ClusterName, "clusterAdmin")
+		if err == nil {
+			return clientcmd.RESTConfigFromKubeConfig(*accessProfile.KubeConfig)
+		}
+		// local accounts may have been disabled out-of-band or the config may be imported
+		if !IsBadRequest(err) {
+			return nil, err
+		}
+		logrus.Debugf("Failed to get admin credentials of cluster [%s], using user credentials: %v", spec.ClusterName, err)
+	}
+
+	credentials, err := clusterClient.ListClusterUserCredentials(ctx, spec.ResourceGroup, spec.ClusterName, "", containerservice.FormatExec)
+	if err != nil {
+		return nil, err
+	}
+	if credentials.Kubeconfigs == nil || len(*credentials.Kubeconfigs) == 0 || (*credentials.Kubeconfigs)[0].Value == nil {
+		return nil, fmt.Errorf("cluster [%s] returned no user kubeconfig", spec.ClusterName)
+	}
+	config, err := clientcmd.RESTConfigFromKubeConfig(*(*credentials.Kubeconfigs)[0].Value)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := aadServerToken(ctx, cred)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Azure AD token for cluster [%s]: %w", spec.ClusterName, err)
+	}
+	config.ExecProvider = nil
+	config.AuthProvider = nil
+	config.BearerToken = token
+	return config, nil
+}
+
+// aadServerToken returns an access token of the credentials for the AKS AAD server
+func aadServerToken(ctx context.Context, cred *Credentials) (string, error) {
+	spToken, err := servicePrincipalToken(cred, aadServerAppID)
+	if err != nil {
+		return "", err
+	}
+	if err := spToken.RefreshWithContext(ctx); err != nil {
+		return "", err
+	}
+	return spToken.OAuthToken(), nil
+}
@@ -0,0 +1,65 @@
+package aks
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+var matchResourceGroup = regexp.MustCompile("(?i)/resourcegroups/([^/]+)")
+
+// ClusterSummary describes an AKS cluster which can be imported
+type ClusterSummary struct {
+	Name              string `json:"name"`
+	ResourceGroup     string `json:"resourceGroup"`
+	Location          string `json:"location"`
+	KubernetesVersion string `json:"kubernetesVersion"`
+	ProvisioningState string `json:"provisioningState"`
+}
+
+// ListClusters lists the AKS clusters in resourceGroup, or in the whole subscription of the client if resourceGroup
+// is empty
+func ListClusters(ctx context.Context, clusterClient *containerservice.ManagedClustersClient, resourceGroup string) ([]ClusterSummary, error) {
+	var (
+		iter containerservice.ManagedClusterListResultIterator
+		err  error
+	)
+	if resourceGroup == "" {
+		iter, err = clusterClient.ListComplete(ctx)
+	} else {
+		iter, err = clusterClient.ListByResourceGroupComplete(ctx, resourceGroup)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var clusters []ClusterSummary
+	for ; iter.NotDone(); err = iter.NextWithContext(ctx) {
+		if err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, newClusterSummary(iter.Value()))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return clusters, nil
+}
+
+func newClusterSummary(cluster containerservice.ManagedCluster) ClusterSummary {
+	summary := ClusterSummary{
+		Name:     to.String(cluster.Name),
+		Location: to.String(cluster.Location),
+	}
+	if match := matchResourceGroup.FindStringSubmatch(to.String(cluster.ID)); len(match) > 1 {
+		summary.ResourceGroup = match[1]
+	}
+	if cluster.ManagedClusterProperties != nil {
+		summary.KubernetesVersion = to.String(cluster.KubernetesVersion)
+		summary.ProvisioningState = to.String(cluster.ProvisioningState)
+	}
+	return summary
+}
@@ -0,0 +1,44 @@
+package aks
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/go-autorest/autorest"
+)
+
+// withRequestMetrics counts every request sent to Azure with inc, including retries and polls of long-running
+// operations
+func withRequestMetrics(inc func(operation, code string)) autorest.SendDecorator {
+	return func(s autorest.Sender) autorest.Sender {
+		return autorest.SenderFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := s.Do(req)
+			code := "error"
+			if resp != nil {
+				code = strconv.Itoa(resp.StatusCode)
+			}
+			inc(requestOperation(req), code)
+			return resp, err
+		})
+	}
+}
+
+// requestOperation names the operation of an ARM request by its method and the type of the resource it targets,
+// e.g. "PUT agentPools" or "POST listClusterUserCredential". Requests outside of a subscription, like the ones for
+// tokens, are named by their host instead, so that IDs in their paths do not end up in the label.
+func requestOperation(req *http.Request) string {
+	segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if !strings.EqualFold(segments[0], "subscriptions") {
+		return req.Method + " " + req.URL.Host
+	}
+	var resourceType string
+	// ARM paths alternate between resource types and names, "providers" is followed by a provider namespace
+	for i := 0; i < len(segments); i += 2 {
+		if strings.EqualFold(segments[i], "providers") {
+			continue
+		}
+		resourceType = segments[i]
+	}
+	return req.Method + " " + resourceType
+}
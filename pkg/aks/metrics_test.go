@@ -0,0 +1,89 @@
+package aks
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWithRequestMetrics(t *testing.T) {
+	const armURL = "https://management.azure.com/subscriptions/s/resourceGroups/rg/providers/Microsoft.ContainerService"
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		// status is the status code of the response, no response is returned if it is zero
+		status        int
+		wantOperation string
+		wantCode      string
+	}{
+		{
+			name:          "list of clusters",
+			method:        http.MethodGet,
+			url:           armURL + "/managedClusters?api-version=2022-03-02-preview",
+			status:        http.StatusOK,
+			wantOperation: "GET managedClusters",
+			wantCode:      "200",
+		},
+		{
+			name:          "node pool of a cluster",
+			method:        http.MethodPut,
+			url:           armURL + "/managedClusters/cluster/agentPools/pool?api-version=2022-03-02-preview",
+			status:        http.StatusCreated,
+			wantOperation: "PUT agentPools",
+			wantCode:      "201",
+		},
+		{
+			name:          "action on a cluster",
+			method:        http.MethodPost,
+			url:           armURL + "/managedClusters/cluster/listClusterUserCredential?api-version=2022-03-02-preview",
+			status:        http.StatusOK,
+			wantOperation: "POST listClusterUserCredential",
+			wantCode:      "200",
+		},
+		{
+			name:          "token endpoint",
+			method:        http.MethodPost,
+			url:           "https://login.microsoftonline.com/0f7c9f8e-tenant/oauth2/v2.0/token",
+			status:        http.StatusBadRequest,
+			wantOperation: "POST login.microsoftonline.com",
+			wantCode:      "400",
+		},
+		{
+			name:          "request without response",
+			method:        http.MethodGet,
+			url:           armURL + "/managedClusters/cluster?api-version=2022-03-02-preview",
+			wantOperation: "GET managedClusters",
+			wantCode:      "error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests_total"}, []string{"operation", "code"})
+			sender := autorest.DecorateSender(autorest.SenderFunc(func(req *http.Request) (*http.Response, error) {
+				if tt.status == 0 {
+					return nil, errors.New("connection reset")
+				}
+				return &http.Response{StatusCode: tt.status, Request: req}, nil
+			}), withRequestMetrics(func(operation, code string) {
+				requests.WithLabelValues(operation, code).Inc()
+			}))
+
+			req, err := http.NewRequest(tt.method, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sender.Do(req)
+			if count := testutil.CollectAndCount(requests); count != 1 {
+				t.Errorf("expected one series, got %d", count)
+			}
+			if count := testutil.ToFloat64(requests.WithLabelValues(tt.wantOperation, tt.wantCode)); count != 1 {
+				t.Errorf("expected one request counted as %q with code %q, got %v", tt.wantOperation, tt.wantCode, count)
+			}
+		})
+	}
+}
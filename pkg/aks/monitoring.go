@@ -0,0 +1,26 @@
+package aks
+
+import (
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+// logAnalyticsWorkspaceIDKey is the config key of the monitoring addon holding the resource ID of its workspace
+const logAnalyticsWorkspaceIDKey = "logAnalyticsWorkspaceResourceID"
+
+// LogAnalyticsWorkspaceID returns the resource ID of the Log Analytics workspace in the config of the monitoring addon,
+// or an empty string if there is none. The config key is matched regardless of its case, Azure returns clusters with
+// both "logAnalyticsWorkspaceResourceID" and "logAnalyticsWorkspaceResourceId".
+func LogAnalyticsWorkspaceID(omsagent *containerservice.ManagedClusterAddonProfile) string {
+	if omsagent == nil {
+		return ""
+	}
+	for key, value := range omsagent.Config {
+		if strings.EqualFold(key, logAnalyticsWorkspaceIDKey) {
+			return to.String(value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package aks
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+func TestLogAnalyticsWorkspaceID(t *testing.T) {
+	workspaceID := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.OperationalInsights/workspaces/workspace"
+	tests := []struct {
+		name     string
+		omsagent *containerservice.ManagedClusterAddonProfile
+		want     string
+	}{
+		{
+			name: "no addon",
+		},
+		{
+			name:     "no config",
+			omsagent: &containerservice.ManagedClusterAddonProfile{Enabled: to.BoolPtr(true)},
+		},
+		{
+			name: "MSI based monitoring without workspace",
+			omsagent: &containerservice.ManagedClusterAddonProfile{Enabled: to.BoolPtr(true), Config: map[string]*string{
+				"useAADAuth": to.StringPtr("true"),
+			}},
+		},
+		{
+			name: "key as sent by the operator",
+			omsagent: &containerservice.ManagedClusterAddonProfile{Config: map[string]*string{
+				"logAnalyticsWorkspaceResourceID": to.StringPtr(workspaceID),
+			}},
+			want: workspaceID,
+		},
+		{
+			name: "key as returned by Azure",
+			omsagent: &containerservice.ManagedClusterAddonProfile{Config: map[string]*string{
+				"logAnalyticsWorkspaceResourceId": to.StringPtr(workspaceID),
+			}},
+			want: workspaceID,
+		},
+		{
+			name: "nil value",
+			omsagent: &containerservice.ManagedClusterAddonProfile{Config: map[string]*string{
+				"logAnalyticsWorkspaceResourceID": nil,
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LogAnalyticsWorkspaceID(tt.omsagent); got != tt.want {
+				t.Errorf("LogAnalyticsWorkspaceID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
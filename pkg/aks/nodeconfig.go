@@ -0,0 +1,133 @@
+package aks
+
+import (
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// kubeletConfig converts the kubelet settings of a node pool to the Azure type, the types have the same fields
+func kubeletConfig(c *aksv1.AKSKubeletConfig) *containerservice.KubeletConfig {
+	if c == nil {
+		return nil
+	}
+	config := &containerservice.KubeletConfig{
+		CPUManagerPolicy:      c.CPUManagerPolicy,
+		CPUCfsQuota:           c.CPUCfsQuota,
+		CPUCfsQuotaPeriod:     c.CPUCfsQuotaPeriod,
+		ImageGcHighThreshold:  c.ImageGcHighThreshold,
+		ImageGcLowThreshold:   c.ImageGcLowThreshold,
+		TopologyManagerPolicy: c.TopologyManagerPolicy,
+		FailSwapOn:            c.FailSwapOn,
+	}
+	if c.AllowedUnsafeSysctls != nil {
+		allowedUnsafeSysctls := append([]string{}, c.AllowedUnsafeSysctls...)
+		config.AllowedUnsafeSysctls = &allowedUnsafeSysctls
+	}
+	return config
+}
+
+// linuxOSConfig converts the Linux OS settings of a node pool to the Azure type, the types have the same fields
+func linuxOSConfig(c *aksv1.AKSLinuxOSConfig) *containerservice.LinuxOSConfig {
+	if c == nil {
+		return nil
+	}
+	config := &containerservice.LinuxOSConfig{
+		TransparentHugePageEnabled: c.TransparentHugePageEnabled,
+		TransparentHugePageDefrag:  c.TransparentHugePageDefrag,
+		SwapFileSizeMB:             c.SwapFileSizeMB,
+	}
+	if s := c.Sysctls; s != nil {
+		config.Sysctls = &containerservice.SysctlConfig{
+			NetCoreSomaxconn:               s.NetCoreSomaxconn,
+			NetCoreNetdevMaxBacklog:        s.NetCoreNetdevMaxBacklog,
+			NetCoreRmemMax:                 s.NetCoreRmemMax,
+			NetCoreWmemMax:                 s.NetCoreWmemMax,
+			NetCoreOptmemMax:               s.NetCoreOptmemMax,
+			NetIpv4TCPMaxSynBacklog:        s.NetIpv4TCPMaxSynBacklog,
+			NetIpv4TCPMaxTwBuckets:         s.NetIpv4TCPMaxTwBuckets,
+			NetIpv4TCPFinTimeout:           s.NetIpv4TCPFinTimeout,
+			NetIpv4TCPKeepaliveTime:        s.NetIpv4TCPKeepaliveTime,
+			NetIpv4TCPKeepaliveProbes:      s.NetIpv4TCPKeepaliveProbes,
+			NetIpv4TcpkeepaliveIntvl:       s.NetIpv4TcpkeepaliveIntvl,
+			NetIpv4TCPTwReuse:              s.NetIpv4TCPTwReuse,
+			NetIpv4IPLocalPortRange:        s.NetIpv4IPLocalPortRange,
+			NetIpv4NeighDefaultGcThresh1:   s.NetIpv4NeighDefaultGcThresh1,
+			NetIpv4NeighDefaultGcThresh2:   s.NetIpv4NeighDefaultGcThresh2,
+			NetIpv4NeighDefaultGcThresh3:   s.NetIpv4NeighDefaultGcThresh3,
+			NetNetfilterNfConntrackMax:     s.NetNetfilterNfConntrackMax,
+			NetNetfilterNfConntrackBuckets: s.NetNetfilterNfConntrackBuckets,
+			FsInotifyMaxUserWatches:        s.FsInotifyMaxUserWatches,
+			FsFileMax:                      s.FsFileMax,
+			FsAioMaxNr:                     s.FsAioMaxNr,
+			FsNrOpen:                       s.FsNrOpen,
+			KernelThreadsMax:               s.KernelThreadsMax,
+			VMMaxMapCount:                  s.VMMaxMapCount,
+			VMSwappiness:                   s.VMSwappiness,
+			VMVfsCachePressure:             s.VMVfsCachePressure,
+		}
+	}
+	return config
+}
+
+// UpstreamKubeletConfig converts the kubelet settings of an Azure node pool
+func UpstreamKubeletConfig(c *containerservice.KubeletConfig) *aksv1.AKSKubeletConfig {
+	if c == nil {
+		return nil
+	}
+	config := &aksv1.AKSKubeletConfig{
+		CPUManagerPolicy:      c.CPUManagerPolicy,
+		CPUCfsQuota:           c.CPUCfsQuota,
+		CPUCfsQuotaPeriod:     c.CPUCfsQuotaPeriod,
+		ImageGcHighThreshold:  c.ImageGcHighThreshold,
+		ImageGcLowThreshold:   c.ImageGcLowThreshold,
+		TopologyManagerPolicy: c.TopologyManagerPolicy,
+		FailSwapOn:            c.FailSwapOn,
+	}
+	if c.AllowedUnsafeSysctls != nil {
+		config.AllowedUnsafeSysctls = append([]string{}, *c.AllowedUnsafeSysctls...)
+	}
+	return config
+}
+
+// UpstreamLinuxOSConfig converts the Linux OS settings of an Azure node pool
+func UpstreamLinuxOSConfig(c *containerservice.LinuxOSConfig) *aksv1.AKSLinuxOSConfig {
+	if c == nil {
+		return nil
+	}
+	config := &aksv1.AKSLinuxOSConfig{
+		TransparentHugePageEnabled: c.TransparentHugePageEnabled,
+		TransparentHugePageDefrag:  c.TransparentHugePageDefrag,
+		SwapFileSizeMB:             c.SwapFileSizeMB,
+	}
+	if s := c.Sysctls; s != nil {
+		config.Sysctls = &aksv1.AKSSysctlConfig{
+			NetCoreSomaxconn:               s.NetCoreSomaxconn,
+			NetCoreNetdevMaxBacklog:        s.NetCoreNetdevMaxBacklog,
+			NetCoreRmemMax:                 s.NetCoreRmemMax,
+			NetCoreWmemMax:                 s.NetCoreWmemMax,
+			NetCoreOptmemMax:               s.NetCoreOptmemMax,
+			NetIpv4TCPMaxSynBacklog:        s.NetIpv4TCPMaxSynBacklog,
+			NetIpv4TCPMaxTwBuckets:         s.NetIpv4TCPMaxTwBuckets,
+			NetIpv4TCPFinTimeout:           s.NetIpv4TCPFinTimeout,
+			NetIpv4TCPKeepaliveTime:        s.NetIpv4TCPKeepaliveTime,
+			NetIpv4TCPKeepaliveProbes:      s.NetIpv4TCPKeepaliveProbes,
+			NetIpv4TcpkeepaliveIntvl:       s.NetIpv4TcpkeepaliveIntvl,
+			NetIpv4TCPTwReuse:              s.NetIpv4TCPTwReuse,
+			NetIpv4IPLocalPortRange:        s.NetIpv4IPLocalPortRange,
+			NetIpv4NeighDefaultGcThresh1:   s.NetIpv4NeighDefaultGcThresh1,
+			NetIpv4NeighDefaultGcThresh2:   s.NetIpv4NeighDefaultGcThresh2,
+			NetIpv4NeighDefaultGcThresh3:   s.NetIpv4NeighDefaultGcThresh3,
+			NetNetfilterNfConntrackMax:     s.NetNetfilterNfConntrackMax,
+			NetNetfilterNfConntrackBuckets: s.NetNetfilterNfConntrackBuckets,
+			FsInotifyMaxUserWatches:        s.FsInotifyMaxUserWatches,
+			FsFileMax:                      s.FsFileMax,
+			FsAioMaxNr:                     s.FsAioMaxNr,
+			FsNrOpen:                       s.FsNrOpen,
+			KernelThreadsMax:               s.KernelThreadsMax,
+			VMMaxMapCount:                  s.VMMaxMapCount,
+			VMSwappiness:                   s.VMSwappiness,
+			VMVfsCachePressure:             s.VMVfsCachePressure,
+		}
+	}
+	return config
+}
@@ -0,0 +1,46 @@
+package aks
+
+import (
+	"encoding/base64"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// httpProxyConfig converts the HTTP proxy settings of the spec to the Azure type, trustedCA is the PEM encoded CA read
+// from the secret referenced by the settings
+func httpProxyConfig(c *aksv1.AKSHTTPProxyConfig, trustedCA string) *containerservice.ManagedClusterHTTPProxyConfig {
+	config := &containerservice.ManagedClusterHTTPProxyConfig{
+		HTTPProxy:  c.HTTPProxy,
+		HTTPSProxy: c.HTTPSProxy,
+	}
+	if c.NoProxy != nil {
+		noProxy := append([]string{}, c.NoProxy...)
+		config.NoProxy = &noProxy
+	}
+	if trustedCA != "" {
+		config.TrustedCa = to.StringPtr(base64.StdEncoding.EncodeToString([]byte(trustedCA)))
+	}
+	return config
+}
+
+// UpstreamHTTPProxyConfig converts the HTTP proxy settings of a cluster to the spec type. The PEM encoded trusted CA is
+// returned separately, the spec only references the secret holding it.
+func UpstreamHTTPProxyConfig(cluster *containerservice.ManagedCluster) (*aksv1.AKSHTTPProxyConfig, string) {
+	if cluster.ManagedClusterProperties == nil || cluster.HTTPProxyConfig == nil {
+		return nil, ""
+	}
+	config := &aksv1.AKSHTTPProxyConfig{
+		HTTPProxy:  cluster.HTTPProxyConfig.HTTPProxy,
+		HTTPSProxy: cluster.HTTPProxyConfig.HTTPSProxy,
+	}
+	if cluster.HTTPProxyConfig.NoProxy != nil {
+		config.NoProxy = append([]string{}, *cluster.HTTPProxyConfig.NoProxy...)
+	}
+	trustedCA, err := base64.StdEncoding.DecodeString(to.String(cluster.HTTPProxyConfig.TrustedCa))
+	if err != nil {
+		return config, ""
+	}
+	return config, string(trustedCA)
+}
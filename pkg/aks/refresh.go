@@ -0,0 +1,95 @@
+package aks
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/sirupsen/logrus"
+)
+
+// refreshingAuthorizer authorizes requests with the credentials of a client and can replace them with the current
+// content of the credential secret, so that requests keep working after the secret has been rotated
+type refreshingAuthorizer struct {
+	mu         sync.Mutex
+	cred       *Credentials
+	authorizer autorest.Authorizer
+}
+
+func newRefreshingAuthorizer(cred *Credentials) (*refreshingAuthorizer, error) {
+	authorizer, err := NewClientAuthorizer(cred)
+	if err != nil {
+		return nil, err
+	}
+	return &refreshingAuthorizer{
+		cred:       cred,
+		authorizer: authorizer,
+	}, nil
+}
+
+// WithAuthorization implements autorest.Authorizer using the current credentials
+func (a *refreshingAuthorizer) WithAuthorization() autorest.PrepareDecorator {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.authorizer.WithAuthorization()
+}
+
+// refresh reads the credentials again and replaces the authorizer if they changed. It returns false if the
+// credentials cannot be read again or did not change, retrying a request would fail the same way in that case.
+func (a *refreshingAuthorizer) refresh() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.cred.refresh == nil {
+		return false
+	}
+	cred, err := a.cred.refresh()
+	if err != nil {
+		logrus.Debugf("Failed to refresh Azure credentials: %v", err)
+		return false
+	}
+	if cred.AuthMode == a.cred.AuthMode && cred.TenantID == a.cred.TenantID && cred.ClientID == a.cred.ClientID &&
+		cred.ClientSecret == a.cred.ClientSecret {
+		return false
+	}
+
+	authorizer, err := NewClientAuthorizer(cred)
+	if err != nil {
+		logrus.Debugf("Failed to authorize with refreshed Azure credentials: %v", err)
+		return false
+	}
+	logrus.Infof("Azure credentials for subscription [%s] changed, using refreshed credentials", cred.SubscriptionID)
+	a.cred = cred
+	a.authorizer = authorizer
+	return true
+}
+
+// withCredentialRefresh retries a request once with refreshed credentials if Azure rejected it as unauthorized
+func withCredentialRefresh(a *refreshingAuthorizer) autorest.SendDecorator {
+	return func(s autorest.Sender) autorest.Sender {
+		return autorest.SenderFunc(func(req *http.Request) (*http.Response, error) {
+			body, err := readRequestBody(req)
+			if err != nil {
+				return nil, err
+			}
+
+			resp, err := s.Do(req)
+			if err != nil || resp == nil || resp.StatusCode != http.StatusUnauthorized || !a.refresh() {
+				return resp, err
+			}
+
+			if body != nil {
+				req.Body = ioutil.NopCloser(bytes.NewReader(body))
+			}
+			retry, err := autorest.Prepare(req, a.WithAuthorization())
+			if err != nil {
+				return resp, fmt.Errorf("failed to authorize request with refreshed credentials: %w", err)
+			}
+			autorest.Respond(resp, autorest.ByDiscardingBody(), autorest.ByClosing())
+			return s.Do(retry)
+		})
+	}
+}
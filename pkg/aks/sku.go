@@ -0,0 +1,34 @@
+package aks
+
+import (
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+)
+
+// the pricing tiers of a cluster, Standard is called Paid by the API version of the operator
+const (
+	TierFree     = "Free"
+	TierStandard = "Standard"
+	TierPremium  = "Premium"
+)
+
+// clusterSKU converts the pricing tier of the spec to the Azure type
+func clusterSKU(tier string) *containerservice.ManagedClusterSKU {
+	skuTier := containerservice.ManagedClusterSKUTierFree
+	if strings.EqualFold(tier, TierStandard) {
+		skuTier = containerservice.ManagedClusterSKUTierPaid
+	}
+	return &containerservice.ManagedClusterSKU{
+		Name: containerservice.ManagedClusterSKUNameBasic,
+		Tier: skuTier,
+	}
+}
+
+// UpstreamTier returns the pricing tier of a cluster, which is Free if Azure does not report one
+func UpstreamTier(cluster *containerservice.ManagedCluster) string {
+	if cluster.Sku != nil && cluster.Sku.Tier == containerservice.ManagedClusterSKUTierPaid {
+		return TierStandard
+	}
+	return TierFree
+}
@@ -0,0 +1,74 @@
+package aks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2020-06-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+// VMSize describes a VM size offered in a location
+type VMSize struct {
+	// Zones are the availability zones the VM size can be deployed to by the subscription
+	Zones []string
+	// Capabilities are the capabilities of the VM size by name, e.g. "CachedDiskBytes"
+	Capabilities map[string]string
+}
+
+// ListVMSizes returns the VM sizes offered in the location, keyed by their lower case name. Zones which are
+// restricted for the subscription are omitted.
+func ListVMSizes(ctx context.Context, client *compute.ResourceSkusClient, location string) (map[string]VMSize, error) {
+	iterator, err := client.ListComplete(ctx, fmt.Sprintf("location eq '%s'", location))
+	if err != nil {
+		return nil, err
+	}
+
+	vmSizes := map[string]VMSize{}
+	for ; iterator.NotDone(); err = iterator.NextWithContext(ctx) {
+		if err != nil {
+			return nil, err
+		}
+		sku := iterator.Value()
+		if !strings.EqualFold(to.String(sku.ResourceType), "virtualMachines") {
+			continue
+		}
+
+		vmSize := VMSize{
+			Capabilities: map[string]string{},
+		}
+		restrictedZones := map[string]bool{}
+		if sku.Restrictions != nil {
+			for _, restriction := range *sku.Restrictions {
+				if restriction.Type == compute.Zone && restriction.RestrictionInfo != nil && restriction.RestrictionInfo.Zones != nil {
+					for _, zone := range *restriction.RestrictionInfo.Zones {
+						restrictedZones[zone] = true
+					}
+				}
+			}
+		}
+		if sku.LocationInfo != nil {
+			for _, info := range *sku.LocationInfo {
+				if !strings.EqualFold(to.String(info.Location), location) || info.Zones == nil {
+					continue
+				}
+				for _, zone := range *info.Zones {
+					if !restrictedZones[zone] {
+						vmSize.Zones = append(vmSize.Zones, zone)
+					}
+				}
+			}
+		}
+		if sku.Capabilities != nil {
+			for _, capability := range *sku.Capabilities {
+				vmSize.Capabilities[to.String(capability.Name)] = to.String(capability.Value)
+			}
+		}
+		vmSizes[strings.ToLower(to.String(sku.Name))] = vmSize
+	}
+	if err != nil {
+		return nil, err
+	}
+	return vmSizes, nil
+}
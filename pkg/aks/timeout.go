@@ -0,0 +1,45 @@
+package aks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+)
+
+// RequestTimeout bounds every request sent to Azure, including reading its response. Long-running operations are not
+// bounded by it as a whole, only each request starting or polling them is.
+var RequestTimeout = 5 * time.Minute
+
+// withRequestTimeout cancels requests which take longer than RequestTimeout. The timeout is released once the
+// response body is closed.
+func withRequestTimeout() autorest.SendDecorator {
+	return func(s autorest.Sender) autorest.Sender {
+		return autorest.SenderFunc(func(req *http.Request) (*http.Response, error) {
+			if RequestTimeout <= 0 {
+				return s.Do(req)
+			}
+
+			ctx, cancel := context.WithTimeout(req.Context(), RequestTimeout)
+			resp, err := s.Do(req.WithContext(ctx))
+			if err != nil || resp == nil || resp.Body == nil {
+				cancel()
+				return resp, err
+			}
+			resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		})
+	}
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
@@ -0,0 +1,59 @@
+package aks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+)
+
+func TestWithRequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/slow" {
+			select {
+			case <-release:
+			case <-req.Context().Done():
+			}
+		}
+		fmt.Fprint(rw, `{"name":"cluster"}`)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	previous := RequestTimeout
+	RequestTimeout = 100 * time.Millisecond
+	defer func() { RequestTimeout = previous }()
+	sender := autorest.CreateSender(withRequestTimeout())
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/slow", nil)
+	if _, err := sender.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a request exceeding the timeout to fail, got %v", err)
+	}
+
+	// the timeout covers reading the body, which is still readable after the response was returned
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/fast", nil)
+	resp, err := sender.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(body) != `{"name":"cluster"}` {
+		t.Errorf("expected the response body to be readable, got %q, %v", body, err)
+	}
+
+	// the context of the caller still cancels the request
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/fast", nil)
+	if _, err := sender.Do(req); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the context of the caller to cancel the request, got %v", err)
+	}
+}
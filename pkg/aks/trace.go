@@ -0,0 +1,137 @@
+package aks
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/rancher/aks-operator/pkg/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+const redacted = "REDACTED"
+
+// sensitiveFields are the JSON keys (compared case-insensitively) whose values must never be logged. This covers the
+// service principal secret, Windows admin password, SSH public keys and kubeconfigs returned by the credential and
+// access profile endpoints.
+var sensitiveFields = map[string]bool{
+	"secret":        true,
+	"clientsecret":  true,
+	"adminpassword": true,
+	"keydata":       true,
+	"kubeconfig":    true,
+	"kubeconfigs":   true,
+}
+
+// newSender returns the sender used by every Azure client. Every call is bounded by RequestTimeout and counted in the
+// Azure request metrics. When trace logging is enabled it logs the method, URL, redacted request and response bodies
+// and duration of every call. Requests rejected as unauthorized are retried once if the credentials of the authorizer
+// changed.
+func newSender(authorizer *refreshingAuthorizer) autorest.Sender {
+	return autorest.CreateSender(withRequestMetrics(metrics.IncAzureRequest), withTraceLogging(), withCredentialRefresh(authorizer), withRequestTimeout())
+}
+
+func withTraceLogging() autorest.SendDecorator {
+	return func(s autorest.Sender) autorest.Sender {
+		return autorest.SenderFunc(func(req *http.Request) (*http.Response, error) {
+			if !logrus.IsLevelEnabled(logrus.TraceLevel) {
+				return s.Do(req)
+			}
+
+			requestBody, err := readRequestBody(req)
+			if err != nil {
+				return nil, err
+			}
+
+			start := time.Now()
+			resp, err := s.Do(req)
+			duration := time.Since(start)
+			if err != nil {
+				logrus.Tracef("Azure request [%s %s] body [%s] failed after %v: %v",
+					req.Method, req.URL, redactBody(requestBody), duration, err)
+				return resp, err
+			}
+
+			responseBody, err := readResponseBody(resp)
+			if err != nil {
+				return resp, err
+			}
+			logrus.Tracef("Azure request [%s %s] body [%s] returned [%s] body [%s] in %v",
+				req.Method, req.URL, redactBody(requestBody), resp.Status, redactBody(responseBody), duration)
+
+			return resp, nil
+		})
+	}
+}
+
+// readRequestBody reads the request body and replaces it so that it can still be sent
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// readResponseBody reads the response body and replaces it so that it can still be unmarshalled by the caller
+func readResponseBody(resp *http.Response) ([]byte, error) {
+	if resp == nil || resp.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// redactBody returns the body with the values of all sensitive fields replaced. Bodies which are not JSON cannot be
+// redacted reliably and are omitted entirely.
+func redactBody(body []byte) string {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return ""
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return "non-JSON body omitted"
+	}
+
+	redactedBody, err := json.Marshal(redactValue(data))
+	if err != nil {
+		return "body omitted"
+	}
+	return string(redactedBody)
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			if sensitiveFields[strings.ToLower(key)] {
+				v[key] = redacted
+				continue
+			}
+			v[key] = redactValue(val)
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
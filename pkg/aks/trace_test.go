@@ -0,0 +1,155 @@
+package aks
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "empty body",
+			body: "",
+			want: "",
+		},
+		{
+			name: "whitespace body",
+			body: " \n\t",
+			want: "",
+		},
+		{
+			name: "non-JSON body",
+			body: "clientSecret=hunter2",
+			want: "non-JSON body omitted",
+		},
+		{
+			name: "truncated JSON body",
+			body: `{"clientSecret": "hunter2"`,
+			want: "non-JSON body omitted",
+		},
+		{
+			name: "body without sensitive fields",
+			body: `{"name":"cluster","properties":{"kubernetesVersion":"1.23.5"}}`,
+			want: `{"name":"cluster","properties":{"kubernetesVersion":"1.23.5"}}`,
+		},
+		{
+			name: "service principal secret",
+			body: `{"properties":{"servicePrincipalProfile":{"clientId":"client","secret":"hunter2"}}}`,
+			want: `{"properties":{"servicePrincipalProfile":{"clientId":"client","secret":"REDACTED"}}}`,
+		},
+		{
+			name: "client secret",
+			body: `{"clientSecret":"hunter2","clientId":"client"}`,
+			want: `{"clientId":"client","clientSecret":"REDACTED"}`,
+		},
+		{
+			name: "windows admin password",
+			body: `{"windowsProfile":{"adminUsername":"azureuser","adminPassword":"hunter2"}}`,
+			want: `{"windowsProfile":{"adminPassword":"REDACTED","adminUsername":"azureuser"}}`,
+		},
+		{
+			name: "ssh public keys in an array",
+			body: `{"linuxProfile":{"ssh":{"publicKeys":[{"keyData":"ssh-rsa AAAA"},{"keyData":"ssh-rsa BBBB"}]}}}`,
+			want: `{"linuxProfile":{"ssh":{"publicKeys":[{"keyData":"REDACTED"},{"keyData":"REDACTED"}]}}}`,
+		},
+		{
+			name: "kubeconfigs of the credential endpoints",
+			body: `{"kubeconfigs":[{"name":"clusterUser","value":"YXBpVmVyc2lvbg=="}]}`,
+			want: `{"kubeconfigs":"REDACTED"}`,
+		},
+		{
+			name: "kubeconfig of the access profile",
+			body: `{"properties":{"kubeConfig":"YXBpVmVyc2lvbg=="}}`,
+			want: `{"properties":{"kubeConfig":"REDACTED"}}`,
+		},
+		{
+			name: "mixed case keys",
+			body: `{"CLIENTSECRET":"a","ClientSecret":"b","AdminPassword":"c","KEYDATA":"d","Secret":"e"}`,
+			want: `{"AdminPassword":"REDACTED","CLIENTSECRET":"REDACTED","ClientSecret":"REDACTED","KEYDATA":"REDACTED","Secret":"REDACTED"}`,
+		},
+		{
+			name: "top-level array",
+			body: `[{"secret":"hunter2"},"plain",1]`,
+			want: `[{"secret":"REDACTED"},"plain",1]`,
+		},
+		{
+			name: "sensitive key with an object value",
+			body: `{"secret":{"nested":"hunter2"}}`,
+			want: `{"secret":"REDACTED"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactBody([]byte(tt.body)); got != tt.want {
+				t.Errorf("redactBody(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+	}{
+		{
+			name:  "string",
+			value: "secret",
+			want:  "secret",
+		},
+		{
+			name:  "nil",
+			value: nil,
+			want:  nil,
+		},
+		{
+			name: "nested objects and arrays",
+			value: map[string]interface{}{
+				"agentPoolProfiles": []interface{}{
+					map[string]interface{}{"name": "pool", "kubeconfig": "x"},
+				},
+				"identity": map[string]interface{}{
+					"clientSecret": "x",
+					"tags":         []interface{}{"secret"},
+				},
+			},
+			want: map[string]interface{}{
+				"agentPoolProfiles": []interface{}{
+					map[string]interface{}{"name": "pool", "kubeconfig": redacted},
+				},
+				"identity": map[string]interface{}{
+					"clientSecret": redacted,
+					"tags":         []interface{}{"secret"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactValue(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("redactValue() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSensitiveFieldsAreLowerCase(t *testing.T) {
+	for _, field := range []string{"secret", "clientsecret", "adminpassword", "keydata", "kubeconfig", "kubeconfigs"} {
+		if !sensitiveFields[field] {
+			t.Errorf("expected %q to be a sensitive field", field)
+		}
+	}
+	for field := range sensitiveFields {
+		for _, r := range field {
+			if r >= 'A' && r <= 'Z' {
+				t.Errorf("sensitive field %q must be lower case, keys are compared lower-cased", field)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package aks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// UpdateCluster updates an existing managed Kubernetes cluster. Unlike CreateOrUpdateCluster, the request is built from
+// the current upstream cluster with only the Kubernetes version, pricing tier, upgrade channel, autoscaler profile,
+// cluster identity, local accounts, OIDC issuer, workload identity, Microsoft Defender, HTTP proxy, NAT gateway, load
+// balancer profile, authorized IP ranges, monitoring, Key Vault secrets provider, ACI connector and ingress application
+// gateway addons taken from the spec, so agent pools, addons and settings changed out-of-band or defaulted by Azure are
+// sent back unchanged. Only the fields set in spec are changed, the controller only sets the fields which differ from
+// the upstream cluster. If monitoring is enabled, the Log Analytics workspace it is wired to is returned.
+func UpdateCluster(ctx context.Context, cred *Credentials, clusterClient *containerservice.ManagedClustersClient,
+	spec *aksv1.AKSClusterConfigSpec, proxyTrustedCA string) (*LogAnalyticsWorkspace, error) {
+	managedCluster, err := clusterClient.Get(ctx, spec.ResourceGroup, spec.ClusterName)
+	if err != nil {
+		return nil, err
+	}
+	if managedCluster.ManagedClusterProperties == nil {
+		return nil, fmt.Errorf("cluster [%s] has no properties", spec.ClusterName)
+	}
+
+	if spec.KubernetesVersion != nil && to.String(spec.KubernetesVersion) != to.String(managedCluster.KubernetesVersion) {
+		// only the control plane is upgraded, the agent pools are sent with their current versions and upgraded
+		// separately afterwards
+		managedCluster.KubernetesVersion = spec.KubernetesVersion
+	}
+
+	if spec.Tier != nil {
+		managedCluster.Sku = clusterSKU(*spec.Tier)
+	}
+
+	if spec.AutoScalerProfile != nil {
+		// settings missing from the profile are reset to their Azure defaults
+		managedCluster.AutoScalerProfile, err = autoScalerProfile(spec.AutoScalerProfile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if spec.NATGatewayProfile != nil && managedCluster.NetworkProfile != nil {
+		natGateway := natGatewayProfile(spec.NATGatewayProfile)
+		if upstreamNATGateway := managedCluster.NetworkProfile.NatGatewayProfile; upstreamNATGateway != nil {
+			// settings missing from the spec keep their upstream values
+			if natGateway.IdleTimeoutInMinutes == nil {
+				natGateway.IdleTimeoutInMinutes = upstreamNATGateway.IdleTimeoutInMinutes
+			}
+			if natGateway.ManagedOutboundIPProfile == nil {
+				natGateway.ManagedOutboundIPProfile = upstreamNATGateway.ManagedOutboundIPProfile
+			}
+		}
+		managedCluster.NetworkProfile.NatGatewayProfile = natGateway
+	}
+
+	if spec.LoadBalancerProfile != nil && managedCluster.NetworkProfile != nil {
+		managedCluster.NetworkProfile.LoadBalancerProfile = loadBalancerProfile(spec.LoadBalancerProfile, managedCluster.NetworkProfile.LoadBalancerProfile)
+	}
+
+	if spec.HTTPProxyConfig != nil {
+		managedCluster.HTTPProxyConfig = httpProxyConfig(spec.HTTPProxyConfig, proxyTrustedCA)
+	}
+
+	if spec.UpgradeChannel != nil {
+		managedCluster.AutoUpgradeProfile = &containerservice.ManagedClusterAutoUpgradeProfile{
+			UpgradeChannel: containerservice.UpgradeChannel(*spec.UpgradeChannel),
+		}
+	}
+
+	if identity := clusterIdentity(spec.Identity); identity != nil {
+		managedCluster.Identity = identity
+	}
+
+	if spec.DisableLocalAccounts != nil {
+		managedCluster.DisableLocalAccounts = spec.DisableLocalAccounts
+	}
+
+	if spec.OIDCIssuerEnabled != nil {
+		if managedCluster.OidcIssuerProfile == nil {
+			managedCluster.OidcIssuerProfile = &containerservice.ManagedClusterOIDCIssuerProfile{}
+		}
+		managedCluster.OidcIssuerProfile.Enabled = spec.OIDCIssuerEnabled
+	}
+	if spec.WorkloadIdentityEnabled != nil {
+		if managedCluster.SecurityProfile == nil {
+			managedCluster.SecurityProfile = &containerservice.ManagedClusterSecurityProfile{}
+		}
+		managedCluster.SecurityProfile.WorkloadIdentity = &containerservice.ManagedClusterSecurityProfileWorkloadIdentity{
+			Enabled: spec.WorkloadIdentityEnabled,
+		}
+	}
+
+	if spec.Defender != nil {
+		if managedCluster.SecurityProfile == nil {
+			managedCluster.SecurityProfile = &containerservice.ManagedClusterSecurityProfile{}
+		}
+		// the workspace Defender already uses is kept unless the spec names one
+		upstreamDefender := managedCluster.SecurityProfile.AzureDefender
+		keepWorkspace := spec.Defender.Enabled && spec.Defender.LogAnalyticsWorkspaceID == nil && spec.Defender.LogAnalyticsWorkspaceName == nil &&
+			upstreamDefender != nil && to.Bool(upstreamDefender.Enabled) && to.String(upstreamDefender.LogAnalyticsWorkspaceResourceID) != ""
+		if !keepWorkspace {
+			managedCluster.SecurityProfile.AzureDefender, err = defenderProfile(ctx, cred, spec)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if spec.AuthorizedIPRanges != nil {
+		if managedCluster.APIServerAccessProfile == nil {
+			managedCluster.APIServerAccessProfile = &containerservice.ManagedClusterAPIServerAccessProfile{}
+		}
+		managedCluster.APIServerAccessProfile.AuthorizedIPRanges = spec.AuthorizedIPRanges
+	}
+
+	var workspace *LogAnalyticsWorkspace
+	if spec.Monitoring != nil {
+		if managedCluster.AddonProfiles == nil {
+			managedCluster.AddonProfiles = map[string]*containerservice.ManagedClusterAddonProfile{}
+		}
+		if to.Bool(spec.Monitoring) && to.String(spec.LogAnalyticsWorkspaceName) == "" && hasMonitoringWorkspace(&managedCluster) {
+			// keep the workspace the cluster is already wired to rather than deriving the default one again, the
+			// default name may have been generated differently when monitoring was enabled
+			workspace = &LogAnalyticsWorkspace{
+				ID: LogAnalyticsWorkspaceID(managedCluster.AddonProfiles["omsagent"]),
+			}
+		} else if to.Bool(spec.Monitoring) {
+			var omsagent *containerservice.ManagedClusterAddonProfile
+			omsagent, workspace, err = monitoringAddonProfile(ctx, cred, spec)
+			if err != nil {
+				return nil, err
+			}
+			managedCluster.AddonProfiles["omsagent"] = omsagent
+		} else if managedCluster.AddonProfiles["omsagent"] != nil {
+			managedCluster.AddonProfiles["omsagent"] = &containerservice.ManagedClusterAddonProfile{
+				Enabled: to.BoolPtr(false),
+			}
+		}
+	}
+
+	if spec.HTTPApplicationRouting != nil && HasHTTPApplicationRoutingSupport(spec) {
+		if managedCluster.AddonProfiles == nil {
+			managedCluster.AddonProfiles = map[string]*containerservice.ManagedClusterAddonProfile{}
+		}
+		managedCluster.AddonProfiles["httpApplicationRouting"] = &containerservice.ManagedClusterAddonProfile{
+			Enabled: spec.HTTPApplicationRouting,
+		}
+	}
+
+	if spec.KeyVaultSecretsProvider != nil {
+		if managedCluster.AddonProfiles == nil {
+			managedCluster.AddonProfiles = map[string]*containerservice.ManagedClusterAddonProfile{}
+		}
+		managedCluster.AddonProfiles[keyVaultSecretsProviderAddon] = keyVaultSecretsProviderAddonProfile(spec.KeyVaultSecretsProvider)
+	}
+	if spec.ACIConnector != nil {
+		if managedCluster.AddonProfiles == nil {
+			managedCluster.AddonProfiles = map[string]*containerservice.ManagedClusterAddonProfile{}
+		}
+		managedCluster.AddonProfiles[aciConnectorAddon] = aciConnectorAddonProfile(spec.ACIConnector)
+	}
+	if spec.IngressApplicationGateway != nil {
+		if managedCluster.AddonProfiles == nil {
+			managedCluster.AddonProfiles = map[string]*containerservice.ManagedClusterAddonProfile{}
+		}
+		managedCluster.AddonProfiles[ingressApplicationGatewayAddon] = ingressApplicationGatewayAddonProfile(spec.IngressApplicationGateway)
+	}
+
+	_, err = clusterClient.CreateOrUpdate(ctx, spec.ResourceGroup, spec.ClusterName, managedCluster)
+	if err != nil {
+		return nil, err
+	}
+
+	return workspace, nil
+}
+
+// hasMonitoringWorkspace returns true if the omsagent addon of the cluster is enabled and wired to a workspace
+func hasMonitoringWorkspace(managedCluster *containerservice.ManagedCluster) bool {
+	omsagent := managedCluster.AddonProfiles["omsagent"]
+	return omsagent != nil && to.Bool(omsagent.Enabled) && LogAnalyticsWorkspaceID(omsagent) != ""
+}
+
+// ResetServicePrincipal sends the client secret of the credentials to the service principal profile of the cluster,
+// the nodes use it to manage Azure resources
+func ResetServicePrincipal(ctx context.Context, clusterClient *containerservice.ManagedClustersClient, spec *aksv1.AKSClusterConfigSpec,
+	cred *Credentials) error {
+	_, err := clusterClient.ResetServicePrincipalProfile(ctx, spec.ResourceGroup, spec.ClusterName, containerservice.ManagedClusterServicePrincipalProfile{
+		ClientID: to.StringPtr(cred.ClientID),
+		Secret:   to.StringPtr(cred.ClientSecret),
+	})
+	return err
+}
@@ -0,0 +1,188 @@
+package aks
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+const testClusterPath = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ContainerService/managedClusters/cluster"
+
+// newTestCredentials returns credentials of a fake Azure whose GET of the cluster answers with upstream, the body of
+// the PUT of the cluster is decoded into sent
+func newTestCredentials(t *testing.T, upstream map[string]interface{}, sent *map[string]interface{}) *Credentials {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/oauth2/token"):
+			json.NewEncoder(rw).Encode(map[string]string{
+				"access_token": "token",
+				"token_type":   "Bearer",
+				"expires_in":   "3600",
+				"expires_on":   "4102444800",
+			})
+		case req.Method == http.MethodGet && req.URL.Path == testClusterPath:
+			json.NewEncoder(rw).Encode(upstream)
+		case req.Method == http.MethodPut && req.URL.Path == testClusterPath:
+			body, _ := ioutil.ReadAll(req.Body)
+			if err := json.Unmarshal(body, sent); err != nil {
+				t.Errorf("cannot decode cluster update: %v", err)
+			}
+			rw.Write(body)
+		default:
+			t.Errorf("unexpected Azure request [%s %s]", req.Method, req.URL.Path)
+			rw.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return &Credentials{
+		AuthBaseURL:    to.StringPtr(server.URL),
+		BaseURL:        to.StringPtr(server.URL),
+		SubscriptionID: "sub",
+		TenantID:       "tenant",
+		ClientID:       "client",
+		ClientSecret:   "secret",
+	}
+}
+
+// upstreamCluster returns an existing cluster with settings which are not managed by the operator
+func upstreamCluster() map[string]interface{} {
+	return map[string]interface{}{
+		"name":     "cluster",
+		"location": "eastus",
+		"tags":     map[string]interface{}{"team": "a"},
+		"properties": map[string]interface{}{
+			"kubernetesVersion": "1.22.6",
+			"dnsPrefix":         "cluster-dns",
+			"agentPoolProfiles": []interface{}{
+				map[string]interface{}{"name": "system", "count": 3, "mode": "System", "orchestratorVersion": "1.22.6"},
+			},
+			"addonProfiles": map[string]interface{}{
+				"azurepolicy": map[string]interface{}{"enabled": true},
+				"omsagent": map[string]interface{}{"enabled": true, "config": map[string]interface{}{
+					"logAnalyticsWorkspaceResourceID": "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.OperationalInsights/workspaces/workspace",
+				}},
+			},
+			"apiServerAccessProfile": map[string]interface{}{"authorizedIPRanges": []interface{}{"10.0.0.0/8"}},
+		},
+	}
+}
+
+func updateCluster(t *testing.T, spec *aksv1.AKSClusterConfigSpec) (map[string]interface{}, *LogAnalyticsWorkspace) {
+	t.Helper()
+	var sent map[string]interface{}
+	cred := newTestCredentials(t, upstreamCluster(), &sent)
+	clusterClient, err := NewClusterClient(cred)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec.ResourceGroup, spec.ClusterName = "rg", "cluster"
+	workspace, err := UpdateCluster(context.Background(), cred, clusterClient, spec, "")
+	if err != nil {
+		t.Fatalf("unexpected error updating the cluster: %v", err)
+	}
+	if sent == nil {
+		t.Fatal("expected the cluster to be updated")
+	}
+	return sent, workspace
+}
+
+// lookup returns the value at the path of keys in a decoded JSON object
+func lookup(value interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+func TestUpdateClusterKeepsUpstreamSettings(t *testing.T) {
+	sent, _ := updateCluster(t, &aksv1.AKSClusterConfigSpec{KubernetesVersion: to.StringPtr("1.23.5")})
+
+	if got := lookup(sent, "properties", "kubernetesVersion"); got != "1.23.5" {
+		t.Errorf("expected the control plane to be upgraded to 1.23.5, got %v", got)
+	}
+	pools, _ := lookup(sent, "properties", "agentPoolProfiles").([]interface{})
+	if len(pools) != 1 || lookup(pools[0], "orchestratorVersion") != "1.22.6" || lookup(pools[0], "count") != float64(3) {
+		t.Errorf("expected the agent pools to be sent unchanged, got %v", pools)
+	}
+	if got := lookup(sent, "properties", "addonProfiles", "azurepolicy", "enabled"); got != true {
+		t.Errorf("expected the azurepolicy addon to be kept, got %v", got)
+	}
+	if got := lookup(sent, "properties", "dnsPrefix"); got != "cluster-dns" {
+		t.Errorf("expected the DNS prefix to be kept, got %v", got)
+	}
+	if got := lookup(sent, "tags", "team"); got != "a" {
+		t.Errorf("expected the tags to be kept, got %v", got)
+	}
+	ranges, _ := lookup(sent, "properties", "apiServerAccessProfile", "authorizedIPRanges").([]interface{})
+	if len(ranges) != 1 || ranges[0] != "10.0.0.0/8" {
+		t.Errorf("expected the authorized IP ranges to be kept, got %v", ranges)
+	}
+}
+
+func TestUpdateClusterSetsChangedFields(t *testing.T) {
+	sent, _ := updateCluster(t, &aksv1.AKSClusterConfigSpec{
+		AuthorizedIPRanges: &[]string{},
+		Monitoring:         to.BoolPtr(false),
+	})
+
+	if got := lookup(sent, "properties", "kubernetesVersion"); got != "1.22.6" {
+		t.Errorf("expected the Kubernetes version to be kept, got %v", got)
+	}
+	if ranges, ok := lookup(sent, "properties", "apiServerAccessProfile", "authorizedIPRanges").([]interface{}); !ok || len(ranges) != 0 {
+		t.Errorf("expected the authorized IP ranges to be cleared, got %v", lookup(sent, "properties", "apiServerAccessProfile"))
+	}
+	if got := lookup(sent, "properties", "addonProfiles", "omsagent"); !jsonEqual(got, map[string]interface{}{"enabled": false}) {
+		t.Errorf("expected the monitoring addon to be disabled, got %v", got)
+	}
+}
+
+func TestUpdateClusterKeepsMonitoringWorkspace(t *testing.T) {
+	_, workspace := updateCluster(t, &aksv1.AKSClusterConfigSpec{Monitoring: to.BoolPtr(true)})
+	want := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.OperationalInsights/workspaces/workspace"
+	if workspace == nil || workspace.ID != want {
+		t.Errorf("expected the workspace the cluster is wired to, got %+v", workspace)
+	}
+}
+
+func TestUpdateClusterKeepsMonitoringWorkspaceWithLowerCaseKey(t *testing.T) {
+	var sent map[string]interface{}
+	upstream := upstreamCluster()
+	workspaceID := "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.OperationalInsights/workspaces/workspace"
+	lookup(upstream, "properties", "addonProfiles").(map[string]interface{})["omsagent"] = map[string]interface{}{
+		"enabled": true,
+		"config":  map[string]interface{}{"logAnalyticsWorkspaceResourceId": workspaceID},
+	}
+	cred := newTestCredentials(t, upstream, &sent)
+	clusterClient, err := NewClusterClient(cred)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := &aksv1.AKSClusterConfigSpec{ResourceGroup: "rg", ClusterName: "cluster", Monitoring: to.BoolPtr(true)}
+	workspace, err := UpdateCluster(context.Background(), cred, clusterClient, spec, "")
+	if err != nil {
+		t.Fatalf("unexpected error updating the cluster: %v", err)
+	}
+	if workspace == nil || workspace.ID != workspaceID {
+		t.Errorf("expected the workspace the cluster is wired to, got %+v", workspace)
+	}
+}
+
+func jsonEqual(a, b interface{}) bool {
+	aJSON, _ := json.Marshal(a)
+	bJSON, _ := json.Marshal(b)
+	return string(aJSON) == string(bJSON)
+}
@@ -0,0 +1,52 @@
+package aks
+
+import (
+	"context"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/containerservice/mgmt/2022-03-02-preview/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+// UpgradeProfile holds the Kubernetes versions the control plane and the agent pools of a cluster can be upgraded to
+type UpgradeProfile struct {
+	// ControlPlaneUpgrades are the versions the control plane can be upgraded to
+	ControlPlaneUpgrades []string
+	// AgentPoolUpgrades are the versions each agent pool can be upgraded to, keyed by the agent pool name
+	AgentPoolUpgrades map[string][]string
+}
+
+// GetUpgradeProfile returns the versions the cluster and its agent pools can be upgraded to from their current versions
+func GetUpgradeProfile(ctx context.Context, clusterClient *containerservice.ManagedClustersClient, spec *aksv1.AKSClusterConfigSpec) (*UpgradeProfile, error) {
+	result, err := clusterClient.GetUpgradeProfile(ctx, spec.ResourceGroup, spec.ClusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &UpgradeProfile{
+		AgentPoolUpgrades: map[string][]string{},
+	}
+	if result.ManagedClusterUpgradeProfileProperties == nil {
+		return profile, nil
+	}
+	if result.ControlPlaneProfile != nil {
+		profile.ControlPlaneUpgrades = upgradeVersions(result.ControlPlaneProfile.Upgrades)
+	}
+	if result.AgentPoolProfiles != nil {
+		for _, agentPool := range *result.AgentPoolProfiles {
+			profile.AgentPoolUpgrades[to.String(agentPool.Name)] = upgradeVersions(agentPool.Upgrades)
+		}
+	}
+	return profile, nil
+}
+
+func upgradeVersions(upgrades *[]containerservice.ManagedClusterPoolUpgradeProfileUpgradesItem) []string {
+	if upgrades == nil {
+		return nil
+	}
+	versions := make([]string, 0, len(*upgrades))
+	for _, upgrade := range *upgrades {
+		versions = append(versions, to.String(upgrade.KubernetesVersion))
+	}
+	return versions
+}
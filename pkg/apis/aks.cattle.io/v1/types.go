@@ -17,6 +17,7 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -62,11 +63,219 @@ type AKSClusterConfigSpec struct {
 	Monitoring                  *bool             `json:"monitoring"`
 	LogAnalyticsWorkspaceGroup  *string           `json:"logAnalyticsWorkspaceGroup"`
 	LogAnalyticsWorkspaceName   *string           `json:"logAnalyticsWorkspaceName"`
+	// UnmanagedFields lists fields which are managed outside of the operator and are not reconciled, e.g. "tags" or
+	// "nodePools.count"
+	UnmanagedFields []string `json:"unmanagedFields"`
+	// AutoRemediateFailedPools deletes node pools of the spec which failed to provision, so that they are recreated
+	AutoRemediateFailedPools *bool `json:"autoRemediateFailedPools"`
+	// IncludeOperatorEgressIP adds the public egress IP of the operator to non-empty authorized IP ranges, so that
+	// the operator keeps access to the API server. The operator must be configured with AKS_OPERATOR_EGRESS_IP or
+	// AKS_OPERATOR_EGRESS_IP_URL.
+	IncludeOperatorEgressIP *bool `json:"includeOperatorEgressIP"`
+	// NodePoolDefaults are merged into every entry of NodePools, values set on a node pool take precedence. The name
+	// of the defaults is ignored.
+	NodePoolDefaults *AKSNodePool `json:"nodePoolDefaults"`
+	// EnableRBAC enables Kubernetes RBAC, it defaults to true and cannot be changed once the cluster is created
+	EnableRBAC *bool `json:"enableRbac"`
+	// MaxConcurrentNodePoolUpgrades is the number of node pools upgraded to a new orchestrator version at the same
+	// time, it defaults to 1
+	MaxConcurrentNodePoolUpgrades *int32 `json:"maxConcurrentNodePoolUpgrades"`
+	// DisableLocalAccounts disables the static admin credentials of the cluster, the cluster must use managed Azure AD.
+	// Clusters created with local accounts disabled use managed Azure AD with Azure RBAC, the service principal of the
+	// operator needs an Azure Kubernetes Service RBAC role to access the API server.
+	DisableLocalAccounts *bool `json:"disableLocalAccounts"`
+	// OIDCIssuerEnabled enables the OIDC issuer of the cluster, it cannot be disabled once enabled
+	OIDCIssuerEnabled *bool `json:"oidcIssuerEnabled"`
+	// WorkloadIdentityEnabled enables the workload identity webhook, it requires the OIDC issuer
+	WorkloadIdentityEnabled *bool `json:"workloadIdentityEnabled"`
+	// Identity is the managed identity of the control plane, the cluster uses the service principal of the operator
+	// credentials if it is not set
+	Identity *AKSClusterIdentity `json:"identity"`
+	// KubeletIdentity is the user-assigned identity of the kubelets, it requires a user-assigned cluster identity and
+	// cannot be changed once the cluster is created
+	KubeletIdentity *AKSKubeletIdentity `json:"kubeletIdentity"`
+	// UpgradeChannel is the auto-upgrade channel of the cluster: none, patch, stable, rapid or node-image. With the
+	// patch, stable and rapid channels Azure upgrades the cluster, the Kubernetes version and node pool orchestrator
+	// versions are then only recorded in status.
+	UpgradeChannel *string `json:"upgradeChannel" norman:"type=nullablestring"`
+	// AutoScalerProfile holds the cluster autoscaler settings by their Azure name, e.g. "scan-interval" or
+	// "scale-down-delay-after-add". Settings removed from the profile are reset to their Azure defaults.
+	AutoScalerProfile map[string]string `json:"autoScalerProfile"`
+	// KeyVaultSecretsProvider configures the Azure Key Vault secrets provider addon
+	KeyVaultSecretsProvider *AKSKeyVaultSecretsProvider `json:"keyVaultSecretsProvider"`
+	// IngressApplicationGateway configures the application gateway ingress controller addon
+	IngressApplicationGateway *AKSIngressApplicationGateway `json:"ingressApplicationGateway"`
+	// Defender configures Microsoft Defender for Containers
+	Defender *AKSDefender `json:"defender"`
+	// HTTPProxyConfig configures the HTTP proxy used by the nodes
+	HTTPProxyConfig *AKSHTTPProxyConfig `json:"httpProxyConfig"`
+	// OutboundType is the egress routing of the cluster: loadBalancer, managedNATGateway, userAssignedNATGateway or
+	// userDefinedRouting. It cannot be changed once the cluster is created.
+	OutboundType *string `json:"outboundType" norman:"type=nullablestring"`
+	// NATGatewayProfile configures the NAT gateway of clusters with outbound type managedNATGateway
+	NATGatewayProfile *AKSNATGatewayProfile `json:"natGatewayProfile"`
+	// LoadBalancerProfile configures the outbound connectivity of clusters using a standard load balancer
+	LoadBalancerProfile *AKSLoadBalancerProfile `json:"loadBalancerProfile"`
+	// PrivateDNSZone is the private DNS zone of a private cluster: "system" (the default), "none" or the resource ID of a
+	// private DNS zone. It cannot be changed once the cluster is created.
+	PrivateDNSZone *string `json:"privateDnsZone" norman:"type=nullablestring"`
+	// EnablePrivateClusterPublicFQDN creates an additional public FQDN for the API server of a private cluster, it
+	// cannot be changed once the cluster is created
+	EnablePrivateClusterPublicFQDN *bool `json:"enablePrivateClusterPublicFqdn"`
+	// Tier is the pricing tier of the cluster: Free or Standard, which adds the uptime SLA
+	Tier *string `json:"tier" norman:"type=nullablestring"`
+	// AttachACRs are the resource IDs of the container registries the kubelet identity is granted the AcrPull role
+	// on, removing a registry revokes the role
+	AttachACRs []string `json:"attachAcrs"`
+	// ACIConnector configures virtual nodes backed by Azure Container Instances
+	ACIConnector *AKSACIConnector `json:"aciConnector"`
+	// DeleteResourceGroupOnRemove deletes the resource group of the cluster once the cluster is removed, if the
+	// operator created it. The resource group is kept if it contains other resources, unless ForceDeleteResourceGroup
+	// is set.
+	DeleteResourceGroupOnRemove *bool `json:"deleteResourceGroupOnRemove"`
+	// ForceDeleteResourceGroup deletes the resource group created by the operator along with any resources left in it
+	ForceDeleteResourceGroup *bool `json:"forceDeleteResourceGroup"`
+	// DeleteWorkspaceOnRemove deletes the Log Analytics workspace of the monitoring addon once the cluster is removed,
+	// if the operator created it and no other cluster uses it
+	DeleteWorkspaceOnRemove *bool `json:"deleteWorkspaceOnRemove"`
+	// RecreateOnDelete creates the cluster again if it is deleted from Azure by something other than the operator. When
+	// it is not set the config is moved to the missing phase instead. Ignored for imported clusters.
+	RecreateOnDelete *bool `json:"recreateOnDelete"`
 }
 
 type AKSClusterConfigStatus struct {
 	Phase          string `json:"phase"`
 	FailureMessage string `json:"failureMessage"`
+	// FailureReason is a short machine readable classification of the failure: QuotaExceeded, Unauthorized,
+	// InvalidSpec, Throttled, CreateTimeout or Unknown
+	FailureReason string `json:"failureReason"`
+	// FailureCode is the ARM error code of the failure, if it was returned by Azure
+	FailureCode       string `json:"failureCode"`
+	KubernetesVersion string `json:"kubernetesVersion"`
+	CurrentNodeCount  int32  `json:"currentNodeCount"`
+	ProvisioningState string `json:"provisioningState"`
+	// ClusterID is the Azure resource ID of the upstream cluster
+	ClusterID string `json:"clusterId"`
+	// Endpoint is the FQDN of the API server, PrivateFQDN is the FQDN of the API server of a private cluster
+	Endpoint    string `json:"endpoint"`
+	PrivateFQDN string `json:"privateFqdn"`
+	// NodeResourceGroup is the resource group holding the nodes of the cluster, it is managed by Azure
+	NodeResourceGroup string `json:"nodeResourceGroup"`
+	// LastSyncTime is the last time the spec was successfully compared against the upstream cluster
+	LastSyncTime metav1.Time `json:"lastSyncTime,omitempty"`
+	// LastUpdateAppliedTime is the last time a create or update was sent to Azure
+	LastUpdateAppliedTime metav1.Time `json:"lastUpdateAppliedTime,omitempty"`
+	// LastFailureTime is the time the current or most recent failure was first recorded
+	LastFailureTime metav1.Time `json:"lastFailureTime,omitempty"`
+	// ThrottledUntil is set while Azure is throttling the requests of the operator, no requests are sent for the
+	// cluster until then
+	ThrottledUntil metav1.Time `json:"throttledUntil,omitempty"`
+	// RBACEnabled, PrivateCluster, ManagedAAD and ManagedIdentity are the capabilities of the upstream cluster
+	RBACEnabled     bool `json:"rbacEnabled"`
+	PrivateCluster  bool `json:"privateCluster"`
+	ManagedAAD      bool `json:"managedAAD"`
+	ManagedIdentity bool `json:"managedIdentity"`
+	// Tier is the effective pricing tier of the upstream cluster
+	Tier string `json:"tier"`
+	// ResourceGroupCreated is true if the resource group of the cluster was created by the operator, it is never cleared
+	ResourceGroupCreated bool `json:"resourceGroupCreated"`
+	// ResourceGroupDeleting is true once the operator has started deleting the resource group of a removed cluster,
+	// the config is removed once the resource group is gone
+	ResourceGroupDeleting bool `json:"resourceGroupDeleting"`
+	// LogAnalyticsWorkspaceID is the resource ID of the Log Analytics workspace the monitoring addon is wired to. A
+	// workspace created by the operator stays recorded until the operator deletes it.
+	LogAnalyticsWorkspaceID string `json:"logAnalyticsWorkspaceId"`
+	// LogAnalyticsWorkspaceCreated is true if the Log Analytics workspace was created by the operator, it is only
+	// cleared once the operator has deleted the workspace
+	LogAnalyticsWorkspaceCreated bool `json:"logAnalyticsWorkspaceCreated"`
+	// LogAnalyticsWorkspaceDeleting is true once the operator has started deleting the Log Analytics workspace of a
+	// removed cluster, the workspace is removed from status once it is gone
+	LogAnalyticsWorkspaceDeleting bool `json:"logAnalyticsWorkspaceDeleting"`
+	// OIDCIssuerURL is the URL of the OIDC issuer of the cluster, federated identity credentials are created for it
+	OIDCIssuerURL string `json:"oidcIssuerUrl"`
+	// AutoScalerProfileSettings are the autoscaler settings applied from the spec, they are reset to their Azure
+	// defaults once they are removed from the spec
+	AutoScalerProfileSettings []string `json:"autoScalerProfileSettings"`
+	// AppliedTags are the names of the tags applied from the spec, they are removed from Azure once they are removed
+	// from the spec, also when the tags of the spec are removed altogether. Tags which were not applied from the spec
+	// are never removed.
+	AppliedTags []string `json:"appliedTags"`
+	// AddonChanges describes the addon changes sent with the last update of the cluster, e.g.
+	// "httpApplicationRouting: enabled". They are cleared once the cluster finished updating.
+	AddonChanges []string `json:"addonChanges"`
+	// KeyVaultSecretsProviderClientID is the client ID of the identity of the Key Vault secrets provider addon, it is
+	// granted access to the key vaults
+	KeyVaultSecretsProviderClientID string `json:"keyVaultSecretsProviderClientId"`
+	// ApplicationGatewayID is the resource ID of the application gateway used by the ingress application gateway addon
+	ApplicationGatewayID string `json:"applicationGatewayId"`
+	// IdentityPrincipalID and KubeletIdentityObjectID are the principals of the cluster and kubelet identities, role
+	// assignments are created for them
+	IdentityPrincipalID     string `json:"identityPrincipalId"`
+	KubeletIdentityObjectID string `json:"kubeletIdentityObjectId"`
+	// IdentityClientID and KubeletIdentityClientID are the client IDs of the cluster and kubelet identities, a
+	// system-assigned cluster identity has none
+	IdentityClientID        string `json:"identityClientId"`
+	KubeletIdentityClientID string `json:"kubeletIdentityClientId"`
+	// AttachedACRs are the resource IDs of the container registries the kubelet identity is currently granted the
+	// AcrPull role on
+	AttachedACRs []string `json:"attachedAcrs"`
+	// ServicePrincipalSecretHash is an HMAC-SHA256 of the client secret last sent to a cluster sharing the service
+	// principal of the operator, keyed by the UID of the credential secret. The cluster is sent the secret again once it
+	// is rotated.
+	ServicePrincipalSecretHash string `json:"servicePrincipalSecretHash"`
+	// UnmanagedFields are the fields which are currently excluded from reconciliation
+	UnmanagedFields []string `json:"unmanagedFields"`
+	// Warnings are conditions worth surfacing which are not failures, they are cleared once resolved
+	Warnings []string `json:"warnings"`
+	// NodePoolRemediationAttempts counts the automatic remediations of each failed node pool, it is reset once the
+	// node pool has been provisioned
+	NodePoolRemediationAttempts map[string]int32 `json:"nodePoolRemediationAttempts"`
+	// NodePoolVersions are the orchestrator versions of the upstream node pools
+	NodePoolVersions map[string]string `json:"nodePoolVersions"`
+	// NodePools are the observed states of the upstream node pools by name
+	NodePools map[string]AKSNodePoolStatus `json:"nodePools"`
+	// NodePoolUpgradeProgress reports the progress of a node pool upgrade rollout, e.g. "2/5 node pools upgraded"
+	NodePoolUpgradeProgress string `json:"nodePoolUpgradeProgress"`
+	// UpgradeStage is the stage of a running upgrade: ControlPlane, SystemNodePools or UserNodePools. The control plane
+	// is upgraded first, then the System node pools and then the User node pools.
+	UpgradeStage string `json:"upgradeStage"`
+	// UpgradingNodePools are the node pools being upgraded in the current stage
+	UpgradingNodePools []string `json:"upgradingNodePools"`
+	// UpdatingNodePools are the node pools whose creation, update or removal was last sent to Azure, they are cleared
+	// once the cluster finished updating
+	UpdatingNodePools []string `json:"updatingNodePools"`
+	// NodePoolReplacements are the node pools temporarily replacing node pools recreated with a new VM size, by the name
+	// of the node pool they replace. A replacement is removed once the node pool has been created again.
+	NodePoolReplacements map[string]string `json:"nodePoolReplacements"`
+	// PlannedChanges are the changes which would be sent to Azure while the config carries the dry-run annotation,
+	// e.g. "upgrade control plane from 1.27.7 to 1.28.5". They are cleared once the annotation is removed.
+	PlannedChanges []string `json:"plannedChanges"`
+	// Conditions report the state of the cluster alongside the phase: Provisioned, Updated, NodePoolsReady,
+	// MonitoringReady and Ready
+	Conditions []AKSClusterConfigCondition `json:"conditions"`
+}
+
+// AKSNodePoolStatus is the observed state of an upstream node pool
+type AKSNodePoolStatus struct {
+	ProvisioningState   string `json:"provisioningState"`
+	Count               int32  `json:"count"`
+	OrchestratorVersion string `json:"orchestratorVersion"`
+}
+
+// AKSClusterConfigCondition is a condition of the cluster, it mirrors the fields of the upstream metav1.Condition
+type AKSClusterConfigCondition struct {
+	// Type of the condition, e.g. Ready
+	Type string `json:"type"`
+	// Status of the condition, one of True, False or Unknown
+	Status corev1.ConditionStatus `json:"status"`
+	// ObservedGeneration is the generation of the config the condition was set for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// LastTransitionTime is the last time the status of the condition changed
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// Reason is a CamelCase reason for the last transition of the condition
+	Reason string `json:"reason,omitempty"`
+	// Message is a human readable message with details about the last transition
+	Message string `json:"message,omitempty"`
 }
 
 type AKSNodePool struct {
@@ -83,4 +292,205 @@ type AKSNodePool struct {
 	MaxCount            *int32    `json:"maxCount,omitempty"`
 	MinCount            *int32    `json:"minCount,omitempty"`
 	EnableAutoScaling   *bool     `json:"enableAutoScaling,omitempty"`
+	// FollowClusterVersion upgrades the node pool to the Kubernetes version of the control plane once the control
+	// plane has been upgraded, it cannot be combined with OrchestratorVersion
+	FollowClusterVersion *bool `json:"followClusterVersion,omitempty"`
+	// UpgradePriority orders the upgrades of node pools to a new orchestrator version, pools with a lower priority
+	// are upgraded first and pools with the same priority in the order of the spec
+	UpgradePriority *int32 `json:"upgradePriority,omitempty"`
+	// ScaleSetPriority is "Regular" (the default) or "Spot", it cannot be changed once the node pool is created
+	ScaleSetPriority string `json:"scaleSetPriority,omitempty"`
+	// ScaleSetEvictionPolicy is "Delete" or "Deallocate" and only applies to spot node pools
+	ScaleSetEvictionPolicy string `json:"scaleSetEvictionPolicy,omitempty"`
+	// SpotMaxPrice is the maximum price per hour of spot VMs, -1 pays up to the on-demand price
+	SpotMaxPrice *float64 `json:"spotMaxPrice,omitempty"`
+	// NodeTaints are the taints of the nodes, e.g. "sku=gpu:NoSchedule". Removing them from the spec removes them from
+	// the node pool.
+	NodeTaints []string `json:"nodeTaints,omitempty"`
+	// NodeLabels are the labels of the nodes, removing them from the spec removes them from the node pool
+	NodeLabels map[string]string `json:"nodeLabels,omitempty"`
+	// UpgradeSettings control how the node pool is upgraded, Azure defaults apply if they are not set
+	UpgradeSettings *AKSUpgradeSettings `json:"upgradeSettings,omitempty"`
+	// VnetSubnetID is the resource ID of the subnet of the nodes, it overrides the virtual network and subnet of the
+	// cluster and cannot be changed once the node pool is created
+	VnetSubnetID *string `json:"vnetSubnetID,omitempty" norman:"type=nullablestring"`
+	// PodSubnetID is the resource ID of the subnet pod IPs are assigned from, it cannot be changed once the node pool
+	// is created
+	PodSubnetID *string `json:"podSubnetID,omitempty" norman:"type=nullablestring"`
+	// KubeletConfig holds the kubelet settings of the nodes, it cannot be changed once the node pool is created
+	KubeletConfig *AKSKubeletConfig `json:"kubeletConfig,omitempty"`
+	// LinuxOSConfig holds the OS settings of Linux nodes, it cannot be changed once the node pool is created
+	LinuxOSConfig *AKSLinuxOSConfig `json:"linuxOSConfig,omitempty"`
+	// EnableFIPS creates the nodes from a FIPS-enabled OS image, it cannot be changed once the node pool is created
+	EnableFIPS *bool `json:"enableFips,omitempty"`
+	// EnableEncryptionAtHost encrypts the OS and data disks of the nodes on the VM host, the EncryptionAtHost feature
+	// must be registered for the subscription. It cannot be changed once the node pool is created.
+	EnableEncryptionAtHost *bool `json:"enableEncryptionAtHost,omitempty"`
+	// EnableUltraSSD allows the nodes to attach UltraSSD disks, it requires availability zones and cannot be changed
+	// once the node pool is created
+	EnableUltraSSD *bool `json:"enableUltraSSD,omitempty"`
+	// OsSKU is the OS image of the nodes (Ubuntu, CBLMariner, Windows2019 or Windows2022), Azure picks the default image
+	// of the OS type if it is not set. It cannot be changed once the node pool is created.
+	OsSKU string `json:"osSku,omitempty"`
+	// ScaleDownMode is either Delete or Deallocate, Deallocate stops the nodes removed when scaling in instead of
+	// deleting them so that scaling out again is faster. Azure deletes the nodes if it is not set.
+	ScaleDownMode string `json:"scaleDownMode,omitempty"`
+	// GpuInstanceProfile partitions the GPUs of the nodes into MIG instances (MIG1g, MIG2g, MIG3g, MIG4g or MIG7g), it
+	// requires a VM size with GPUs supporting MIG and cannot be changed once the node pool is created
+	GpuInstanceProfile string `json:"gpuInstanceProfile,omitempty"`
+	// WorkloadRuntime is the runtime of the workloads on the nodes, either OCIContainer or WasmWasi, Azure defaults to
+	// OCIContainer
+	WorkloadRuntime string `json:"workloadRuntime,omitempty"`
+	// SnapshotID is the resource ID of the node pool snapshot the node pool is created from, it pins the node image of
+	// the snapshot and cannot be changed once the node pool is created
+	SnapshotID *string `json:"snapshotId,omitempty" norman:"type=nullablestring"`
+	// ProximityPlacementGroupID is the resource ID of the proximity placement group of the nodes, it cannot be changed
+	// once the node pool is created
+	ProximityPlacementGroupID *string `json:"proximityPlacementGroupID,omitempty" norman:"type=nullablestring"`
+	// HostGroupID is the resource ID of the dedicated host group the nodes are placed on, it cannot be changed once the
+	// node pool is created
+	HostGroupID *string `json:"hostGroupID,omitempty" norman:"type=nullablestring"`
+}
+
+type AKSUpgradeSettings struct {
+	// MaxSurge is the number (e.g. "2") or percentage (e.g. "33%") of extra nodes added during upgrades
+	MaxSurge string `json:"maxSurge,omitempty"`
+}
+
+// AKSClusterIdentity is the managed identity of the control plane of a cluster
+type AKSClusterIdentity struct {
+	// Type is SystemAssigned or UserAssigned
+	Type string `json:"type,omitempty"`
+	// UserAssignedIdentityID is the resource ID of the identity, it is required for UserAssigned identities
+	UserAssignedIdentityID *string `json:"userAssignedIdentityId,omitempty" norman:"type=nullablestring"`
+}
+
+// AKSKubeletIdentity is the user-assigned identity used by the kubelets of a cluster
+type AKSKubeletIdentity struct {
+	ResourceID *string `json:"resourceId,omitempty" norman:"type=nullablestring"`
+	ClientID   *string `json:"clientId,omitempty" norman:"type=nullablestring"`
+	ObjectID   *string `json:"objectId,omitempty" norman:"type=nullablestring"`
+}
+
+// AKSKeyVaultSecretsProvider holds the settings of the Azure Key Vault secrets provider addon
+type AKSKeyVaultSecretsProvider struct {
+	Enabled bool `json:"enabled"`
+	// RotationEnabled enables the rotation of mounted secrets, it defaults to false
+	RotationEnabled *bool `json:"rotationEnabled,omitempty"`
+	// RotationPollInterval is the interval secrets are polled for rotation, e.g. "2m", it defaults to 2 minutes
+	RotationPollInterval *string `json:"rotationPollInterval,omitempty" norman:"type=nullablestring"`
+}
+
+// AKSACIConnector holds the settings of the ACI connector addon, which adds a virtual node running pods on Azure
+// Container Instances
+type AKSACIConnector struct {
+	Enabled bool `json:"enabled"`
+	// SubnetName is the name of the subnet of the cluster virtual network the container instances are placed in
+	SubnetName *string `json:"subnetName,omitempty" norman:"type=nullablestring"`
+}
+
+// AKSIngressApplicationGateway holds the settings of the application gateway ingress controller addon, which either
+// uses an existing application gateway or creates one in a new subnet
+type AKSIngressApplicationGateway struct {
+	Enabled bool `json:"enabled"`
+	// GatewayID is the resource ID of an existing application gateway
+	GatewayID *string `json:"gatewayId,omitempty" norman:"type=nullablestring"`
+	// SubnetCIDR is the CIDR of the subnet created for a new application gateway
+	SubnetCIDR *string `json:"subnetCidr,omitempty" norman:"type=nullablestring"`
+	// GatewayName is the name of the new application gateway
+	GatewayName *string `json:"gatewayName,omitempty" norman:"type=nullablestring"`
+}
+
+// AKSDefender holds the settings of Microsoft Defender for Containers. The Log Analytics workspace is either given by
+// its resource ID or by its resource group and name, it is resolved like the monitoring workspace otherwise.
+type AKSDefender struct {
+	Enabled                    bool    `json:"enabled"`
+	LogAnalyticsWorkspaceID    *string `json:"logAnalyticsWorkspaceId,omitempty" norman:"type=nullablestring"`
+	LogAnalyticsWorkspaceGroup *string `json:"logAnalyticsWorkspaceGroup,omitempty" norman:"type=nullablestring"`
+	LogAnalyticsWorkspaceName  *string `json:"logAnalyticsWorkspaceName,omitempty" norman:"type=nullablestring"`
+}
+
+// AKSHTTPProxyConfig holds the HTTP proxy settings of the nodes of a cluster
+type AKSHTTPProxyConfig struct {
+	HTTPProxy  *string `json:"httpProxy,omitempty" norman:"type=nullablestring"`
+	HTTPSProxy *string `json:"httpsProxy,omitempty" norman:"type=nullablestring"`
+	// NoProxy lists the host names, domain suffixes, IP addresses and CIDRs which are not reached through the proxy
+	NoProxy []string `json:"noProxy,omitempty"`
+	// TrustedCASecret is the "namespace:name" of a secret holding the PEM encoded CA of the proxy in its "ca.crt" key,
+	// so that the certificate is not stored in the config
+	TrustedCASecret string `json:"trustedCaSecret,omitempty"`
+}
+
+// AKSNATGatewayProfile holds the settings of the NAT gateway managed by Azure
+type AKSNATGatewayProfile struct {
+	// ManagedOutboundIPCount is the number of outbound IPs of the NAT gateway, from 1 to 16
+	ManagedOutboundIPCount *int32 `json:"managedOutboundIPCount,omitempty"`
+	// IdleTimeoutInMinutes is the idle timeout of outbound flows, from 4 to 120 minutes
+	IdleTimeoutInMinutes *int32 `json:"idleTimeoutInMinutes,omitempty"`
+}
+
+// AKSLoadBalancerProfile holds the outbound settings of the standard load balancer of a cluster. The outbound IPs are
+// either managed by Azure or taken from public IP prefixes.
+type AKSLoadBalancerProfile struct {
+	// ManagedOutboundIPCount is the number of outbound IPs managed by Azure, from 1 to 100
+	ManagedOutboundIPCount *int32 `json:"managedOutboundIPCount,omitempty"`
+	// OutboundIPPrefixes are the resource IDs of the public IP prefixes used for outbound connections
+	OutboundIPPrefixes []string `json:"outboundIPPrefixes,omitempty"`
+	// AllocatedOutboundPorts is the number of SNAT ports per node, a multiple of 8 up to 64000. Azure allocates the
+	// ports dynamically if it is 0.
+	AllocatedOutboundPorts *int32 `json:"allocatedOutboundPorts,omitempty"`
+	// IdleTimeoutInMinutes is the idle timeout of outbound flows, from 4 to 120 minutes
+	IdleTimeoutInMinutes *int32 `json:"idleTimeoutInMinutes,omitempty"`
+}
+
+// AKSKubeletConfig holds the kubelet settings of the nodes of a node pool
+type AKSKubeletConfig struct {
+	CPUManagerPolicy      *string  `json:"cpuManagerPolicy,omitempty"`
+	CPUCfsQuota           *bool    `json:"cpuCfsQuota,omitempty"`
+	CPUCfsQuotaPeriod     *string  `json:"cpuCfsQuotaPeriod,omitempty"`
+	ImageGcHighThreshold  *int32   `json:"imageGcHighThreshold,omitempty"`
+	ImageGcLowThreshold   *int32   `json:"imageGcLowThreshold,omitempty"`
+	TopologyManagerPolicy *string  `json:"topologyManagerPolicy,omitempty"`
+	AllowedUnsafeSysctls  []string `json:"allowedUnsafeSysctls,omitempty"`
+	FailSwapOn            *bool    `json:"failSwapOn,omitempty"`
+}
+
+// AKSLinuxOSConfig holds the OS settings of the Linux nodes of a node pool
+type AKSLinuxOSConfig struct {
+	Sysctls                    *AKSSysctlConfig `json:"sysctls,omitempty"`
+	TransparentHugePageEnabled *string          `json:"transparentHugePageEnabled,omitempty"`
+	TransparentHugePageDefrag  *string          `json:"transparentHugePageDefrag,omitempty"`
+	SwapFileSizeMB             *int32           `json:"swapFileSizeMB,omitempty"`
+}
+
+// AKSSysctlConfig holds the sysctl settings of the Linux nodes of a node pool
+type AKSSysctlConfig struct {
+	NetCoreSomaxconn               *int32  `json:"netCoreSomaxconn,omitempty"`
+	NetCoreNetdevMaxBacklog        *int32  `json:"netCoreNetdevMaxBacklog,omitempty"`
+	NetCoreRmemMax                 *int32  `json:"netCoreRmemMax,omitempty"`
+	NetCoreWmemMax                 *int32  `json:"netCoreWmemMax,omitempty"`
+	NetIpv4TCPRmem                 *int32  `json:"netIpv4TcpRmem,omitempty"`
+	NetIpv4TCPWmem                 *int32  `json:"netIpv4TcpWmem,omitempty"`
+	NetCoreOptmemMax               *int32  `json:"netCoreOptmemMax,omitempty"`
+	NetIpv4TCPMaxSynBacklog        *int32  `json:"netIpv4TcpMaxSynBacklog,omitempty"`
+	NetIpv4TCPMaxTwBuckets         *int32  `json:"netIpv4TcpMaxTwBuckets,omitempty"`
+	NetIpv4TCPFinTimeout           *int32  `json:"netIpv4TcpFinTimeout,omitempty"`
+	NetIpv4TCPKeepaliveTime        *int32  `json:"netIpv4TcpKeepaliveTime,omitempty"`
+	NetIpv4TCPKeepaliveProbes      *int32  `json:"netIpv4TcpKeepaliveProbes,omitempty"`
+	NetIpv4TcpkeepaliveIntvl       *int32  `json:"netIpv4TcpkeepaliveIntvl,omitempty"`
+	NetIpv4TCPTwReuse              *bool   `json:"netIpv4TcpTwReuse,omitempty"`
+	NetIpv4IPLocalPortRange        *string `json:"netIpv4IpLocalPortRange,omitempty"`
+	NetIpv4NeighDefaultGcThresh1   *int32  `json:"netIpv4NeighDefaultGcThresh1,omitempty"`
+	NetIpv4NeighDefaultGcThresh2   *int32  `json:"netIpv4NeighDefaultGcThresh2,omitempty"`
+	NetIpv4NeighDefaultGcThresh3   *int32  `json:"netIpv4NeighDefaultGcThresh3,omitempty"`
+	NetNetfilterNfConntrackMax     *int32  `json:"netNetfilterNfConntrackMax,omitempty"`
+	NetNetfilterNfConntrackBuckets *int32  `json:"netNetfilterNfConntrackBuckets,omitempty"`
+	FsInotifyMaxUserWatches        *int32  `json:"fsInotifyMaxUserWatches,omitempty"`
+	FsFileMax                      *int32  `json:"fsFileMax,omitempty"`
+	FsAioMaxNr                     *int32  `json:"fsAioMaxNr,omitempty"`
+	FsNrOpen                       *int32  `json:"fsNrOpen,omitempty"`
+	KernelThreadsMax               *int32  `json:"kernelThreadsMax,omitempty"`
+	VMMaxMapCount                  *int32  `json:"vmMaxMapCount,omitempty"`
+	VMSwappiness                   *int32  `json:"vmSwappiness,omitempty"`
+	VMVfsCachePressure             *int32  `json:"vmVfsCachePressure,omitempty"`
 }
@@ -24,13 +24,34 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AKSACIConnector) DeepCopyInto(out *AKSACIConnector) {
+	*out = *in
+	if in.SubnetName != nil {
+		in, out := &in.SubnetName, &out.SubnetName
+		*out = new(string)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AKSACIConnector.
+func (in *AKSACIConnector) DeepCopy() *AKSACIConnector {
+	if in == nil {
+		return nil
+	}
+	out := new(AKSACIConnector)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AKSClusterConfig) DeepCopyInto(out *AKSClusterConfig) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -52,6 +73,23 @@ func (in *AKSClusterConfig) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AKSClusterConfigCondition) DeepCopyInto(out *AKSClusterConfigCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AKSClusterConfigCondition.
+func (in *AKSClusterConfigCondition) DeepCopy() *AKSClusterConfigCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(AKSClusterConfigCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AKSClusterConfigList) DeepCopyInto(out *AKSClusterConfigList) {
 	*out = *in
@@ -216,6 +254,153 @@ func (in *AKSClusterConfigSpec) DeepCopyInto(out *AKSClusterConfigSpec) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.UnmanagedFields != nil {
+		in, out := &in.UnmanagedFields, &out.UnmanagedFields
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AutoRemediateFailedPools != nil {
+		in, out := &in.AutoRemediateFailedPools, &out.AutoRemediateFailedPools
+		*out = new(bool)
+		**out = **in
+	}
+	if in.IncludeOperatorEgressIP != nil {
+		in, out := &in.IncludeOperatorEgressIP, &out.IncludeOperatorEgressIP
+		*out = new(bool)
+		**out = **in
+	}
+	if in.NodePoolDefaults != nil {
+		in, out := &in.NodePoolDefaults, &out.NodePoolDefaults
+		*out = new(AKSNodePool)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EnableRBAC != nil {
+		in, out := &in.EnableRBAC, &out.EnableRBAC
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MaxConcurrentNodePoolUpgrades != nil {
+		in, out := &in.MaxConcurrentNodePoolUpgrades, &out.MaxConcurrentNodePoolUpgrades
+		*out = new(int32)
+		**out = **in
+	}
+	if in.DisableLocalAccounts != nil {
+		in, out := &in.DisableLocalAccounts, &out.DisableLocalAccounts
+		*out = new(bool)
+		**out = **in
+	}
+	if in.OIDCIssuerEnabled != nil {
+		in, out := &in.OIDCIssuerEnabled, &out.OIDCIssuerEnabled
+		*out = new(bool)
+		**out = **in
+	}
+	if in.WorkloadIdentityEnabled != nil {
+		in, out := &in.WorkloadIdentityEnabled, &out.WorkloadIdentityEnabled
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Identity != nil {
+		in, out := &in.Identity, &out.Identity
+		*out = new(AKSClusterIdentity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KubeletIdentity != nil {
+		in, out := &in.KubeletIdentity, &out.KubeletIdentity
+		*out = new(AKSKubeletIdentity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UpgradeChannel != nil {
+		in, out := &in.UpgradeChannel, &out.UpgradeChannel
+		*out = new(string)
+		**out = **in
+	}
+	if in.AutoScalerProfile != nil {
+		in, out := &in.AutoScalerProfile, &out.AutoScalerProfile
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.KeyVaultSecretsProvider != nil {
+		in, out := &in.KeyVaultSecretsProvider, &out.KeyVaultSecretsProvider
+		*out = new(AKSKeyVaultSecretsProvider)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IngressApplicationGateway != nil {
+		in, out := &in.IngressApplicationGateway, &out.IngressApplicationGateway
+		*out = new(AKSIngressApplicationGateway)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Defender != nil {
+		in, out := &in.Defender, &out.Defender
+		*out = new(AKSDefender)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HTTPProxyConfig != nil {
+		in, out := &in.HTTPProxyConfig, &out.HTTPProxyConfig
+		*out = new(AKSHTTPProxyConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OutboundType != nil {
+		in, out := &in.OutboundType, &out.OutboundType
+		*out = new(string)
+		**out = **in
+	}
+	if in.NATGatewayProfile != nil {
+		in, out := &in.NATGatewayProfile, &out.NATGatewayProfile
+		*out = new(AKSNATGatewayProfile)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LoadBalancerProfile != nil {
+		in, out := &in.LoadBalancerProfile, &out.LoadBalancerProfile
+		*out = new(AKSLoadBalancerProfile)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PrivateDNSZone != nil {
+		in, out := &in.PrivateDNSZone, &out.PrivateDNSZone
+		*out = new(string)
+		**out = **in
+	}
+	if in.EnablePrivateClusterPublicFQDN != nil {
+		in, out := &in.EnablePrivateClusterPublicFQDN, &out.EnablePrivateClusterPublicFQDN
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Tier != nil {
+		in, out := &in.Tier, &out.Tier
+		*out = new(string)
+		**out = **in
+	}
+	if in.AttachACRs != nil {
+		in, out := &in.AttachACRs, &out.AttachACRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ACIConnector != nil {
+		in, out := &in.ACIConnector, &out.ACIConnector
+		*out = new(AKSACIConnector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DeleteResourceGroupOnRemove != nil {
+		in, out := &in.DeleteResourceGroupOnRemove, &out.DeleteResourceGroupOnRemove
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ForceDeleteResourceGroup != nil {
+		in, out := &in.ForceDeleteResourceGroup, &out.ForceDeleteResourceGroup
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DeleteWorkspaceOnRemove != nil {
+		in, out := &in.DeleteWorkspaceOnRemove, &out.DeleteWorkspaceOnRemove
+		*out = new(bool)
+		**out = **in
+	}
+	if in.RecreateOnDelete != nil {
+		in, out := &in.RecreateOnDelete, &out.RecreateOnDelete
+		*out = new(bool)
+		**out = **in
+	}
 	return
 }
 
@@ -232,6 +417,90 @@ func (in *AKSClusterConfigSpec) DeepCopy() *AKSClusterConfigSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AKSClusterConfigStatus) DeepCopyInto(out *AKSClusterConfigStatus) {
 	*out = *in
+	in.LastSyncTime.DeepCopyInto(&out.LastSyncTime)
+	in.LastUpdateAppliedTime.DeepCopyInto(&out.LastUpdateAppliedTime)
+	in.LastFailureTime.DeepCopyInto(&out.LastFailureTime)
+	in.ThrottledUntil.DeepCopyInto(&out.ThrottledUntil)
+	if in.AutoScalerProfileSettings != nil {
+		in, out := &in.AutoScalerProfileSettings, &out.AutoScalerProfileSettings
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AppliedTags != nil {
+		in, out := &in.AppliedTags, &out.AppliedTags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AddonChanges != nil {
+		in, out := &in.AddonChanges, &out.AddonChanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AttachedACRs != nil {
+		in, out := &in.AttachedACRs, &out.AttachedACRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.UnmanagedFields != nil {
+		in, out := &in.UnmanagedFields, &out.UnmanagedFields
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Warnings != nil {
+		in, out := &in.Warnings, &out.Warnings
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NodePoolRemediationAttempts != nil {
+		in, out := &in.NodePoolRemediationAttempts, &out.NodePoolRemediationAttempts
+		*out = make(map[string]int32, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.NodePoolVersions != nil {
+		in, out := &in.NodePoolVersions, &out.NodePoolVersions
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.NodePools != nil {
+		in, out := &in.NodePools, &out.NodePools
+		*out = make(map[string]AKSNodePoolStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.UpgradingNodePools != nil {
+		in, out := &in.UpgradingNodePools, &out.UpgradingNodePools
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.UpdatingNodePools != nil {
+		in, out := &in.UpdatingNodePools, &out.UpdatingNodePools
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NodePoolReplacements != nil {
+		in, out := &in.NodePoolReplacements, &out.NodePoolReplacements
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PlannedChanges != nil {
+		in, out := &in.PlannedChanges, &out.PlannedChanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]AKSClusterConfigCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -245,6 +514,331 @@ func (in *AKSClusterConfigStatus) DeepCopy() *AKSClusterConfigStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AKSClusterIdentity) DeepCopyInto(out *AKSClusterIdentity) {
+	*out = *in
+	if in.UserAssignedIdentityID != nil {
+		in, out := &in.UserAssignedIdentityID, &out.UserAssignedIdentityID
+		*out = new(string)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AKSClusterIdentity.
+func (in *AKSClusterIdentity) DeepCopy() *AKSClusterIdentity {
+	if in == nil {
+		return nil
+	}
+	out := new(AKSClusterIdentity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AKSDefender) DeepCopyInto(out *AKSDefender) {
+	*out = *in
+	if in.LogAnalyticsWorkspaceID != nil {
+		in, out := &in.LogAnalyticsWorkspaceID, &out.LogAnalyticsWorkspaceID
+		*out = new(string)
+		**out = **in
+	}
+	if in.LogAnalyticsWorkspaceGroup != nil {
+		in, out := &in.LogAnalyticsWorkspaceGroup, &out.LogAnalyticsWorkspaceGroup
+		*out = new(string)
+		**out = **in
+	}
+	if in.LogAnalyticsWorkspaceName != nil {
+		in, out := &in.LogAnalyticsWorkspaceName, &out.LogAnalyticsWorkspaceName
+		*out = new(string)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AKSDefender.
+func (in *AKSDefender) DeepCopy() *AKSDefender {
+	if in == nil {
+		return nil
+	}
+	out := new(AKSDefender)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AKSHTTPProxyConfig) DeepCopyInto(out *AKSHTTPProxyConfig) {
+	*out = *in
+	if in.HTTPProxy != nil {
+		in, out := &in.HTTPProxy, &out.HTTPProxy
+		*out = new(string)
+		**out = **in
+	}
+	if in.HTTPSProxy != nil {
+		in, out := &in.HTTPSProxy, &out.HTTPSProxy
+		*out = new(string)
+		**out = **in
+	}
+	if in.NoProxy != nil {
+		in, out := &in.NoProxy, &out.NoProxy
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AKSHTTPProxyConfig.
+func (in *AKSHTTPProxyConfig) DeepCopy() *AKSHTTPProxyConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AKSHTTPProxyConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AKSIngressApplicationGateway) DeepCopyInto(out *AKSIngressApplicationGateway) {
+	*out = *in
+	if in.GatewayID != nil {
+		in, out := &in.GatewayID, &out.GatewayID
+		*out = new(string)
+		**out = **in
+	}
+	if in.SubnetCIDR != nil {
+		in, out := &in.SubnetCIDR, &out.SubnetCIDR
+		*out = new(string)
+		**out = **in
+	}
+	if in.GatewayName != nil {
+		in, out := &in.GatewayName, &out.GatewayName
+		*out = new(string)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AKSIngressApplicationGateway.
+func (in *AKSIngressApplicationGateway) DeepCopy() *AKSIngressApplicationGateway {
+	if in == nil {
+		return nil
+	}
+	out := new(AKSIngressApplicationGateway)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AKSKeyVaultSecretsProvider) DeepCopyInto(out *AKSKeyVaultSecretsProvider) {
+	*out = *in
+	if in.RotationEnabled != nil {
+		in, out := &in.RotationEnabled, &out.RotationEnabled
+		*out = new(bool)
+		**out = **in
+	}
+	if in.RotationPollInterval != nil {
+		in, out := &in.RotationPollInterval, &out.RotationPollInterval
+		*out = new(string)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AKSKeyVaultSecretsProvider.
+func (in *AKSKeyVaultSecretsProvider) DeepCopy() *AKSKeyVaultSecretsProvider {
+	if in == nil {
+		return nil
+	}
+	out := new(AKSKeyVaultSecretsProvider)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AKSKubeletConfig) DeepCopyInto(out *AKSKubeletConfig) {
+	*out = *in
+	if in.CPUManagerPolicy != nil {
+		in, out := &in.CPUManagerPolicy, &out.CPUManagerPolicy
+		*out = new(string)
+		**out = **in
+	}
+	if in.CPUCfsQuota != nil {
+		in, out := &in.CPUCfsQuota, &out.CPUCfsQuota
+		*out = new(bool)
+		**out = **in
+	}
+	if in.CPUCfsQuotaPeriod != nil {
+		in, out := &in.CPUCfsQuotaPeriod, &out.CPUCfsQuotaPeriod
+		*out = new(string)
+		**out = **in
+	}
+	if in.ImageGcHighThreshold != nil {
+		in, out := &in.ImageGcHighThreshold, &out.ImageGcHighThreshold
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ImageGcLowThreshold != nil {
+		in, out := &in.ImageGcLowThreshold, &out.ImageGcLowThreshold
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TopologyManagerPolicy != nil {
+		in, out := &in.TopologyManagerPolicy, &out.TopologyManagerPolicy
+		*out = new(string)
+		**out = **in
+	}
+	if in.AllowedUnsafeSysctls != nil {
+		in, out := &in.AllowedUnsafeSysctls, &out.AllowedUnsafeSysctls
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.FailSwapOn != nil {
+		in, out := &in.FailSwapOn, &out.FailSwapOn
+		*out = new(bool)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AKSKubeletConfig.
+func (in *AKSKubeletConfig) DeepCopy() *AKSKubeletConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AKSKubeletConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AKSKubeletIdentity) DeepCopyInto(out *AKSKubeletIdentity) {
+	*out = *in
+	if in.ResourceID != nil {
+		in, out := &in.ResourceID, &out.ResourceID
+		*out = new(string)
+		**out = **in
+	}
+	if in.ClientID != nil {
+		in, out := &in.ClientID, &out.ClientID
+		*out = new(string)
+		**out = **in
+	}
+	if in.ObjectID != nil {
+		in, out := &in.ObjectID, &out.ObjectID
+		*out = new(string)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AKSKubeletIdentity.
+func (in *AKSKubeletIdentity) DeepCopy() *AKSKubeletIdentity {
+	if in == nil {
+		return nil
+	}
+	out := new(AKSKubeletIdentity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AKSLinuxOSConfig) DeepCopyInto(out *AKSLinuxOSConfig) {
+	*out = *in
+	if in.Sysctls != nil {
+		in, out := &in.Sysctls, &out.Sysctls
+		*out = new(AKSSysctlConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TransparentHugePageEnabled != nil {
+		in, out := &in.TransparentHugePageEnabled, &out.TransparentHugePageEnabled
+		*out = new(string)
+		**out = **in
+	}
+	if in.TransparentHugePageDefrag != nil {
+		in, out := &in.TransparentHugePageDefrag, &out.TransparentHugePageDefrag
+		*out = new(string)
+		**out = **in
+	}
+	if in.SwapFileSizeMB != nil {
+		in, out := &in.SwapFileSizeMB, &out.SwapFileSizeMB
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AKSLinuxOSConfig.
+func (in *AKSLinuxOSConfig) DeepCopy() *AKSLinuxOSConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AKSLinuxOSConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AKSLoadBalancerProfile) DeepCopyInto(out *AKSLoadBalancerProfile) {
+	*out = *in
+	if in.ManagedOutboundIPCount != nil {
+		in, out := &in.ManagedOutboundIPCount, &out.ManagedOutboundIPCount
+		*out = new(int32)
+		**out = **in
+	}
+	if in.OutboundIPPrefixes != nil {
+		in, out := &in.OutboundIPPrefixes, &out.OutboundIPPrefixes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllocatedOutboundPorts != nil {
+		in, out := &in.AllocatedOutboundPorts, &out.AllocatedOutboundPorts
+		*out = new(int32)
+		**out = **in
+	}
+	if in.IdleTimeoutInMinutes != nil {
+		in, out := &in.IdleTimeoutInMinutes, &out.IdleTimeoutInMinutes
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AKSLoadBalancerProfile.
+func (in *AKSLoadBalancerProfile) DeepCopy() *AKSLoadBalancerProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(AKSLoadBalancerProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AKSNATGatewayProfile) DeepCopyInto(out *AKSNATGatewayProfile) {
+	*out = *in
+	if in.ManagedOutboundIPCount != nil {
+		in, out := &in.ManagedOutboundIPCount, &out.ManagedOutboundIPCount
+		*out = new(int32)
+		**out = **in
+	}
+	if in.IdleTimeoutInMinutes != nil {
+		in, out := &in.IdleTimeoutInMinutes, &out.IdleTimeoutInMinutes
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AKSNATGatewayProfile.
+func (in *AKSNATGatewayProfile) DeepCopy() *AKSNATGatewayProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(AKSNATGatewayProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AKSNodePool) DeepCopyInto(out *AKSNodePool) {
 	*out = *in
@@ -297,6 +891,88 @@ func (in *AKSNodePool) DeepCopyInto(out *AKSNodePool) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.FollowClusterVersion != nil {
+		in, out := &in.FollowClusterVersion, &out.FollowClusterVersion
+		*out = new(bool)
+		**out = **in
+	}
+	if in.UpgradePriority != nil {
+		in, out := &in.UpgradePriority, &out.UpgradePriority
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SpotMaxPrice != nil {
+		in, out := &in.SpotMaxPrice, &out.SpotMaxPrice
+		*out = new(float64)
+		**out = **in
+	}
+	if in.NodeTaints != nil {
+		in, out := &in.NodeTaints, &out.NodeTaints
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NodeLabels != nil {
+		in, out := &in.NodeLabels, &out.NodeLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.UpgradeSettings != nil {
+		in, out := &in.UpgradeSettings, &out.UpgradeSettings
+		*out = new(AKSUpgradeSettings)
+		**out = **in
+	}
+	if in.VnetSubnetID != nil {
+		in, out := &in.VnetSubnetID, &out.VnetSubnetID
+		*out = new(string)
+		**out = **in
+	}
+	if in.PodSubnetID != nil {
+		in, out := &in.PodSubnetID, &out.PodSubnetID
+		*out = new(string)
+		**out = **in
+	}
+	if in.KubeletConfig != nil {
+		in, out := &in.KubeletConfig, &out.KubeletConfig
+		*out = new(AKSKubeletConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LinuxOSConfig != nil {
+		in, out := &in.LinuxOSConfig, &out.LinuxOSConfig
+		*out = new(AKSLinuxOSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EnableFIPS != nil {
+		in, out := &in.EnableFIPS, &out.EnableFIPS
+		*out = new(bool)
+		**out = **in
+	}
+	if in.EnableEncryptionAtHost != nil {
+		in, out := &in.EnableEncryptionAtHost, &out.EnableEncryptionAtHost
+		*out = new(bool)
+		**out = **in
+	}
+	if in.EnableUltraSSD != nil {
+		in, out := &in.EnableUltraSSD, &out.EnableUltraSSD
+		*out = new(bool)
+		**out = **in
+	}
+	if in.SnapshotID != nil {
+		in, out := &in.SnapshotID, &out.SnapshotID
+		*out = new(string)
+		**out = **in
+	}
+	if in.ProximityPlacementGroupID != nil {
+		in, out := &in.ProximityPlacementGroupID, &out.ProximityPlacementGroupID
+		*out = new(string)
+		**out = **in
+	}
+	if in.HostGroupID != nil {
+		in, out := &in.HostGroupID, &out.HostGroupID
+		*out = new(string)
+		**out = **in
+	}
 	return
 }
 
@@ -309,3 +985,191 @@ func (in *AKSNodePool) DeepCopy() *AKSNodePool {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AKSNodePoolStatus) DeepCopyInto(out *AKSNodePoolStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AKSNodePoolStatus.
+func (in *AKSNodePoolStatus) DeepCopy() *AKSNodePoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AKSNodePoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AKSSysctlConfig) DeepCopyInto(out *AKSSysctlConfig) {
+	*out = *in
+	if in.NetCoreSomaxconn != nil {
+		in, out := &in.NetCoreSomaxconn, &out.NetCoreSomaxconn
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NetCoreNetdevMaxBacklog != nil {
+		in, out := &in.NetCoreNetdevMaxBacklog, &out.NetCoreNetdevMaxBacklog
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NetCoreRmemMax != nil {
+		in, out := &in.NetCoreRmemMax, &out.NetCoreRmemMax
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NetCoreWmemMax != nil {
+		in, out := &in.NetCoreWmemMax, &out.NetCoreWmemMax
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NetIpv4TCPRmem != nil {
+		in, out := &in.NetIpv4TCPRmem, &out.NetIpv4TCPRmem
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NetIpv4TCPWmem != nil {
+		in, out := &in.NetIpv4TCPWmem, &out.NetIpv4TCPWmem
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NetCoreOptmemMax != nil {
+		in, out := &in.NetCoreOptmemMax, &out.NetCoreOptmemMax
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NetIpv4TCPMaxSynBacklog != nil {
+		in, out := &in.NetIpv4TCPMaxSynBacklog, &out.NetIpv4TCPMaxSynBacklog
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NetIpv4TCPMaxTwBuckets != nil {
+		in, out := &in.NetIpv4TCPMaxTwBuckets, &out.NetIpv4TCPMaxTwBuckets
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NetIpv4TCPFinTimeout != nil {
+		in, out := &in.NetIpv4TCPFinTimeout, &out.NetIpv4TCPFinTimeout
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NetIpv4TCPKeepaliveTime != nil {
+		in, out := &in.NetIpv4TCPKeepaliveTime, &out.NetIpv4TCPKeepaliveTime
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NetIpv4TCPKeepaliveProbes != nil {
+		in, out := &in.NetIpv4TCPKeepaliveProbes, &out.NetIpv4TCPKeepaliveProbes
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NetIpv4TcpkeepaliveIntvl != nil {
+		in, out := &in.NetIpv4TcpkeepaliveIntvl, &out.NetIpv4TcpkeepaliveIntvl
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NetIpv4TCPTwReuse != nil {
+		in, out := &in.NetIpv4TCPTwReuse, &out.NetIpv4TCPTwReuse
+		*out = new(bool)
+		**out = **in
+	}
+	if in.NetIpv4IPLocalPortRange != nil {
+		in, out := &in.NetIpv4IPLocalPortRange, &out.NetIpv4IPLocalPortRange
+		*out = new(string)
+		**out = **in
+	}
+	if in.NetIpv4NeighDefaultGcThresh1 != nil {
+		in, out := &in.NetIpv4NeighDefaultGcThresh1, &out.NetIpv4NeighDefaultGcThresh1
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NetIpv4NeighDefaultGcThresh2 != nil {
+		in, out := &in.NetIpv4NeighDefaultGcThresh2, &out.NetIpv4NeighDefaultGcThresh2
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NetIpv4NeighDefaultGcThresh3 != nil {
+		in, out := &in.NetIpv4NeighDefaultGcThresh3, &out.NetIpv4NeighDefaultGcThresh3
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NetNetfilterNfConntrackMax != nil {
+		in, out := &in.NetNetfilterNfConntrackMax, &out.NetNetfilterNfConntrackMax
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NetNetfilterNfConntrackBuckets != nil {
+		in, out := &in.NetNetfilterNfConntrackBuckets, &out.NetNetfilterNfConntrackBuckets
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FsInotifyMaxUserWatches != nil {
+		in, out := &in.FsInotifyMaxUserWatches, &out.FsInotifyMaxUserWatches
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FsFileMax != nil {
+		in, out := &in.FsFileMax, &out.FsFileMax
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FsAioMaxNr != nil {
+		in, out := &in.FsAioMaxNr, &out.FsAioMaxNr
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FsNrOpen != nil {
+		in, out := &in.FsNrOpen, &out.FsNrOpen
+		*out = new(int32)
+		**out = **in
+	}
+	if in.KernelThreadsMax != nil {
+		in, out := &in.KernelThreadsMax, &out.KernelThreadsMax
+		*out = new(int32)
+		**out = **in
+	}
+	if in.VMMaxMapCount != nil {
+		in, out := &in.VMMaxMapCount, &out.VMMaxMapCount
+		*out = new(int32)
+		**out = **in
+	}
+	if in.VMSwappiness != nil {
+		in, out := &in.VMSwappiness, &out.VMSwappiness
+		*out = new(int32)
+		**out = **in
+	}
+	if in.VMVfsCachePressure != nil {
+		in, out := &in.VMVfsCachePressure, &out.VMVfsCachePressure
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AKSSysctlConfig.
+func (in *AKSSysctlConfig) DeepCopy() *AKSSysctlConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AKSSysctlConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AKSUpgradeSettings) DeepCopyInto(out *AKSUpgradeSettings) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AKSUpgradeSettings.
+func (in *AKSUpgradeSettings) DeepCopy() *AKSUpgradeSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(AKSUpgradeSettings)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/rancher/wrangler/pkg/crd"
 	"github.com/rancher/wrangler/pkg/yaml"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
@@ -45,7 +46,16 @@ func main() {
 
 	aksClusterConfig := newCRD(&aksv1.AKSClusterConfig{}, func(c crd.CRD) crd.CRD {
 		c.ShortNames = []string{"akscc"}
-		return c
+		return c.
+			WithColumn("Cluster", ".spec.clusterName").
+			WithColumn("Phase", ".status.phase").
+			WithColumn("Version", ".status.kubernetesVersion").
+			WithCustomColumn(v1beta1.CustomResourceColumnDefinition{
+				Name:     "Nodes",
+				Type:     "integer",
+				JSONPath: ".status.currentNodeCount",
+			}).
+			WithColumn("Endpoint", ".status.endpoint")
 	})
 
 	obj, err := aksClusterConfig.ToCustomResourceDefinition()
@@ -0,0 +1,115 @@
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"time"
+
+	v10 "github.com/rancher/aks-operator/pkg/generated/controllers/aks.cattle.io/v1"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ConfigState is the state of a single AKSClusterConfig as reported by the debug server
+type ConfigState struct {
+	Namespace         string `json:"namespace"`
+	Name              string `json:"name"`
+	ClusterName       string `json:"clusterName"`
+	Phase             string `json:"phase"`
+	ProvisioningState string `json:"provisioningState"`
+	FailureMessage    string `json:"failureMessage,omitempty"`
+	// ThrottledUntil is set while Azure throttles the requests for the config
+	ThrottledUntil *time.Time `json:"throttledUntil,omitempty"`
+	// ErrorBackoff is the wait before retrying a transient Azure failure, empty unless such a retry is pending
+	ErrorBackoff string `json:"errorBackoff,omitempty"`
+	// NextRequeue is when the controller reconciles the config again, unset if no requeue is scheduled
+	NextRequeue *time.Time `json:"nextRequeue,omitempty"`
+}
+
+// Requeues reports the requeues scheduled by the controller
+type Requeues interface {
+	// NextRequeue returns when the config is requeued next and the current error backoff, both are zero if no
+	// requeue is pending
+	NextRequeue(namespace, name string) (time.Time, time.Duration)
+}
+
+// NewServeMux returns a mux exposing the net/http/pprof handlers under /debug/pprof/ and the state of every
+// AKSClusterConfig known to the operator under /debug/state, including the requeues scheduled for it by requeues. Other
+// endpoints can be registered on the returned mux so that they share the same listener.
+func NewServeMux(aksCache v10.AKSClusterConfigCache, requeues Requeues) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/state", stateHandler(aksCache, requeues))
+	return mux
+}
+
+// ListenAndServe serves handler on address until ctx is done, it is used by the debug and metrics servers
+func ListenAndServe(ctx context.Context, address string, handler http.Handler) {
+	server := &http.Server{
+		Addr:    address,
+		Handler: handler,
+	}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	go func() {
+		logrus.Infof("Starting HTTP server on [%s]", address)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logrus.Errorf("HTTP server on [%s] stopped: %v", address, err)
+		}
+	}()
+}
+
+func stateHandler(aksCache v10.AKSClusterConfigCache, requeues Requeues) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		configs, err := aksCache.List("", labels.Everything())
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		state := make([]ConfigState, 0, len(configs))
+		for _, config := range configs {
+			configState := ConfigState{
+				Namespace:         config.Namespace,
+				Name:              config.Name,
+				ClusterName:       config.Spec.ClusterName,
+				Phase:             config.Status.Phase,
+				ProvisioningState: config.Status.ProvisioningState,
+				FailureMessage:    config.Status.FailureMessage,
+			}
+			if !config.Status.ThrottledUntil.IsZero() {
+				throttledUntil := config.Status.ThrottledUntil.Time
+				configState.ThrottledUntil = &throttledUntil
+			}
+			if next, backoff := requeues.NextRequeue(config.Namespace, config.Name); !next.IsZero() {
+				configState.NextRequeue = &next
+				if backoff > 0 {
+					configState.ErrorBackoff = backoff.Round(time.Second).String()
+				}
+			}
+			state = append(state, configState)
+		}
+		sort.Slice(state, func(i, j int) bool {
+			if state[i].Namespace != state[j].Namespace {
+				return state[i].Namespace < state[j].Namespace
+			}
+			return state[i].Name < state[j].Name
+		})
+
+		rw.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(rw).Encode(state); err != nil {
+			logrus.Errorf("Error writing debug state: %v", err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+	v10 "github.com/rancher/aks-operator/pkg/generated/controllers/aks.cattle.io/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// fakeCache is an AKSClusterConfigCache listing configs
+type fakeCache struct {
+	v10.AKSClusterConfigCache
+	configs []*aksv1.AKSClusterConfig
+}
+
+func (c *fakeCache) List(string, labels.Selector) ([]*aksv1.AKSClusterConfig, error) {
+	return c.configs, nil
+}
+
+// fakeRequeues reports the requeues of the configs by name
+type fakeRequeues map[string]struct {
+	next    time.Time
+	backoff time.Duration
+}
+
+func (r fakeRequeues) NextRequeue(namespace, name string) (time.Time, time.Duration) {
+	requeue := r[name]
+	return requeue.next, requeue.backoff
+}
+
+func TestStateHandler(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	config := func(name string) *aksv1.AKSClusterConfig {
+		return &aksv1.AKSClusterConfig{ObjectMeta: metav1.ObjectMeta{Namespace: "cattle-global-data", Name: name}}
+	}
+	throttled := config("throttled")
+	throttled.Status.ThrottledUntil = metav1.NewTime(now.Add(time.Minute))
+	failing := config("failing")
+	failing.Status.FailureMessage = "request failed"
+	cache := &fakeCache{configs: []*aksv1.AKSClusterConfig{throttled, failing, config("idle")}}
+	requeues := fakeRequeues{
+		"throttled": {next: now.Add(time.Minute)},
+		"failing":   {next: now.Add(2 * time.Minute), backoff: 2 * time.Minute},
+	}
+
+	rw := httptest.NewRecorder()
+	NewServeMux(cache, requeues).ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/debug/state", nil))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rw.Code, rw.Body.String())
+	}
+	var state []ConfigState
+	if err := json.Unmarshal(rw.Body.Bytes(), &state); err != nil {
+		t.Fatal(err)
+	}
+	if len(state) != 3 {
+		t.Fatalf("expected the state of 3 configs, got %+v", state)
+	}
+
+	// the state is sorted by name
+	if got := state[0]; got.Name != "failing" || got.ErrorBackoff != "2m0s" || got.NextRequeue == nil ||
+		!got.NextRequeue.Equal(now.Add(2*time.Minute)) || got.ThrottledUntil != nil {
+		t.Errorf("expected the failing config to be retried in 2m0s, got %+v", got)
+	}
+	if got := state[1]; got.Name != "idle" || got.ErrorBackoff != "" || got.NextRequeue != nil || got.ThrottledUntil != nil {
+		t.Errorf("expected no requeue of the idle config, got %+v", got)
+	}
+	if got := state[2]; got.Name != "throttled" || got.ErrorBackoff != "" || got.ThrottledUntil == nil ||
+		!got.ThrottledUntil.Equal(now.Add(time.Minute)) || got.NextRequeue == nil || !got.NextRequeue.Equal(now.Add(time.Minute)) {
+		t.Errorf("expected the throttled config to be requeued once it is no longer throttled, got %+v", got)
+	}
+}
@@ -0,0 +1,73 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
+// Run campaigns for the lease name in namespace until ctx is done and calls run once the lease is acquired, so that
+// of several replicas of the operator only the leader reconciles. A replica which loses the lease exits, its
+// controllers cannot be stopped cleanly and another replica takes over.
+func Run(ctx context.Context, namespace, name string, client kubernetes.Interface, run func(ctx context.Context)) error {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	// the hostname is the pod name in a cluster, the suffix keeps replicas outside of a cluster apart
+	identity := hostname + "_" + uuid.New().String()
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta: v1.ObjectMeta{
+				Namespace: namespace,
+				Name:      name,
+			},
+			Client: client.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{
+				Identity: identity,
+			},
+		},
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		ReleaseOnCancel: true,
+		Name:            name,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				logrus.Infof("Acquired leader lease [%s/%s] as [%s]", namespace, name, identity)
+				run(ctx)
+			},
+			OnStoppedLeading: func() {
+				if ctx.Err() == nil {
+					logrus.Fatalf("Lost leader lease [%s/%s]", namespace, name)
+				}
+			},
+			OnNewLeader: func(leader string) {
+				if leader != identity {
+					logrus.Infof("Waiting for leader lease [%s/%s], held by [%s]", namespace, name, leader)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error creating leader election for [%s/%s]: %w", namespace, name, err)
+	}
+
+	elector.Run(ctx)
+	return nil
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	v10 "github.com/rancher/aks-operator/pkg/generated/controllers/aks.cattle.io/v1"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// ResultSuccess and ResultError are the results of a reconcile
+	ResultSuccess = "success"
+	ResultError   = "error"
+	// pendingPhase is reported for configs which have no phase yet
+	pendingPhase = "pending"
+)
+
+var (
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "aks_operator_reconcile_duration_seconds",
+		Help:    "Duration of AKSClusterConfig reconciles by the phase the config was in and their result",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 12),
+	}, []string{"phase", "result"})
+
+	azureRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "aks_operator_azure_requests_total",
+		Help: "Azure API requests by operation and HTTP status code, requests which got no response have the code \"error\"",
+	}, []string{"operation", "code"})
+
+	clustersDesc = prometheus.NewDesc("aks_operator_clusters",
+		"AKSClusterConfigs by phase, configs without a phase are reported as \"pending\"",
+		[]string{"phase"}, nil)
+)
+
+// ObserveReconcile records the duration of a reconcile of a config in phase
+func ObserveReconcile(phase, result string, duration time.Duration) {
+	reconcileDuration.WithLabelValues(phaseLabel(phase), result).Observe(duration.Seconds())
+}
+
+// IncAzureRequest counts an Azure API request for operation, code is the HTTP status code of the response
+func IncAzureRequest(operation, code string) {
+	azureRequests.WithLabelValues(operation, code).Inc()
+}
+
+// Handler returns a handler serving the operator metrics, along with the Go runtime and process metrics, in the
+// Prometheus text format. The number of configs per phase is read from aksCache on each scrape.
+func Handler(aksCache v10.AKSClusterConfigCache) http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		reconcileDuration,
+		azureRequests,
+		&clusterCollector{aksCache: aksCache},
+	)
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// clusterCollector counts the configs in each phase
+type clusterCollector struct {
+	aksCache v10.AKSClusterConfigCache
+}
+
+func (c *clusterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- clustersDesc
+}
+
+func (c *clusterCollector) Collect(ch chan<- prometheus.Metric) {
+	configs, err := c.aksCache.List("", labels.Everything())
+	if err != nil {
+		logrus.Errorf("Error listing AKSClusterConfigs for metrics: %v", err)
+		return
+	}
+
+	phases := map[string]int{}
+	for _, config := range configs {
+		phases[phaseLabel(config.Status.Phase)]++
+	}
+	for phase, count := range phases {
+		ch <- prometheus.MustNewConstMetric(clustersDesc, prometheus.GaugeValue, float64(count), phase)
+	}
+}
+
+func phaseLabel(phase string) string {
+	if phase == "" {
+		return pendingPhase
+	}
+	return phase
+}
@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/to"
+
+	aksv1 "github.com/rancher/aks-operator/pkg/apis/aks.cattle.io/v1"
+)
+
+func TestBuildNodePoolMap(t *testing.T) {
+	nodePools := []aksv1.AKSNodePool{
+		{Name: to.StringPtr("workerspool1"), VMSize: "Standard_DS2_v2"},
+		{Name: to.StringPtr("workerspool2"), VMSize: "Standard_D4s_v3"},
+	}
+	nodePoolMap, err := BuildNodePoolMap(nodePools, "cluster")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nodePoolMap) != 2 {
+		t.Fatalf("expected both node pools to be kept, got %v", nodePoolMap)
+	}
+	for i := range nodePools {
+		if np := nodePoolMap[*nodePools[i].Name]; np != &nodePools[i] {
+			t.Errorf("expected node pool %s to map to its own spec, got %+v", *nodePools[i].Name, np)
+		}
+	}
+
+	nodePools = append(nodePools, aksv1.AKSNodePool{Name: to.StringPtr("workerspool1")})
+	if _, err := BuildNodePoolMap(nodePools, "cluster"); err == nil {
+		t.Error("expected an error for duplicate node pool names")
+	}
+}
@@ -4,8 +4,13 @@ import (
 	"strings"
 )
 
+// ParseSecretName splits a "namespace:name" or "namespace/name" secret reference, the namespace is empty if the
+// reference does not name one
 func ParseSecretName(ref string) (namespace string, name string) {
 	parts := strings.SplitN(ref, ":", 2)
+	if len(parts) == 1 {
+		parts = strings.SplitN(ref, "/", 2)
+	}
 	if len(parts) == 1 {
 		return "", parts[0]
 	}
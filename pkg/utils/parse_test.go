@@ -0,0 +1,24 @@
+package utils
+
+import "testing"
+
+func TestParseSecretName(t *testing.T) {
+	tests := []struct {
+		ref           string
+		wantNamespace string
+		wantName      string
+	}{
+		{ref: "cattle-global-data:cc-test", wantNamespace: "cattle-global-data", wantName: "cc-test"},
+		{ref: "cattle-global-data/cc-test", wantNamespace: "cattle-global-data", wantName: "cc-test"},
+		{ref: "cc-test", wantName: "cc-test"},
+		{ref: "a:b/c", wantNamespace: "a", wantName: "b/c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			namespace, name := ParseSecretName(tt.ref)
+			if namespace != tt.wantNamespace || name != tt.wantName {
+				t.Errorf("expected namespace %q and name %q, got %q and %q", tt.wantNamespace, tt.wantName, namespace, name)
+			}
+		})
+	}
+}